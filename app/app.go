@@ -5,7 +5,12 @@ import (
 	"banking_ledger/config"
 	"banking_ledger/database"
 	"banking_ledger/middleware"
+	"banking_ledger/notifier"
+	"banking_ledger/ratelimit"
 	"banking_ledger/services"
+	"banking_ledger/tracing"
+	"banking_ledger/utils"
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -28,15 +33,44 @@ func init() {
 
 func StartApp() {
 
+	shutdownTracing, err := tracing.InitTracing(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
 	defer func() {
 		database.CloseDatabasePool()
 		database.DisconnectMongoDB()
+		shutdownTracing(context.Background())
 	}()
 
+	if err := utils.InitJWTKeys(); err != nil {
+		panic(err)
+	}
+
+	if err := ratelimit.InitStore(); err != nil {
+		panic(err)
+	}
+
+	if err := utils.InitUserTokenKey(); err != nil {
+		panic(err)
+	}
+
+	if err := notifier.InitSender(); err != nil {
+		panic(err)
+	}
+
+	// ClientIP only reads X-Forwarded-For from these proxies, the failed login count
+	// of an IP must not be spoofable
+	if err := Router.SetTrustedProxies(utils.SplitCommaSeparated(config.TRUSTED_PROXIES)); err != nil {
+		panic(err)
+	}
+
 	SetupHealthRoute()
 	SetupRoutesMiddleware()
 	SetupUserRoute()
 	SetupCognitoProtectedRoutes()
+	SetupAdminRoutes()
 
 	if err := database.InitializeDatabasePool(); err != nil {
 		panic(err)
@@ -59,6 +93,32 @@ func StartApp() {
 
 	go clients.KafkaConsumer(config.TRANSACTION_PROCESSING_KAFKA_CG, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, services.KafkaConsumerProcessTransactions)
 
+	go services.StartDailyBalanceJob()
+
+	go services.StartReconciliationJob()
+
+	go services.StartRecurringScheduler()
+
+	go services.StartInterestJob()
+
+	go services.StartFeeJob()
+
+	go services.StartFeeIncomeRollupJob()
+
+	go services.StartDormancyJob()
+
+	go services.StartWebhookDispatcher()
+
+	go services.StartUserEventListener()
+
+	go services.StartUserEventRetentionJob()
+
+	go services.StartTransactionBatchDispatcher()
+
+	go services.StartAuthTokenCleanupJob()
+
+	go services.StartRateLimitCleanupJob()
+
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-interrupt
@@ -1,51 +1,153 @@
 package app
 
 import (
+	"banking_ledger/config"
 	"banking_ledger/handlers"
 	"banking_ledger/middleware"
+	"banking_ledger/models"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 var (
 	SERVICE_BASE_PATH      string
 	cognitoProtectedRoutes *gin.RouterGroup
 	userRoutes             *gin.RouterGroup
+	adminRoutes            *gin.RouterGroup
 )
 
 func init() {
 	SERVICE_BASE_PATH = os.Getenv("SERVICE_BASE_PATH")
 	cognitoProtectedRoutes = Router.Group(SERVICE_BASE_PATH)
 	userRoutes = Router.Group(SERVICE_BASE_PATH)
+	adminRoutes = Router.Group(SERVICE_BASE_PATH + "/v1/admin")
 }
 
 func SetupRoutesMiddleware() {
 
+	cognitoProtectedRoutes.Use(otelgin.Middleware(os.Getenv("SERVICE_NAME")))
+	cognitoProtectedRoutes.Use(middleware.CorrelationId())
 	cognitoProtectedRoutes.Use(middleware.CorsMiddleware())
 	cognitoProtectedRoutes.Use(middleware.LogRequest())
 	cognitoProtectedRoutes.Use(middleware.AuthTokenMiddleware())
+	cognitoProtectedRoutes.Use(middleware.RateLimit(userRateLimit()))
 
+	userRoutes.Use(otelgin.Middleware(os.Getenv("SERVICE_NAME")))
+	userRoutes.Use(middleware.CorrelationId())
 	userRoutes.Use(middleware.CorsMiddleware())
 	userRoutes.Use(middleware.LogRequest())
-	userRoutes.Use(middleware.AuthorizeApiKey(middleware.API_KEY))
+	userRoutes.Use(middleware.AuthorizeApiKey())
+	userRoutes.Use(middleware.RateLimit(models.RateLimitPolicy{Name: "api_key", Key: models.RATE_LIMIT_KEY_API_KEY, Capacity: config.RATE_LIMIT_API_KEY_BURST, PerMinute: config.RATE_LIMIT_API_KEY_PER_MINUTE}))
+
+	adminRoutes.Use(otelgin.Middleware(os.Getenv("SERVICE_NAME")))
+	adminRoutes.Use(middleware.CorrelationId())
+	adminRoutes.Use(middleware.CorsMiddleware())
+	adminRoutes.Use(middleware.LogRequest())
+	adminRoutes.Use(middleware.AuthTokenMiddleware())
+	adminRoutes.Use(middleware.RateLimit(userRateLimit()))
+}
+
+// userRateLimit is the limit of every route with a JWT, customer and admin routes
+// share the bucket of a user.
+func userRateLimit() models.RateLimitPolicy {
+	return models.RateLimitPolicy{Name: "user", Key: models.RATE_LIMIT_KEY_USER, Capacity: config.RATE_LIMIT_USER_BURST, PerMinute: config.RATE_LIMIT_USER_PER_MINUTE}
 }
 
 func SetupHealthRoute() {
 
 	Router.GET(SERVICE_BASE_PATH+"/v1/health", handlers.GetHealth)
+	Router.GET(SERVICE_BASE_PATH+"/v1/health/live", handlers.GetHealth)
+	Router.GET(SERVICE_BASE_PATH+"/v1/health/ready", handlers.GetReadiness)
+	Router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	Router.GET("/.well-known/jwks.json", handlers.GetJWKS)
 	Router.NoRoute(handlers.NoRoute)
 }
 
 func SetupUserRoute() {
-	userRoutes.POST("/user/v1/register", handlers.RegisterUser)
-	userRoutes.POST("/user/v1/login", handlers.UserLogin)
+
+	// Login and its second step are limited per client IP on top of the API key limit
+	loginRateLimit := middleware.RateLimit(models.RateLimitPolicy{Name: "login", Key: models.RATE_LIMIT_KEY_IP, Capacity: config.RATE_LIMIT_LOGIN_BURST, PerMinute: config.RATE_LIMIT_LOGIN_PER_MINUTE})
+
+	userRoutes.POST("/user/v1/register", middleware.RequireApiScope(models.API_SCOPE_USERS_REGISTER), handlers.RegisterUser)
+	userRoutes.POST("/user/v1/login", middleware.RequireApiScope(models.API_SCOPE_USERS_LOGIN), loginRateLimit, handlers.UserLogin)
+	userRoutes.POST("/user/v1/token/refresh", middleware.RequireApiScope(models.API_SCOPE_USERS_LOGIN), handlers.RefreshSession)
+	userRoutes.POST("/user/v1/login/2fa", middleware.RequireApiScope(models.API_SCOPE_USERS_LOGIN), loginRateLimit, handlers.CompleteLoginTwoFactor)
+	userRoutes.POST("/user/v1/email/verify", middleware.RequireApiScope(models.API_SCOPE_USERS_REGISTER), handlers.VerifyEmail)
+	userRoutes.POST("/user/v1/password/forgot", middleware.RequireApiScope(models.API_SCOPE_USERS_LOGIN), loginRateLimit, handlers.ForgotPassword)
+	userRoutes.POST("/user/v1/password/reset", middleware.RequireApiScope(models.API_SCOPE_USERS_LOGIN), loginRateLimit, handlers.ResetPassword)
 }
 
 func SetupCognitoProtectedRoutes() {
 
-	cognitoProtectedRoutes.POST("/v1/account", handlers.CreateAccount)
-	cognitoProtectedRoutes.PATCH("/v1/account/transaction", handlers.FundTransaction)
-	cognitoProtectedRoutes.POST("/v1/account/ledger", handlers.GetTransactionHistory)
+	transactionRateLimit := middleware.RateLimit(models.RateLimitPolicy{Name: "transactions", Key: models.RATE_LIMIT_KEY_USER, Capacity: config.RATE_LIMIT_TRANSACTIONS_BURST, PerMinute: config.RATE_LIMIT_TRANSACTIONS_PER_MINUTE})
+
+	// Users with an unverified email can read, but not move money or send data out
+	verifiedEmail := middleware.RequireVerifiedEmail()
+
+	cognitoProtectedRoutes.POST("/v1/account", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), handlers.CreateAccount)
+	cognitoProtectedRoutes.PATCH("/v1/account/transaction", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), verifiedEmail, transactionRateLimit, handlers.FundTransaction)
+	cognitoProtectedRoutes.POST("/v1/account/ledger", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ, models.PERMISSION_LEDGER_READ_ALL), handlers.GetTransactionHistory)
+	cognitoProtectedRoutes.GET("/v1/account/balance", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetBalanceAsOf)
+	cognitoProtectedRoutes.GET("/v1/account/balance/history", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetBalanceHistory)
+	cognitoProtectedRoutes.POST("/v1/account/schedules", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), verifiedEmail, handlers.CreateRecurringSchedule)
+	cognitoProtectedRoutes.GET("/v1/account/schedules", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetRecurringSchedules)
+	cognitoProtectedRoutes.DELETE("/v1/account/schedules/:scheduleId", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), handlers.CancelRecurringSchedule)
+	cognitoProtectedRoutes.GET("/v1/account/schedules/:scheduleId/runs", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetRecurringScheduleRuns)
+	cognitoProtectedRoutes.GET("/v1/account/fees", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetFeeCharges)
+	cognitoProtectedRoutes.GET("/v1/account/limits", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetTransactionAllowance)
+	cognitoProtectedRoutes.GET("/v1/account/events", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.StreamUserEvents)
+	cognitoProtectedRoutes.POST("/v1/webhooks", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), verifiedEmail, handlers.CreateWebhookSubscription)
+	cognitoProtectedRoutes.GET("/v1/webhooks", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetWebhookSubscriptions)
+	cognitoProtectedRoutes.DELETE("/v1/webhooks/:subscriptionId", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), handlers.DeleteWebhookSubscription)
+	cognitoProtectedRoutes.GET("/v1/webhooks/:subscriptionId/deliveries", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetWebhookDeliveries)
+	cognitoProtectedRoutes.GET("/v1/webhooks/deliveries/:deliveryId/attempts", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_READ), handlers.GetWebhookDeliveryAttempts)
+	cognitoProtectedRoutes.POST("/v1/webhooks/deliveries/:deliveryId/redeliver", middleware.RequirePermission(models.PERMISSION_OWN_ACCOUNT_WRITE), verifiedEmail, handlers.RedeliverWebhook)
+	cognitoProtectedRoutes.POST("/v1/logout", middleware.RequirePermission(models.PERMISSION_OWN_SECURITY_WRITE), handlers.Logout)
+	cognitoProtectedRoutes.POST("/v1/email/verification", middleware.RequirePermission(models.PERMISSION_OWN_SECURITY_WRITE), handlers.ResendEmailVerification)
+	cognitoProtectedRoutes.POST("/v1/2fa/totp/enroll", middleware.RequirePermission(models.PERMISSION_OWN_SECURITY_WRITE), handlers.EnrollTotp)
+	cognitoProtectedRoutes.POST("/v1/2fa/totp/confirm", middleware.RequirePermission(models.PERMISSION_OWN_SECURITY_WRITE), handlers.ConfirmTotp)
+	cognitoProtectedRoutes.POST("/v1/2fa/totp/disable", middleware.RequirePermission(models.PERMISSION_OWN_SECURITY_WRITE), handlers.DisableTotp)
+	cognitoProtectedRoutes.POST("/v1/2fa/recovery-codes", middleware.RequirePermission(models.PERMISSION_OWN_SECURITY_WRITE), handlers.RegenerateRecoveryCodes)
+
+}
+
+func SetupAdminRoutes() {
+
+	adminRoutes.POST("/balance/backfill", middleware.RequirePermission(models.PERMISSION_BALANCE_BACKFILL), handlers.BackfillDailyBalances)
+	adminRoutes.POST("/reconciliation/runs", middleware.RequirePermission(models.PERMISSION_RECONCILIATION_RUN), handlers.StartReconciliation)
+	adminRoutes.GET("/reconciliation/runs", middleware.RequirePermission(models.PERMISSION_RECONCILIATION_READ), handlers.GetReconciliationRuns)
+	adminRoutes.GET("/reconciliation/runs/:runId", middleware.RequirePermission(models.PERMISSION_RECONCILIATION_READ), handlers.GetReconciliationReport)
+	adminRoutes.POST("/interest/products", middleware.RequirePermission(models.PERMISSION_PRODUCTS_WRITE), handlers.CreateInterestProduct)
+	adminRoutes.GET("/interest/products", middleware.RequirePermission(models.PERMISSION_PRODUCTS_READ), handlers.GetInterestProducts)
+	adminRoutes.POST("/fees/schedules", middleware.RequirePermission(models.PERMISSION_PRODUCTS_WRITE), handlers.CreateFeeSchedule)
+	adminRoutes.GET("/fees/schedules", middleware.RequirePermission(models.PERMISSION_PRODUCTS_READ), handlers.GetFeeSchedules)
+	adminRoutes.POST("/fees/waivers", middleware.RequirePermission(models.PERMISSION_FEE_WAIVERS_WRITE), handlers.CreateFeeWaiver)
+	adminRoutes.GET("/fees/waivers", middleware.RequirePermission(models.PERMISSION_PRODUCTS_READ), handlers.GetFeeWaivers)
+	adminRoutes.DELETE("/fees/waivers/:waiverId", middleware.RequirePermission(models.PERMISSION_FEE_WAIVERS_WRITE), handlers.RevokeFeeWaiver)
+	adminRoutes.PUT("/limits", middleware.RequirePermission(models.PERMISSION_PRODUCTS_WRITE), handlers.SetTransactionLimit)
+	adminRoutes.GET("/limits", middleware.RequirePermission(models.PERMISSION_PRODUCTS_READ), handlers.GetTransactionLimits)
+	adminRoutes.DELETE("/limits/:limitId", middleware.RequirePermission(models.PERMISSION_PRODUCTS_WRITE), handlers.DeleteTransactionLimit)
+	adminRoutes.POST("/accounts/:accountId/status", middleware.RequirePermission(models.PERMISSION_ACCOUNT_STATUS_WRITE), handlers.ChangeAccountStatus)
+	adminRoutes.GET("/accounts/:accountId/status-history", middleware.RequirePermission(models.PERMISSION_ACCOUNT_STATUS_READ), handlers.GetAccountStatusHistory)
+	adminRoutes.POST("/transactions/batches", middleware.RequirePermission(models.PERMISSION_BATCHES_WRITE), handlers.CreateTransactionBatch)
+	adminRoutes.GET("/transactions/batches", middleware.RequirePermission(models.PERMISSION_BATCHES_READ), handlers.GetTransactionBatches)
+	adminRoutes.GET("/transactions/batches/:batchId", middleware.RequirePermission(models.PERMISSION_BATCHES_READ), handlers.GetTransactionBatch)
+	adminRoutes.GET("/transactions/batches/:batchId/report", middleware.RequirePermission(models.PERMISSION_BATCHES_READ), handlers.GetTransactionBatchReport)
+	adminRoutes.POST("/transactions/batches/:batchId/cancel", middleware.RequirePermission(models.PERMISSION_BATCHES_WRITE), handlers.CancelTransactionBatch)
+	adminRoutes.GET("/roles", middleware.RequirePermission(models.PERMISSION_USERS_READ), handlers.GetRoles)
+	adminRoutes.PUT("/users/:userId/role", middleware.RequirePermission(models.PERMISSION_ROLES_ASSIGN), handlers.AssignUserRole)
+	adminRoutes.GET("/users/:userId/role-history", middleware.RequirePermission(models.PERMISSION_USERS_READ), handlers.GetUserRoleChanges)
+	adminRoutes.POST("/users/:userId/sessions/revoke", middleware.RequirePermission(models.PERMISSION_SESSIONS_REVOKE), handlers.RevokeUserSessions)
+	adminRoutes.POST("/users/:userId/unlock", middleware.RequirePermission(models.PERMISSION_USERS_UNLOCK), handlers.UnlockUserLogin)
+	adminRoutes.GET("/users/:userId/security-events", middleware.RequirePermission(models.PERMISSION_USERS_READ), handlers.GetUserSecurityEvents)
+	adminRoutes.POST("/api-clients", middleware.RequirePermission(models.PERMISSION_API_CLIENTS_WRITE), handlers.CreateApiClient)
+	adminRoutes.GET("/api-clients", middleware.RequirePermission(models.PERMISSION_API_CLIENTS_READ), handlers.GetApiClients)
+	adminRoutes.PUT("/api-clients/:clientId/scopes", middleware.RequirePermission(models.PERMISSION_API_CLIENTS_WRITE), handlers.UpdateApiClientScopes)
+	adminRoutes.POST("/api-clients/:clientId/keys", middleware.RequirePermission(models.PERMISSION_API_CLIENTS_WRITE), handlers.CreateApiKey)
+	adminRoutes.GET("/api-clients/:clientId/keys", middleware.RequirePermission(models.PERMISSION_API_CLIENTS_READ), handlers.GetApiKeys)
+	adminRoutes.DELETE("/api-clients/:clientId/keys/:keyId", middleware.RequirePermission(models.PERMISSION_API_CLIENTS_WRITE), handlers.RevokeApiKey)
 
 }
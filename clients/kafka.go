@@ -3,23 +3,32 @@ package clients
 import (
 	"banking_ledger/config"
 	"banking_ledger/logger"
+	"banking_ledger/metrics"
 	"banking_ledger/misc"
 	"banking_ledger/models"
+	"banking_ledger/tracing"
 	"banking_ledger/utils"
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type callbackFunctionWithMsg func(*kafka.Message) error
 
 type ToKafkaMessage struct {
-	Topic string
-	Key   string
-	Value []byte
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers []kafka.Header
+	Span    trace.Span // Producer span, ended once the broker acknowledges the message
 }
 
 var (
@@ -44,7 +53,7 @@ func KafkaConsumer(kafkaConsumerGroup string, kafkaTopicName string, callbackFun
 
 	if err != nil {
 		errorMsg := fmt.Sprintf("Kafka consumer connection error.Topic:%s,Error:%s!\n", kafkaTopicName, err.Error())
-		logger.Log.Error(errorMsg)
+		logger.WithContext(ctx).Error(errorMsg)
 		misc.ProcessError(ctx, models.KAFKA_CONSUMER_ERROR, errorMsg, nil)
 		panic(err)
 	}
@@ -53,28 +62,38 @@ func KafkaConsumer(kafkaConsumerGroup string, kafkaTopicName string, callbackFun
 
 	if err != nil {
 		errorMsg := fmt.Sprintf("Kafka consumer subscribe error.Topic:%s,Error:%s!\n", kafkaTopicName, err.Error())
-		logger.Log.Error(errorMsg)
+		logger.WithContext(ctx).Error(errorMsg)
 		misc.ProcessError(ctx, models.KAFKA_CONSUMER_ERROR, errorMsg, nil)
 		panic(err)
 	}
 
+	defer consumerStopped(kafkaTopicName)
+
 	for {
-		msg, err := c.ReadMessage(-1)
+		consumerHeartbeat(kafkaTopicName)
+
+		msg, err := c.ReadMessage(consumerPollTimeout)
 		if err == nil {
+			observeConsumedMessage(c, msg)
+
+			processingStart := time.Now()
 			err = callbackFunction(msg)
+			metrics.ObserveConsumerProcessing(kafkaTopicName, time.Since(processingStart).Seconds())
 			if err == nil {
 				c.CommitMessage(msg)
 			} else {
 				errorMsg := fmt.Sprintf("Kafka consumer commit error.Error:%s,Message:%s!\n", err.Error(), string(msg.Value))
-				logger.Log.Error(errorMsg)
+				logger.WithContext(ctx).Error(errorMsg)
 				misc.ProcessError(ctx, models.KAFKA_CONSUMER_ERROR, errorMsg, msg.Value)
 				c.Close()
 				return
 			}
+		} else if kafkaError, ok := err.(kafka.Error); ok && kafkaError.Code() == kafka.ErrTimedOut {
+			continue
 		} else {
 			//Here I am making the service panic and restart whenever consumer read error occurs
 			errorMsg := fmt.Sprintf("Kafka consumer read error.Error:%s!\n", err.Error())
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 			misc.ProcessError(ctx, models.KAFKA_CONSUMER_ERROR, errorMsg, nil)
 			panic(err)
 		}
@@ -97,21 +116,25 @@ func KafkaProducer(producerChannel <-chan ToKafkaMessage) {
 
 	if err != nil {
 		errorMsg := fmt.Sprintf("Kafka producer connection error.Error:%s!\n", err.Error())
-		logger.Log.Error(errorMsg)
+		logger.WithContext(ctx).Error(errorMsg)
 		misc.ProcessError(ctx, models.KAFKA_PRODUCER_ERROR, errorMsg, nil)
 		panic(err)
 	}
 
+	setKafkaProducer(p)
+
 	deliveryChan := make(chan kafka.Event)
 
 	for {
 
 		message := <-producerChannel
+		ctx := utils.CreateContextWithRequestId(GetKafkaHeader(message.Headers, models.KAFKA_HEADER_CORRELATION_ID))
 
 		err := p.Produce(&kafka.Message{
 			TopicPartition: kafka.TopicPartition{Topic: &(message.Topic), Partition: kafka.PartitionAny},
 			Key:            []byte(message.Key),
 			Value:          []byte(message.Value),
+			Headers:        message.Headers,
 		}, deliveryChan)
 
 		kafkaEvent := <-deliveryChan
@@ -119,36 +142,58 @@ func KafkaProducer(producerChannel <-chan ToKafkaMessage) {
 
 		if kafkaMessage.TopicPartition.Partition == -1 {
 			errorMsg := fmt.Sprintf("Kafka producer or topic partition error.Topic:%s,Message:%s!\n", *kafkaMessage.TopicPartition.Topic, string(kafkaMessage.Value))
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 			misc.ProcessError(ctx, models.KAFKA_PRODUCER_ERROR, errorMsg, kafkaMessage.Value)
+			metrics.IncProducerDeliveryFailure(message.Topic)
+			message.Span.SetStatus(codes.Error, errorMsg)
 		}
 
 		if err != nil {
 			errorMsg := fmt.Sprintf("Kafka producer produce error.Error:%s!\n", err.Error())
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 			misc.ProcessError(ctx, models.KAFKA_PRODUCER_ERROR, errorMsg, nil)
+			metrics.IncProducerDeliveryFailure(message.Topic)
+			message.Span.SetStatus(codes.Error, errorMsg)
 		}
 
+		message.Span.SetAttributes(semconv.MessagingDestinationPartitionID(strconv.Itoa(int(kafkaMessage.TopicPartition.Partition))), semconv.MessagingKafkaMessageOffset(int(kafkaMessage.TopicPartition.Offset)))
+		message.Span.End()
+
 	}
 
 }
 
-func SendMessageToKafkaTopic(ctx context.Context, topic string, kafkaMessage interface{}, kafkaMessageKey string) *models.ApplicationError {
+func SendMessageToKafkaTopic(ctx context.Context, topic string, kafkaMessage interface{}, kafkaMessageKey string, headers ...kafka.Header) *models.ApplicationError {
 
 	txByteArray, err := json.Marshal(kafkaMessage)
 	if err != nil {
 
 		errMsg := fmt.Sprintf("SendMessageToKafkaTopic: Kafka topic:%s,Cannot convert struct to byte array,Error:%s", topic, err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 1001, errMsg, "", kafkaMessage)
 		return appError
 
 	} else {
 
+		ctx, span := tracing.Tracer.Start(ctx, topic+" publish", trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingKafkaMessageKey(kafkaMessageKey),
+		))
+
+		headers = tracing.InjectKafkaHeaders(ctx, headers)
+
+		if requestId := utils.GetRequestIdFromContext(ctx); requestId != "" && GetKafkaHeader(headers, models.KAFKA_HEADER_CORRELATION_ID) == "" {
+			headers = append(headers, kafka.Header{Key: models.KAFKA_HEADER_CORRELATION_ID, Value: []byte(requestId)})
+		}
+
 		kafkaMsg := ToKafkaMessage{
-			Topic: topic,
-			Key:   kafkaMessageKey,
-			Value: txByteArray,
+			Topic:   topic,
+			Key:     kafkaMessageKey,
+			Value:   txByteArray,
+			Headers: headers,
+			Span:    span,
 		}
 
 		ToKafkaChToTransactionProcessor <- kafkaMsg
@@ -156,3 +201,33 @@ func SendMessageToKafkaTopic(ctx context.Context, topic string, kafkaMessage int
 
 	return nil
 }
+
+// observeConsumedMessage records how long a message waited in the topic and how far
+// the partition is behind. The watermark comes from the statistics librdkafka already
+// keeps, it does not query the broker.
+func observeConsumedMessage(c *kafka.Consumer, msg *kafka.Message) {
+
+	topic := *msg.TopicPartition.Topic
+
+	if !msg.Timestamp.IsZero() {
+		metrics.ObserveConsumerMessageDelay(topic, time.Since(msg.Timestamp).Seconds())
+	}
+
+	_, high, err := c.GetWatermarkOffsets(topic, msg.TopicPartition.Partition)
+	if err == nil && high >= 0 {
+		metrics.SetConsumerLag(topic, msg.TopicPartition.Partition, max(high-int64(msg.TopicPartition.Offset)-1, 0))
+	}
+}
+
+// GetKafkaHeader returns the value of the first header with the given key, or an
+// empty string when the message does not have it.
+func GetKafkaHeader(headers []kafka.Header, key string) string {
+
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+
+	return ""
+}
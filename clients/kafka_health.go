@@ -0,0 +1,99 @@
+package clients
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// consumerPollTimeout bounds how long a consumer waits for a message, so its loop
+// beats even when the topic is idle.
+const consumerPollTimeout = time.Second
+
+type consumerState struct {
+	lastHeartbeat time.Time
+	running       bool
+}
+
+var (
+	kafkaProducer     *kafka.Producer
+	kafkaProducerLock sync.RWMutex
+
+	consumerStates     = map[string]*consumerState{}
+	consumerStatesLock sync.RWMutex
+)
+
+func setKafkaProducer(p *kafka.Producer) {
+
+	kafkaProducerLock.Lock()
+	defer kafkaProducerLock.Unlock()
+
+	kafkaProducer = p
+}
+
+// CheckKafkaBrokers asks the brokers for the cluster metadata through the producer
+// connection.
+func CheckKafkaBrokers(timeout time.Duration) error {
+
+	kafkaProducerLock.RLock()
+	p := kafkaProducer
+	kafkaProducerLock.RUnlock()
+
+	if p == nil {
+		return fmt.Errorf("kafka producer is not started")
+	}
+
+	metadata, err := p.GetMetadata(nil, false, int(timeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+
+	if len(metadata.Brokers) == 0 {
+		return fmt.Errorf("no kafka broker available")
+	}
+
+	return nil
+}
+
+func consumerHeartbeat(kafkaTopicName string) {
+
+	consumerStatesLock.Lock()
+	defer consumerStatesLock.Unlock()
+
+	consumerStates[kafkaTopicName] = &consumerState{lastHeartbeat: time.Now(), running: true}
+}
+
+func consumerStopped(kafkaTopicName string) {
+
+	consumerStatesLock.Lock()
+	defer consumerStatesLock.Unlock()
+
+	if state, ok := consumerStates[kafkaTopicName]; ok {
+		state.running = false
+	}
+}
+
+// CheckKafkaConsumer fails when the consumer loop of the topic has exited, never
+// started or has not polled within maxSilence.
+func CheckKafkaConsumer(kafkaTopicName string, maxSilence time.Duration) error {
+
+	consumerStatesLock.RLock()
+	defer consumerStatesLock.RUnlock()
+
+	state, ok := consumerStates[kafkaTopicName]
+	if !ok {
+		return fmt.Errorf("kafka consumer of topic %s is not started", kafkaTopicName)
+	}
+
+	if !state.running {
+		return fmt.Errorf("kafka consumer of topic %s has stopped", kafkaTopicName)
+	}
+
+	if silence := time.Since(state.lastHeartbeat); silence > maxSilence {
+		return fmt.Errorf("kafka consumer of topic %s has not polled for %s", kafkaTopicName, silence.Round(time.Second))
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+	WEBHOOK_TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+	WEBHOOK_EVENT_HEADER     = "X-Webhook-Event"
+	WEBHOOK_DELIVERY_HEADER  = "X-Webhook-Delivery"
+)
+
+// nonPublicPrefixes are the ranges not covered by the net.IP checks that a webhook
+// must not reach: carrier-grade NAT, the IPv4 benchmarking range and NAT64.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicWebhookIp reports whether a webhook may be delivered to ip. Loopback,
+// private, link-local, multicast and unspecified addresses are rejected, so a
+// subscription cannot reach the internal network or the cloud metadata endpoint.
+func isPublicWebhookIp(ip net.IP) bool {
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateWebhookUrl checks that a subscription url is https and that its host only
+// resolves to public addresses.
+func ValidateWebhookUrl(ctx context.Context, rawUrl string) error {
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	if parsedUrl.Scheme != "https" {
+		return errors.New("webhook url must be https")
+	}
+
+	host := parsedUrl.Hostname()
+	if host == "" {
+		return errors.New("webhook url has no host")
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicWebhookIp(ip) {
+			return fmt.Errorf("webhook url must not point to the non public address %s", ip)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("webhook host %s could not be resolved", host)
+	}
+
+	for _, addr := range addrs {
+		if !isPublicWebhookIp(addr.IP) {
+			return fmt.Errorf("webhook host %s resolves to the non public address %s", host, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// webhookDialControl checks every address a delivery connects to, after DNS
+// resolution, so a host that resolved to a public address when the subscription was
+// created cannot be rebound to an internal one later.
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicWebhookIp(ip) {
+		return fmt.Errorf("webhook delivery to the non public address %s is not allowed", host)
+	}
+
+	return nil
+}
+
+// WebhookHttpClient sends webhook deliveries, the timeout comes from the context of
+// each delivery. It only connects to public addresses and does not follow redirects,
+// a redirect response counts as a failed attempt. It can be replaced to send to an
+// httptest server.
+var WebhookHttpClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: webhookDialControl}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with
+// the subscription secret. Receivers recompute it from the X-Webhook-Timestamp header
+// and the raw body and compare it with the v1 value of X-Webhook-Signature.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendWebhook posts a signed delivery to webhookUrl and returns the response status code,
+// 0 when no response was received. Any status outside 2xx is returned as an error.
+func SendWebhook(ctx context.Context, webhookUrl string, secret string, deliveryId int, eventType string, body []byte) (statusCode int, err error) {
+
+	parsedUrl, err := url.Parse(webhookUrl)
+	if err != nil {
+		return 0, err
+	}
+
+	// Subscriptions created before https was required are not sent in plain text
+	if parsedUrl.Scheme != "https" {
+		return 0, errors.New("webhook url must be https")
+	}
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WEBHOOK_EVENT_HEADER, eventType)
+	req.Header.Set(WEBHOOK_DELIVERY_HEADER, strconv.Itoa(deliveryId))
+	req.Header.Set(WEBHOOK_TIMESTAMP_HEADER, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WEBHOOK_SIGNATURE_HEADER, "v1="+SignWebhookPayload(secret, timestamp, body))
+
+	resp, err := WebhookHttpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,157 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestIsPublicWebhookIp(t *testing.T) {
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{ip: "93.184.216.34", expected: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", expected: true},
+		{ip: "127.0.0.1", expected: false},
+		{ip: "::1", expected: false},
+		{ip: "10.0.0.5", expected: false},
+		{ip: "172.16.3.4", expected: false},
+		{ip: "192.168.1.1", expected: false},
+		{ip: "fd00::1", expected: false},
+		{ip: "169.254.169.254", expected: false},
+		{ip: "fe80::1", expected: false},
+		{ip: "224.0.0.1", expected: false},
+		{ip: "ff02::1", expected: false},
+		{ip: "0.0.0.0", expected: false},
+		{ip: "100.64.0.1", expected: false},
+		{ip: "::ffff:127.0.0.1", expected: false},
+		{ip: "::ffff:10.0.0.1", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			if public := isPublicWebhookIp(net.ParseIP(test.ip)); public != test.expected {
+				t.Errorf("isPublicWebhookIp(%s) = %v, expected %v", test.ip, public, test.expected)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookUrl(t *testing.T) {
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "https://93.184.216.34/hooks", allowed: true},
+		{url: "https://[2606:2800:220:1:248:1893:25c8:1946]:8443/hooks", allowed: true},
+		{url: "http://93.184.216.34/hooks", allowed: false},
+		{url: "ftp://93.184.216.34/hooks", allowed: false},
+		{url: "https://127.0.0.1/hooks", allowed: false},
+		{url: "https://[::1]/hooks", allowed: false},
+		{url: "https://10.1.2.3/hooks", allowed: false},
+		{url: "https://169.254.169.254/latest/meta-data", allowed: false},
+		{url: "https://localhost/hooks", allowed: false},
+		{url: "https:///hooks", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			err := ValidateWebhookUrl(context.Background(), test.url)
+			if (err == nil) != test.allowed {
+				t.Errorf("ValidateWebhookUrl(%s) error = %v, expected allowed %v", test.url, err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestWebhookDialControl(t *testing.T) {
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{address: "93.184.216.34:443", allowed: true},
+		{address: "127.0.0.1:443", allowed: false},
+		{address: "[::1]:443", allowed: false},
+		{address: "169.254.169.254:80", allowed: false},
+		{address: "192.168.0.10:8443", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			err := webhookDialControl("tcp", test.address, nil)
+			if (err == nil) != test.allowed {
+				t.Errorf("webhookDialControl(%s) error = %v, expected allowed %v", test.address, err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestWebhookHttpClientRefusesLoopback(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	statusCode, err := SendWebhook(context.Background(), server.URL, "whsec_test", 1, "TransactionCompleted", []byte(`{}`))
+	if err == nil || statusCode != 0 {
+		t.Errorf("SendWebhook to a loopback receiver returned status %d error %v, expected the dial to be refused", statusCode, err)
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+
+	secret := "whsec_test"
+	body := []byte(`{"eventType":"TransactionCompleted"}`)
+
+	var receivedSignature, expectedSignature string
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		receivedBody, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WEBHOOK_TIMESTAMP_HEADER), 10, 64)
+
+		receivedSignature = r.Header.Get(WEBHOOK_SIGNATURE_HEADER)
+		expectedSignature = "v1=" + SignWebhookPayload(secret, timestamp, receivedBody)
+
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/hooks", http.StatusFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	defaultClient := WebhookHttpClient
+	WebhookHttpClient = server.Client()
+	WebhookHttpClient.CheckRedirect = defaultClient.CheckRedirect
+	defer func() { WebhookHttpClient = defaultClient }()
+
+	statusCode, err := SendWebhook(context.Background(), server.URL+"/hooks", secret, 7, "TransactionCompleted", body)
+	if err != nil || statusCode != http.StatusNoContent {
+		t.Fatalf("SendWebhook returned status %d error %v, expected 204", statusCode, err)
+	}
+
+	if receivedSignature != expectedSignature {
+		t.Errorf("signature = %s, expected %s", receivedSignature, expectedSignature)
+	}
+
+	statusCode, err = SendWebhook(context.Background(), server.URL+"/redirect", secret, 7, "TransactionCompleted", body)
+	if err == nil || statusCode != http.StatusFound {
+		t.Errorf("SendWebhook to a redirect returned status %d error %v, expected the redirect not to be followed", statusCode, err)
+	}
+
+	_, err = SendWebhook(context.Background(), "http://93.184.216.34/hooks", secret, 7, "TransactionCompleted", body)
+	if err == nil {
+		t.Error("SendWebhook sent to a plain http url")
+	}
+}
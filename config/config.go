@@ -28,9 +28,101 @@ var AppConfig Config
 
 var (
 	SERVICE_BASE_PATH                  string
-	JWT_SECRET                         string
 	TRANSACTION_PROCESSING_KAFKA_TOPIC string
 	TRANSACTION_PROCESSING_KAFKA_CG    string
+	DOMAIN_EVENTS_KAFKA_TOPIC          string
+
+	TRANSACTION_REQUEST_PRODUCE_SCHEMA_VERSION int
+
+	DAILY_BALANCE_JOB_INTERVAL_MINUTES     int
+	DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES int
+
+	RECONCILIATION_JOB_INTERVAL_MINUTES int
+	RECONCILIATION_WRITE_SERVICE_ERRORS bool
+
+	RECURRING_SCHEDULER_INTERVAL_SECONDS    int
+	RECURRING_SCHEDULE_MAX_ATTEMPTS         int
+	RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS int
+
+	INTEREST_JOB_INTERVAL_MINUTES int
+
+	FEE_JOB_INTERVAL_MINUTES           int
+	FEE_INCOME_ROLLUP_INTERVAL_MINUTES int
+
+	ACCOUNT_DORMANCY_DAYS                 int
+	ACCOUNT_DORMANCY_JOB_INTERVAL_MINUTES int
+
+	WEBHOOK_DISPATCH_INTERVAL_SECONDS int
+	WEBHOOK_TIMEOUT_SECONDS           int
+	WEBHOOK_MAX_ATTEMPTS              int
+	WEBHOOK_BACKOFF_BASE_SECONDS      int
+
+	USER_EVENT_RETENTION_HOURS int
+
+	TRANSACTION_BATCH_MAX_ROWS                  int
+	TRANSACTION_BATCH_DISPATCH_INTERVAL_SECONDS int
+	TRANSACTION_BATCH_DISPATCH_SIZE             int
+
+	TRACING_EXPORTER       string
+	TRACING_FILE_PATH      string
+	TRACING_SAMPLE_PERCENT int
+
+	HEALTH_CHECK_TIMEOUT_MILLISECONDS        int
+	HEALTH_CHECK_SLOW_MILLISECONDS           int
+	KAFKA_CONSUMER_HEARTBEAT_TIMEOUT_SECONDS int
+
+	JWT_SIGNING_KEY_FILE       string
+	JWT_VERIFICATION_KEY_FILES string
+	JWT_ISSUER                 string
+	JWT_AUDIENCE               string
+	JWT_DEV_GENERATED_KEY      bool // Sign with a generated key when JWT_SIGNING_KEY_FILE is not set, local development only
+
+	ACCESS_TOKEN_TTL_MINUTES            int
+	REFRESH_TOKEN_TTL_HOURS             int
+	AUTH_TOKEN_CLEANUP_INTERVAL_MINUTES int
+
+	LOGIN_FREE_ATTEMPTS          int
+	LOGIN_DELAY_BASE_SECONDS     int
+	LOGIN_MAX_DELAY_SECONDS      int
+	LOGIN_LOCKOUT_THRESHOLD      int
+	LOGIN_IP_LOCKOUT_THRESHOLD   int
+	LOGIN_LOCKOUT_MINUTES        int
+	LOGIN_FAILURE_WINDOW_MINUTES int
+	TRUSTED_PROXIES              string
+
+	TOTP_ENCRYPTION_KEY          string
+	TOTP_ISSUER                  string
+	LOGIN_CHALLENGE_TTL_SECONDS  int
+	LOGIN_CHALLENGE_MAX_ATTEMPTS int
+	STEP_UP_WITHDRAWAL_THRESHOLD int
+	STEP_UP_MAX_AGE_SECONDS      int
+
+	API_KEY                        string // Deprecated shared key of every partner, see AuthorizeApiKey
+	API_KEY_LEGACY_UNTIL           string // Last day API_KEY is accepted as 2006-01-02, empty rejects it
+	API_KEY_LEGACY_SCOPES          string // Comma separated scopes of API_KEY
+	API_KEY_DEFAULT_TTL_DAYS       int
+	API_KEYS_MAX_ACTIVE_PER_CLIENT int
+
+	RATE_LIMIT_STORE                    string
+	RATE_LIMIT_USER_PER_MINUTE          int
+	RATE_LIMIT_USER_BURST               int
+	RATE_LIMIT_API_KEY_PER_MINUTE       int
+	RATE_LIMIT_API_KEY_BURST            int
+	RATE_LIMIT_TRANSACTIONS_PER_MINUTE  int
+	RATE_LIMIT_TRANSACTIONS_BURST       int
+	RATE_LIMIT_LOGIN_PER_MINUTE         int
+	RATE_LIMIT_LOGIN_BURST              int
+	RATE_LIMIT_CLEANUP_INTERVAL_MINUTES int
+
+	USER_TOKEN_SIGNING_KEY             string
+	EMAIL_VERIFICATION_TOKEN_TTL_HOURS int
+	PASSWORD_RESET_TOKEN_TTL_MINUTES   int
+	USER_TOKEN_RESEND_INTERVAL_SECONDS int
+	EMAIL_VERIFICATION_URL             string
+	PASSWORD_RESET_URL                 string
+	REQUIRE_VERIFIED_EMAIL             bool
+	NOTIFIER_SENDER                    string
+	NOTIFIER_FILE_PATH                 string
 )
 
 func init() {
@@ -61,9 +153,101 @@ func LoadEnv() {
 	}
 
 	SERVICE_BASE_PATH = os.Getenv("SERVICE_BASE_PATH")
-	JWT_SECRET = os.Getenv("JWT_SECRET")
 	TRANSACTION_PROCESSING_KAFKA_TOPIC = os.Getenv("TRANSACTION_PROCESSING_KAFKA_TOPIC")
 	TRANSACTION_PROCESSING_KAFKA_CG = os.Getenv("TRANSACTION_PROCESSING_KAFKA_CG")
+	DOMAIN_EVENTS_KAFKA_TOPIC = os.Getenv("DOMAIN_EVENTS_KAFKA_TOPIC")
+
+	TRANSACTION_REQUEST_PRODUCE_SCHEMA_VERSION = getEnvAsInt("TRANSACTION_REQUEST_PRODUCE_SCHEMA_VERSION", 2)
+
+	DAILY_BALANCE_JOB_INTERVAL_MINUTES = getEnvAsInt("DAILY_BALANCE_JOB_INTERVAL_MINUTES", 60)
+	DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES = getEnvAsInt("DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES", 60)
+
+	RECONCILIATION_JOB_INTERVAL_MINUTES = getEnvAsInt("RECONCILIATION_JOB_INTERVAL_MINUTES", 1440)
+	RECONCILIATION_WRITE_SERVICE_ERRORS = getEnvAsBool("RECONCILIATION_WRITE_SERVICE_ERRORS", false)
+
+	RECURRING_SCHEDULER_INTERVAL_SECONDS = getEnvAsInt("RECURRING_SCHEDULER_INTERVAL_SECONDS", 60)
+	RECURRING_SCHEDULE_MAX_ATTEMPTS = getEnvAsInt("RECURRING_SCHEDULE_MAX_ATTEMPTS", 5)
+	RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS = getEnvAsInt("RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS", 60)
+
+	INTEREST_JOB_INTERVAL_MINUTES = getEnvAsInt("INTEREST_JOB_INTERVAL_MINUTES", 60)
+
+	FEE_JOB_INTERVAL_MINUTES = getEnvAsInt("FEE_JOB_INTERVAL_MINUTES", 60)
+	FEE_INCOME_ROLLUP_INTERVAL_MINUTES = getEnvAsInt("FEE_INCOME_ROLLUP_INTERVAL_MINUTES", 5)
+
+	ACCOUNT_DORMANCY_DAYS = getEnvAsInt("ACCOUNT_DORMANCY_DAYS", 365)
+	ACCOUNT_DORMANCY_JOB_INTERVAL_MINUTES = getEnvAsInt("ACCOUNT_DORMANCY_JOB_INTERVAL_MINUTES", 1440)
+
+	WEBHOOK_DISPATCH_INTERVAL_SECONDS = getEnvAsInt("WEBHOOK_DISPATCH_INTERVAL_SECONDS", 5)
+	WEBHOOK_TIMEOUT_SECONDS = getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 10)
+	WEBHOOK_MAX_ATTEMPTS = getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	WEBHOOK_BACKOFF_BASE_SECONDS = getEnvAsInt("WEBHOOK_BACKOFF_BASE_SECONDS", 30)
+
+	USER_EVENT_RETENTION_HOURS = getEnvAsInt("USER_EVENT_RETENTION_HOURS", 72)
+
+	TRANSACTION_BATCH_MAX_ROWS = getEnvAsInt("TRANSACTION_BATCH_MAX_ROWS", 1000)
+	TRANSACTION_BATCH_DISPATCH_INTERVAL_SECONDS = getEnvAsInt("TRANSACTION_BATCH_DISPATCH_INTERVAL_SECONDS", 5)
+	TRANSACTION_BATCH_DISPATCH_SIZE = getEnvAsInt("TRANSACTION_BATCH_DISPATCH_SIZE", 100)
+
+	TRACING_EXPORTER = getEnv("TRACING_EXPORTER", "none")
+	TRACING_FILE_PATH = getEnv("TRACING_FILE_PATH", "traces.jsonl")
+	TRACING_SAMPLE_PERCENT = getEnvAsInt("TRACING_SAMPLE_PERCENT", 100)
+
+	HEALTH_CHECK_TIMEOUT_MILLISECONDS = getEnvAsInt("HEALTH_CHECK_TIMEOUT_MILLISECONDS", 2000)
+	HEALTH_CHECK_SLOW_MILLISECONDS = getEnvAsInt("HEALTH_CHECK_SLOW_MILLISECONDS", 500)
+	KAFKA_CONSUMER_HEARTBEAT_TIMEOUT_SECONDS = getEnvAsInt("KAFKA_CONSUMER_HEARTBEAT_TIMEOUT_SECONDS", 30)
+
+	JWT_SIGNING_KEY_FILE = getEnv("JWT_SIGNING_KEY_FILE", "")
+	JWT_VERIFICATION_KEY_FILES = getEnv("JWT_VERIFICATION_KEY_FILES", "")
+	JWT_ISSUER = getEnv("JWT_ISSUER", "banking_ledger")
+	JWT_AUDIENCE = getEnv("JWT_AUDIENCE", "banking_ledger")
+	JWT_DEV_GENERATED_KEY = getEnvAsBool("JWT_DEV_GENERATED_KEY", false)
+
+	ACCESS_TOKEN_TTL_MINUTES = getEnvAsInt("ACCESS_TOKEN_TTL_MINUTES", 10)
+	REFRESH_TOKEN_TTL_HOURS = getEnvAsInt("REFRESH_TOKEN_TTL_HOURS", 720)
+	AUTH_TOKEN_CLEANUP_INTERVAL_MINUTES = getEnvAsInt("AUTH_TOKEN_CLEANUP_INTERVAL_MINUTES", 60)
+
+	LOGIN_FREE_ATTEMPTS = getEnvAsInt("LOGIN_FREE_ATTEMPTS", 3)
+	LOGIN_DELAY_BASE_SECONDS = getEnvAsInt("LOGIN_DELAY_BASE_SECONDS", 1)
+	LOGIN_MAX_DELAY_SECONDS = getEnvAsInt("LOGIN_MAX_DELAY_SECONDS", 30)
+	LOGIN_LOCKOUT_THRESHOLD = getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 10)
+	LOGIN_IP_LOCKOUT_THRESHOLD = getEnvAsInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50)
+	LOGIN_LOCKOUT_MINUTES = getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15)
+	LOGIN_FAILURE_WINDOW_MINUTES = getEnvAsInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)
+	TRUSTED_PROXIES = getEnv("TRUSTED_PROXIES", "")
+
+	TOTP_ENCRYPTION_KEY = getEnv("TOTP_ENCRYPTION_KEY", "")
+	TOTP_ISSUER = getEnv("TOTP_ISSUER", "Banking Ledger")
+	LOGIN_CHALLENGE_TTL_SECONDS = getEnvAsInt("LOGIN_CHALLENGE_TTL_SECONDS", 300)
+	LOGIN_CHALLENGE_MAX_ATTEMPTS = getEnvAsInt("LOGIN_CHALLENGE_MAX_ATTEMPTS", 5)
+	STEP_UP_WITHDRAWAL_THRESHOLD = getEnvAsInt("STEP_UP_WITHDRAWAL_THRESHOLD", 0)
+	STEP_UP_MAX_AGE_SECONDS = getEnvAsInt("STEP_UP_MAX_AGE_SECONDS", 300)
+
+	API_KEY = getEnv("API_KEY", "")
+	API_KEY_LEGACY_UNTIL = getEnv("API_KEY_LEGACY_UNTIL", "")
+	API_KEY_LEGACY_SCOPES = getEnv("API_KEY_LEGACY_SCOPES", "users:login")
+	API_KEY_DEFAULT_TTL_DAYS = getEnvAsInt("API_KEY_DEFAULT_TTL_DAYS", 365)
+	API_KEYS_MAX_ACTIVE_PER_CLIENT = getEnvAsInt("API_KEYS_MAX_ACTIVE_PER_CLIENT", 5)
+
+	RATE_LIMIT_STORE = getEnv("RATE_LIMIT_STORE", "memory")
+	RATE_LIMIT_USER_PER_MINUTE = getEnvAsInt("RATE_LIMIT_USER_PER_MINUTE", 300)
+	RATE_LIMIT_USER_BURST = getEnvAsInt("RATE_LIMIT_USER_BURST", 60)
+	RATE_LIMIT_API_KEY_PER_MINUTE = getEnvAsInt("RATE_LIMIT_API_KEY_PER_MINUTE", 600)
+	RATE_LIMIT_API_KEY_BURST = getEnvAsInt("RATE_LIMIT_API_KEY_BURST", 100)
+	RATE_LIMIT_TRANSACTIONS_PER_MINUTE = getEnvAsInt("RATE_LIMIT_TRANSACTIONS_PER_MINUTE", 30)
+	RATE_LIMIT_TRANSACTIONS_BURST = getEnvAsInt("RATE_LIMIT_TRANSACTIONS_BURST", 10)
+	RATE_LIMIT_LOGIN_PER_MINUTE = getEnvAsInt("RATE_LIMIT_LOGIN_PER_MINUTE", 10)
+	RATE_LIMIT_LOGIN_BURST = getEnvAsInt("RATE_LIMIT_LOGIN_BURST", 5)
+	RATE_LIMIT_CLEANUP_INTERVAL_MINUTES = getEnvAsInt("RATE_LIMIT_CLEANUP_INTERVAL_MINUTES", 10)
+
+	USER_TOKEN_SIGNING_KEY = getEnv("USER_TOKEN_SIGNING_KEY", "")
+	EMAIL_VERIFICATION_TOKEN_TTL_HOURS = getEnvAsInt("EMAIL_VERIFICATION_TOKEN_TTL_HOURS", 24)
+	PASSWORD_RESET_TOKEN_TTL_MINUTES = getEnvAsInt("PASSWORD_RESET_TOKEN_TTL_MINUTES", 30)
+	USER_TOKEN_RESEND_INTERVAL_SECONDS = getEnvAsInt("USER_TOKEN_RESEND_INTERVAL_SECONDS", 60)
+	EMAIL_VERIFICATION_URL = getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email")
+	PASSWORD_RESET_URL = getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+	REQUIRE_VERIFIED_EMAIL = getEnvAsBool("REQUIRE_VERIFIED_EMAIL", true)
+	NOTIFIER_SENDER = getEnv("NOTIFIER_SENDER", "")
+	NOTIFIER_FILE_PATH = getEnv("NOTIFIER_FILE_PATH", "notifications.log")
 }
 
 // Helper function to read environment variable or fallback default
@@ -83,6 +267,14 @@ func getEnvAsInt(name string, defaultVal int) int {
 	return defaultVal
 }
 
+func getEnvAsBool(name string, defaultVal bool) bool {
+	valStr := getEnv(name, "")
+	if val, err := strconv.ParseBool(valStr); err == nil {
+		return val
+	}
+	return defaultVal
+}
+
 func ShowServiceInfo() {
 
 	serviceName := os.Getenv("SERVICE_NAME")
@@ -6,7 +6,9 @@ import (
 	"banking_ledger/utils"
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -15,9 +17,17 @@ type accountDb struct{}
 type accountDbInterface interface {
 	BeginTx(ctx context.Context) (pgx.Tx, error)
 	GetAccountByUserId(ctx context.Context, tx pgx.Tx, userId int) (exists bool, account models.Account, appError *models.ApplicationError)
-	CreateAccountForUser(ctx context.Context, tx pgx.Tx, userId int, balance int64) *models.ApplicationError
+	CreateAccountForUser(ctx context.Context, tx pgx.Tx, userId int, balance int64, accountType string) (accountId int, appError *models.ApplicationError)
 	GetBalanceForUserId(ctx context.Context, tx pgx.Tx, userId int) (exists bool, balance int64, appError *models.ApplicationError)
 	UpdateBalanceForUserId(ctx context.Context, tx pgx.Tx, userId int, balance int64) *models.ApplicationError
+	GetAllAccounts(ctx context.Context) (accounts []models.Account, appError *models.ApplicationError)
+	MarkTransactionRequestProcessed(ctx context.Context, tx pgx.Tx, requestId uuid.UUID, userId int) (isNew bool, appError *models.ApplicationError)
+	GetAccountByIdForUpdate(ctx context.Context, tx pgx.Tx, accountId int) (exists bool, account models.Account, appError *models.ApplicationError)
+	UpdateAccountStatus(ctx context.Context, tx pgx.Tx, accountId int, status string) *models.ApplicationError
+	InsertAccountStatusHistory(ctx context.Context, tx pgx.Tx, history models.AccountStatusHistory) *models.ApplicationError
+	GetAccountStatusHistory(ctx context.Context, accountId int) (history []models.AccountStatusHistory, appError *models.ApplicationError)
+	UpdateAccountActivity(ctx context.Context, tx pgx.Tx, accountId int) *models.ApplicationError
+	GetInactiveAccountsForUpdate(ctx context.Context, tx pgx.Tx, inactiveSince time.Time, limit int) (accounts []models.Account, appError *models.ApplicationError)
 }
 
 var AccDb accountDbInterface
@@ -26,11 +36,17 @@ func init() {
 	AccDb = &accountDb{}
 }
 
+const accountColumns = `ac."account_id", ac."user_id", ac."balance", ac."account_type", ac."status", ac."last_activity_at", ac."closed_at", ac."created_at", ac."updated_at"`
+
+func scanAccount(row pgx.Row, account *models.Account) error {
+	return row.Scan(&account.AccountID, &account.UserID, &account.Balance, &account.AccountType, &account.Status, &account.LastActivityAt, &account.ClosedAt, &account.CreatedAt, &account.UpdatedAt)
+}
+
 func (a *accountDb) GetAccountByUserId(ctx context.Context, tx pgx.Tx, userId int) (exists bool, account models.Account, appError *models.ApplicationError) {
 
-	sqlStatement := `select ac."account_id", ac."user_id", ac."balance" from accounts ac where ac."user_id" = $1`
+	sqlStatement := `select ` + accountColumns + ` from accounts ac where ac."user_id" = $1`
 
-	err := tx.QueryRow(ctx, sqlStatement, userId).Scan(&account.AccountID, &account.UserID, &account.Balance)
+	err := scanAccount(tx.QueryRow(ctx, sqlStatement, userId), &account)
 	if err != nil {
 
 		if err == pgx.ErrNoRows {
@@ -39,7 +55,7 @@ func (a *accountDb) GetAccountByUserId(ctx context.Context, tx pgx.Tx, userId in
 
 		errMsg := fmt.Sprintf("GetAccountByUserId: Could not get account details from Database. Error:%s!", err.Error())
 		displayMsg := "Could not get account details for the user!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError = utils.RenderAppError(ctx, 2001, errMsg, displayMsg, nil)
 		return false, account, appError
 	}
@@ -47,22 +63,20 @@ func (a *accountDb) GetAccountByUserId(ctx context.Context, tx pgx.Tx, userId in
 	return true, account, nil
 }
 
-func (a *accountDb) CreateAccountForUser(ctx context.Context, tx pgx.Tx, userId int, balance int64) *models.ApplicationError {
+func (a *accountDb) CreateAccountForUser(ctx context.Context, tx pgx.Tx, userId int, balance int64, accountType string) (accountId int, appError *models.ApplicationError) {
 
-	var accountId int
+	sqlStatement := `INSERT INTO accounts (user_id, balance, account_type) VALUES ($1, $2, $3) RETURNING account_id;`
 
-	sqlStatement := `INSERT INTO accounts (user_id, balance) VALUES ($1, $2) RETURNING account_id;`
-
-	err := tx.QueryRow(ctx, sqlStatement, userId, balance).Scan(&accountId)
+	err := tx.QueryRow(ctx, sqlStatement, userId, balance, accountType).Scan(&accountId)
 	if err != nil {
 		errMsg := fmt.Sprintf("CreateAccountForUser: Couldn't insert user account details. Error:%s!", err.Error())
 		displayMsg := fmt.Sprintf("Could not create account for userId: %d", userId)
-		logger.Log.Error(errMsg)
-		appError := utils.RenderAppError(ctx, 2002, errMsg, displayMsg, nil)
-		return appError
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2002, errMsg, displayMsg, nil)
+		return 0, appError
 	}
 
-	return nil
+	return accountId, nil
 }
 
 func (a *accountDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
@@ -84,7 +98,7 @@ func (a *accountDb) GetBalanceForUserId(ctx context.Context, tx pgx.Tx, userId i
 
 		errMsg := fmt.Sprintf("GetBalanceForUserId: Could not get account details from Database. Error:%s!", err.Error())
 		displayMsg := "Could not get account balance for the user!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError = utils.RenderAppError(ctx, 2003, errMsg, displayMsg, nil)
 		return false, accountBalance, appError
 	}
@@ -101,7 +115,7 @@ func (a *accountDb) UpdateBalanceForUserId(ctx context.Context, tx pgx.Tx, userI
 	if err != nil {
 		errMsg := fmt.Sprintf("UpdateBalanceForUserId: Could not update balance for userId: %d! Error:%s!", userId, err.Error())
 		displayMsg := "Could not update balance for the user!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 2004, errMsg, displayMsg, nil)
 		return appError
 	}
@@ -110,10 +124,213 @@ func (a *accountDb) UpdateBalanceForUserId(ctx context.Context, tx pgx.Tx, userI
 	if rowsAffected == 0 {
 		errMsg := fmt.Sprintf("UpdateBalanceForUserId: No rows affected while updating balance for userId: %d!", userId)
 		displayMsg := "Could not update balance for the user!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 2005, errMsg, displayMsg, nil)
 		return appError
 	}
 
 	return nil
 }
+
+func (a *accountDb) GetAllAccounts(ctx context.Context) (accounts []models.Account, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + accountColumns + ` from accounts ac order by ac."account_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetAllAccounts: Could not get accounts from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get accounts!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2006, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var account models.Account
+		if err := scanAccount(rows, &account); err != nil {
+			errMsg := fmt.Sprintf("GetAllAccounts: Could not scan account row. Error:%s!", err.Error())
+			displayMsg := "Could not get accounts!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2007, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetAllAccounts: Error while iterating account rows. Error:%s!", err.Error())
+		displayMsg := "Could not get accounts!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2008, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return accounts, nil
+}
+
+// MarkTransactionRequestProcessed records a request id in the same transaction that
+// applies it, isNew is false when the request was already applied before.
+func (a *accountDb) MarkTransactionRequestProcessed(ctx context.Context, tx pgx.Tx, requestId uuid.UUID, userId int) (isNew bool, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO processed_transaction_requests ("request_id", "user_id") VALUES ($1, $2) ON CONFLICT ("request_id") DO NOTHING`
+
+	result, err := tx.Exec(ctx, sqlStatement, requestId, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("MarkTransactionRequestProcessed: Could not record requestId: %s! Error:%s!", requestId.String(), err.Error())
+		displayMsg := "Could not record processed transaction request!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2009, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (a *accountDb) GetAccountByIdForUpdate(ctx context.Context, tx pgx.Tx, accountId int) (exists bool, account models.Account, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + accountColumns + ` from accounts ac where ac."account_id" = $1 FOR UPDATE`
+
+	err := scanAccount(tx.QueryRow(ctx, sqlStatement, accountId), &account)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, account, nil
+		}
+
+		errMsg := fmt.Sprintf("GetAccountByIdForUpdate: Could not get account: %d from Database. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get account details!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2010, errMsg, displayMsg, nil)
+		return false, account, appError
+	}
+
+	return true, account, nil
+}
+
+func (a *accountDb) UpdateAccountStatus(ctx context.Context, tx pgx.Tx, accountId int, status string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE accounts SET "status" = $1, "closed_at" = CASE WHEN $1 = 'closed' THEN NOW() ELSE NULL END,
+		"last_activity_at" = CASE WHEN $1 = 'active' THEN NOW() ELSE "last_activity_at" END WHERE "account_id" = $2`
+
+	_, err := tx.Exec(ctx, sqlStatement, status, accountId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateAccountStatus: Could not update status of account: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not update account status!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2011, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (a *accountDb) InsertAccountStatusHistory(ctx context.Context, tx pgx.Tx, history models.AccountStatusHistory) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO account_status_history ("account_id", "from_status", "to_status", "reason", "changed_by") VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.Exec(ctx, sqlStatement, history.AccountId, history.FromStatus, history.ToStatus, history.Reason, history.ChangedBy)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertAccountStatusHistory: Could not save status change of account: %d! Error:%s!", history.AccountId, err.Error())
+		displayMsg := "Could not save account status change!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2012, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (a *accountDb) GetAccountStatusHistory(ctx context.Context, accountId int) (history []models.AccountStatusHistory, appError *models.ApplicationError) {
+
+	sqlStatement := `select h."account_id", h."from_status", h."to_status", h."reason", h."changed_by", h."created_at"
+		from account_status_history h where h."account_id" = $1 order by h."id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, accountId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetAccountStatusHistory: Could not get status history of account: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get account status history!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2013, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change models.AccountStatusHistory
+		if err := rows.Scan(&change.AccountId, &change.FromStatus, &change.ToStatus, &change.Reason, &change.ChangedBy, &change.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetAccountStatusHistory: Could not scan status history row. Error:%s!", err.Error())
+			displayMsg := "Could not get account status history!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2014, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		history = append(history, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetAccountStatusHistory: Error while iterating status history rows. Error:%s!", err.Error())
+		displayMsg := "Could not get account status history!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2015, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return history, nil
+}
+
+func (a *accountDb) UpdateAccountActivity(ctx context.Context, tx pgx.Tx, accountId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE accounts SET "last_activity_at" = NOW() WHERE "account_id" = $1`
+
+	_, err := tx.Exec(ctx, sqlStatement, accountId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateAccountActivity: Could not update last activity of account: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not update account activity!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2016, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// GetInactiveAccountsForUpdate locks active customer accounts without a deposit or
+// withdrawal since inactiveSince.
+func (a *accountDb) GetInactiveAccountsForUpdate(ctx context.Context, tx pgx.Tx, inactiveSince time.Time, limit int) (accounts []models.Account, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + accountColumns + ` from accounts ac where ac."status" = 'active' and ac."account_type" <> 'internal_income'
+		and ac."last_activity_at" < $1 order by ac."account_id" limit $2 FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, sqlStatement, inactiveSince, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetInactiveAccountsForUpdate: Could not get inactive accounts from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get inactive accounts!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2017, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var account models.Account
+		if err := scanAccount(rows, &account); err != nil {
+			errMsg := fmt.Sprintf("GetInactiveAccountsForUpdate: Could not scan account row. Error:%s!", err.Error())
+			displayMsg := "Could not get inactive accounts!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2018, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetInactiveAccountsForUpdate: Error while iterating account rows. Error:%s!", err.Error())
+		displayMsg := "Could not get inactive accounts!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2019, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return accounts, nil
+}
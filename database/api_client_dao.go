@@ -0,0 +1,264 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type apiClientDb struct{}
+
+type apiClientDbInterface interface {
+	CreateApiClient(ctx context.Context, client models.ApiClient) (created bool, newClient models.ApiClient, appError *models.ApplicationError)
+	GetApiClients(ctx context.Context) (clients []models.ApiClient, appError *models.ApplicationError)
+	GetApiClientById(ctx context.Context, clientId int) (exists bool, client models.ApiClient, appError *models.ApplicationError)
+	UpdateApiClientScopes(ctx context.Context, clientId int, scopes []string) (updated bool, appError *models.ApplicationError)
+	CountActiveApiKeys(ctx context.Context, clientId int) (count int, appError *models.ApplicationError)
+	InsertApiKey(ctx context.Context, key models.ApiKey) *models.ApplicationError
+	GetApiKeysByClientId(ctx context.Context, clientId int) (keys []models.ApiKey, appError *models.ApplicationError)
+	RevokeApiKey(ctx context.Context, clientId int, keyId uuid.UUID, revokedBy int) (revoked bool, appError *models.ApplicationError)
+	GetApiKeyByPrefix(ctx context.Context, prefix string) (exists bool, key models.ApiKey, scopes []string, appError *models.ApplicationError)
+	TouchApiKey(ctx context.Context, keyId uuid.UUID, minInterval time.Duration) *models.ApplicationError
+}
+
+var ApiClientDb apiClientDbInterface
+
+func init() {
+	ApiClientDb = &apiClientDb{}
+}
+
+// CreateApiClient returns created false when a client with the name exists.
+func (a *apiClientDb) CreateApiClient(ctx context.Context, client models.ApiClient) (created bool, newClient models.ApiClient, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO api_clients ("name", "scopes", "created_by") VALUES ($1, $2, $3)
+		ON CONFLICT ("name") DO NOTHING
+		RETURNING "client_id", "name", "scopes", "created_by", "created_at"`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, client.Name, client.Scopes, client.CreatedBy).Scan(&newClient.ClientId, &newClient.Name, &newClient.Scopes, &newClient.CreatedBy, &newClient.CreatedAt)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, newClient, nil
+		}
+
+		errMsg := fmt.Sprintf("CreateApiClient: Could not create api client. Error:%s!", err.Error())
+		displayMsg := "Could not create api client!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2651, errMsg, displayMsg, nil)
+		return false, newClient, appError
+	}
+
+	return true, newClient, nil
+}
+
+func (a *apiClientDb) GetApiClients(ctx context.Context) (clients []models.ApiClient, appError *models.ApplicationError) {
+
+	sqlStatement := `select c."client_id", c."name", c."scopes", c."created_by", c."created_at" from api_clients c order by c."client_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetApiClients: Could not get api clients. Error:%s!", err.Error())
+		displayMsg := "Could not get api clients!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2652, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var client models.ApiClient
+		if err := rows.Scan(&client.ClientId, &client.Name, &client.Scopes, &client.CreatedBy, &client.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetApiClients: Could not scan api client row. Error:%s!", err.Error())
+			displayMsg := "Could not get api clients!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2653, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		clients = append(clients, client)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetApiClients: Error while iterating api client rows. Error:%s!", err.Error())
+		displayMsg := "Could not get api clients!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2654, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return clients, nil
+}
+
+func (a *apiClientDb) GetApiClientById(ctx context.Context, clientId int) (exists bool, client models.ApiClient, appError *models.ApplicationError) {
+
+	sqlStatement := `select c."client_id", c."name", c."scopes", c."created_by", c."created_at" from api_clients c where c."client_id" = $1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, clientId).Scan(&client.ClientId, &client.Name, &client.Scopes, &client.CreatedBy, &client.CreatedAt)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, client, nil
+		}
+
+		errMsg := fmt.Sprintf("GetApiClientById: Could not get api client: %d. Error:%s!", clientId, err.Error())
+		displayMsg := "Could not get api client!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2655, errMsg, displayMsg, nil)
+		return false, client, appError
+	}
+
+	return true, client, nil
+}
+
+func (a *apiClientDb) UpdateApiClientScopes(ctx context.Context, clientId int, scopes []string) (updated bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE api_clients SET "scopes" = $2 WHERE "client_id" = $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, clientId, scopes)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateApiClientScopes: Could not update scopes of api client: %d. Error:%s!", clientId, err.Error())
+		displayMsg := "Could not update api client!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2656, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+// CountActiveApiKeys counts the keys of the client that are neither revoked nor expired.
+func (a *apiClientDb) CountActiveApiKeys(ctx context.Context, clientId int) (count int, appError *models.ApplicationError) {
+
+	sqlStatement := `select count(*) from api_keys k where k."client_id" = $1 AND k."revoked_at" IS NULL AND (k."expires_at" IS NULL OR k."expires_at" > NOW())`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, clientId).Scan(&count)
+	if err != nil {
+		errMsg := fmt.Sprintf("CountActiveApiKeys: Could not count api keys of client: %d. Error:%s!", clientId, err.Error())
+		displayMsg := "Could not get api keys!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2657, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return count, nil
+}
+
+func (a *apiClientDb) InsertApiKey(ctx context.Context, key models.ApiKey) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO api_keys ("key_id", "client_id", "name", "key_prefix", "key_hash", "expires_at", "created_by", "created_at")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, key.KeyId, key.ClientId, key.Name, key.KeyPrefix, key.KeyHash, key.ExpiresAt, key.CreatedBy, key.CreatedAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertApiKey: Could not save api key of client: %d. Error:%s!", key.ClientId, err.Error())
+		displayMsg := "Could not create api key!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2658, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (a *apiClientDb) GetApiKeysByClientId(ctx context.Context, clientId int) (keys []models.ApiKey, appError *models.ApplicationError) {
+
+	sqlStatement := `select k."key_id", k."client_id", k."name", k."key_prefix", k."expires_at", k."last_used_at", k."revoked_at", k."revoked_by", k."created_by", k."created_at"
+		from api_keys k where k."client_id" = $1 order by k."created_at" desc`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, clientId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetApiKeysByClientId: Could not get api keys of client: %d. Error:%s!", clientId, err.Error())
+		displayMsg := "Could not get api keys!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2659, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key models.ApiKey
+		if err := rows.Scan(&key.KeyId, &key.ClientId, &key.Name, &key.KeyPrefix, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.RevokedBy, &key.CreatedBy, &key.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetApiKeysByClientId: Could not scan api key row. Error:%s!", err.Error())
+			displayMsg := "Could not get api keys!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2660, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetApiKeysByClientId: Error while iterating api key rows. Error:%s!", err.Error())
+		displayMsg := "Could not get api keys!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2661, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return keys, nil
+}
+
+// RevokeApiKey returns revoked false when the client has no such key or it was
+// already revoked.
+func (a *apiClientDb) RevokeApiKey(ctx context.Context, clientId int, keyId uuid.UUID, revokedBy int) (revoked bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE api_keys SET "revoked_at" = NOW(), "revoked_by" = $3 WHERE "client_id" = $1 AND "key_id" = $2 AND "revoked_at" IS NULL`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, clientId, keyId, revokedBy)
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeApiKey: Could not revoke api key: %s. Error:%s!", keyId, err.Error())
+		displayMsg := "Could not revoke api key!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2662, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+// GetApiKeyByPrefix returns the key with its hash and the scopes of its client.
+func (a *apiClientDb) GetApiKeyByPrefix(ctx context.Context, prefix string) (exists bool, key models.ApiKey, scopes []string, appError *models.ApplicationError) {
+
+	sqlStatement := `select k."key_id", k."client_id", k."name", k."key_prefix", k."key_hash", k."expires_at", k."last_used_at", k."revoked_at", c."scopes"
+		from api_keys k join api_clients c on c."client_id" = k."client_id"
+		where k."key_prefix" = $1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, prefix).Scan(&key.KeyId, &key.ClientId, &key.Name, &key.KeyPrefix, &key.KeyHash, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &scopes)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, key, nil, nil
+		}
+
+		errMsg := fmt.Sprintf("GetApiKeyByPrefix: Could not get api key. Error:%s!", err.Error())
+		displayMsg := "Could not verify api key!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2663, errMsg, displayMsg, nil)
+		return false, key, nil, appError
+	}
+
+	return true, key, scopes, nil
+}
+
+// TouchApiKey records the use of a key, at most once per minInterval so busy keys do
+// not write on every request.
+func (a *apiClientDb) TouchApiKey(ctx context.Context, keyId uuid.UUID, minInterval time.Duration) *models.ApplicationError {
+
+	sqlStatement := `UPDATE api_keys SET "last_used_at" = NOW() WHERE "key_id" = $1 AND ("last_used_at" IS NULL OR "last_used_at" < NOW() - make_interval(secs => $2))`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, keyId, minInterval.Seconds())
+	if err != nil {
+		errMsg := fmt.Sprintf("TouchApiKey: Could not save last use of api key: %s. Error:%s!", keyId, err.Error())
+		displayMsg := "Could not save last use of api key!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2664, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type balanceSnapshotDb struct{}
+
+type balanceSnapshotDbInterface interface {
+	UpsertDailyBalance(ctx context.Context, dailyBalance models.DailyBalance) *models.ApplicationError
+	GetLatestDailyBalance(ctx context.Context, accountId int) (exists bool, dailyBalance models.DailyBalance, appError *models.ApplicationError)
+	GetLatestDailyBalanceBefore(ctx context.Context, accountId int, balanceDate time.Time) (exists bool, dailyBalance models.DailyBalance, appError *models.ApplicationError)
+	GetDailyBalances(ctx context.Context, accountId int, startDate time.Time, endDate time.Time) (dailyBalances []models.DailyBalance, appError *models.ApplicationError)
+	MarkDailyBalanceRecompute(ctx context.Context, tx pgx.Tx, userId int, fromDate time.Time) *models.ApplicationError
+	GetDailyBalanceRecomputes(ctx context.Context) (recomputes []models.DailyBalanceRecompute, appError *models.ApplicationError)
+	ClearDailyBalanceRecompute(ctx context.Context, recompute models.DailyBalanceRecompute) *models.ApplicationError
+}
+
+var BalSnapDb balanceSnapshotDbInterface
+
+func init() {
+	BalSnapDb = &balanceSnapshotDb{}
+}
+
+func (b *balanceSnapshotDb) UpsertDailyBalance(ctx context.Context, dailyBalance models.DailyBalance) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO account_daily_balances ("account_id", "user_id", "balance_date", "closing_balance") VALUES ($1, $2, $3, $4)
+		ON CONFLICT ("account_id", "balance_date") DO UPDATE SET "closing_balance" = EXCLUDED."closing_balance"`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, dailyBalance.AccountID, dailyBalance.UserID, dailyBalance.BalanceDate, dailyBalance.ClosingBalance)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpsertDailyBalance: Could not save daily balance for accountId: %d! Error:%s!", dailyBalance.AccountID, err.Error())
+		displayMsg := "Could not save daily balance!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2301, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (b *balanceSnapshotDb) GetLatestDailyBalance(ctx context.Context, accountId int) (exists bool, dailyBalance models.DailyBalance, appError *models.ApplicationError) {
+
+	sqlStatement := `select db."account_id", db."user_id", db."balance_date", db."closing_balance" from account_daily_balances db
+		where db."account_id" = $1 order by db."balance_date" desc limit 1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, accountId).Scan(&dailyBalance.AccountID, &dailyBalance.UserID, &dailyBalance.BalanceDate, &dailyBalance.ClosingBalance)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, dailyBalance, nil
+		}
+
+		errMsg := fmt.Sprintf("GetLatestDailyBalance: Could not get daily balance for accountId: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get daily balance!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2302, errMsg, displayMsg, nil)
+		return false, dailyBalance, appError
+	}
+
+	return true, dailyBalance, nil
+}
+
+func (b *balanceSnapshotDb) GetLatestDailyBalanceBefore(ctx context.Context, accountId int, balanceDate time.Time) (exists bool, dailyBalance models.DailyBalance, appError *models.ApplicationError) {
+
+	sqlStatement := `select db."account_id", db."user_id", db."balance_date", db."closing_balance" from account_daily_balances db
+		where db."account_id" = $1 and db."balance_date" < $2 order by db."balance_date" desc limit 1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, accountId, balanceDate).Scan(&dailyBalance.AccountID, &dailyBalance.UserID, &dailyBalance.BalanceDate, &dailyBalance.ClosingBalance)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, dailyBalance, nil
+		}
+
+		errMsg := fmt.Sprintf("GetLatestDailyBalanceBefore: Could not get daily balance for accountId: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get daily balance!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2303, errMsg, displayMsg, nil)
+		return false, dailyBalance, appError
+	}
+
+	return true, dailyBalance, nil
+}
+
+func (b *balanceSnapshotDb) GetDailyBalances(ctx context.Context, accountId int, startDate time.Time, endDate time.Time) (dailyBalances []models.DailyBalance, appError *models.ApplicationError) {
+
+	sqlStatement := `select db."account_id", db."user_id", db."balance_date", db."closing_balance" from account_daily_balances db
+		where db."account_id" = $1 and db."balance_date" between $2 and $3 order by db."balance_date"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, accountId, startDate, endDate)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetDailyBalances: Could not get daily balances for accountId: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get daily balances!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2304, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dailyBalance models.DailyBalance
+		if err := rows.Scan(&dailyBalance.AccountID, &dailyBalance.UserID, &dailyBalance.BalanceDate, &dailyBalance.ClosingBalance); err != nil {
+			errMsg := fmt.Sprintf("GetDailyBalances: Could not scan daily balance row. Error:%s!", err.Error())
+			displayMsg := "Could not get daily balances!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2305, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		dailyBalances = append(dailyBalances, dailyBalance)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetDailyBalances: Error while iterating daily balance rows. Error:%s!", err.Error())
+		displayMsg := "Could not get daily balances!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2306, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return dailyBalances, nil
+}
+
+// MarkDailyBalanceRecompute marks the daily balances of the account of the user stale
+// from fromDate, an earlier mark is kept.
+func (b *balanceSnapshotDb) MarkDailyBalanceRecompute(ctx context.Context, tx pgx.Tx, userId int, fromDate time.Time) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO daily_balance_recomputes ("account_id", "from_date", "marked_at")
+		SELECT a."account_id", $2, NOW() FROM accounts a WHERE a."user_id" = $1
+		ON CONFLICT ("account_id") DO UPDATE SET
+			"from_date" = LEAST(daily_balance_recomputes."from_date", EXCLUDED."from_date"),
+			"marked_at" = NOW()`
+
+	_, err := tx.Exec(ctx, sqlStatement, userId, fromDate)
+	if err != nil {
+		errMsg := fmt.Sprintf("MarkDailyBalanceRecompute: Could not mark daily balances of user: %d stale! Error:%s!", userId, err.Error())
+		displayMsg := "Could not mark daily balances stale!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2307, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (b *balanceSnapshotDb) GetDailyBalanceRecomputes(ctx context.Context) (recomputes []models.DailyBalanceRecompute, appError *models.ApplicationError) {
+
+	sqlStatement := `select r."account_id", r."from_date", r."marked_at" from daily_balance_recomputes r`
+
+	rows, err := dbPool.Query(ctx, sqlStatement)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetDailyBalanceRecomputes: Could not get stale daily balances! Error:%s!", err.Error())
+		displayMsg := "Could not get stale daily balances!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2308, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recompute models.DailyBalanceRecompute
+		if err := rows.Scan(&recompute.AccountID, &recompute.FromDate, &recompute.MarkedAt); err != nil {
+			errMsg := fmt.Sprintf("GetDailyBalanceRecomputes: Could not scan stale daily balance row. Error:%s!", err.Error())
+			displayMsg := "Could not get stale daily balances!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2309, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		recomputes = append(recomputes, recompute)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetDailyBalanceRecomputes: Error while iterating stale daily balance rows. Error:%s!", err.Error())
+		displayMsg := "Could not get stale daily balances!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2310, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return recomputes, nil
+}
+
+// ClearDailyBalanceRecompute deletes the mark when it was not marked again since it
+// was read, a newer mark is recomputed by the next run.
+func (b *balanceSnapshotDb) ClearDailyBalanceRecompute(ctx context.Context, recompute models.DailyBalanceRecompute) *models.ApplicationError {
+
+	sqlStatement := `DELETE FROM daily_balance_recomputes WHERE "account_id" = $1 AND "marked_at" = $2`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, recompute.AccountID, recompute.MarkedAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("ClearDailyBalanceRecompute: Could not clear stale daily balances of accountId: %d! Error:%s!", recompute.AccountID, err.Error())
+		displayMsg := "Could not clear stale daily balances!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2311, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
@@ -3,9 +3,13 @@ package database
 import (
 	"banking_ledger/config"
 	"banking_ledger/logger"
+	"banking_ledger/metrics"
 	"context"
 	"fmt"
+	"net"
+	"net/url"
 
+	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -13,35 +17,58 @@ var dbPool *pgxpool.Pool
 
 func InitializeDatabasePool() error {
 
-	var err error
+	databaseUrl := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(config.AppConfig.DBUser, config.AppConfig.DBPassword),
+		Host:   net.JoinHostPort(config.AppConfig.DBHost, config.AppConfig.DBPort),
+		Path:   "/" + config.AppConfig.DBName,
+	}
 
-	databaseUrl := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", config.AppConfig.DBUser, config.AppConfig.DBPassword, config.AppConfig.DBHost, config.AppConfig.DBPort, config.AppConfig.DBName)
-	fmt.Println(databaseUrl)
+	// Redacted replaces the password, the url is never logged with it
+	logger.Log.Info(fmt.Sprintf("Connecting to database %s", databaseUrl.Redacted()))
 
-	dbconfig, _ := pgxpool.ParseConfig(databaseUrl)
+	dbconfig, err := pgxpool.ParseConfig(databaseUrl.String())
+	if err != nil {
+		errorMsg := fmt.Sprintf("Invalid database url %s.Error:%s!\n", databaseUrl.Redacted(), err.Error())
+		logger.Log.Error(errorMsg)
+		return err
+	}
+
+	dbconfig.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName())
 	dbPool, err = pgxpool.NewWithConfig(context.Background(), dbconfig)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Cannot connect to database %s.Error:%s!\n", databaseUrl, err.Error())
+		errorMsg := fmt.Sprintf("Cannot connect to database %s.Error:%s!\n", databaseUrl.Redacted(), err.Error())
 		logger.Log.Error(errorMsg)
 		return err
 	}
 
-	err = PingDatabasePool()
+	err = PingDatabasePool(context.Background())
 	if err != nil {
 		errMsg := fmt.Sprintf("Unable to ping database: %v\n", err)
 		logger.Log.Error(errMsg)
 		return err
 	}
 
+	err = metrics.RegisterPgxPool(dbPool)
+	if err != nil {
+		errMsg := fmt.Sprintf("Unable to register database pool metrics: %v\n", err)
+		logger.Log.Error(errMsg)
+		return err
+	}
+
 	logger.Log.Info("Successfully connected to database")
 
 	return nil
 
 }
 
-func PingDatabasePool() error {
+func PingDatabasePool(ctx context.Context) error {
+
+	if dbPool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
 
-	return dbPool.Ping(context.Background())
+	return dbPool.Ping(ctx)
 
 }
 
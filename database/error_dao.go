@@ -3,6 +3,7 @@ package database
 import (
 	"banking_ledger/logger"
 	"banking_ledger/models"
+	"banking_ledger/redact"
 	"banking_ledger/utils"
 	"context"
 
@@ -24,13 +25,13 @@ func init() {
 
 func (d *errorDb) SaveDroppedMessage(ctx context.Context, droppedMessage models.DroppedMessage) *models.ApplicationError {
 
-	sqlStatement := `insert into kafka_topic_dropped_messages ("topic_name","error_type","kafka_message") values ($1,$2,$3)`
-	_, err := dbPool.Exec(context.Background(), sqlStatement, droppedMessage.TopicName, droppedMessage.ErrorType, droppedMessage.KafkaMessage)
+	sqlStatement := `insert into kafka_topic_dropped_messages ("topic_name","error_type","kafka_message","correlation_id") values ($1,$2,$3,NULLIF($4,''))`
+	_, err := dbPool.Exec(context.Background(), sqlStatement, droppedMessage.TopicName, droppedMessage.ErrorType, redact.JSON([]byte(droppedMessage.KafkaMessage), redact.Rule{}), utils.GetRequestIdFromContext(ctx))
 
 	if err != nil {
 		errMsg := fmt.Sprintf("SaveDroppedMessage:Could not write to topic dropped message database table.Error:%s", err.Error())
 		displayMsg := "Could not write to topic dropped message database table!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 2101, errMsg, displayMsg, nil)
 		return appError
 	}
@@ -41,13 +42,13 @@ func (d *errorDb) SaveDroppedMessage(ctx context.Context, droppedMessage models.
 
 func (d *errorDb) ProcessErrorMessages(ctx context.Context, priority int, errorMessage string, additionalInfo string) *models.ApplicationError {
 
-	sqlStatement := `insert into service_errors ("priority","error_message","additional_info") values ($1,$2,$3)`
-	_, err := dbPool.Exec(context.Background(), sqlStatement, priority, errorMessage, additionalInfo)
+	sqlStatement := `insert into service_errors ("priority","error_message","additional_info","correlation_id") values ($1,$2,$3,NULLIF($4,''))`
+	_, err := dbPool.Exec(context.Background(), sqlStatement, priority, redact.String(errorMessage), redact.String(additionalInfo), utils.GetRequestIdFromContext(ctx))
 
 	if err != nil {
 		errMsg := fmt.Sprintf("ProcessErrorMessages:Could not write to errors table.Error:%s", err.Error())
 		displayMsg := "Could not write to errors table!"
-		logger.Log.Error(err.Error())
+		logger.WithContext(ctx).Error(err.Error())
 		appError := utils.RenderAppError(ctx, 2102, errMsg, displayMsg, nil)
 		return appError
 	}
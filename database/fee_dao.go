@@ -0,0 +1,403 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type feeDb struct{}
+
+type feeDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	DeactivateFeeSchedule(ctx context.Context, tx pgx.Tx, accountType string, feeType string) *models.ApplicationError
+	CreateFeeSchedule(ctx context.Context, tx pgx.Tx, schedule models.FeeSchedule) (feeScheduleId int, appError *models.ApplicationError)
+	GetFeeSchedules(ctx context.Context, activeOnly bool) (schedules []models.FeeSchedule, appError *models.ApplicationError)
+	GetActiveFeeSchedule(ctx context.Context, tx pgx.Tx, accountType string, feeType string) (exists bool, schedule models.FeeSchedule, appError *models.ApplicationError)
+	CreateFeeWaiver(ctx context.Context, waiver models.FeeWaiver) (waiverId int, appError *models.ApplicationError)
+	GetFeeWaivers(ctx context.Context, accountId int) (waivers []models.FeeWaiver, appError *models.ApplicationError)
+	RevokeFeeWaiver(ctx context.Context, waiverId int) (exists bool, appError *models.ApplicationError)
+	GetApplicableFeeWaiver(ctx context.Context, tx pgx.Tx, accountId int, feeType string, feeDate time.Time) (exists bool, waiverId int, appError *models.ApplicationError)
+	InsertFeeCharge(ctx context.Context, tx pgx.Tx, charge models.FeeCharge) (inserted bool, appError *models.ApplicationError)
+	RecordFeeCharged(ctx context.Context, tx pgx.Tx, charge models.FeeCharge) *models.ApplicationError
+	FailFeeCharge(ctx context.Context, userId int, charge models.FeeCharge) *models.ApplicationError
+	GetIncomeAccountUserId(ctx context.Context) (exists bool, userId int, appError *models.ApplicationError)
+	RollUpFeeIncome(ctx context.Context) (feesRolledUp int64, amount int64, appError *models.ApplicationError)
+	GetPendingFeeIncome(ctx context.Context, chargedBefore time.Time) (amount int64, appError *models.ApplicationError)
+	GetFeeChargesByAccountId(ctx context.Context, accountId int, limit int) (charges []models.FeeCharge, appError *models.ApplicationError)
+}
+
+var FeeDb feeDbInterface
+
+func init() {
+	FeeDb = &feeDb{}
+}
+
+const feeScheduleColumns = `s."fee_schedule_id", s."account_type", s."fee_type", s."amount", s."minimum_balance", s."active"`
+
+const feeWaiverColumns = `w."waiver_id", w."account_id", w."fee_type", w."valid_from", w."valid_until", w."reason", w."revoked"`
+
+func scanFeeSchedule(row pgx.Row, schedule *models.FeeSchedule) error {
+	return row.Scan(&schedule.FeeScheduleId, &schedule.AccountType, &schedule.FeeType, &schedule.Amount, &schedule.MinimumBalance, &schedule.Active)
+}
+
+func (f *feeDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (f *feeDb) DeactivateFeeSchedule(ctx context.Context, tx pgx.Tx, accountType string, feeType string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE fee_schedules SET "active" = FALSE WHERE "account_type" = $1 AND "fee_type" = $2 AND "active"`
+
+	_, err := tx.Exec(ctx, sqlStatement, accountType, feeType)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeactivateFeeSchedule: Could not deactivate %s fee schedule for account type: %s! Error:%s!", feeType, accountType, err.Error())
+		displayMsg := "Could not update fee schedules!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2701, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (f *feeDb) CreateFeeSchedule(ctx context.Context, tx pgx.Tx, schedule models.FeeSchedule) (feeScheduleId int, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO fee_schedules ("account_type", "fee_type", "amount", "minimum_balance") VALUES ($1, $2, $3, $4) RETURNING fee_schedule_id;`
+
+	err := tx.QueryRow(ctx, sqlStatement, schedule.AccountType, schedule.FeeType, schedule.Amount, schedule.MinimumBalance).Scan(&feeScheduleId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateFeeSchedule: Couldn't insert fee schedule. Error:%s!", err.Error())
+		displayMsg := "Could not create fee schedule!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2702, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return feeScheduleId, nil
+}
+
+func (f *feeDb) GetFeeSchedules(ctx context.Context, activeOnly bool) (schedules []models.FeeSchedule, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + feeScheduleColumns + ` from fee_schedules s where (NOT $1 OR s."active") order by s."fee_schedule_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, activeOnly)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetFeeSchedules: Could not get fee schedules from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get fee schedules!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2703, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schedule models.FeeSchedule
+		if err := scanFeeSchedule(rows, &schedule); err != nil {
+			errMsg := fmt.Sprintf("GetFeeSchedules: Could not scan fee schedule row. Error:%s!", err.Error())
+			displayMsg := "Could not get fee schedules!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2704, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetFeeSchedules: Error while iterating fee schedule rows. Error:%s!", err.Error())
+		displayMsg := "Could not get fee schedules!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2705, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return schedules, nil
+}
+
+func (f *feeDb) GetActiveFeeSchedule(ctx context.Context, tx pgx.Tx, accountType string, feeType string) (exists bool, schedule models.FeeSchedule, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + feeScheduleColumns + ` from fee_schedules s where s."account_type" = $1 and s."fee_type" = $2 and s."active"`
+
+	err := scanFeeSchedule(tx.QueryRow(ctx, sqlStatement, accountType, feeType), &schedule)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, schedule, nil
+		}
+
+		errMsg := fmt.Sprintf("GetActiveFeeSchedule: Could not get %s fee schedule for account type: %s. Error:%s!", feeType, accountType, err.Error())
+		displayMsg := "Could not get fee schedule!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2706, errMsg, displayMsg, nil)
+		return false, schedule, appError
+	}
+
+	return true, schedule, nil
+}
+
+func (f *feeDb) CreateFeeWaiver(ctx context.Context, waiver models.FeeWaiver) (waiverId int, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO fee_waivers ("account_id", "fee_type", "valid_from", "valid_until", "reason") VALUES ($1, $2, $3, $4, $5) RETURNING waiver_id;`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, waiver.AccountId, waiver.FeeType, waiver.ValidFrom, waiver.ValidUntil, waiver.Reason).Scan(&waiverId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateFeeWaiver: Couldn't insert fee waiver for accountId: %d. Error:%s!", waiver.AccountId, err.Error())
+		displayMsg := "Could not create fee waiver!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2707, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return waiverId, nil
+}
+
+func (f *feeDb) GetFeeWaivers(ctx context.Context, accountId int) (waivers []models.FeeWaiver, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + feeWaiverColumns + ` from fee_waivers w where w."account_id" = $1 order by w."waiver_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, accountId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetFeeWaivers: Could not get fee waivers for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get fee waivers!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2708, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var waiver models.FeeWaiver
+		if err := rows.Scan(&waiver.WaiverId, &waiver.AccountId, &waiver.FeeType, &waiver.ValidFrom, &waiver.ValidUntil, &waiver.Reason, &waiver.Revoked); err != nil {
+			errMsg := fmt.Sprintf("GetFeeWaivers: Could not scan fee waiver row. Error:%s!", err.Error())
+			displayMsg := "Could not get fee waivers!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2709, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		waivers = append(waivers, waiver)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetFeeWaivers: Error while iterating fee waiver rows. Error:%s!", err.Error())
+		displayMsg := "Could not get fee waivers!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2710, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return waivers, nil
+}
+
+func (f *feeDb) RevokeFeeWaiver(ctx context.Context, waiverId int) (exists bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE fee_waivers SET "revoked" = TRUE WHERE "waiver_id" = $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, waiverId)
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeFeeWaiver: Could not revoke fee waiver: %d! Error:%s!", waiverId, err.Error())
+		displayMsg := "Could not revoke fee waiver!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2711, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// GetApplicableFeeWaiver returns the waiver covering a fee of feeType charged on
+// feeDate, a waiver without a fee type covers every fee.
+func (f *feeDb) GetApplicableFeeWaiver(ctx context.Context, tx pgx.Tx, accountId int, feeType string, feeDate time.Time) (exists bool, waiverId int, appError *models.ApplicationError) {
+
+	sqlStatement := `select w."waiver_id" from fee_waivers w where w."account_id" = $1 and NOT w."revoked"
+		and (w."fee_type" IS NULL or w."fee_type" = $2) and w."valid_from" <= $3 and (w."valid_until" IS NULL or w."valid_until" >= $3)
+		order by w."waiver_id" limit 1`
+
+	err := tx.QueryRow(ctx, sqlStatement, accountId, feeType, feeDate).Scan(&waiverId)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, 0, nil
+		}
+
+		errMsg := fmt.Sprintf("GetApplicableFeeWaiver: Could not get fee waiver for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get fee waiver!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2712, errMsg, displayMsg, nil)
+		return false, 0, appError
+	}
+
+	return true, waiverId, nil
+}
+
+func (f *feeDb) InsertFeeCharge(ctx context.Context, tx pgx.Tx, charge models.FeeCharge) (inserted bool, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO fee_charges ("account_id", "fee_type", "amount", "request_id", "linked_request_id", "period_end", "status", "waiver_id")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT ("request_id") DO NOTHING`
+
+	commandTag, err := tx.Exec(ctx, sqlStatement, charge.AccountId, charge.FeeType, charge.Amount, charge.RequestId, charge.LinkedRequestId, charge.PeriodEnd, charge.Status, charge.WaiverId)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertFeeCharge: Could not save %s fee for accountId: %d! Error:%s!", charge.FeeType, charge.AccountId, err.Error())
+		displayMsg := "Could not save fee charge!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2713, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// RecordFeeCharged marks the pending charge of a periodic fee as charged. The
+// charge is inserted when it is missing, the job that assessed the fee may not
+// have committed it yet when its message is processed. A failed charge whose
+// dropped message is replayed is charged too.
+func (f *feeDb) RecordFeeCharged(ctx context.Context, tx pgx.Tx, charge models.FeeCharge) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO fee_charges ("account_id", "fee_type", "amount", "request_id", "status") VALUES ($1, $2, $3, $4, 'charged')
+		ON CONFLICT ("request_id") DO UPDATE SET "status" = 'charged', "failure_reason" = NULL`
+
+	_, err := tx.Exec(ctx, sqlStatement, charge.AccountId, charge.FeeType, charge.Amount, charge.RequestId)
+	if err != nil {
+		errMsg := fmt.Sprintf("RecordFeeCharged: Could not mark fee charged for requestId: %s! Error:%s!", charge.RequestId.String(), err.Error())
+		displayMsg := "Could not update fee charge!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2714, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// FailFeeCharge marks a pending fee rejected by ProcessTransaction failed with the
+// reason. The fee message is sent before the job commits the pending charge, so the
+// charge is inserted as failed if the job has not committed it yet.
+func (f *feeDb) FailFeeCharge(ctx context.Context, userId int, charge models.FeeCharge) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO fee_charges ("account_id", "fee_type", "amount", "request_id", "status", "failure_reason")
+		select ac."account_id", $2, $3, $4, 'failed', $5 from accounts ac where ac."user_id" = $1
+		ON CONFLICT ("request_id") DO UPDATE SET "status" = 'failed', "failure_reason" = excluded."failure_reason" WHERE fee_charges."status" = 'pending'`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, userId, charge.FeeType, charge.Amount, charge.RequestId, charge.FailureReason)
+	if err != nil {
+		errMsg := fmt.Sprintf("FailFeeCharge: Could not mark fee failed for requestId: %s! Error:%s!", charge.RequestId.String(), err.Error())
+		displayMsg := "Could not update fee charge!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2721, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// GetIncomeAccountUserId returns the internal user the internal income account
+// belongs to.
+func (f *feeDb) GetIncomeAccountUserId(ctx context.Context) (exists bool, userId int, appError *models.ApplicationError) {
+
+	sqlStatement := `select ac."user_id" from accounts ac where ac."account_type" = 'internal_income'`
+
+	err := dbPool.QueryRow(ctx, sqlStatement).Scan(&userId)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, 0, nil
+		}
+
+		errMsg := fmt.Sprintf("GetIncomeAccountUserId: Could not get fee income account! Error:%s!", err.Error())
+		displayMsg := "Could not get fee income account!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2715, errMsg, displayMsg, nil)
+		return false, 0, appError
+	}
+
+	return true, userId, nil
+}
+
+// RollUpFeeIncome adds the fees charged since the last roll up to the internal
+// income account in one statement, so the income account row is updated once per
+// roll up instead of once per charged fee.
+func (f *feeDb) RollUpFeeIncome(ctx context.Context) (feesRolledUp int64, amount int64, appError *models.ApplicationError) {
+
+	sqlStatement := `WITH rolled AS (
+			UPDATE fee_charges SET "income_rolled_up_at" = NOW()
+			WHERE "status" = 'charged' AND "income_rolled_up_at" IS NULL
+			RETURNING "amount"
+		), total AS (
+			select count(*) AS "fees", COALESCE(sum(r."amount"), 0) AS "amount" from rolled r
+		), credited AS (
+			UPDATE accounts SET "balance" = "balance" + t."amount" FROM total t
+			WHERE "account_type" = 'internal_income' AND t."fees" > 0
+		)
+		select t."fees", t."amount" from total t`
+
+	err := dbPool.QueryRow(ctx, sqlStatement).Scan(&feesRolledUp, &amount)
+	if err != nil {
+		errMsg := fmt.Sprintf("RollUpFeeIncome: Could not roll up fee income! Error:%s!", err.Error())
+		displayMsg := "Could not credit fee income account!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2719, errMsg, displayMsg, nil)
+		return 0, 0, appError
+	}
+
+	return feesRolledUp, amount, nil
+}
+
+// GetPendingFeeIncome returns the fees charged before chargedBefore that are not
+// rolled up into the internal income account yet.
+func (f *feeDb) GetPendingFeeIncome(ctx context.Context, chargedBefore time.Time) (amount int64, appError *models.ApplicationError) {
+
+	sqlStatement := `select COALESCE(sum(c."amount"), 0) from fee_charges c
+		where c."status" = 'charged' and c."income_rolled_up_at" IS NULL and c."updated_at" < $1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, chargedBefore).Scan(&amount)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetPendingFeeIncome: Could not get pending fee income! Error:%s!", err.Error())
+		displayMsg := "Could not get fee income!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2720, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return amount, nil
+}
+
+func (f *feeDb) GetFeeChargesByAccountId(ctx context.Context, accountId int, limit int) (charges []models.FeeCharge, appError *models.ApplicationError) {
+
+	sqlStatement := `select c."account_id", c."fee_type", c."amount", c."request_id", c."linked_request_id", c."period_end", c."status", c."waiver_id", c."failure_reason", c."created_at"
+		from fee_charges c where c."account_id" = $1 order by c."created_at" desc limit $2`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, accountId, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetFeeChargesByAccountId: Could not get fee charges for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get fee charges!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2716, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var charge models.FeeCharge
+		if err := rows.Scan(&charge.AccountId, &charge.FeeType, &charge.Amount, &charge.RequestId, &charge.LinkedRequestId, &charge.PeriodEnd, &charge.Status, &charge.WaiverId, &charge.FailureReason, &charge.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetFeeChargesByAccountId: Could not scan fee charge row. Error:%s!", err.Error())
+			displayMsg := "Could not get fee charges!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2717, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		charges = append(charges, charge)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetFeeChargesByAccountId: Error while iterating fee charge rows. Error:%s!", err.Error())
+		displayMsg := "Could not get fee charges!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2718, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return charges, nil
+}
@@ -0,0 +1,335 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type interestDb struct{}
+
+type interestDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	DeactivateInterestProducts(ctx context.Context, tx pgx.Tx, accountType string) *models.ApplicationError
+	CreateInterestProduct(ctx context.Context, tx pgx.Tx, product models.InterestProduct) (productId int, appError *models.ApplicationError)
+	GetInterestProducts(ctx context.Context, activeOnly bool) (products []models.InterestProduct, appError *models.ApplicationError)
+	GetLatestAccrualDate(ctx context.Context, accountId int) (exists bool, accrualDate time.Time, appError *models.ApplicationError)
+	GetUnpostedAccruedAmount(ctx context.Context, accountId int) (accruedAmount float64, appError *models.ApplicationError)
+	InsertInterestAccrual(ctx context.Context, accrual models.InterestAccrual) *models.ApplicationError
+	LockUnpostedAccruals(ctx context.Context, tx pgx.Tx, accountId int, periodEnd time.Time) (accruedAmount float64, appError *models.ApplicationError)
+	AssignAccrualsToPosting(ctx context.Context, tx pgx.Tx, accountId int, periodEnd time.Time, requestId uuid.UUID) *models.ApplicationError
+	CompleteProcessedInterestPostings(ctx context.Context, accountId int) *models.ApplicationError
+	HasPendingInterestPosting(ctx context.Context, tx pgx.Tx, accountId int) (pending bool, appError *models.ApplicationError)
+	GetFailedInterestPostingCount(ctx context.Context, tx pgx.Tx, accountId int, periodEnd time.Time) (failedCount int, appError *models.ApplicationError)
+	GetCarriedInterestRemainder(ctx context.Context, tx pgx.Tx, accountId int) (remainder float64, appError *models.ApplicationError)
+	CreateInterestPosting(ctx context.Context, tx pgx.Tx, posting models.InterestPosting) *models.ApplicationError
+	CompleteInterestPosting(ctx context.Context, tx pgx.Tx, requestId uuid.UUID) *models.ApplicationError
+	FailInterestPosting(ctx context.Context, requestId uuid.UUID) *models.ApplicationError
+}
+
+var InterestDb interestDbInterface
+
+func init() {
+	InterestDb = &interestDb{}
+}
+
+const interestProductColumns = `p."product_id", p."name", p."account_type", p."annual_rate", p."accrual_basis", p."compounding_frequency", p."posting_frequency", p."effective_from", p."active"`
+
+func (i *interestDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (i *interestDb) DeactivateInterestProducts(ctx context.Context, tx pgx.Tx, accountType string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE interest_products SET "active" = FALSE WHERE "account_type" = $1 AND "active"`
+
+	_, err := tx.Exec(ctx, sqlStatement, accountType)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeactivateInterestProducts: Could not deactivate interest products for account type: %s! Error:%s!", accountType, err.Error())
+		displayMsg := "Could not update interest products!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2601, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (i *interestDb) CreateInterestProduct(ctx context.Context, tx pgx.Tx, product models.InterestProduct) (productId int, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO interest_products ("name", "account_type", "annual_rate", "accrual_basis", "compounding_frequency", "posting_frequency", "effective_from")
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING product_id;`
+
+	err := tx.QueryRow(ctx, sqlStatement, product.Name, product.AccountType, product.AnnualRate, product.AccrualBasis, product.CompoundingFrequency, product.PostingFrequency, product.EffectiveFrom).Scan(&productId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateInterestProduct: Couldn't insert interest product. Error:%s!", err.Error())
+		displayMsg := "Could not create interest product!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2602, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return productId, nil
+}
+
+func (i *interestDb) GetInterestProducts(ctx context.Context, activeOnly bool) (products []models.InterestProduct, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + interestProductColumns + ` from interest_products p where (NOT $1 OR p."active") order by p."product_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, activeOnly)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetInterestProducts: Could not get interest products from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get interest products!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2603, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.InterestProduct
+		if err := rows.Scan(&product.ProductId, &product.Name, &product.AccountType, &product.AnnualRate, &product.AccrualBasis, &product.CompoundingFrequency, &product.PostingFrequency, &product.EffectiveFrom, &product.Active); err != nil {
+			errMsg := fmt.Sprintf("GetInterestProducts: Could not scan interest product row. Error:%s!", err.Error())
+			displayMsg := "Could not get interest products!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2604, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetInterestProducts: Error while iterating interest product rows. Error:%s!", err.Error())
+		displayMsg := "Could not get interest products!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2605, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return products, nil
+}
+
+func (i *interestDb) GetLatestAccrualDate(ctx context.Context, accountId int) (exists bool, accrualDate time.Time, appError *models.ApplicationError) {
+
+	var latest *time.Time
+
+	sqlStatement := `select max(a."accrual_date") from interest_accruals a where a."account_id" = $1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, accountId).Scan(&latest)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetLatestAccrualDate: Could not get latest accrual date for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get interest accruals!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2606, errMsg, displayMsg, nil)
+		return false, accrualDate, appError
+	}
+
+	if latest == nil {
+		return false, accrualDate, nil
+	}
+
+	return true, *latest, nil
+}
+
+func (i *interestDb) GetUnpostedAccruedAmount(ctx context.Context, accountId int) (accruedAmount float64, appError *models.ApplicationError) {
+
+	sqlStatement := `select COALESCE(sum(a."accrued_amount"), 0) from interest_accruals a where a."account_id" = $1 and a."posting_request_id" IS NULL`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, accountId).Scan(&accruedAmount)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetUnpostedAccruedAmount: Could not get unposted interest for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get interest accruals!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2607, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return accruedAmount, nil
+}
+
+func (i *interestDb) InsertInterestAccrual(ctx context.Context, accrual models.InterestAccrual) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO interest_accruals ("account_id", "accrual_date", "product_id", "balance_basis", "accrued_amount") VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT ("account_id", "accrual_date") DO NOTHING`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, accrual.AccountId, accrual.AccrualDate, accrual.ProductId, accrual.BalanceBasis, accrual.AccruedAmount)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertInterestAccrual: Could not save interest accrual for accountId: %d! Error:%s!", accrual.AccountId, err.Error())
+		displayMsg := "Could not save interest accrual!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2608, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (i *interestDb) LockUnpostedAccruals(ctx context.Context, tx pgx.Tx, accountId int, periodEnd time.Time) (accruedAmount float64, appError *models.ApplicationError) {
+
+	sqlStatement := `select COALESCE(sum(l."accrued_amount"), 0) from (
+			select a."accrued_amount" from interest_accruals a
+			where a."account_id" = $1 and a."accrual_date" <= $2 and a."posting_request_id" IS NULL FOR UPDATE SKIP LOCKED
+		) l`
+
+	err := tx.QueryRow(ctx, sqlStatement, accountId, periodEnd).Scan(&accruedAmount)
+	if err != nil {
+		errMsg := fmt.Sprintf("LockUnpostedAccruals: Could not lock unposted interest for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get interest accruals!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2609, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return accruedAmount, nil
+}
+
+// AssignAccrualsToPosting reserves the unposted accruals up to periodEnd for the
+// posting, they count as posted once the posting is applied.
+func (i *interestDb) AssignAccrualsToPosting(ctx context.Context, tx pgx.Tx, accountId int, periodEnd time.Time, requestId uuid.UUID) *models.ApplicationError {
+
+	sqlStatement := `UPDATE interest_accruals SET "posting_request_id" = $1 WHERE "account_id" = $2 AND "accrual_date" <= $3 AND "posting_request_id" IS NULL`
+
+	_, err := tx.Exec(ctx, sqlStatement, requestId, accountId, periodEnd)
+	if err != nil {
+		errMsg := fmt.Sprintf("AssignAccrualsToPosting: Could not assign interest accruals to posting for accountId: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not update interest accruals!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2610, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// CompleteProcessedInterestPostings completes the pending postings whose request was
+// already applied, when the posting was sent again after its first commit failed the
+// duplicate is skipped by ProcessTransaction and never completes the posting.
+func (i *interestDb) CompleteProcessedInterestPostings(ctx context.Context, accountId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE interest_postings p SET "status" = 'posted'
+		WHERE p."account_id" = $1 AND p."status" = 'pending'
+		AND EXISTS (select 1 from processed_transaction_requests r where r."request_id" = p."request_id")`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, accountId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CompleteProcessedInterestPostings: Could not complete interest postings for accountId: %d! Error:%s!", accountId, err.Error())
+		displayMsg := "Could not update interest postings!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2611, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (i *interestDb) HasPendingInterestPosting(ctx context.Context, tx pgx.Tx, accountId int) (pending bool, appError *models.ApplicationError) {
+
+	sqlStatement := `select EXISTS (select 1 from interest_postings p where p."account_id" = $1 and p."status" = 'pending')`
+
+	err := tx.QueryRow(ctx, sqlStatement, accountId).Scan(&pending)
+	if err != nil {
+		errMsg := fmt.Sprintf("HasPendingInterestPosting: Could not get pending interest posting for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get interest postings!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2612, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return pending, nil
+}
+
+func (i *interestDb) GetFailedInterestPostingCount(ctx context.Context, tx pgx.Tx, accountId int, periodEnd time.Time) (failedCount int, appError *models.ApplicationError) {
+
+	sqlStatement := `select count(*) from interest_postings p where p."account_id" = $1 and p."period_end" = $2 and p."status" = 'failed'`
+
+	err := tx.QueryRow(ctx, sqlStatement, accountId, periodEnd).Scan(&failedCount)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetFailedInterestPostingCount: Could not count failed interest postings for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get interest postings!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2613, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return failedCount, nil
+}
+
+// GetCarriedInterestRemainder returns the fraction of a paisa left over by the latest
+// applied posting of the account.
+func (i *interestDb) GetCarriedInterestRemainder(ctx context.Context, tx pgx.Tx, accountId int) (remainder float64, appError *models.ApplicationError) {
+
+	sqlStatement := `select COALESCE((select p."remainder" from interest_postings p
+		where p."account_id" = $1 and p."status" = 'posted' order by p."created_at" desc limit 1), 0)`
+
+	err := tx.QueryRow(ctx, sqlStatement, accountId).Scan(&remainder)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetCarriedInterestRemainder: Could not get interest remainder for accountId: %d. Error:%s!", accountId, err.Error())
+		displayMsg := "Could not get interest postings!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2614, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return remainder, nil
+}
+
+func (i *interestDb) CreateInterestPosting(ctx context.Context, tx pgx.Tx, posting models.InterestPosting) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO interest_postings ("request_id", "account_id", "period_end", "amount", "remainder", "status") VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := tx.Exec(ctx, sqlStatement, posting.RequestId, posting.AccountId, posting.PeriodEnd, posting.Amount, posting.Remainder, posting.Status)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateInterestPosting: Could not save interest posting for accountId: %d! Error:%s!", posting.AccountId, err.Error())
+		displayMsg := "Could not save interest posting!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2615, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// CompleteInterestPosting marks the posting applied, it runs in the transaction that
+// credits the interest.
+func (i *interestDb) CompleteInterestPosting(ctx context.Context, tx pgx.Tx, requestId uuid.UUID) *models.ApplicationError {
+
+	sqlStatement := `UPDATE interest_postings SET "status" = 'posted' WHERE "request_id" = $1 AND "status" = 'pending'`
+
+	_, err := tx.Exec(ctx, sqlStatement, requestId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CompleteInterestPosting: Could not complete interest posting requestId: %s! Error:%s!", requestId.String(), err.Error())
+		displayMsg := "Could not update interest posting!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2616, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// FailInterestPosting marks a posting rejected by ProcessTransaction failed and
+// releases its accruals, so they are posted again by the next run.
+func (i *interestDb) FailInterestPosting(ctx context.Context, requestId uuid.UUID) *models.ApplicationError {
+
+	sqlStatement := `WITH failed AS (
+			UPDATE interest_postings SET "status" = 'failed' WHERE "request_id" = $1 AND "status" = 'pending' RETURNING "request_id"
+		)
+		UPDATE interest_accruals SET "posting_request_id" = NULL WHERE "posting_request_id" IN (select f."request_id" from failed f)`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, requestId)
+	if err != nil {
+		errMsg := fmt.Sprintf("FailInterestPosting: Could not release interest posting requestId: %s! Error:%s!", requestId.String(), err.Error())
+		displayMsg := "Could not update interest posting!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2617, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type limitDb struct{}
+
+type limitDbInterface interface {
+	UpsertTransactionLimit(ctx context.Context, limit models.TransactionLimit) (limitId int, appError *models.ApplicationError)
+	GetTransactionLimits(ctx context.Context) (limits []models.TransactionLimit, appError *models.ApplicationError)
+	DeleteTransactionLimit(ctx context.Context, limitId int) (exists bool, appError *models.ApplicationError)
+	GetApplicableTransactionLimits(ctx context.Context, tx pgx.Tx, userId int, accountType string, transactionType string) (limits []models.TransactionLimit, appError *models.ApplicationError)
+	GetTransactionLimitUsage(ctx context.Context, tx pgx.Tx, userId int, transactionType string, usageDate time.Time) (usage models.TransactionLimitUsage, appError *models.ApplicationError)
+	AddTransactionLimitUsage(ctx context.Context, tx pgx.Tx, userId int, transactionType string, usageDate time.Time, amount int64) *models.ApplicationError
+}
+
+var LimitDb limitDbInterface
+
+func init() {
+	LimitDb = &limitDb{}
+}
+
+const transactionLimitColumns = `l."limit_id", l."scope", l."account_type", l."user_id", l."transaction_type", l."per_transaction_amount", l."daily_amount", l."daily_count", l."monthly_amount", l."monthly_count"`
+
+func scanTransactionLimit(row pgx.Row, limit *models.TransactionLimit) error {
+	return row.Scan(&limit.LimitId, &limit.Scope, &limit.AccountType, &limit.UserId, &limit.TransactionType, &limit.PerTransactionAmount, &limit.DailyAmount, &limit.DailyCount, &limit.MonthlyAmount, &limit.MonthlyCount)
+}
+
+// UpsertTransactionLimit replaces the limit of the same scope and transaction type.
+func (l *limitDb) UpsertTransactionLimit(ctx context.Context, limit models.TransactionLimit) (limitId int, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO transaction_limits ("scope", "account_type", "user_id", "transaction_type", "per_transaction_amount", "daily_amount", "daily_count", "monthly_amount", "monthly_count")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT ("scope", COALESCE("account_type", ''), COALESCE("user_id", 0), "transaction_type") DO UPDATE SET
+			"per_transaction_amount" = EXCLUDED."per_transaction_amount", "daily_amount" = EXCLUDED."daily_amount", "daily_count" = EXCLUDED."daily_count",
+			"monthly_amount" = EXCLUDED."monthly_amount", "monthly_count" = EXCLUDED."monthly_count"
+		RETURNING limit_id;`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, limit.Scope, limit.AccountType, limit.UserId, limit.TransactionType, limit.PerTransactionAmount, limit.DailyAmount, limit.DailyCount, limit.MonthlyAmount, limit.MonthlyCount).Scan(&limitId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpsertTransactionLimit: Couldn't save %s transaction limit. Error:%s!", limit.Scope, err.Error())
+		displayMsg := "Could not save transaction limit!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2801, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return limitId, nil
+}
+
+func (l *limitDb) GetTransactionLimits(ctx context.Context) (limits []models.TransactionLimit, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + transactionLimitColumns + ` from transaction_limits l order by l."limit_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetTransactionLimits: Could not get transaction limits from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get transaction limits!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2802, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var limit models.TransactionLimit
+		if err := scanTransactionLimit(rows, &limit); err != nil {
+			errMsg := fmt.Sprintf("GetTransactionLimits: Could not scan transaction limit row. Error:%s!", err.Error())
+			displayMsg := "Could not get transaction limits!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2803, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		limits = append(limits, limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetTransactionLimits: Error while iterating transaction limit rows. Error:%s!", err.Error())
+		displayMsg := "Could not get transaction limits!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2804, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return limits, nil
+}
+
+func (l *limitDb) DeleteTransactionLimit(ctx context.Context, limitId int) (exists bool, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM transaction_limits WHERE "limit_id" = $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, limitId)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteTransactionLimit: Could not delete transaction limit: %d! Error:%s!", limitId, err.Error())
+		displayMsg := "Could not delete transaction limit!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2805, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// GetApplicableTransactionLimits returns the global, account type and user limits
+// of a transaction type, from the least to the most specific scope.
+func (l *limitDb) GetApplicableTransactionLimits(ctx context.Context, tx pgx.Tx, userId int, accountType string, transactionType string) (limits []models.TransactionLimit, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + transactionLimitColumns + ` from transaction_limits l where l."transaction_type" = $1
+		and (l."scope" = 'global' or (l."scope" = 'account_type' and l."account_type" = $2) or (l."scope" = 'user' and l."user_id" = $3))
+		order by case l."scope" when 'global' then 0 when 'account_type' then 1 else 2 end`
+
+	rows, err := tx.Query(ctx, sqlStatement, transactionType, accountType, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetApplicableTransactionLimits: Could not get transaction limits for userId: %d. Error:%s!", userId, err.Error())
+		displayMsg := "Could not get transaction limits!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2806, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var limit models.TransactionLimit
+		if err := scanTransactionLimit(rows, &limit); err != nil {
+			errMsg := fmt.Sprintf("GetApplicableTransactionLimits: Could not scan transaction limit row. Error:%s!", err.Error())
+			displayMsg := "Could not get transaction limits!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2807, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		limits = append(limits, limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetApplicableTransactionLimits: Error while iterating transaction limit rows. Error:%s!", err.Error())
+		displayMsg := "Could not get transaction limits!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2808, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return limits, nil
+}
+
+// GetTransactionLimitUsage returns what a user has already used of a transaction
+// type on usageDate and in its month up to usageDate.
+func (l *limitDb) GetTransactionLimitUsage(ctx context.Context, tx pgx.Tx, userId int, transactionType string, usageDate time.Time) (usage models.TransactionLimitUsage, appError *models.ApplicationError) {
+
+	sqlStatement := `select COALESCE(sum(u."amount") filter (where u."usage_date" = $3), 0), COALESCE(sum(u."count") filter (where u."usage_date" = $3), 0),
+			COALESCE(sum(u."amount"), 0), COALESCE(sum(u."count"), 0)
+		from transaction_limit_usage u where u."user_id" = $1 and u."transaction_type" = $2
+			and u."usage_date" >= date_trunc('month', $3::date)::date and u."usage_date" <= $3`
+
+	err := tx.QueryRow(ctx, sqlStatement, userId, transactionType, usageDate).Scan(&usage.DailyAmount, &usage.DailyCount, &usage.MonthlyAmount, &usage.MonthlyCount)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetTransactionLimitUsage: Could not get limit usage for userId: %d. Error:%s!", userId, err.Error())
+		displayMsg := "Could not get transaction limit usage!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2809, errMsg, displayMsg, nil)
+		return usage, appError
+	}
+
+	return usage, nil
+}
+
+func (l *limitDb) AddTransactionLimitUsage(ctx context.Context, tx pgx.Tx, userId int, transactionType string, usageDate time.Time, amount int64) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO transaction_limit_usage ("user_id", "transaction_type", "usage_date", "amount", "count") VALUES ($1, $2, $3, $4, 1)
+		ON CONFLICT ("user_id", "transaction_type", "usage_date") DO UPDATE SET "amount" = transaction_limit_usage."amount" + EXCLUDED."amount", "count" = transaction_limit_usage."count" + 1`
+
+	_, err := tx.Exec(ctx, sqlStatement, userId, transactionType, usageDate, amount)
+	if err != nil {
+		errMsg := fmt.Sprintf("AddTransactionLimitUsage: Could not update limit usage for userId: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not update transaction limit usage!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2810, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type loginProtectionDb struct{}
+
+type loginProtectionDbInterface interface {
+	GetLoginFailure(ctx context.Context, scope string, key string) (exists bool, failure models.LoginFailure, appError *models.ApplicationError)
+	RecordLoginFailure(ctx context.Context, scope string, key string, window time.Duration) (failure models.LoginFailure, appError *models.ApplicationError)
+	LockLogin(ctx context.Context, scope string, key string, lockedUntil time.Time) *models.ApplicationError
+	ClearLoginFailure(ctx context.Context, scope string, key string) (cleared bool, appError *models.ApplicationError)
+	DeleteStaleLoginFailures(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError)
+}
+
+var LoginProtectionDb loginProtectionDbInterface
+
+func init() {
+	LoginProtectionDb = &loginProtectionDb{}
+}
+
+func (l *loginProtectionDb) GetLoginFailure(ctx context.Context, scope string, key string) (exists bool, failure models.LoginFailure, appError *models.ApplicationError) {
+
+	sqlStatement := `select f."scope", f."key", f."failed_count", f."last_failed_at", f."locked_until" from login_failures f where f."scope" = $1 AND f."key" = $2`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, scope, key).Scan(&failure.Scope, &failure.Key, &failure.FailedCount, &failure.LastFailedAt, &failure.LockedUntil)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, failure, nil
+		}
+
+		errMsg := fmt.Sprintf("GetLoginFailure: Could not get failed logins of %s. Error:%s!", scope, err.Error())
+		displayMsg := "Could not get failed logins!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2351, errMsg, displayMsg, nil)
+		return false, failure, appError
+	}
+
+	return true, failure, nil
+}
+
+// RecordLoginFailure counts one more failed login. The count starts again when the
+// last failure is older than the window or a lockout has ended.
+func (l *loginProtectionDb) RecordLoginFailure(ctx context.Context, scope string, key string, window time.Duration) (failure models.LoginFailure, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO login_failures ("scope", "key", "failed_count", "last_failed_at") VALUES ($1, $2, 1, NOW())
+		ON CONFLICT ("scope", "key") DO UPDATE SET
+			"failed_count" = CASE WHEN login_failures."last_failed_at" < NOW() - make_interval(secs => $3) OR login_failures."locked_until" < NOW()
+				THEN 1 ELSE login_failures."failed_count" + 1 END,
+			"locked_until" = CASE WHEN login_failures."locked_until" < NOW() THEN NULL ELSE login_failures."locked_until" END,
+			"last_failed_at" = NOW()
+		RETURNING "scope", "key", "failed_count", "last_failed_at", "locked_until"`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, scope, key, window.Seconds()).Scan(&failure.Scope, &failure.Key, &failure.FailedCount, &failure.LastFailedAt, &failure.LockedUntil)
+	if err != nil {
+		errMsg := fmt.Sprintf("RecordLoginFailure: Could not save failed login of %s. Error:%s!", scope, err.Error())
+		displayMsg := "Could not save failed login!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2352, errMsg, displayMsg, nil)
+		return failure, appError
+	}
+
+	return failure, nil
+}
+
+func (l *loginProtectionDb) LockLogin(ctx context.Context, scope string, key string, lockedUntil time.Time) *models.ApplicationError {
+
+	sqlStatement := `UPDATE login_failures SET "locked_until" = $3 WHERE "scope" = $1 AND "key" = $2`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, scope, key, lockedUntil)
+	if err != nil {
+		errMsg := fmt.Sprintf("LockLogin: Could not lock login of %s. Error:%s!", scope, err.Error())
+		displayMsg := "Could not lock login!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2353, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// ClearLoginFailure forgets the failed logins, which also ends a lockout. It tells
+// whether a lockout was active.
+func (l *loginProtectionDb) ClearLoginFailure(ctx context.Context, scope string, key string) (cleared bool, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM login_failures WHERE "scope" = $1 AND "key" = $2 RETURNING COALESCE("locked_until" > NOW(), false)`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, scope, key).Scan(&cleared)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+
+		errMsg := fmt.Sprintf("ClearLoginFailure: Could not clear failed logins of %s. Error:%s!", scope, err.Error())
+		displayMsg := "Could not clear failed logins!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2354, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return cleared, nil
+}
+
+func (l *loginProtectionDb) DeleteStaleLoginFailures(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM login_failures WHERE "last_failed_at" < $1 AND ("locked_until" IS NULL OR "locked_until" < NOW())`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, before)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteStaleLoginFailures: Could not delete old failed logins. Error:%s!", err.Error())
+		displayMsg := "Could not delete old failed logins!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2355, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
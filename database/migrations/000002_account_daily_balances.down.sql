@@ -0,0 +1,9 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON account_daily_balances;
+
+  DROP index if exists "idx_daily_balance_user_date";
+
+  DROP TABLE IF EXISTS account_daily_balances;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS account_daily_balances (
+    "id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "user_id" INT NOT NULL,
+    "balance_date" DATE NOT NULL,                       -- Calendar day (UTC) the closing balance belongs to
+    "closing_balance" INT8 NOT NULL,                    -- Closing balance in paise at the end of balance_date
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_daily_balance_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE,
+    CONSTRAINT "uq_account_balance_date" UNIQUE ("account_id", "balance_date")
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON account_daily_balances FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_daily_balance_user_date ON account_daily_balances("user_id", "balance_date");
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+  DROP index if exists "idx_reconciliation_finding_run";
+
+  DROP TABLE IF EXISTS reconciliation_findings;
+  DROP TABLE IF EXISTS reconciliation_runs;
+
+COMMIT;
@@ -0,0 +1,33 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    "run_id" SERIAL PRIMARY KEY,
+    "status" VARCHAR(20) NOT NULL DEFAULT 'running',     -- running, completed or failed
+    "write_service_errors" BOOLEAN NOT NULL DEFAULT FALSE,
+    "accounts_checked" INT NOT NULL DEFAULT 0,
+    "accounts_skipped" INT NOT NULL DEFAULT 0,            -- Accounts updated while the run was in progress
+    "mismatch_count" INT NOT NULL DEFAULT 0,
+    "orphan_count" INT NOT NULL DEFAULT 0,
+    "missing_count" INT NOT NULL DEFAULT 0,
+    "error_message" TEXT,
+    "started_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "finished_at" TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS reconciliation_findings (
+    "id" SERIAL PRIMARY KEY,
+    "run_id" INT NOT NULL,
+    "finding_type" VARCHAR(30) NOT NULL,                 -- BALANCE_MISMATCH, ORPHAN_RECORD or MISSING_RECORDS
+    "account_id" INT,
+    "user_id" INT,
+    "request_id" UUID,
+    "expected_balance" INT8,                             -- Balance in paise recomputed from the transaction log
+    "actual_balance" INT8,                               -- Balance in paise stored in accounts
+    "details" TEXT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_finding_run" FOREIGN KEY("run_id") REFERENCES reconciliation_runs(run_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reconciliation_finding_run ON reconciliation_findings("run_id");
+
+COMMIT;
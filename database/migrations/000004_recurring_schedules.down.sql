@@ -0,0 +1,14 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON recurring_schedule_runs;
+  DROP TRIGGER IF EXISTS set_timestamp ON recurring_schedules;
+
+  DROP index if exists "idx_schedule_run_status";
+  DROP index if exists "idx_schedule_user_id";
+  DROP index if exists "idx_schedule_next_run";
+
+  DROP TABLE IF EXISTS recurring_schedule_runs;
+  DROP TABLE IF EXISTS recurring_schedules;
+  DROP TABLE IF EXISTS processed_transaction_requests;
+
+COMMIT;
@@ -0,0 +1,50 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS processed_transaction_requests (
+    "request_id" UUID PRIMARY KEY,                       -- RequestId of the TransactionRequestKafka message applied to the balance
+    "user_id" INT NOT NULL,
+    "processed_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS recurring_schedules (
+    "schedule_id" SERIAL PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "transaction_type" VARCHAR(20) NOT NULL,             -- deposit or withdraw
+    "amount" INT8 NOT NULL,                              -- Amount in paise
+    "frequency" VARCHAR(20) NOT NULL,                    -- daily, weekly or monthly
+    "day_of_week" INT,                                   -- 0 (Sunday) to 6 for weekly schedules
+    "day_of_month" INT,                                  -- 1 to 31 for monthly schedules, clamped to the last day of shorter months
+    "start_date" DATE NOT NULL,
+    "end_date" DATE,
+    "next_run_date" DATE,                                -- NULL once the schedule is cancelled or completed
+    "status" VARCHAR(20) NOT NULL DEFAULT 'active',      -- active, cancelled or completed
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_schedule_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE,
+    CHECK ("amount" > 0)
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON recurring_schedules FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE TABLE IF NOT EXISTS recurring_schedule_runs (
+    "run_id" SERIAL PRIMARY KEY,
+    "schedule_id" INT NOT NULL,
+    "scheduled_date" DATE NOT NULL,
+    "request_id" UUID NOT NULL UNIQUE,                   -- Derived from schedule_id and scheduled_date
+    "status" VARCHAR(20) NOT NULL,                       -- pending, queued, failed or skipped
+    "message" TEXT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_run_schedule" FOREIGN KEY("schedule_id") REFERENCES recurring_schedules(schedule_id) ON DELETE CASCADE,
+    CONSTRAINT "uq_schedule_run_date" UNIQUE ("schedule_id", "scheduled_date")
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON recurring_schedule_runs FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_schedule_next_run ON recurring_schedules("status", "next_run_date");
+CREATE INDEX idx_schedule_user_id ON recurring_schedules("user_id");
+CREATE INDEX idx_schedule_run_status ON recurring_schedule_runs("status");
+
+COMMIT;
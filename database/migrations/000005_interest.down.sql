@@ -0,0 +1,13 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON interest_products;
+
+  DROP index if exists "idx_accrual_unposted";
+  DROP index if exists "idx_interest_product_active_type";
+
+  DROP TABLE IF EXISTS interest_accruals;
+  DROP TABLE IF EXISTS interest_products;
+
+  ALTER TABLE accounts DROP COLUMN IF EXISTS "account_type";
+
+COMMIT;
@@ -0,0 +1,41 @@
+BEGIN;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS "account_type" VARCHAR(30) NOT NULL DEFAULT 'savings';   -- savings or current
+
+CREATE TABLE IF NOT EXISTS interest_products (
+    "product_id" SERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL,
+    "account_type" VARCHAR(30) NOT NULL,                 -- Account type the product applies to
+    "annual_rate" NUMERIC(7,4) NOT NULL,                 -- Annual rate in percent, 3.5 means 3.5%
+    "accrual_basis" VARCHAR(20) NOT NULL,                -- actual/365, actual/360 or actual/actual
+    "compounding_frequency" VARCHAR(20) NOT NULL,        -- daily (unposted interest earns interest) or monthly (only posted interest does)
+    "posting_frequency" VARCHAR(20) NOT NULL,            -- monthly or quarterly
+    "effective_from" DATE NOT NULL DEFAULT CURRENT_DATE,
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ("annual_rate" >= 0)
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON interest_products FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE UNIQUE INDEX idx_interest_product_active_type ON interest_products("account_type") WHERE "active";
+
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    "id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "accrual_date" DATE NOT NULL,
+    "product_id" INT NOT NULL,
+    "balance_basis" INT8 NOT NULL,                       -- Balance in paise the day's interest was computed on
+    "accrued_amount" NUMERIC(20,6) NOT NULL,             -- Interest in paise, kept fractional until it is posted
+    "posting_request_id" UUID,                           -- RequestId of the interest transaction, NULL until posted
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_accrual_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE,
+    CONSTRAINT "fk_accrual_product" FOREIGN KEY("product_id") REFERENCES interest_products(product_id),
+    CONSTRAINT "uq_account_accrual_date" UNIQUE ("account_id", "accrual_date")
+);
+
+CREATE INDEX idx_accrual_unposted ON interest_accruals("account_id") WHERE "posting_request_id" IS NULL;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON fee_charges;
+  DROP TRIGGER IF EXISTS set_timestamp ON fee_waivers;
+  DROP TRIGGER IF EXISTS set_timestamp ON fee_schedules;
+
+  DROP index if exists "idx_internal_income_account";
+  DROP index if exists "idx_fee_charge_account";
+  DROP index if exists "idx_fee_waiver_account";
+  DROP index if exists "idx_fee_schedule_active_type";
+
+  DELETE FROM users WHERE "email" = 'fee-income@internal.banking-ledger';
+
+  DROP TABLE IF EXISTS fee_charges;
+  DROP TABLE IF EXISTS fee_waivers;
+  DROP TABLE IF EXISTS fee_schedules;
+
+COMMIT;
@@ -0,0 +1,69 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    "fee_schedule_id" SERIAL PRIMARY KEY,
+    "account_type" VARCHAR(30) NOT NULL,                 -- Account type the fee applies to
+    "fee_type" VARCHAR(30) NOT NULL,                     -- withdrawal, maintenance or min_balance_penalty
+    "amount" INT8 NOT NULL,                              -- Fee in paise
+    "minimum_balance" INT8,                              -- Average daily balance in paise below which min_balance_penalty is charged
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ("amount" > 0),
+    CHECK ("fee_type" <> 'min_balance_penalty' OR "minimum_balance" IS NOT NULL)
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON fee_schedules FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE UNIQUE INDEX idx_fee_schedule_active_type ON fee_schedules("account_type", "fee_type") WHERE "active";
+
+CREATE TABLE IF NOT EXISTS fee_waivers (
+    "waiver_id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "fee_type" VARCHAR(30),                              -- NULL waives every fee type
+    "valid_from" DATE NOT NULL,
+    "valid_until" DATE,                                  -- NULL waives until the waiver is revoked
+    "reason" TEXT NOT NULL,
+    "revoked" BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_waiver_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON fee_waivers FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_fee_waiver_account ON fee_waivers("account_id") WHERE NOT "revoked";
+
+CREATE TABLE IF NOT EXISTS fee_charges (
+    "id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "fee_type" VARCHAR(30) NOT NULL,
+    "amount" INT8 NOT NULL,                              -- Fee in paise
+    "request_id" UUID NOT NULL UNIQUE,                   -- RequestId of the fee ledger entry
+    "linked_request_id" UUID,                            -- RequestId of the transaction that triggered the fee
+    "period_end" DATE,                                   -- Last day of the month a periodic fee was assessed for
+    "status" VARCHAR(20) NOT NULL,                       -- pending, charged or waived
+    "waiver_id" INT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_fee_charge_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE,
+    CONSTRAINT "fk_fee_charge_waiver" FOREIGN KEY("waiver_id") REFERENCES fee_waivers(waiver_id)
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON fee_charges FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_fee_charge_account ON fee_charges("account_id", "created_at");
+
+-- Fee revenue is credited to the account of an internal user that cannot log in.
+INSERT INTO users ("email", "password_hash", "first_name", "last_name", "role")
+VALUES ('fee-income@internal.banking-ledger', '!', 'Fee', 'Income', 'system');
+
+INSERT INTO accounts ("user_id", "balance", "account_type")
+SELECT "user_id", 0, 'internal_income' FROM users WHERE "email" = 'fee-income@internal.banking-ledger';
+
+CREATE UNIQUE INDEX idx_internal_income_account ON accounts("account_type") WHERE "account_type" = 'internal_income';
+
+COMMIT;
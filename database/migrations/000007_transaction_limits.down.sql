@@ -0,0 +1,10 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON transaction_limits;
+
+  DROP index if exists "idx_transaction_limit_scope";
+
+  DROP TABLE IF EXISTS transaction_limit_usage;
+  DROP TABLE IF EXISTS transaction_limits;
+
+COMMIT;
@@ -0,0 +1,37 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS transaction_limits (
+    "limit_id" SERIAL PRIMARY KEY,
+    "scope" VARCHAR(20) NOT NULL,                        -- global, account_type or user
+    "account_type" VARCHAR(30),                          -- Set for the account_type scope
+    "user_id" INT,                                       -- Set for the user scope
+    "transaction_type" VARCHAR(20) NOT NULL,             -- deposit or withdraw
+    "per_transaction_amount" INT8,                       -- Amounts in paise, NULL means no limit at this scope
+    "daily_amount" INT8,
+    "daily_count" INT,
+    "monthly_amount" INT8,
+    "monthly_count" INT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_limit_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE,
+    CHECK (("scope" = 'global' AND "account_type" IS NULL AND "user_id" IS NULL)
+        OR ("scope" = 'account_type' AND "account_type" IS NOT NULL AND "user_id" IS NULL)
+        OR ("scope" = 'user' AND "account_type" IS NULL AND "user_id" IS NOT NULL))
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON transaction_limits FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE UNIQUE INDEX idx_transaction_limit_scope ON transaction_limits("scope", COALESCE("account_type", ''), COALESCE("user_id", 0), "transaction_type");
+
+CREATE TABLE IF NOT EXISTS transaction_limit_usage (
+    "user_id" INT NOT NULL,
+    "transaction_type" VARCHAR(20) NOT NULL,
+    "usage_date" DATE NOT NULL,                          -- UTC day of the transaction request time
+    "amount" INT8 NOT NULL DEFAULT 0,                    -- Amount in paise of the successful transactions of the day
+    "count" INT NOT NULL DEFAULT 0,
+    PRIMARY KEY ("user_id", "transaction_type", "usage_date"),
+    CONSTRAINT "fk_limit_usage_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+  DROP index if exists "idx_status_history_account";
+  DROP index if exists "idx_account_dormancy";
+
+  DROP TABLE IF EXISTS account_status_history;
+
+  ALTER TABLE accounts DROP CONSTRAINT IF EXISTS "fk_user";
+  ALTER TABLE accounts ADD CONSTRAINT "fk_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE;
+
+  ALTER TABLE accounts DROP CONSTRAINT IF EXISTS "chk_account_status";
+  ALTER TABLE accounts DROP COLUMN IF EXISTS "closed_at";
+  ALTER TABLE accounts DROP COLUMN IF EXISTS "last_activity_at";
+  ALTER TABLE accounts DROP COLUMN IF EXISTS "status";
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS "status" VARCHAR(20) NOT NULL DEFAULT 'active';                -- active, frozen, dormant or closed
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS "last_activity_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();         -- Last customer deposit or withdrawal, drives dormancy
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS "closed_at" TIMESTAMPTZ;
+
+ALTER TABLE accounts ADD CONSTRAINT "chk_account_status" CHECK ("status" IN ('active', 'frozen', 'dormant', 'closed'));
+
+-- Deleting a user must not silently delete the account and its balance.
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS "fk_user";
+ALTER TABLE accounts ADD CONSTRAINT "fk_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_account_dormancy ON accounts("last_activity_at") WHERE "status" = 'active';
+
+CREATE TABLE IF NOT EXISTS account_status_history (
+    "id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "from_status" VARCHAR(20) NOT NULL,
+    "to_status" VARCHAR(20) NOT NULL,
+    "reason" TEXT NOT NULL,
+    "changed_by" INT,                                    -- Admin user id, NULL when changed by the dormancy job
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_status_history_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_status_history_account ON account_status_history("account_id");
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON webhook_deliveries;
+  DROP TRIGGER IF EXISTS set_timestamp ON webhook_subscriptions;
+
+  DROP index if exists "idx_webhook_attempt_delivery";
+  DROP index if exists "idx_webhook_delivery_due";
+  DROP index if exists "idx_webhook_subscription_user";
+
+  DROP TABLE IF EXISTS webhook_delivery_attempts;
+  DROP TABLE IF EXISTS webhook_deliveries;
+  DROP TABLE IF EXISTS webhook_subscriptions;
+
+COMMIT;
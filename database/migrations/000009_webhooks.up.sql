@@ -0,0 +1,54 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    "subscription_id" SERIAL PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "url" TEXT NOT NULL,
+    "event_types" TEXT[] NOT NULL,                       -- Domain event types delivered to the url
+    "secret" TEXT NOT NULL,                              -- HMAC-SHA256 signing key, kept in plain text to sign deliveries
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_webhook_subscription_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON webhook_subscriptions FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_webhook_subscription_user ON webhook_subscriptions("user_id") WHERE "active";
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    "delivery_id" SERIAL PRIMARY KEY,
+    "subscription_id" INT NOT NULL,
+    "event_id" UUID NOT NULL,
+    "event_type" VARCHAR(50) NOT NULL,
+    "payload" JSONB NOT NULL,                            -- Domain event sent as the request body
+    "status" VARCHAR(20) NOT NULL DEFAULT 'pending',     -- pending, delivered, failed or cancelled
+    "attempt_count" INT NOT NULL DEFAULT 0,              -- Attempts since the delivery was queued or redelivered
+    "next_attempt_at" TIMESTAMPTZ,                       -- NULL once the delivery is no longer pending
+    "last_attempt_at" TIMESTAMPTZ,
+    "delivered_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_webhook_delivery_subscription" FOREIGN KEY("subscription_id") REFERENCES webhook_subscriptions(subscription_id) ON DELETE CASCADE,
+    UNIQUE ("subscription_id", "event_id")
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON webhook_deliveries FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_webhook_delivery_due ON webhook_deliveries("next_attempt_at") WHERE "status" = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    "attempt_id" SERIAL PRIMARY KEY,
+    "delivery_id" INT NOT NULL,
+    "status_code" INT,                                   -- NULL when no response was received
+    "error" TEXT,
+    "duration_ms" INT NOT NULL,
+    "attempted_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_webhook_attempt_delivery" FOREIGN KEY("delivery_id") REFERENCES webhook_deliveries(delivery_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_attempt_delivery ON webhook_delivery_attempts("delivery_id");
+
+COMMIT;
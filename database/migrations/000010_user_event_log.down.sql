@@ -0,0 +1,11 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS notify_user_event ON user_event_log;
+  DROP FUNCTION IF EXISTS notify_user_event();
+
+  DROP index if exists "idx_user_event_log_created";
+  DROP index if exists "idx_user_event_log_user";
+
+  DROP TABLE IF EXISTS user_event_log;
+
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_event_log (
+    "event_seq" BIGSERIAL PRIMARY KEY,                   -- Sent as the SSE event id, clients resume after it
+    "user_id" INT NOT NULL,
+    "event_id" UUID NOT NULL,                            -- Domain event id
+    "event_type" VARCHAR(50) NOT NULL,
+    "payload" JSONB NOT NULL,                            -- Domain event as published on the events topic
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_user_event_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_event_log_user ON user_event_log("user_id", "event_seq");
+CREATE INDEX idx_user_event_log_created ON user_event_log("created_at");
+
+-- Every replica LISTENs on user_events and wakes the streams of the user, the
+-- notification is only sent once the inserting transaction commits.
+CREATE OR REPLACE FUNCTION notify_user_event()
+RETURNS TRIGGER AS $$
+BEGIN
+  PERFORM pg_notify('user_events', NEW.user_id::TEXT);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_user_event AFTER
+INSERT ON user_event_log FOR EACH ROW EXECUTE FUNCTION notify_user_event();
+
+COMMIT;
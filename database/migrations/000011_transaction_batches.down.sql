@@ -0,0 +1,12 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON transaction_batch_rows;
+  DROP TRIGGER IF EXISTS set_timestamp ON transaction_batches;
+
+  DROP index if exists "idx_batch_row_pending";
+  DROP index if exists "idx_batch_created";
+
+  DROP TABLE IF EXISTS transaction_batch_rows;
+  DROP TABLE IF EXISTS transaction_batches;
+
+COMMIT;
@@ -0,0 +1,43 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS transaction_batches (
+    "batch_id" UUID PRIMARY KEY,
+    "file_name" TEXT NOT NULL,
+    "format" VARCHAR(10) NOT NULL,                       -- csv or jsonl
+    "total_rows" INT NOT NULL,
+    "status" VARCHAR(20) NOT NULL DEFAULT 'processing',  -- processing or cancelled, completion is derived from the rows
+    "created_by" INT,                                    -- Admin user id
+    "cancelled_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_batch_created_by" FOREIGN KEY("created_by") REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON transaction_batches FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE TABLE IF NOT EXISTS transaction_batch_rows (
+    "batch_id" UUID NOT NULL,
+    "line_number" INT NOT NULL,                          -- Line of the row in the uploaded file
+    "request_id" UUID NOT NULL UNIQUE,                   -- RequestId of the TransactionRequestKafka message sent for the row
+    "user_id" INT NOT NULL,
+    "transaction_type" VARCHAR(20) NOT NULL,             -- deposit or withdraw
+    "amount" INT8 NOT NULL,                              -- Amount in paise
+    "reference" TEXT,
+    "status" VARCHAR(20) NOT NULL DEFAULT 'pending',     -- pending, queued, failed (not sent) or cancelled
+    "message" TEXT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("batch_id", "line_number"),
+    CONSTRAINT "fk_batch_row_batch" FOREIGN KEY("batch_id") REFERENCES transaction_batches(batch_id) ON DELETE CASCADE,
+    CONSTRAINT "fk_batch_row_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE,
+    CHECK ("amount" > 0)
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON transaction_batch_rows FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+CREATE INDEX idx_batch_created ON transaction_batches("created_at");
+CREATE INDEX idx_batch_row_pending ON transaction_batch_rows("batch_id", "line_number") WHERE "status" = 'pending';
+
+COMMIT;
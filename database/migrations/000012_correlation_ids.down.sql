@@ -0,0 +1,9 @@
+BEGIN;
+
+  DROP index if exists "idx_dropped_messages_correlation";
+  DROP index if exists "idx_service_errors_correlation";
+
+  ALTER TABLE kafka_topic_dropped_messages DROP COLUMN IF EXISTS "correlation_id";
+  ALTER TABLE service_errors DROP COLUMN IF EXISTS "correlation_id";
+
+COMMIT;
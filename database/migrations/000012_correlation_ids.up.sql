@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE service_errors ADD COLUMN IF NOT EXISTS "correlation_id" TEXT;                   -- Request id of the API call, Kafka message or job run
+ALTER TABLE kafka_topic_dropped_messages ADD COLUMN IF NOT EXISTS "correlation_id" TEXT;     -- correlation-id header of the dropped message
+
+CREATE INDEX idx_service_errors_correlation ON service_errors("correlation_id");
+CREATE INDEX idx_dropped_messages_correlation ON kafka_topic_dropped_messages("correlation_id");
+
+COMMIT;
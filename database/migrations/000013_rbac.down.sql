@@ -0,0 +1,18 @@
+BEGIN;
+
+  ALTER TABLE users DROP CONSTRAINT IF EXISTS "chk_user_role";
+  ALTER TABLE users ALTER COLUMN "role" SET DEFAULT 'user';
+
+  UPDATE users SET "role" = 'user' WHERE "role" NOT IN ('admin', 'system');
+
+  -- Admins downgraded by the up migration get their role back
+  UPDATE users SET "role" = 'admin' WHERE "user_id" IN (
+    SELECT "user_id" FROM user_role_changes
+    WHERE "changed_by" IS NULL AND "reason" LIKE 'Self-registered admin downgraded by migration 000013%'
+  );
+
+  DROP index if exists "idx_role_change_user";
+
+  DROP TABLE IF EXISTS user_role_changes;
+
+COMMIT;
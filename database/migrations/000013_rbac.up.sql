@@ -0,0 +1,30 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_role_changes (
+    "id" SERIAL PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "from_role" VARCHAR(50) NOT NULL,
+    "to_role" VARCHAR(50) NOT NULL,
+    "reason" TEXT NOT NULL,
+    "changed_by" INT,                                    -- Admin user id
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_role_change_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE,
+    CONSTRAINT "fk_role_change_changed_by" FOREIGN KEY("changed_by") REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_role_change_user ON user_role_changes("user_id");
+
+-- Anyone could register as admin so far, every admin is downgraded and recorded for
+-- an audit. The real admins are promoted again by hand, see Roles and Permissions.
+INSERT INTO user_role_changes ("user_id", "from_role", "to_role", "reason")
+SELECT "user_id", 'admin', 'customer', 'Self-registered admin downgraded by migration 000013, audit before promoting again'
+FROM users WHERE "role" = 'admin';
+
+-- The old default role 'user' is the customer role.
+UPDATE users SET "role" = 'customer' WHERE "role" IN ('user', 'admin');
+
+-- 'system' is the role of internal users like the fee income user, it grants nothing.
+ALTER TABLE users ALTER COLUMN "role" SET DEFAULT 'customer';
+ALTER TABLE users ADD CONSTRAINT "chk_user_role" CHECK ("role" IN ('customer', 'support', 'auditor', 'ops', 'admin', 'system'));
+
+COMMIT;
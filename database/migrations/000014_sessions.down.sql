@@ -0,0 +1,12 @@
+BEGIN;
+
+  DROP index if exists "idx_revoked_access_token_expires_at";
+  DROP index if exists "idx_refresh_token_expires_at";
+  DROP index if exists "idx_refresh_token_session";
+  DROP index if exists "idx_session_user";
+
+  DROP TABLE IF EXISTS revoked_access_tokens;
+  DROP TABLE IF EXISTS refresh_tokens;
+  DROP TABLE IF EXISTS user_sessions;
+
+COMMIT;
@@ -0,0 +1,40 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    "session_id" UUID PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "revoked_at" TIMESTAMPTZ,
+    "revoked_reason" VARCHAR(50),                       -- logout, token_reuse or admin
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_session_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_session_user ON user_sessions("user_id");
+
+-- Every refresh token is stored as the SHA-256 of the token with the access token it was issued with.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    "id" SERIAL PRIMARY KEY,
+    "session_id" UUID NOT NULL,
+    "token_hash" CHAR(64) NOT NULL UNIQUE,
+    "access_token_jti" UUID NOT NULL,
+    "access_token_expires_at" TIMESTAMPTZ NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "used_at" TIMESTAMPTZ,                              -- Set when the token is rotated, a second use is a reuse
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_refresh_token_session" FOREIGN KEY("session_id") REFERENCES user_sessions(session_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_token_session ON refresh_tokens("session_id");
+CREATE INDEX idx_refresh_token_expires_at ON refresh_tokens("expires_at");
+
+-- Denylist of access tokens revoked before they expired, checked by AuthTokenMiddleware.
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    "jti" UUID PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "revoked_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_revoked_access_token_expires_at ON revoked_access_tokens("expires_at");
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+  DROP index if exists "idx_security_event_created_at";
+  DROP index if exists "idx_security_event_user";
+  DROP index if exists "idx_login_failure_last_failed_at";
+
+  DROP TABLE IF EXISTS security_events;
+  DROP TABLE IF EXISTS login_failures;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Failed logins per normalised email and per client IP, the email is tracked whether it is registered or not.
+CREATE TABLE IF NOT EXISTS login_failures (
+    "scope" VARCHAR(10) NOT NULL,                       -- email or ip
+    "key" VARCHAR(320) NOT NULL,
+    "failed_count" INT NOT NULL DEFAULT 0,
+    "last_failed_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "locked_until" TIMESTAMPTZ,
+    PRIMARY KEY ("scope", "key")
+);
+
+CREATE INDEX idx_login_failure_last_failed_at ON login_failures("last_failed_at");
+
+-- Audit trail of security relevant events, like lockouts and unlocks.
+CREATE TABLE IF NOT EXISTS security_events (
+    "id" SERIAL PRIMARY KEY,
+    "event_type" VARCHAR(50) NOT NULL,
+    "user_id" INT,
+    "ip_address" VARCHAR(64),
+    "details" TEXT NOT NULL DEFAULT '',
+    "actor_user_id" INT,                                -- Admin that caused the event, NULL for the service itself
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_security_event_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE SET NULL,
+    CONSTRAINT "fk_security_event_actor" FOREIGN KEY("actor_user_id") REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_security_event_user ON security_events("user_id");
+CREATE INDEX idx_security_event_created_at ON security_events("created_at");
+
+COMMIT;
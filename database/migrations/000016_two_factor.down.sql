@@ -0,0 +1,13 @@
+BEGIN;
+
+  ALTER TABLE user_sessions DROP COLUMN IF EXISTS "authenticated_at";
+  ALTER TABLE user_sessions DROP COLUMN IF EXISTS "amr";
+
+  DROP index if exists "idx_login_challenge_expires_at";
+  DROP index if exists "idx_recovery_code_user";
+
+  DROP TABLE IF EXISTS login_challenges;
+  DROP TABLE IF EXISTS user_recovery_codes;
+  DROP TABLE IF EXISTS user_totp;
+
+COMMIT;
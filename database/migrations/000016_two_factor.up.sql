@@ -0,0 +1,40 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_totp (
+    "user_id" INT PRIMARY KEY,
+    "secret_encrypted" TEXT NOT NULL,                   -- AES-GCM with TOTP_ENCRYPTION_KEY
+    "enabled_at" TIMESTAMPTZ,                           -- NULL until the first code is confirmed
+    "last_used_step" BIGINT NOT NULL DEFAULT 0,         -- A code of this or an earlier time step is a replay
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_totp_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    "id" SERIAL PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "code_hash" CHAR(64) NOT NULL,
+    "used_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_recovery_code_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recovery_code_user ON user_recovery_codes("user_id");
+
+-- Second step of a login of a user with two-factor authentication.
+CREATE TABLE IF NOT EXISTS login_challenges (
+    "challenge_id" UUID PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "attempts" INT NOT NULL DEFAULT 0,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "used_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_login_challenge_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_login_challenge_expires_at ON login_challenges("expires_at");
+
+-- How the user of a session authenticated, refreshed tokens keep it.
+ALTER TABLE user_sessions ADD COLUMN "amr" TEXT[] NOT NULL DEFAULT '{pwd}';
+ALTER TABLE user_sessions ADD COLUMN "authenticated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+COMMIT;
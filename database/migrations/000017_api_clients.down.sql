@@ -0,0 +1,8 @@
+BEGIN;
+
+  DROP index if exists "idx_api_key_client";
+
+  DROP TABLE IF EXISTS api_keys;
+  DROP TABLE IF EXISTS api_clients;
+
+COMMIT;
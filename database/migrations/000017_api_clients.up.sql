@@ -0,0 +1,33 @@
+BEGIN;
+
+-- Partners calling the user APIs, every partner gets its own keys.
+CREATE TABLE IF NOT EXISTS api_clients (
+    "client_id" SERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL UNIQUE,
+    "scopes" TEXT[] NOT NULL,                           -- User APIs the keys of the client may call
+    "created_by" INT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_api_client_created_by" FOREIGN KEY("created_by") REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- Only the SHA-256 of a key is stored, the prefix finds the key without its secret part.
+CREATE TABLE IF NOT EXISTS api_keys (
+    "key_id" UUID PRIMARY KEY,
+    "client_id" INT NOT NULL,
+    "name" VARCHAR(100) NOT NULL,
+    "key_prefix" VARCHAR(32) NOT NULL UNIQUE,
+    "key_hash" CHAR(64) NOT NULL,
+    "expires_at" TIMESTAMPTZ,                           -- NULL never expires
+    "last_used_at" TIMESTAMPTZ,
+    "revoked_at" TIMESTAMPTZ,
+    "revoked_by" INT,
+    "created_by" INT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_api_key_client" FOREIGN KEY("client_id") REFERENCES api_clients(client_id) ON DELETE CASCADE,
+    CONSTRAINT "fk_api_key_revoked_by" FOREIGN KEY("revoked_by") REFERENCES users(user_id) ON DELETE SET NULL,
+    CONSTRAINT "fk_api_key_created_by" FOREIGN KEY("created_by") REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_api_key_client ON api_keys("client_id");
+
+COMMIT;
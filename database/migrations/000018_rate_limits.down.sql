@@ -0,0 +1,7 @@
+BEGIN;
+
+  DROP index if exists "idx_rate_limit_bucket_full_at";
+
+  DROP TABLE IF EXISTS rate_limit_buckets;
+
+COMMIT;
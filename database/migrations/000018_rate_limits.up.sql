@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Token buckets of the postgres rate limit store, shared by every replica.
+-- A bucket without a row is full, so rows past full_at are deleted.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    "bucket_key" VARCHAR(200) PRIMARY KEY,              -- <policy>:<key type>:<user id, api key id or ip>
+    "tokens" DOUBLE PRECISION NOT NULL,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "full_at" TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_bucket_full_at ON rate_limit_buckets("full_at");
+
+COMMIT;
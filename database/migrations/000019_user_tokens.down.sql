@@ -0,0 +1,11 @@
+BEGIN;
+
+  DROP index if exists "idx_user_token_expires_at";
+
+  DROP index if exists "idx_user_token_user_purpose";
+
+  DROP TABLE IF EXISTS user_tokens;
+
+  ALTER TABLE users DROP COLUMN IF EXISTS "email_verified_at";
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- Users registered before email verification keep working, they count as verified.
+ALTER TABLE users ADD COLUMN "email_verified_at" TIMESTAMPTZ;
+UPDATE users SET "email_verified_at" = "created_at";
+
+-- Email verification and password reset tokens. The token handed out is signed with
+-- USER_TOKEN_SIGNING_KEY, the row makes it single use.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    "token_id" UUID PRIMARY KEY,
+    "user_id" INT NOT NULL,
+    "purpose" VARCHAR(30) NOT NULL,                     -- email_verification or password_reset
+    "email" VARCHAR(255) NOT NULL,                      -- Address the token was sent to
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "used_at" TIMESTAMPTZ,                              -- Also set on unused tokens when a newer one is issued or one is used
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_user_token_user" FOREIGN KEY("user_id") REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_token_user_purpose ON user_tokens("user_id", "purpose");
+CREATE INDEX idx_user_token_expires_at ON user_tokens("expires_at");
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+  DROP TABLE IF EXISTS daily_balance_recomputes;
+
+COMMIT;
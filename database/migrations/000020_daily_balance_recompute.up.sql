@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Accounts whose closed daily balances are stale because a transaction landed on a
+-- day that was already closed. The daily balance job recomputes from from_date.
+CREATE TABLE IF NOT EXISTS daily_balance_recomputes (
+    "account_id" INT PRIMARY KEY,
+    "from_date" DATE NOT NULL,                          -- Earliest day to recompute
+    "marked_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),     -- Changes with every mark, the job only clears the mark it read
+    CONSTRAINT "fk_recompute_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+COMMIT;
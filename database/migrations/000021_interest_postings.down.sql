@@ -0,0 +1,9 @@
+BEGIN;
+
+  DROP TRIGGER IF EXISTS set_timestamp ON interest_postings;
+
+  DROP index if exists "idx_interest_posting_pending";
+
+  DROP TABLE IF EXISTS interest_postings;
+
+COMMIT;
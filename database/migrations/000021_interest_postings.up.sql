@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS interest_postings (
+    "request_id" UUID PRIMARY KEY,                       -- RequestId of the interest transaction
+    "account_id" INT NOT NULL,
+    "period_end" DATE NOT NULL,
+    "amount" INT8 NOT NULL,                              -- Interest in paise sent for posting
+    "remainder" NUMERIC(20,6) NOT NULL DEFAULT 0,        -- Fraction of a paisa carried to the next posting
+    "status" VARCHAR(20) NOT NULL DEFAULT 'pending',     -- pending until ProcessTransaction applies it, then posted or failed
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "fk_posting_account" FOREIGN KEY("account_id") REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER set_timestamp BEFORE
+UPDATE ON interest_postings FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+-- An account has at most one posting waiting for ProcessTransaction
+CREATE UNIQUE INDEX idx_interest_posting_pending ON interest_postings("account_id") WHERE "status" = 'pending';
+
+-- Postings made before this table existed were marked posted when they were sent
+INSERT INTO interest_postings ("request_id", "account_id", "period_end", "amount", "status")
+    SELECT a."posting_request_id", a."account_id", MAX(a."accrual_date"), ROUND(SUM(a."accrued_amount")), 'posted'
+    FROM interest_accruals a
+    WHERE a."posting_request_id" IS NOT NULL
+    GROUP BY a."posting_request_id", a."account_id"
+ON CONFLICT ("request_id") DO NOTHING;
+
+COMMIT;
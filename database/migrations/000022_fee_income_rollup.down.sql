@@ -0,0 +1,8 @@
+BEGIN;
+
+  DROP index if exists "idx_fee_charge_income_pending";
+
+  -- Fee income not rolled up yet is lost from the internal income account balance
+  ALTER TABLE fee_charges DROP COLUMN IF EXISTS "income_rolled_up_at";
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Fee income is rolled up into the internal income account periodically instead of
+-- updating its balance with every charged fee.
+ALTER TABLE fee_charges ADD COLUMN IF NOT EXISTS "income_rolled_up_at" TIMESTAMPTZ;   -- NULL until the fee is added to the internal income account
+
+-- Fees charged before this migration were credited to the income account directly
+UPDATE fee_charges SET "income_rolled_up_at" = "updated_at" WHERE "status" = 'charged';
+
+CREATE INDEX idx_fee_charge_income_pending ON fee_charges("id") WHERE "status" = 'charged' AND "income_rolled_up_at" IS NULL;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+  -- Failed fees go back to pending, as before the migration
+  UPDATE fee_charges SET "status" = 'pending' WHERE "status" = 'failed';
+
+  ALTER TABLE fee_charges DROP COLUMN IF EXISTS "failure_reason";
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- A monthly fee rejected by ProcessTransaction (balance, account status or limits)
+-- is marked failed instead of staying pending.
+ALTER TABLE fee_charges ADD COLUMN IF NOT EXISTS "failure_reason" TEXT;   -- Why the fee transaction was rejected, set with status failed
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+  DROP index if exists "idx_schedule_run_due";
+
+  ALTER TABLE recurring_schedule_runs DROP COLUMN IF EXISTS "next_attempt_at";
+  ALTER TABLE recurring_schedule_runs DROP COLUMN IF EXISTS "attempt_count";
+
+COMMIT;
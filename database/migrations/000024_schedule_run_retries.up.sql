@@ -0,0 +1,12 @@
+BEGIN;
+
+-- A run whose message could not be sent stays pending and is sent again with backoff
+-- until RECURRING_SCHEDULE_MAX_ATTEMPTS sends failed.
+ALTER TABLE recurring_schedule_runs ADD COLUMN IF NOT EXISTS "attempt_count" INT NOT NULL DEFAULT 0;   -- Failed sends of the run
+ALTER TABLE recurring_schedule_runs ADD COLUMN IF NOT EXISTS "next_attempt_at" TIMESTAMPTZ DEFAULT NOW(); -- NULL once the run is no longer pending
+
+UPDATE recurring_schedule_runs SET "next_attempt_at" = NULL WHERE "status" <> 'pending';
+
+CREATE INDEX idx_schedule_run_due ON recurring_schedule_runs("next_attempt_at") WHERE "status" = 'pending';
+
+COMMIT;
@@ -11,6 +11,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 var (
@@ -24,7 +25,7 @@ func InitMongoDB() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	clientOptions := options.Client().ApplyURI(uri)
+	clientOptions := options.Client().ApplyURI(uri).SetMonitor(otelmongo.NewMonitor())
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		errMsg := fmt.Sprintf("Error connecting to MongoDB: %v", err)
@@ -60,6 +61,14 @@ func DisconnectMongoDB() {
 	}
 }
 
+func PingMongoDB(ctx context.Context) error {
+	if MongoClient == nil {
+		return fmt.Errorf("MongoDB not initialized")
+	}
+
+	return MongoClient.Ping(ctx, nil)
+}
+
 func GetCollection(collectionName string) *mongo.Collection {
 	if MongoClient == nil {
 		panic("MongoDB not initialized")
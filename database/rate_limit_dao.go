@@ -0,0 +1,82 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type rateLimitDb struct{}
+
+type rateLimitDbInterface interface {
+	TakeRateLimitToken(ctx context.Context, bucketKey string, capacity float64, refillPerSecond float64) (allowed bool, tokens float64, appError *models.ApplicationError)
+	DeleteFullRateLimitBuckets(ctx context.Context) (deleted int64, appError *models.ApplicationError)
+}
+
+var RateLimitDb rateLimitDbInterface
+
+func init() {
+	RateLimitDb = &rateLimitDb{}
+}
+
+// TakeRateLimitToken refills the bucket for the time since its last request and takes
+// one token when there is one. It returns the tokens left, or the tokens there are
+// when the request is not allowed. The database clock is used, so replicas agree.
+func (r *rateLimitDb) TakeRateLimitToken(ctx context.Context, bucketKey string, capacity float64, refillPerSecond float64) (allowed bool, tokens float64, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO rate_limit_buckets AS b ("bucket_key", "tokens", "updated_at", "full_at")
+		VALUES ($1, $2::float8 - 1, NOW(), NOW() + make_interval(secs => 1 / $3::float8))
+		ON CONFLICT ("bucket_key") DO UPDATE SET
+			"tokens" = LEAST($2::float8, b."tokens" + EXTRACT(EPOCH FROM NOW() - b."updated_at")::float8 * $3::float8) - 1,
+			"updated_at" = NOW(),
+			"full_at" = NOW() + make_interval(secs => ($2::float8 + 1 - LEAST($2::float8, b."tokens" + EXTRACT(EPOCH FROM NOW() - b."updated_at")::float8 * $3::float8)) / $3::float8)
+		WHERE LEAST($2::float8, b."tokens" + EXTRACT(EPOCH FROM NOW() - b."updated_at")::float8 * $3::float8) >= 1
+		RETURNING "tokens"`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, bucketKey, capacity, refillPerSecond).Scan(&tokens)
+	if err == nil {
+		return true, tokens, nil
+	}
+
+	if err != pgx.ErrNoRows {
+		errMsg := fmt.Sprintf("TakeRateLimitToken: Could not take token of rate limit bucket: %s. Error:%s!", bucketKey, err.Error())
+		displayMsg := "Could not check rate limit!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2751, errMsg, displayMsg, nil)
+		return false, 0, appError
+	}
+
+	// The bucket is empty, the update was skipped
+	sqlStatement = `select LEAST($2::float8, b."tokens" + EXTRACT(EPOCH FROM NOW() - b."updated_at")::float8 * $3::float8) from rate_limit_buckets b where b."bucket_key" = $1`
+
+	err = dbPool.QueryRow(ctx, sqlStatement, bucketKey, capacity, refillPerSecond).Scan(&tokens)
+	if err != nil {
+		errMsg := fmt.Sprintf("TakeRateLimitToken: Could not get tokens of rate limit bucket: %s. Error:%s!", bucketKey, err.Error())
+		displayMsg := "Could not check rate limit!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2752, errMsg, displayMsg, nil)
+		return false, 0, appError
+	}
+
+	return false, tokens, nil
+}
+
+func (r *rateLimitDb) DeleteFullRateLimitBuckets(ctx context.Context) (deleted int64, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM rate_limit_buckets WHERE "full_at" < NOW()`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteFullRateLimitBuckets: Could not delete full rate limit buckets. Error:%s!", err.Error())
+		displayMsg := "Could not delete rate limit buckets!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2753, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type reconciliationDb struct{}
+
+type reconciliationDbInterface interface {
+	CreateReconciliationRun(ctx context.Context, writeServiceErrors bool) (run models.ReconciliationRun, appError *models.ApplicationError)
+	CompleteReconciliationRun(ctx context.Context, run models.ReconciliationRun) *models.ApplicationError
+	SaveReconciliationFinding(ctx context.Context, finding models.ReconciliationFinding) *models.ApplicationError
+	GetReconciliationRuns(ctx context.Context, limit int) (runs []models.ReconciliationRun, appError *models.ApplicationError)
+	GetReconciliationRun(ctx context.Context, runId int) (exists bool, run models.ReconciliationRun, appError *models.ApplicationError)
+	GetReconciliationFindings(ctx context.Context, runId int) (findings []models.ReconciliationFinding, appError *models.ApplicationError)
+	GetProcessedRequestsBefore(ctx context.Context, processedBefore time.Time, afterRequestId uuid.UUID, limit int) (requests []models.ProcessedTransactionRequest, appError *models.ApplicationError)
+}
+
+var ReconDb reconciliationDbInterface
+
+func init() {
+	ReconDb = &reconciliationDb{}
+}
+
+const reconciliationRunColumns = `r."run_id", r."status", r."write_service_errors", r."accounts_checked", r."accounts_skipped", r."mismatch_count", r."orphan_count", r."missing_count", r."error_message", r."started_at", r."finished_at"`
+
+func scanReconciliationRun(row pgx.Row, run *models.ReconciliationRun) error {
+	return row.Scan(&run.RunId, &run.Status, &run.WriteServiceErrors, &run.AccountsChecked, &run.AccountsSkipped, &run.MismatchCount, &run.OrphanCount, &run.MissingCount, &run.ErrorMessage, &run.StartedAt, &run.FinishedAt)
+}
+
+func (r *reconciliationDb) CreateReconciliationRun(ctx context.Context, writeServiceErrors bool) (run models.ReconciliationRun, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO reconciliation_runs AS r ("write_service_errors") VALUES ($1) RETURNING ` + reconciliationRunColumns
+
+	err := scanReconciliationRun(dbPool.QueryRow(ctx, sqlStatement, writeServiceErrors), &run)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateReconciliationRun: Couldn't insert reconciliation run. Error:%s!", err.Error())
+		displayMsg := "Could not start reconciliation run!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2401, errMsg, displayMsg, nil)
+		return run, appError
+	}
+
+	return run, nil
+}
+
+func (r *reconciliationDb) CompleteReconciliationRun(ctx context.Context, run models.ReconciliationRun) *models.ApplicationError {
+
+	sqlStatement := `UPDATE reconciliation_runs SET "status" = $1, "accounts_checked" = $2, "accounts_skipped" = $3, "mismatch_count" = $4,
+		"orphan_count" = $5, "missing_count" = $6, "error_message" = $7, "finished_at" = NOW() WHERE "run_id" = $8`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, run.Status, run.AccountsChecked, run.AccountsSkipped, run.MismatchCount, run.OrphanCount, run.MissingCount, run.ErrorMessage, run.RunId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CompleteReconciliationRun: Could not update reconciliation run: %d! Error:%s!", run.RunId, err.Error())
+		displayMsg := "Could not update reconciliation run!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2402, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (r *reconciliationDb) SaveReconciliationFinding(ctx context.Context, finding models.ReconciliationFinding) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO reconciliation_findings ("run_id", "finding_type", "account_id", "user_id", "request_id", "expected_balance", "actual_balance", "details")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, finding.RunId, finding.FindingType, finding.AccountId, finding.UserId, finding.RequestId, finding.ExpectedBalance, finding.ActualBalance, finding.Details)
+	if err != nil {
+		errMsg := fmt.Sprintf("SaveReconciliationFinding: Couldn't insert reconciliation finding for run: %d. Error:%s!", finding.RunId, err.Error())
+		displayMsg := "Could not save reconciliation finding!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2403, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (r *reconciliationDb) GetReconciliationRuns(ctx context.Context, limit int) (runs []models.ReconciliationRun, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + reconciliationRunColumns + ` from reconciliation_runs r order by r."run_id" desc limit $1`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetReconciliationRuns: Could not get reconciliation runs from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get reconciliation runs!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2404, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.ReconciliationRun
+		if err := scanReconciliationRun(rows, &run); err != nil {
+			errMsg := fmt.Sprintf("GetReconciliationRuns: Could not scan reconciliation run row. Error:%s!", err.Error())
+			displayMsg := "Could not get reconciliation runs!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2405, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetReconciliationRuns: Error while iterating reconciliation run rows. Error:%s!", err.Error())
+		displayMsg := "Could not get reconciliation runs!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2406, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return runs, nil
+}
+
+func (r *reconciliationDb) GetReconciliationRun(ctx context.Context, runId int) (exists bool, run models.ReconciliationRun, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + reconciliationRunColumns + ` from reconciliation_runs r where r."run_id" = $1`
+
+	err := scanReconciliationRun(dbPool.QueryRow(ctx, sqlStatement, runId), &run)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, run, nil
+		}
+
+		errMsg := fmt.Sprintf("GetReconciliationRun: Could not get reconciliation run: %d from Database. Error:%s!", runId, err.Error())
+		displayMsg := "Could not get reconciliation run!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2407, errMsg, displayMsg, nil)
+		return false, run, appError
+	}
+
+	return true, run, nil
+}
+
+func (r *reconciliationDb) GetReconciliationFindings(ctx context.Context, runId int) (findings []models.ReconciliationFinding, appError *models.ApplicationError) {
+
+	sqlStatement := `select f."run_id", f."finding_type", f."account_id", f."user_id", f."request_id", f."expected_balance", f."actual_balance", f."details"
+		from reconciliation_findings f where f."run_id" = $1 order by f."id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, runId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetReconciliationFindings: Could not get findings for run: %d. Error:%s!", runId, err.Error())
+		displayMsg := "Could not get reconciliation findings!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2408, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var finding models.ReconciliationFinding
+		if err := rows.Scan(&finding.RunId, &finding.FindingType, &finding.AccountId, &finding.UserId, &finding.RequestId, &finding.ExpectedBalance, &finding.ActualBalance, &finding.Details); err != nil {
+			errMsg := fmt.Sprintf("GetReconciliationFindings: Could not scan finding row. Error:%s!", err.Error())
+			displayMsg := "Could not get reconciliation findings!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2409, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		findings = append(findings, finding)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetReconciliationFindings: Error while iterating finding rows. Error:%s!", err.Error())
+		displayMsg := "Could not get reconciliation findings!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2410, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return findings, nil
+}
+
+// GetProcessedRequestsBefore returns a page of the requests applied to a balance
+// before processedBefore, ordered by request id after afterRequestId.
+func (r *reconciliationDb) GetProcessedRequestsBefore(ctx context.Context, processedBefore time.Time, afterRequestId uuid.UUID, limit int) (requests []models.ProcessedTransactionRequest, appError *models.ApplicationError) {
+
+	sqlStatement := `select p."request_id", p."user_id", p."processed_at" from processed_transaction_requests p
+		where p."processed_at" < $1 AND p."request_id" > $2 order by p."request_id" limit $3`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, processedBefore, afterRequestId, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetProcessedRequestsBefore: Could not get processed transaction requests. Error:%s!", err.Error())
+		displayMsg := "Could not get processed transaction requests!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2411, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var request models.ProcessedTransactionRequest
+		if err := rows.Scan(&request.RequestId, &request.UserId, &request.ProcessedAt); err != nil {
+			errMsg := fmt.Sprintf("GetProcessedRequestsBefore: Could not scan processed transaction request row. Error:%s!", err.Error())
+			displayMsg := "Could not get processed transaction requests!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2412, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetProcessedRequestsBefore: Error while iterating processed transaction request rows. Error:%s!", err.Error())
+		displayMsg := "Could not get processed transaction requests!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2413, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type scheduleDb struct{}
+
+type scheduleDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	CreateSchedule(ctx context.Context, schedule models.RecurringSchedule) (scheduleId int, appError *models.ApplicationError)
+	GetSchedulesByUserId(ctx context.Context, userId int) (schedules []models.RecurringSchedule, appError *models.ApplicationError)
+	GetScheduleById(ctx context.Context, scheduleId int) (exists bool, schedule models.RecurringSchedule, appError *models.ApplicationError)
+	CancelSchedule(ctx context.Context, scheduleId int) *models.ApplicationError
+	GetScheduleRuns(ctx context.Context, scheduleId int) (runs []models.RecurringScheduleRun, appError *models.ApplicationError)
+	GetDueSchedulesForUpdate(ctx context.Context, tx pgx.Tx, runDate time.Time, limit int) (schedules []models.RecurringSchedule, appError *models.ApplicationError)
+	UpdateScheduleNextRun(ctx context.Context, tx pgx.Tx, scheduleId int, nextRunDate *time.Time, status string) *models.ApplicationError
+	InsertScheduleRun(ctx context.Context, tx pgx.Tx, run models.RecurringScheduleRun) *models.ApplicationError
+	GetPendingScheduleRunsForUpdate(ctx context.Context, tx pgx.Tx, limit int) (runs []models.RecurringScheduleRun, appError *models.ApplicationError)
+	UpdateScheduleRunResult(ctx context.Context, tx pgx.Tx, run models.RecurringScheduleRun) *models.ApplicationError
+}
+
+var SchedDb scheduleDbInterface
+
+func init() {
+	SchedDb = &scheduleDb{}
+}
+
+const scheduleColumns = `s."schedule_id", s."user_id", s."transaction_type", s."amount", s."frequency", s."day_of_week", s."day_of_month", s."start_date", s."end_date", s."next_run_date", s."status"`
+
+const scheduleRunColumns = `r."run_id", r."schedule_id", r."scheduled_date", r."request_id", r."status", r."message", r."attempt_count", r."next_attempt_at"`
+
+func scanSchedule(row pgx.Row, schedule *models.RecurringSchedule) error {
+	return row.Scan(&schedule.ScheduleId, &schedule.UserId, &schedule.TransactionType, &schedule.Amount, &schedule.Frequency, &schedule.DayOfWeek, &schedule.DayOfMonth, &schedule.StartDate, &schedule.EndDate, &schedule.NextRunDate, &schedule.Status)
+}
+
+func scanScheduleRun(row pgx.Row, run *models.RecurringScheduleRun) error {
+	return row.Scan(&run.RunId, &run.ScheduleId, &run.ScheduledDate, &run.RequestId, &run.Status, &run.Message, &run.AttemptCount, &run.NextAttemptAt)
+}
+
+func (s *scheduleDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (s *scheduleDb) CreateSchedule(ctx context.Context, schedule models.RecurringSchedule) (scheduleId int, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO recurring_schedules ("user_id", "transaction_type", "amount", "frequency", "day_of_week", "day_of_month", "start_date", "end_date", "next_run_date", "status")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING schedule_id;`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, schedule.UserId, schedule.TransactionType, schedule.Amount, schedule.Frequency, schedule.DayOfWeek, schedule.DayOfMonth, schedule.StartDate, schedule.EndDate, schedule.NextRunDate, schedule.Status).Scan(&scheduleId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateSchedule: Couldn't insert recurring schedule. Error:%s!", err.Error())
+		displayMsg := fmt.Sprintf("Could not create recurring schedule for userId: %d", schedule.UserId)
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2501, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return scheduleId, nil
+}
+
+func (s *scheduleDb) GetSchedulesByUserId(ctx context.Context, userId int) (schedules []models.RecurringSchedule, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + scheduleColumns + ` from recurring_schedules s where s."user_id" = $1 order by s."schedule_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetSchedulesByUserId: Could not get recurring schedules from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get recurring schedules for the user!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2502, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schedule models.RecurringSchedule
+		if err := scanSchedule(rows, &schedule); err != nil {
+			errMsg := fmt.Sprintf("GetSchedulesByUserId: Could not scan recurring schedule row. Error:%s!", err.Error())
+			displayMsg := "Could not get recurring schedules for the user!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2503, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetSchedulesByUserId: Error while iterating recurring schedule rows. Error:%s!", err.Error())
+		displayMsg := "Could not get recurring schedules for the user!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2504, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return schedules, nil
+}
+
+func (s *scheduleDb) GetScheduleById(ctx context.Context, scheduleId int) (exists bool, schedule models.RecurringSchedule, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + scheduleColumns + ` from recurring_schedules s where s."schedule_id" = $1`
+
+	err := scanSchedule(dbPool.QueryRow(ctx, sqlStatement, scheduleId), &schedule)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, schedule, nil
+		}
+
+		errMsg := fmt.Sprintf("GetScheduleById: Could not get recurring schedule: %d from Database. Error:%s!", scheduleId, err.Error())
+		displayMsg := "Could not get recurring schedule!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2505, errMsg, displayMsg, nil)
+		return false, schedule, appError
+	}
+
+	return true, schedule, nil
+}
+
+func (s *scheduleDb) CancelSchedule(ctx context.Context, scheduleId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE recurring_schedules SET "status" = 'cancelled', "next_run_date" = NULL WHERE "schedule_id" = $1 AND "status" = 'active'`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, scheduleId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CancelSchedule: Could not cancel recurring schedule: %d! Error:%s!", scheduleId, err.Error())
+		displayMsg := "Could not cancel recurring schedule!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2506, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (s *scheduleDb) GetScheduleRuns(ctx context.Context, scheduleId int) (runs []models.RecurringScheduleRun, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + scheduleRunColumns + ` from recurring_schedule_runs r where r."schedule_id" = $1 order by r."scheduled_date" desc`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, scheduleId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetScheduleRuns: Could not get runs for schedule: %d. Error:%s!", scheduleId, err.Error())
+		displayMsg := "Could not get recurring schedule runs!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2507, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.RecurringScheduleRun
+		if err := scanScheduleRun(rows, &run); err != nil {
+			errMsg := fmt.Sprintf("GetScheduleRuns: Could not scan schedule run row. Error:%s!", err.Error())
+			displayMsg := "Could not get recurring schedule runs!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2508, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetScheduleRuns: Error while iterating schedule run rows. Error:%s!", err.Error())
+		displayMsg := "Could not get recurring schedule runs!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2509, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return runs, nil
+}
+
+// GetDueSchedulesForUpdate locks the active schedules due on or before runDate. Rows
+// locked by another scheduler instance are skipped.
+func (s *scheduleDb) GetDueSchedulesForUpdate(ctx context.Context, tx pgx.Tx, runDate time.Time, limit int) (schedules []models.RecurringSchedule, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + scheduleColumns + ` from recurring_schedules s where s."status" = 'active' and s."next_run_date" <= $1
+		order by s."next_run_date" limit $2 FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, sqlStatement, runDate, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetDueSchedulesForUpdate: Could not get due recurring schedules from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get due recurring schedules!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2510, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schedule models.RecurringSchedule
+		if err := scanSchedule(rows, &schedule); err != nil {
+			errMsg := fmt.Sprintf("GetDueSchedulesForUpdate: Could not scan recurring schedule row. Error:%s!", err.Error())
+			displayMsg := "Could not get due recurring schedules!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2511, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetDueSchedulesForUpdate: Error while iterating recurring schedule rows. Error:%s!", err.Error())
+		displayMsg := "Could not get due recurring schedules!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2512, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return schedules, nil
+}
+
+func (s *scheduleDb) UpdateScheduleNextRun(ctx context.Context, tx pgx.Tx, scheduleId int, nextRunDate *time.Time, status string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE recurring_schedules SET "next_run_date" = $1, "status" = $2 WHERE "schedule_id" = $3`
+
+	_, err := tx.Exec(ctx, sqlStatement, nextRunDate, status, scheduleId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateScheduleNextRun: Could not update next run of schedule: %d! Error:%s!", scheduleId, err.Error())
+		displayMsg := "Could not update recurring schedule!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2513, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// InsertScheduleRun records a run once, a run already recorded for the same
+// schedule and date is left untouched.
+func (s *scheduleDb) InsertScheduleRun(ctx context.Context, tx pgx.Tx, run models.RecurringScheduleRun) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO recurring_schedule_runs ("schedule_id", "scheduled_date", "request_id", "status", "message") VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`
+
+	_, err := tx.Exec(ctx, sqlStatement, run.ScheduleId, run.ScheduledDate, run.RequestId, run.Status, run.Message)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertScheduleRun: Couldn't insert run for schedule: %d. Error:%s!", run.ScheduleId, err.Error())
+		displayMsg := "Could not save recurring schedule run!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2514, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (s *scheduleDb) GetPendingScheduleRunsForUpdate(ctx context.Context, tx pgx.Tx, limit int) (runs []models.RecurringScheduleRun, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + scheduleRunColumns + ` from recurring_schedule_runs r where r."status" = 'pending' and r."next_attempt_at" <= NOW()
+		order by r."run_id" limit $1 FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, sqlStatement, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetPendingScheduleRunsForUpdate: Could not get pending schedule runs. Error:%s!", err.Error())
+		displayMsg := "Could not get pending schedule runs!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2515, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.RecurringScheduleRun
+		if err := scanScheduleRun(rows, &run); err != nil {
+			errMsg := fmt.Sprintf("GetPendingScheduleRunsForUpdate: Could not scan schedule run row. Error:%s!", err.Error())
+			displayMsg := "Could not get pending schedule runs!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2516, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetPendingScheduleRunsForUpdate: Error while iterating schedule run rows. Error:%s!", err.Error())
+		displayMsg := "Could not get pending schedule runs!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2517, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return runs, nil
+}
+
+func (s *scheduleDb) UpdateScheduleRunResult(ctx context.Context, tx pgx.Tx, run models.RecurringScheduleRun) *models.ApplicationError {
+
+	sqlStatement := `UPDATE recurring_schedule_runs SET "status" = $1, "message" = $2, "attempt_count" = $3, "next_attempt_at" = $4 WHERE "run_id" = $5`
+
+	_, err := tx.Exec(ctx, sqlStatement, run.Status, run.Message, run.AttemptCount, run.NextAttemptAt, run.RunId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateScheduleRunResult: Could not update schedule run: %d! Error:%s!", run.RunId, err.Error())
+		displayMsg := "Could not update recurring schedule run!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2518, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
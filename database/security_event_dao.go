@@ -0,0 +1,76 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+)
+
+type securityEventDb struct{}
+
+type securityEventDbInterface interface {
+	InsertSecurityEvent(ctx context.Context, event models.SecurityEvent) *models.ApplicationError
+	GetSecurityEventsByUserId(ctx context.Context, userId int, limit int) (events []models.SecurityEvent, appError *models.ApplicationError)
+}
+
+var SecurityEventDb securityEventDbInterface
+
+func init() {
+	SecurityEventDb = &securityEventDb{}
+}
+
+func (s *securityEventDb) InsertSecurityEvent(ctx context.Context, event models.SecurityEvent) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO security_events ("event_type", "user_id", "ip_address", "details", "actor_user_id") VALUES ($1, $2, NULLIF($3, ''), $4, $5)`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, event.EventType, event.UserId, event.IpAddress, event.Details, event.ActorUserId)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertSecurityEvent: Could not save %s event. Error:%s!", event.EventType, err.Error())
+		displayMsg := "Could not save security event!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2451, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (s *securityEventDb) GetSecurityEventsByUserId(ctx context.Context, userId int, limit int) (events []models.SecurityEvent, appError *models.ApplicationError) {
+
+	sqlStatement := `select e."id", e."event_type", e."user_id", COALESCE(e."ip_address", ''), e."details", e."actor_user_id", e."created_at"
+		from security_events e where e."user_id" = $1 order by e."id" desc LIMIT $2`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, userId, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetSecurityEventsByUserId: Could not get security events of user: %d. Error:%s!", userId, err.Error())
+		displayMsg := "Could not get security events!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2452, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.SecurityEvent
+		if err := rows.Scan(&event.Id, &event.EventType, &event.UserId, &event.IpAddress, &event.Details, &event.ActorUserId, &event.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetSecurityEventsByUserId: Could not scan security event row. Error:%s!", err.Error())
+			displayMsg := "Could not get security events!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2453, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetSecurityEventsByUserId: Error while iterating security event rows. Error:%s!", err.Error())
+		displayMsg := "Could not get security events!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2454, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return events, nil
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type sessionDb struct{}
+
+type sessionDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	CreateSession(ctx context.Context, tx pgx.Tx, session models.Session) *models.ApplicationError
+	InsertRefreshToken(ctx context.Context, tx pgx.Tx, sessionId uuid.UUID, tokenHash string, accessTokenJti string, accessTokenExpiresAt time.Time, expiresAt time.Time) *models.ApplicationError
+	GetRefreshTokenByHashForUpdate(ctx context.Context, tx pgx.Tx, tokenHash string) (exists bool, token models.RefreshToken, appError *models.ApplicationError)
+	MarkRefreshTokenUsed(ctx context.Context, tx pgx.Tx, tokenId int) *models.ApplicationError
+	RevokeSessions(ctx context.Context, tx pgx.Tx, userId int, sessionId *uuid.UUID, reason string) (revoked int64, appError *models.ApplicationError)
+	IsAccessTokenRevoked(ctx context.Context, jti string) (revoked bool, appError *models.ApplicationError)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError)
+}
+
+var SessionDb sessionDbInterface
+
+func init() {
+	SessionDb = &sessionDb{}
+}
+
+func (s *sessionDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (s *sessionDb) CreateSession(ctx context.Context, tx pgx.Tx, session models.Session) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO user_sessions ("session_id", "user_id", "amr", "authenticated_at") VALUES ($1, $2, $3, $4)`
+
+	_, err := tx.Exec(ctx, sqlStatement, session.SessionId, session.UserId, session.Amr, session.AuthenticatedAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateSession: Could not create session for user: %d! Error:%s!", session.UserId, err.Error())
+		displayMsg := "Could not create session!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2251, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (s *sessionDb) InsertRefreshToken(ctx context.Context, tx pgx.Tx, sessionId uuid.UUID, tokenHash string, accessTokenJti string, accessTokenExpiresAt time.Time, expiresAt time.Time) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO refresh_tokens ("session_id", "token_hash", "access_token_jti", "access_token_expires_at", "expires_at") VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.Exec(ctx, sqlStatement, sessionId, tokenHash, accessTokenJti, accessTokenExpiresAt, expiresAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertRefreshToken: Could not save refresh token of session: %s! Error:%s!", sessionId, err.Error())
+		displayMsg := "Could not save refresh token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2252, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// GetRefreshTokenByHashForUpdate locks the token, so two refreshes with the same token
+// can not both rotate it.
+func (s *sessionDb) GetRefreshTokenByHashForUpdate(ctx context.Context, tx pgx.Tx, tokenHash string) (exists bool, token models.RefreshToken, appError *models.ApplicationError) {
+
+	sqlStatement := `select r."id", r."session_id", s."user_id", s."amr", s."authenticated_at", r."expires_at", r."used_at", s."revoked_at"
+		from refresh_tokens r join user_sessions s on s."session_id" = r."session_id"
+		where r."token_hash" = $1 FOR UPDATE OF r`
+
+	err := tx.QueryRow(ctx, sqlStatement, tokenHash).Scan(&token.Id, &token.Session.SessionId, &token.Session.UserId, &token.Session.Amr, &token.Session.AuthenticatedAt, &token.ExpiresAt, &token.UsedAt, &token.SessionRevokedAt)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, token, nil
+		}
+
+		errMsg := fmt.Sprintf("GetRefreshTokenByHashForUpdate: Could not get refresh token. Error:%s!", err.Error())
+		displayMsg := "Could not get refresh token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2253, errMsg, displayMsg, nil)
+		return false, token, appError
+	}
+
+	return true, token, nil
+}
+
+func (s *sessionDb) MarkRefreshTokenUsed(ctx context.Context, tx pgx.Tx, tokenId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE refresh_tokens SET "used_at" = NOW() WHERE "id" = $1`
+
+	_, err := tx.Exec(ctx, sqlStatement, tokenId)
+	if err != nil {
+		errMsg := fmt.Sprintf("MarkRefreshTokenUsed: Could not mark refresh token: %d as used! Error:%s!", tokenId, err.Error())
+		displayMsg := "Could not update refresh token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2254, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes one session of the user, or every session when sessionId is
+// nil, and adds the access tokens of those sessions that have not expired yet to the
+// denylist.
+func (s *sessionDb) RevokeSessions(ctx context.Context, tx pgx.Tx, userId int, sessionId *uuid.UUID, reason string) (revoked int64, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE user_sessions SET "revoked_at" = NOW(), "revoked_reason" = $3
+		WHERE "user_id" = $1 AND ($2::uuid IS NULL OR "session_id" = $2) AND "revoked_at" IS NULL`
+
+	commandTag, err := tx.Exec(ctx, sqlStatement, userId, sessionId, reason)
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeSessions: Could not revoke sessions of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not revoke sessions!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2255, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	sqlStatement = `INSERT INTO revoked_access_tokens ("jti", "user_id", "expires_at")
+		SELECT r."access_token_jti", s."user_id", r."access_token_expires_at"
+		from refresh_tokens r join user_sessions s on s."session_id" = r."session_id"
+		where s."user_id" = $1 AND ($2::uuid IS NULL OR s."session_id" = $2) AND r."access_token_expires_at" > NOW()
+		ON CONFLICT ("jti") DO NOTHING`
+
+	_, err = tx.Exec(ctx, sqlStatement, userId, sessionId)
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeSessions: Could not revoke access tokens of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not revoke sessions!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2256, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+func (s *sessionDb) IsAccessTokenRevoked(ctx context.Context, jti string) (revoked bool, appError *models.ApplicationError) {
+
+	sqlStatement := `select exists (select 1 from revoked_access_tokens where "jti" = $1)`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, jti).Scan(&revoked)
+	if err != nil {
+		errMsg := fmt.Sprintf("IsAccessTokenRevoked: Could not check access token denylist. Error:%s!", err.Error())
+		displayMsg := "Could not verify token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2257, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredTokens deletes refresh tokens and denylist entries that expired before
+// the given time, an expired token is rejected anyway.
+func (s *sessionDb) DeleteExpiredTokens(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM refresh_tokens WHERE "expires_at" < $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, before)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteExpiredTokens: Could not delete expired refresh tokens. Error:%s!", err.Error())
+		displayMsg := "Could not delete expired tokens!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2258, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	deleted = commandTag.RowsAffected()
+
+	sqlStatement = `DELETE FROM revoked_access_tokens WHERE "expires_at" < $1`
+
+	commandTag, err = dbPool.Exec(ctx, sqlStatement, before)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteExpiredTokens: Could not delete expired denylist entries. Error:%s!", err.Error())
+		displayMsg := "Could not delete expired tokens!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2259, errMsg, displayMsg, nil)
+		return deleted, appError
+	}
+
+	return deleted + commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type transactionBatchDb struct{}
+
+type transactionBatchDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	CreateBatch(ctx context.Context, tx pgx.Tx, batch models.TransactionBatch) *models.ApplicationError
+	InsertBatchRow(ctx context.Context, tx pgx.Tx, row models.TransactionBatchRow) *models.ApplicationError
+	GetBatches(ctx context.Context, limit int) (batches []models.TransactionBatch, appError *models.ApplicationError)
+	GetBatchById(ctx context.Context, batchId uuid.UUID) (exists bool, batch models.TransactionBatch, appError *models.ApplicationError)
+	GetBatchRows(ctx context.Context, batchId uuid.UUID) (rows []models.TransactionBatchRow, appError *models.ApplicationError)
+	CancelBatch(ctx context.Context, tx pgx.Tx, batchId uuid.UUID) (cancelled bool, appError *models.ApplicationError)
+	CancelPendingBatchRows(ctx context.Context, tx pgx.Tx, batchId uuid.UUID) (rowsCancelled int64, appError *models.ApplicationError)
+	GetPendingBatchRowsForUpdate(ctx context.Context, tx pgx.Tx, limit int) (rows []models.TransactionBatchRow, appError *models.ApplicationError)
+	UpdateBatchRowStatus(ctx context.Context, tx pgx.Tx, requestId uuid.UUID, status string, message *string) *models.ApplicationError
+	GetBatchStatus(ctx context.Context, tx pgx.Tx, batchId uuid.UUID) (status string, appError *models.ApplicationError)
+}
+
+var BatchDb transactionBatchDbInterface
+
+func init() {
+	BatchDb = &transactionBatchDb{}
+}
+
+const batchColumns = `b."batch_id", b."file_name", b."format", b."total_rows", b."status", b."created_by", b."cancelled_at", b."created_at"`
+
+const batchRowColumns = `r."batch_id", r."line_number", r."request_id", r."user_id", r."transaction_type", r."amount", r."reference", r."status", r."message"`
+
+func scanBatch(row pgx.Row, batch *models.TransactionBatch) error {
+	return row.Scan(&batch.BatchId, &batch.FileName, &batch.Format, &batch.TotalRows, &batch.Status, &batch.CreatedBy, &batch.CancelledAt, &batch.CreatedAt)
+}
+
+func scanBatchRow(row pgx.Row, batchRow *models.TransactionBatchRow) error {
+	return row.Scan(&batchRow.BatchId, &batchRow.LineNumber, &batchRow.RequestId, &batchRow.UserId, &batchRow.TransactionType, &batchRow.Amount, &batchRow.Reference, &batchRow.Status, &batchRow.Message)
+}
+
+func (b *transactionBatchDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (b *transactionBatchDb) CreateBatch(ctx context.Context, tx pgx.Tx, batch models.TransactionBatch) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO transaction_batches ("batch_id", "file_name", "format", "total_rows", "status", "created_by") VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := tx.Exec(ctx, sqlStatement, batch.BatchId, batch.FileName, batch.Format, batch.TotalRows, batch.Status, batch.CreatedBy)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateBatch: Couldn't insert transaction batch. Error:%s!", err.Error())
+		displayMsg := "Could not create transaction batch!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2051, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (b *transactionBatchDb) InsertBatchRow(ctx context.Context, tx pgx.Tx, row models.TransactionBatchRow) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO transaction_batch_rows ("batch_id", "line_number", "request_id", "user_id", "transaction_type", "amount", "reference", "status")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := tx.Exec(ctx, sqlStatement, row.BatchId, row.LineNumber, row.RequestId, row.UserId, row.TransactionType, row.Amount, row.Reference, row.Status)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertBatchRow: Couldn't insert line %d of batch: %s. Error:%s!", row.LineNumber, row.BatchId.String(), err.Error())
+		displayMsg := "Could not create transaction batch!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2052, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (b *transactionBatchDb) GetBatches(ctx context.Context, limit int) (batches []models.TransactionBatch, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + batchColumns + ` from transaction_batches b order by b."created_at" desc limit $1`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetBatches: Could not get transaction batches from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get transaction batches!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2053, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var batch models.TransactionBatch
+		if err := scanBatch(rows, &batch); err != nil {
+			errMsg := fmt.Sprintf("GetBatches: Could not scan transaction batch row. Error:%s!", err.Error())
+			displayMsg := "Could not get transaction batches!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2054, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		batches = append(batches, batch)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetBatches: Error while iterating transaction batch rows. Error:%s!", err.Error())
+		displayMsg := "Could not get transaction batches!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2055, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return batches, nil
+}
+
+func (b *transactionBatchDb) GetBatchById(ctx context.Context, batchId uuid.UUID) (exists bool, batch models.TransactionBatch, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + batchColumns + ` from transaction_batches b where b."batch_id" = $1`
+
+	err := scanBatch(dbPool.QueryRow(ctx, sqlStatement, batchId), &batch)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, batch, nil
+		}
+
+		errMsg := fmt.Sprintf("GetBatchById: Could not get transaction batch: %s from Database. Error:%s!", batchId.String(), err.Error())
+		displayMsg := "Could not get transaction batch!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2056, errMsg, displayMsg, nil)
+		return false, batch, appError
+	}
+
+	return true, batch, nil
+}
+
+func (b *transactionBatchDb) GetBatchRows(ctx context.Context, batchId uuid.UUID) (batchRows []models.TransactionBatchRow, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + batchRowColumns + ` from transaction_batch_rows r where r."batch_id" = $1 order by r."line_number"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, batchId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetBatchRows: Could not get rows of batch: %s. Error:%s!", batchId.String(), err.Error())
+		displayMsg := "Could not get transaction batch rows!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2057, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var batchRow models.TransactionBatchRow
+		if err := scanBatchRow(rows, &batchRow); err != nil {
+			errMsg := fmt.Sprintf("GetBatchRows: Could not scan transaction batch row. Error:%s!", err.Error())
+			displayMsg := "Could not get transaction batch rows!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2058, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		batchRows = append(batchRows, batchRow)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetBatchRows: Error while iterating transaction batch rows. Error:%s!", err.Error())
+		displayMsg := "Could not get transaction batch rows!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2059, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return batchRows, nil
+}
+
+// CancelBatch marks a batch that is still processing as cancelled, cancelled is
+// false when the batch was already cancelled.
+func (b *transactionBatchDb) CancelBatch(ctx context.Context, tx pgx.Tx, batchId uuid.UUID) (cancelled bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE transaction_batches SET "status" = 'cancelled', "cancelled_at" = NOW() WHERE "batch_id" = $1 AND "status" = 'processing'`
+
+	commandTag, err := tx.Exec(ctx, sqlStatement, batchId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CancelBatch: Could not cancel transaction batch: %s! Error:%s!", batchId.String(), err.Error())
+		displayMsg := "Could not cancel transaction batch!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2060, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+func (b *transactionBatchDb) CancelPendingBatchRows(ctx context.Context, tx pgx.Tx, batchId uuid.UUID) (rowsCancelled int64, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE transaction_batch_rows SET "status" = 'cancelled', "message" = 'Batch was cancelled before the row was sent' WHERE "batch_id" = $1 AND "status" = 'pending'`
+
+	commandTag, err := tx.Exec(ctx, sqlStatement, batchId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CancelPendingBatchRows: Could not cancel rows of batch: %s! Error:%s!", batchId.String(), err.Error())
+		displayMsg := "Could not cancel transaction batch!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2061, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// GetPendingBatchRowsForUpdate locks the oldest unsent rows in file order. Rows
+// locked by another dispatcher instance are skipped.
+func (b *transactionBatchDb) GetPendingBatchRowsForUpdate(ctx context.Context, tx pgx.Tx, limit int) (batchRows []models.TransactionBatchRow, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + batchRowColumns + ` from transaction_batch_rows r where r."status" = 'pending'
+		order by r."created_at", r."batch_id", r."line_number" limit $1 FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, sqlStatement, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetPendingBatchRowsForUpdate: Could not get pending batch rows. Error:%s!", err.Error())
+		displayMsg := "Could not get pending batch rows!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2062, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var batchRow models.TransactionBatchRow
+		if err := scanBatchRow(rows, &batchRow); err != nil {
+			errMsg := fmt.Sprintf("GetPendingBatchRowsForUpdate: Could not scan transaction batch row. Error:%s!", err.Error())
+			displayMsg := "Could not get pending batch rows!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2063, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		batchRows = append(batchRows, batchRow)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetPendingBatchRowsForUpdate: Error while iterating transaction batch rows. Error:%s!", err.Error())
+		displayMsg := "Could not get pending batch rows!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2064, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return batchRows, nil
+}
+
+func (b *transactionBatchDb) UpdateBatchRowStatus(ctx context.Context, tx pgx.Tx, requestId uuid.UUID, status string, message *string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE transaction_batch_rows SET "status" = $1, "message" = $2 WHERE "request_id" = $3`
+
+	_, err := tx.Exec(ctx, sqlStatement, status, message, requestId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateBatchRowStatus: Could not update batch row: %s! Error:%s!", requestId.String(), err.Error())
+		displayMsg := "Could not update transaction batch row!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2065, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// GetBatchStatus is read inside the transaction that applies a batch row, so a
+// batch cancelled after the row was sent still stops it.
+func (b *transactionBatchDb) GetBatchStatus(ctx context.Context, tx pgx.Tx, batchId uuid.UUID) (status string, appError *models.ApplicationError) {
+
+	sqlStatement := `select "status" from transaction_batches where "batch_id" = $1`
+
+	err := tx.QueryRow(ctx, sqlStatement, batchId).Scan(&status)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+
+		errMsg := fmt.Sprintf("GetBatchStatus: Could not get status of batch: %s. Error:%s!", batchId.String(), err.Error())
+		displayMsg := "Could not get transaction batch!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2066, errMsg, displayMsg, nil)
+		return "", appError
+	}
+
+	return status, nil
+}
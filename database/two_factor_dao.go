@@ -0,0 +1,265 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type twoFactorDb struct{}
+
+type twoFactorDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	UpsertPendingTotp(ctx context.Context, userId int, secretEncrypted string) (stored bool, appError *models.ApplicationError)
+	GetUserTotp(ctx context.Context, userId int) (exists bool, totp models.UserTotp, appError *models.ApplicationError)
+	ConsumeTotpStep(ctx context.Context, userId int, step int64) (consumed bool, appError *models.ApplicationError)
+	EnableTotp(ctx context.Context, tx pgx.Tx, userId int, step int64) (enabled bool, appError *models.ApplicationError)
+	DeleteTotp(ctx context.Context, tx pgx.Tx, userId int) *models.ApplicationError
+	ReplaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userId int, codeHashes []string) *models.ApplicationError
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) (used bool, appError *models.ApplicationError)
+	CreateLoginChallenge(ctx context.Context, challengeId uuid.UUID, userId int, expiresAt time.Time) *models.ApplicationError
+	StartLoginChallengeAttempt(ctx context.Context, challengeId uuid.UUID, maxAttempts int) (exists bool, userId int, appError *models.ApplicationError)
+	CompleteLoginChallenge(ctx context.Context, challengeId uuid.UUID) (completed bool, appError *models.ApplicationError)
+	DeleteExpiredLoginChallenges(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError)
+}
+
+var TwoFactorDb twoFactorDbInterface
+
+func init() {
+	TwoFactorDb = &twoFactorDb{}
+}
+
+func (t *twoFactorDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+// UpsertPendingTotp stores a new secret that is not enabled yet. An enrolment that
+// was never confirmed is replaced, an enabled one is left as it is and stored is false.
+func (t *twoFactorDb) UpsertPendingTotp(ctx context.Context, userId int, secretEncrypted string) (stored bool, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO user_totp ("user_id", "secret_encrypted") VALUES ($1, $2)
+		ON CONFLICT ("user_id") DO UPDATE SET "secret_encrypted" = EXCLUDED."secret_encrypted", "last_used_step" = 0, "created_at" = NOW()
+		WHERE user_totp."enabled_at" IS NULL`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, userId, secretEncrypted)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpsertPendingTotp: Could not save TOTP secret of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not save TOTP secret!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2551, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+func (t *twoFactorDb) GetUserTotp(ctx context.Context, userId int) (exists bool, totp models.UserTotp, appError *models.ApplicationError) {
+
+	sqlStatement := `select t."user_id", t."secret_encrypted", t."enabled_at", t."last_used_step" from user_totp t where t."user_id" = $1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, userId).Scan(&totp.UserId, &totp.SecretEncrypted, &totp.EnabledAt, &totp.LastUsedStep)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, totp, nil
+		}
+
+		errMsg := fmt.Sprintf("GetUserTotp: Could not get TOTP of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not get TOTP details!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2552, errMsg, displayMsg, nil)
+		return false, totp, appError
+	}
+
+	return true, totp, nil
+}
+
+// ConsumeTotpStep records the time step of an accepted code. consumed is false when a
+// code of this or a later step was already used, so a code works only once.
+func (t *twoFactorDb) ConsumeTotpStep(ctx context.Context, userId int, step int64) (consumed bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE user_totp SET "last_used_step" = $2 WHERE "user_id" = $1 AND "enabled_at" IS NOT NULL AND "last_used_step" < $2`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, userId, step)
+	if err != nil {
+		errMsg := fmt.Sprintf("ConsumeTotpStep: Could not save used TOTP step of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not verify TOTP code!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2553, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+func (t *twoFactorDb) EnableTotp(ctx context.Context, tx pgx.Tx, userId int, step int64) (enabled bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE user_totp SET "enabled_at" = NOW(), "last_used_step" = $2 WHERE "user_id" = $1 AND "enabled_at" IS NULL AND "last_used_step" < $2`
+
+	commandTag, err := tx.Exec(ctx, sqlStatement, userId, step)
+	if err != nil {
+		errMsg := fmt.Sprintf("EnableTotp: Could not enable TOTP of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not enable two-factor authentication!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2554, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+// DeleteTotp removes the TOTP secret and the recovery codes of the user.
+func (t *twoFactorDb) DeleteTotp(ctx context.Context, tx pgx.Tx, userId int) *models.ApplicationError {
+
+	sqlStatement := `DELETE FROM user_totp WHERE "user_id" = $1`
+
+	_, err := tx.Exec(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteTotp: Could not delete TOTP of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not disable two-factor authentication!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2555, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	sqlStatement = `DELETE FROM user_recovery_codes WHERE "user_id" = $1`
+
+	_, err = tx.Exec(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteTotp: Could not delete recovery codes of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not disable two-factor authentication!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2556, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes deletes the recovery codes of the user, used or not, and
+// stores the new ones.
+func (t *twoFactorDb) ReplaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userId int, codeHashes []string) *models.ApplicationError {
+
+	sqlStatement := `DELETE FROM user_recovery_codes WHERE "user_id" = $1`
+
+	_, err := tx.Exec(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("ReplaceRecoveryCodes: Could not delete recovery codes of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not save recovery codes!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2557, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	sqlStatement = `INSERT INTO user_recovery_codes ("user_id", "code_hash") SELECT $1, unnest($2::text[])`
+
+	_, err = tx.Exec(ctx, sqlStatement, userId, codeHashes)
+	if err != nil {
+		errMsg := fmt.Sprintf("ReplaceRecoveryCodes: Could not save recovery codes of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not save recovery codes!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2558, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used. used is false
+// when the code is unknown or was already used.
+func (t *twoFactorDb) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (used bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE user_recovery_codes SET "used_at" = NOW() WHERE "user_id" = $1 AND "code_hash" = $2 AND "used_at" IS NULL`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, userId, codeHash)
+	if err != nil {
+		errMsg := fmt.Sprintf("UseRecoveryCode: Could not use recovery code of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not verify recovery code!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2559, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+func (t *twoFactorDb) CreateLoginChallenge(ctx context.Context, challengeId uuid.UUID, userId int, expiresAt time.Time) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO login_challenges ("challenge_id", "user_id", "expires_at") VALUES ($1, $2, $3)`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, challengeId, userId, expiresAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateLoginChallenge: Could not create login challenge for user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not start two-factor login!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2560, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// StartLoginChallengeAttempt counts an attempt at a login challenge and returns its
+// user. exists is false when the challenge is unknown, used, expired or out of attempts.
+func (t *twoFactorDb) StartLoginChallengeAttempt(ctx context.Context, challengeId uuid.UUID, maxAttempts int) (exists bool, userId int, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE login_challenges SET "attempts" = "attempts" + 1
+		WHERE "challenge_id" = $1 AND "used_at" IS NULL AND "expires_at" > NOW() AND "attempts" < $2
+		RETURNING "user_id"`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, challengeId, maxAttempts).Scan(&userId)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, 0, nil
+		}
+
+		errMsg := fmt.Sprintf("StartLoginChallengeAttempt: Could not get login challenge: %s! Error:%s!", challengeId, err.Error())
+		displayMsg := "Could not verify two-factor login!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2561, errMsg, displayMsg, nil)
+		return false, 0, appError
+	}
+
+	return true, userId, nil
+}
+
+// CompleteLoginChallenge marks the challenge used. completed is false when a parallel
+// request completed it first.
+func (t *twoFactorDb) CompleteLoginChallenge(ctx context.Context, challengeId uuid.UUID) (completed bool, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE login_challenges SET "used_at" = NOW() WHERE "challenge_id" = $1 AND "used_at" IS NULL`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, challengeId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CompleteLoginChallenge: Could not complete login challenge: %s! Error:%s!", challengeId, err.Error())
+		displayMsg := "Could not verify two-factor login!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2562, errMsg, displayMsg, nil)
+		return false, appError
+	}
+
+	return commandTag.RowsAffected() == 1, nil
+}
+
+func (t *twoFactorDb) DeleteExpiredLoginChallenges(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM login_challenges WHERE "expires_at" < $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, before)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteExpiredLoginChallenges: Could not delete expired login challenges! Error:%s!", err.Error())
+		displayMsg := "Could not delete expired login challenges!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2563, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
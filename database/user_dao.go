@@ -13,9 +13,17 @@ import (
 type userDb struct{}
 
 type userDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
 	GetUserByEmail(ctx context.Context, email string) (exists bool, user models.User, appError *models.ApplicationError)
-	CreateUser(ctx context.Context, userDetails models.User) *models.ApplicationError
+	CreateUser(ctx context.Context, userDetails models.User) (userId int, appError *models.ApplicationError)
 	GetUserByUserId(ctx context.Context, userId int) (exists bool, user models.User, appError *models.ApplicationError)
+	GetUserByUserIdForUpdate(ctx context.Context, tx pgx.Tx, userId int) (exists bool, user models.User, appError *models.ApplicationError)
+	CountUsersWithRoleForUpdate(ctx context.Context, tx pgx.Tx, role string) (count int, appError *models.ApplicationError)
+	UpdateUserRole(ctx context.Context, tx pgx.Tx, userId int, role string) *models.ApplicationError
+	InsertUserRoleChange(ctx context.Context, tx pgx.Tx, change models.UserRoleChange) (created models.UserRoleChange, appError *models.ApplicationError)
+	GetUserRoleChanges(ctx context.Context, userId int) (changes []models.UserRoleChange, appError *models.ApplicationError)
+	MarkEmailVerified(ctx context.Context, tx pgx.Tx, userId int) *models.ApplicationError
+	UpdateUserPassword(ctx context.Context, tx pgx.Tx, userId int, passwordHash string) *models.ApplicationError
 }
 
 var UserDb userDbInterface
@@ -24,11 +32,15 @@ func init() {
 	UserDb = &userDb{}
 }
 
+func (u *userDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
 func (u *userDb) GetUserByEmail(ctx context.Context, email string) (exists bool, user models.User, appError *models.ApplicationError) {
 
-	sqlStatement := `select u."user_id", u."email", u."password_hash", u."first_name", u."last_name", u."role" from users u where  u."email" = $1`
+	sqlStatement := `select u."user_id", u."email", u."password_hash", u."first_name", u."last_name", u."role", u."email_verified_at" from users u where  u."email" = $1`
 
-	err := dbPool.QueryRow(ctx, sqlStatement, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role)
+	err := dbPool.QueryRow(ctx, sqlStatement, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerifiedAt)
 	if err != nil {
 
 		if err == pgx.ErrNoRows {
@@ -37,7 +49,7 @@ func (u *userDb) GetUserByEmail(ctx context.Context, email string) (exists bool,
 
 		errMsg := fmt.Sprintf("GetUserByEmail: Could not get user details from Database. Error:%s!", err.Error())
 		displayMsg := fmt.Sprintf("Could not get user details for emailId: %s!", email)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError = utils.RenderAppError(ctx, 2201, errMsg, displayMsg, nil)
 		return false, user, appError
 	}
@@ -45,9 +57,7 @@ func (u *userDb) GetUserByEmail(ctx context.Context, email string) (exists bool,
 	return true, user, nil
 }
 
-func (u *userDb) CreateUser(ctx context.Context, userDetails models.User) *models.ApplicationError {
-
-	var userId int
+func (u *userDb) CreateUser(ctx context.Context, userDetails models.User) (userId int, appError *models.ApplicationError) {
 
 	sqlStatement := `INSERT INTO users ( "email", "password_hash", "first_name","last_name", "role") VALUES ($1, $2, $3, $4, $5) RETURNING user_id;`
 
@@ -55,19 +65,19 @@ func (u *userDb) CreateUser(ctx context.Context, userDetails models.User) *model
 	if err != nil {
 		errMsg := fmt.Sprintf("CreateUser: Couldn't insert user details. Error:%s!", err.Error())
 		displayMsg := fmt.Sprintf("Could not save user details for emailId: %s", userDetails.Email)
-		logger.Log.Error(errMsg)
-		appError := utils.RenderAppError(ctx, 2202, errMsg, displayMsg, nil)
-		return appError
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2202, errMsg, displayMsg, nil)
+		return 0, appError
 	}
 
-	return nil
+	return userId, nil
 }
 
 func (u *userDb) GetUserByUserId(ctx context.Context, userId int) (exists bool, user models.User, appError *models.ApplicationError) {
 
-	sqlStatement := `select u."user_id", u."email", u."password_hash", u."first_name", u."last_name", u."role" from users u where  u."user_id" = $1`
+	sqlStatement := `select u."user_id", u."email", u."password_hash", u."first_name", u."last_name", u."role", u."email_verified_at" from users u where  u."user_id" = $1`
 
-	err := dbPool.QueryRow(ctx, sqlStatement, userId).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role)
+	err := dbPool.QueryRow(ctx, sqlStatement, userId).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerifiedAt)
 	if err != nil {
 
 		if err == pgx.ErrNoRows {
@@ -76,10 +86,169 @@ func (u *userDb) GetUserByUserId(ctx context.Context, userId int) (exists bool,
 
 		errMsg := fmt.Sprintf("GetUserByUserId: Could not get user details from Database. Error:%s!", err.Error())
 		displayMsg := fmt.Sprintf("Could not get user details for userId: %d!", userId)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError = utils.RenderAppError(ctx, 2203, errMsg, displayMsg, nil)
 		return false, user, appError
 	}
 
 	return true, user, nil
 }
+
+func (u *userDb) GetUserByUserIdForUpdate(ctx context.Context, tx pgx.Tx, userId int) (exists bool, user models.User, appError *models.ApplicationError) {
+
+	sqlStatement := `select u."user_id", u."email", u."password_hash", u."first_name", u."last_name", u."role", u."email_verified_at" from users u where  u."user_id" = $1 FOR UPDATE`
+
+	err := tx.QueryRow(ctx, sqlStatement, userId).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Role, &user.EmailVerifiedAt)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, user, nil
+		}
+
+		errMsg := fmt.Sprintf("GetUserByUserIdForUpdate: Could not get user details from Database. Error:%s!", err.Error())
+		displayMsg := fmt.Sprintf("Could not get user details for userId: %d!", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2204, errMsg, displayMsg, nil)
+		return false, user, appError
+	}
+
+	return true, user, nil
+}
+
+// CountUsersWithRoleForUpdate locks every user with the role, so two admins can not
+// demote each other at the same time and leave no admin.
+func (u *userDb) CountUsersWithRoleForUpdate(ctx context.Context, tx pgx.Tx, role string) (count int, appError *models.ApplicationError) {
+
+	sqlStatement := `select u."user_id" from users u where u."role" = $1 FOR UPDATE`
+
+	rows, err := tx.Query(ctx, sqlStatement, role)
+	if err != nil {
+		errMsg := fmt.Sprintf("CountUsersWithRoleForUpdate: Could not get users with role: %s. Error:%s!", role, err.Error())
+		displayMsg := "Could not get users with role!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2205, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("CountUsersWithRoleForUpdate: Error while iterating user rows. Error:%s!", err.Error())
+		displayMsg := "Could not get users with role!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2206, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return count, nil
+}
+
+func (u *userDb) UpdateUserRole(ctx context.Context, tx pgx.Tx, userId int, role string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE users SET "role" = $1 WHERE "user_id" = $2`
+
+	_, err := tx.Exec(ctx, sqlStatement, role, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateUserRole: Could not update role of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not update user role!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2207, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (u *userDb) InsertUserRoleChange(ctx context.Context, tx pgx.Tx, change models.UserRoleChange) (created models.UserRoleChange, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO user_role_changes ("user_id", "from_role", "to_role", "reason", "changed_by") VALUES ($1, $2, $3, $4, $5)
+		RETURNING "id", "created_at"`
+
+	created = change
+
+	err := tx.QueryRow(ctx, sqlStatement, change.UserId, change.FromRole, change.ToRole, change.Reason, change.ChangedBy).Scan(&created.Id, &created.CreatedAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertUserRoleChange: Could not save role change of user: %d! Error:%s!", change.UserId, err.Error())
+		displayMsg := "Could not save user role change!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2208, errMsg, displayMsg, nil)
+		return created, appError
+	}
+
+	return created, nil
+}
+
+func (u *userDb) GetUserRoleChanges(ctx context.Context, userId int) (changes []models.UserRoleChange, appError *models.ApplicationError) {
+
+	sqlStatement := `select r."id", r."user_id", r."from_role", r."to_role", r."reason", r."changed_by", r."created_at"
+		from user_role_changes r where r."user_id" = $1 order by r."id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetUserRoleChanges: Could not get role changes of user: %d. Error:%s!", userId, err.Error())
+		displayMsg := "Could not get user role changes!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2209, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change models.UserRoleChange
+		if err := rows.Scan(&change.Id, &change.UserId, &change.FromRole, &change.ToRole, &change.Reason, &change.ChangedBy, &change.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetUserRoleChanges: Could not scan role change row. Error:%s!", err.Error())
+			displayMsg := "Could not get user role changes!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2210, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetUserRoleChanges: Error while iterating role change rows. Error:%s!", err.Error())
+		displayMsg := "Could not get user role changes!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2211, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return changes, nil
+}
+
+// MarkEmailVerified keeps the first verification time when the email was verified
+// before.
+func (u *userDb) MarkEmailVerified(ctx context.Context, tx pgx.Tx, userId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE users SET "email_verified_at" = COALESCE("email_verified_at", NOW()) WHERE "user_id" = $1`
+
+	_, err := tx.Exec(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("MarkEmailVerified: Could not mark email of user: %d verified! Error:%s!", userId, err.Error())
+		displayMsg := "Could not verify email!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2212, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (u *userDb) UpdateUserPassword(ctx context.Context, tx pgx.Tx, userId int, passwordHash string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE users SET "password_hash" = $1 WHERE "user_id" = $2`
+
+	_, err := tx.Exec(ctx, sqlStatement, passwordHash, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateUserPassword: Could not update password of user: %d! Error:%s!", userId, err.Error())
+		displayMsg := "Could not update password!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2213, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const userEventsChannel = "user_events"
+
+type userEventDb struct{}
+
+type userEventDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	AppendUserEvent(ctx context.Context, tx pgx.Tx, event models.DomainEvent, payload []byte) *models.ApplicationError
+	GetUserEventsAfter(ctx context.Context, userId int, afterSeq int64, limit int) (events []models.UserEvent, appError *models.ApplicationError)
+	GetLatestUserEventSeq(ctx context.Context, userId int) (eventSeq int64, appError *models.ApplicationError)
+	DeleteUserEventsBefore(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError)
+	ListenForUserEvents(ctx context.Context, onNotify func(userId int)) *models.ApplicationError
+}
+
+var UserEventDb userEventDbInterface
+
+func init() {
+	UserEventDb = &userEventDb{}
+}
+
+func (u *userEventDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (u *userEventDb) AppendUserEvent(ctx context.Context, tx pgx.Tx, event models.DomainEvent, payload []byte) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO user_event_log ("user_id", "event_id", "event_type", "payload") VALUES ($1, $2, $3, $4)`
+
+	_, err := tx.Exec(ctx, sqlStatement, event.UserId, event.EventId, event.EventType, string(payload))
+	if err != nil {
+		errMsg := fmt.Sprintf("AppendUserEvent: Couldn't insert %s event for user: %d. Error:%s!", event.EventType, event.UserId, err.Error())
+		displayMsg := "Could not save user event!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2951, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (u *userEventDb) GetUserEventsAfter(ctx context.Context, userId int, afterSeq int64, limit int) (events []models.UserEvent, appError *models.ApplicationError) {
+
+	sqlStatement := `select e."event_seq", e."user_id", e."event_id", e."event_type", e."payload", e."created_at"
+		from user_event_log e where e."user_id" = $1 and e."event_seq" > $2 order by e."event_seq" limit $3`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, userId, afterSeq, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetUserEventsAfter: Could not get events of user: %d. Error:%s!", userId, err.Error())
+		displayMsg := "Could not get user events!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2952, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.UserEvent
+		if err := rows.Scan(&event.EventSeq, &event.UserId, &event.EventId, &event.EventType, &event.Payload, &event.CreatedAt); err != nil {
+			errMsg := fmt.Sprintf("GetUserEventsAfter: Could not scan user event row. Error:%s!", err.Error())
+			displayMsg := "Could not get user events!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2953, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetUserEventsAfter: Error while iterating user event rows. Error:%s!", err.Error())
+		displayMsg := "Could not get user events!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2954, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return events, nil
+}
+
+func (u *userEventDb) GetLatestUserEventSeq(ctx context.Context, userId int) (eventSeq int64, appError *models.ApplicationError) {
+
+	sqlStatement := `select COALESCE(MAX(e."event_seq"), 0) from user_event_log e where e."user_id" = $1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, userId).Scan(&eventSeq)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetLatestUserEventSeq: Could not get latest event of user: %d. Error:%s!", userId, err.Error())
+		displayMsg := "Could not get user events!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2955, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return eventSeq, nil
+}
+
+func (u *userEventDb) DeleteUserEventsBefore(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM user_event_log WHERE "created_at" < $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, before)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteUserEventsBefore: Could not delete old user events. Error:%s!", err.Error())
+		displayMsg := "Could not delete old user events!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2956, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// ListenForUserEvents holds a pool connection on LISTEN user_events and calls
+// onNotify with the user id of every committed user event. It only returns when
+// ctx is done or the connection fails.
+func (u *userEventDb) ListenForUserEvents(ctx context.Context, onNotify func(userId int)) *models.ApplicationError {
+
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		errMsg := fmt.Sprintf("ListenForUserEvents: Could not acquire connection. Error:%s!", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 2957, errMsg, "", nil)
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+userEventsChannel)
+	if err != nil {
+		errMsg := fmt.Sprintf("ListenForUserEvents: Could not listen on %s. Error:%s!", userEventsChannel, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 2958, errMsg, "", nil)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			errMsg := fmt.Sprintf("ListenForUserEvents: Stopped waiting for notifications. Error:%s!", err.Error())
+			logger.WithContext(ctx).Error(errMsg)
+			return utils.RenderAppError(ctx, 2959, errMsg, "", nil)
+		}
+
+		userId, err := strconv.Atoi(notification.Payload)
+		if err != nil {
+			logger.WithContext(ctx).Error(fmt.Sprintf("ListenForUserEvents: Invalid notification payload: %s", notification.Payload))
+			continue
+		}
+
+		onNotify(userId)
+	}
+}
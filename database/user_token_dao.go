@@ -0,0 +1,128 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type userTokenDb struct{}
+
+type userTokenDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	SupersedeUserTokens(ctx context.Context, tx pgx.Tx, userId int, purpose string) *models.ApplicationError
+	InsertUserToken(ctx context.Context, tx pgx.Tx, token models.UserToken) *models.ApplicationError
+	GetLatestUserTokenCreatedAt(ctx context.Context, userId int, purpose string) (exists bool, createdAt time.Time, appError *models.ApplicationError)
+	GetUserTokenForUpdate(ctx context.Context, tx pgx.Tx, tokenId uuid.UUID, purpose string) (exists bool, token models.UserToken, appError *models.ApplicationError)
+	DeleteExpiredUserTokens(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError)
+}
+
+var UserTokenDb userTokenDbInterface
+
+func init() {
+	UserTokenDb = &userTokenDb{}
+}
+
+func (u *userTokenDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+// SupersedeUserTokens marks every unused token of the purpose used. It is called
+// before a new token is issued, so only the newest link works, and when a token is
+// used.
+func (u *userTokenDb) SupersedeUserTokens(ctx context.Context, tx pgx.Tx, userId int, purpose string) *models.ApplicationError {
+
+	sqlStatement := `UPDATE user_tokens SET "used_at" = NOW() WHERE "user_id" = $1 AND "purpose" = $2 AND "used_at" IS NULL`
+
+	_, err := tx.Exec(ctx, sqlStatement, userId, purpose)
+	if err != nil {
+		errMsg := fmt.Sprintf("SupersedeUserTokens: Could not supersede %s tokens of user: %d! Error:%s!", purpose, userId, err.Error())
+		displayMsg := "Could not create token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2851, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (u *userTokenDb) InsertUserToken(ctx context.Context, tx pgx.Tx, token models.UserToken) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO user_tokens ("token_id", "user_id", "purpose", "email", "expires_at") VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.Exec(ctx, sqlStatement, token.TokenId, token.UserId, token.Purpose, token.Email, token.ExpiresAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("InsertUserToken: Could not save %s token of user: %d! Error:%s!", token.Purpose, token.UserId, err.Error())
+		displayMsg := "Could not create token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2852, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (u *userTokenDb) GetLatestUserTokenCreatedAt(ctx context.Context, userId int, purpose string) (exists bool, createdAt time.Time, appError *models.ApplicationError) {
+
+	sqlStatement := `select t."created_at" from user_tokens t where t."user_id" = $1 and t."purpose" = $2 order by t."created_at" desc limit 1`
+
+	err := dbPool.QueryRow(ctx, sqlStatement, userId, purpose).Scan(&createdAt)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, createdAt, nil
+		}
+
+		errMsg := fmt.Sprintf("GetLatestUserTokenCreatedAt: Could not get latest %s token of user: %d. Error:%s!", purpose, userId, err.Error())
+		displayMsg := "Could not get token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2853, errMsg, displayMsg, nil)
+		return false, createdAt, appError
+	}
+
+	return true, createdAt, nil
+}
+
+func (u *userTokenDb) GetUserTokenForUpdate(ctx context.Context, tx pgx.Tx, tokenId uuid.UUID, purpose string) (exists bool, token models.UserToken, appError *models.ApplicationError) {
+
+	sqlStatement := `select t."token_id", t."user_id", t."purpose", t."email", t."expires_at", t."used_at", t."created_at"
+		from user_tokens t where t."token_id" = $1 and t."purpose" = $2 FOR UPDATE`
+
+	err := tx.QueryRow(ctx, sqlStatement, tokenId, purpose).Scan(&token.TokenId, &token.UserId, &token.Purpose, &token.Email, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, token, nil
+		}
+
+		errMsg := fmt.Sprintf("GetUserTokenForUpdate: Could not get token: %s. Error:%s!", tokenId, err.Error())
+		displayMsg := "Could not get token!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2854, errMsg, displayMsg, nil)
+		return false, token, appError
+	}
+
+	return true, token, nil
+}
+
+func (u *userTokenDb) DeleteExpiredUserTokens(ctx context.Context, before time.Time) (deleted int64, appError *models.ApplicationError) {
+
+	sqlStatement := `DELETE FROM user_tokens WHERE "expires_at" < $1`
+
+	commandTag, err := dbPool.Exec(ctx, sqlStatement, before)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeleteExpiredUserTokens: Could not delete expired user tokens! Error:%s!", err.Error())
+		displayMsg := "Could not delete expired user tokens!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2855, errMsg, displayMsg, nil)
+		return 0, appError
+	}
+
+	return commandTag.RowsAffected(), nil
+}
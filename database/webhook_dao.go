@@ -0,0 +1,373 @@
+package database
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type webhookDb struct{}
+
+type webhookDbInterface interface {
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+	CreateWebhookSubscription(ctx context.Context, subscription models.WebhookSubscription) (created models.WebhookSubscription, appError *models.ApplicationError)
+	GetWebhookSubscriptionsByUserId(ctx context.Context, userId int) (subscriptions []models.WebhookSubscription, appError *models.ApplicationError)
+	GetWebhookSubscription(ctx context.Context, subscriptionId int) (exists bool, subscription models.WebhookSubscription, appError *models.ApplicationError)
+	DeactivateWebhookSubscription(ctx context.Context, tx pgx.Tx, subscriptionId int) *models.ApplicationError
+	CancelPendingWebhookDeliveries(ctx context.Context, tx pgx.Tx, subscriptionId int) *models.ApplicationError
+	EnqueueWebhookDeliveries(ctx context.Context, tx pgx.Tx, event models.DomainEvent, payload []byte) *models.ApplicationError
+	ClaimDueWebhookDeliveries(ctx context.Context, leaseUntil time.Time, limit int) (deliveries []models.WebhookDelivery, appError *models.ApplicationError)
+	RecordWebhookDeliveryAttempt(ctx context.Context, tx pgx.Tx, attempt models.WebhookDeliveryAttempt) *models.ApplicationError
+	UpdateWebhookDeliveryResult(ctx context.Context, tx pgx.Tx, delivery models.WebhookDelivery) *models.ApplicationError
+	GetWebhookDeliveries(ctx context.Context, subscriptionId int, limit int) (deliveries []models.WebhookDelivery, appError *models.ApplicationError)
+	GetWebhookDelivery(ctx context.Context, deliveryId int) (exists bool, delivery models.WebhookDelivery, appError *models.ApplicationError)
+	GetWebhookDeliveryAttempts(ctx context.Context, deliveryId int) (attempts []models.WebhookDeliveryAttempt, appError *models.ApplicationError)
+	RequeueWebhookDelivery(ctx context.Context, deliveryId int) *models.ApplicationError
+}
+
+var WebhookDb webhookDbInterface
+
+func init() {
+	WebhookDb = &webhookDb{}
+}
+
+const webhookSubscriptionColumns = `s."subscription_id", s."user_id", s."url", s."event_types", s."active", s."created_at"`
+
+const webhookDeliveryColumns = `d."delivery_id", d."subscription_id", d."event_id", d."event_type", d."payload", d."status", d."attempt_count", d."next_attempt_at", d."last_attempt_at", d."delivered_at", d."created_at"`
+
+func scanWebhookSubscription(row pgx.Row, subscription *models.WebhookSubscription) error {
+	return row.Scan(&subscription.SubscriptionId, &subscription.UserId, &subscription.Url, &subscription.EventTypes, &subscription.Active, &subscription.CreatedAt)
+}
+
+func scanWebhookDelivery(row pgx.Row, delivery *models.WebhookDelivery) error {
+	return row.Scan(&delivery.DeliveryId, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.AttemptCount, &delivery.NextAttemptAt, &delivery.LastAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt)
+}
+
+func (w *webhookDb) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return dbPool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+}
+
+func (w *webhookDb) CreateWebhookSubscription(ctx context.Context, subscription models.WebhookSubscription) (created models.WebhookSubscription, appError *models.ApplicationError) {
+
+	sqlStatement := `INSERT INTO webhook_subscriptions AS s ("user_id", "url", "event_types", "secret") VALUES ($1, $2, $3, $4) RETURNING ` + webhookSubscriptionColumns
+
+	err := scanWebhookSubscription(dbPool.QueryRow(ctx, sqlStatement, subscription.UserId, subscription.Url, subscription.EventTypes, subscription.Secret), &created)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateWebhookSubscription: Couldn't insert webhook subscription for user: %d. Error:%s!", subscription.UserId, err.Error())
+		displayMsg := "Could not create webhook subscription!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2901, errMsg, displayMsg, nil)
+		return created, appError
+	}
+
+	return created, nil
+}
+
+func (w *webhookDb) GetWebhookSubscriptionsByUserId(ctx context.Context, userId int) (subscriptions []models.WebhookSubscription, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + webhookSubscriptionColumns + ` from webhook_subscriptions s where s."user_id" = $1 order by s."subscription_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, userId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetWebhookSubscriptionsByUserId: Could not get webhook subscriptions from Database. Error:%s!", err.Error())
+		displayMsg := "Could not get webhook subscriptions!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2902, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subscription models.WebhookSubscription
+		if err := scanWebhookSubscription(rows, &subscription); err != nil {
+			errMsg := fmt.Sprintf("GetWebhookSubscriptionsByUserId: Could not scan webhook subscription row. Error:%s!", err.Error())
+			displayMsg := "Could not get webhook subscriptions!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2903, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetWebhookSubscriptionsByUserId: Error while iterating webhook subscription rows. Error:%s!", err.Error())
+		displayMsg := "Could not get webhook subscriptions!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2904, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return subscriptions, nil
+}
+
+func (w *webhookDb) GetWebhookSubscription(ctx context.Context, subscriptionId int) (exists bool, subscription models.WebhookSubscription, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + webhookSubscriptionColumns + ` from webhook_subscriptions s where s."subscription_id" = $1`
+
+	err := scanWebhookSubscription(dbPool.QueryRow(ctx, sqlStatement, subscriptionId), &subscription)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, subscription, nil
+		}
+
+		errMsg := fmt.Sprintf("GetWebhookSubscription: Could not get webhook subscription: %d from Database. Error:%s!", subscriptionId, err.Error())
+		displayMsg := "Could not get webhook subscription!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2905, errMsg, displayMsg, nil)
+		return false, subscription, appError
+	}
+
+	return true, subscription, nil
+}
+
+func (w *webhookDb) DeactivateWebhookSubscription(ctx context.Context, tx pgx.Tx, subscriptionId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE webhook_subscriptions SET "active" = FALSE WHERE "subscription_id" = $1`
+
+	_, err := tx.Exec(ctx, sqlStatement, subscriptionId)
+	if err != nil {
+		errMsg := fmt.Sprintf("DeactivateWebhookSubscription: Could not deactivate webhook subscription: %d! Error:%s!", subscriptionId, err.Error())
+		displayMsg := "Could not delete webhook subscription!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2906, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (w *webhookDb) CancelPendingWebhookDeliveries(ctx context.Context, tx pgx.Tx, subscriptionId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE webhook_deliveries SET "status" = 'cancelled', "next_attempt_at" = NULL WHERE "subscription_id" = $1 AND "status" = 'pending'`
+
+	_, err := tx.Exec(ctx, sqlStatement, subscriptionId)
+	if err != nil {
+		errMsg := fmt.Sprintf("CancelPendingWebhookDeliveries: Could not cancel deliveries of webhook subscription: %d! Error:%s!", subscriptionId, err.Error())
+		displayMsg := "Could not delete webhook subscription!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2907, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries queues the event for every active subscription of its
+// user to its event type. An event queued again for a subscription is ignored.
+func (w *webhookDb) EnqueueWebhookDeliveries(ctx context.Context, tx pgx.Tx, event models.DomainEvent, payload []byte) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO webhook_deliveries ("subscription_id", "event_id", "event_type", "payload", "next_attempt_at")
+		SELECT s."subscription_id", $1, $2, $3, NOW() FROM webhook_subscriptions s
+		WHERE s."user_id" = $4 AND s."active" AND $2 = ANY(s."event_types")
+		ON CONFLICT ("subscription_id", "event_id") DO NOTHING`
+
+	_, err := tx.Exec(ctx, sqlStatement, event.EventId, event.EventType, string(payload), event.UserId)
+	if err != nil {
+		errMsg := fmt.Sprintf("EnqueueWebhookDeliveries: Could not queue webhook deliveries for event: %s! Error:%s!", event.EventId.String(), err.Error())
+		displayMsg := "Could not queue webhook deliveries!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2908, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+// ClaimDueWebhookDeliveries returns pending deliveries that are due, with the url and
+// secret of their subscription, and moves their next attempt to leaseUntil so other
+// dispatchers skip them while they are sent.
+func (w *webhookDb) ClaimDueWebhookDeliveries(ctx context.Context, leaseUntil time.Time, limit int) (deliveries []models.WebhookDelivery, appError *models.ApplicationError) {
+
+	sqlStatement := `UPDATE webhook_deliveries d SET "next_attempt_at" = $1 FROM webhook_subscriptions s
+		WHERE s."subscription_id" = d."subscription_id" AND d."delivery_id" IN (
+			select "delivery_id" from webhook_deliveries where "status" = 'pending' and "next_attempt_at" <= NOW()
+			order by "next_attempt_at" limit $2 FOR UPDATE SKIP LOCKED)
+		RETURNING ` + webhookDeliveryColumns + `, s."url", s."secret"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, leaseUntil, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("ClaimDueWebhookDeliveries: Could not claim due webhook deliveries. Error:%s!", err.Error())
+		displayMsg := "Could not get due webhook deliveries!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2909, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(&delivery.DeliveryId, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.AttemptCount,
+			&delivery.NextAttemptAt, &delivery.LastAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt, &delivery.Url, &delivery.Secret)
+		if err != nil {
+			errMsg := fmt.Sprintf("ClaimDueWebhookDeliveries: Could not scan webhook delivery row. Error:%s!", err.Error())
+			displayMsg := "Could not get due webhook deliveries!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2910, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("ClaimDueWebhookDeliveries: Error while iterating webhook delivery rows. Error:%s!", err.Error())
+		displayMsg := "Could not get due webhook deliveries!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2911, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return deliveries, nil
+}
+
+func (w *webhookDb) RecordWebhookDeliveryAttempt(ctx context.Context, tx pgx.Tx, attempt models.WebhookDeliveryAttempt) *models.ApplicationError {
+
+	sqlStatement := `INSERT INTO webhook_delivery_attempts ("delivery_id", "status_code", "error", "duration_ms", "attempted_at") VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.Exec(ctx, sqlStatement, attempt.DeliveryId, attempt.StatusCode, attempt.Error, attempt.DurationMs, attempt.AttemptedAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("RecordWebhookDeliveryAttempt: Couldn't insert attempt of webhook delivery: %d. Error:%s!", attempt.DeliveryId, err.Error())
+		displayMsg := "Could not save webhook delivery attempt!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2912, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (w *webhookDb) UpdateWebhookDeliveryResult(ctx context.Context, tx pgx.Tx, delivery models.WebhookDelivery) *models.ApplicationError {
+
+	sqlStatement := `UPDATE webhook_deliveries SET "status" = $1, "attempt_count" = $2, "next_attempt_at" = $3, "last_attempt_at" = $4, "delivered_at" = $5
+		WHERE "delivery_id" = $6 AND "status" = 'pending'`
+
+	_, err := tx.Exec(ctx, sqlStatement, delivery.Status, delivery.AttemptCount, delivery.NextAttemptAt, delivery.LastAttemptAt, delivery.DeliveredAt, delivery.DeliveryId)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateWebhookDeliveryResult: Could not update webhook delivery: %d! Error:%s!", delivery.DeliveryId, err.Error())
+		displayMsg := "Could not update webhook delivery!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2913, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
+
+func (w *webhookDb) GetWebhookDeliveries(ctx context.Context, subscriptionId int, limit int) (deliveries []models.WebhookDelivery, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + webhookDeliveryColumns + ` from webhook_deliveries d where d."subscription_id" = $1 order by d."delivery_id" desc limit $2`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, subscriptionId, limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetWebhookDeliveries: Could not get deliveries of webhook subscription: %d. Error:%s!", subscriptionId, err.Error())
+		displayMsg := "Could not get webhook deliveries!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2914, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			errMsg := fmt.Sprintf("GetWebhookDeliveries: Could not scan webhook delivery row. Error:%s!", err.Error())
+			displayMsg := "Could not get webhook deliveries!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2915, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetWebhookDeliveries: Error while iterating webhook delivery rows. Error:%s!", err.Error())
+		displayMsg := "Could not get webhook deliveries!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2916, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return deliveries, nil
+}
+
+func (w *webhookDb) GetWebhookDelivery(ctx context.Context, deliveryId int) (exists bool, delivery models.WebhookDelivery, appError *models.ApplicationError) {
+
+	sqlStatement := `select ` + webhookDeliveryColumns + ` from webhook_deliveries d where d."delivery_id" = $1`
+
+	err := scanWebhookDelivery(dbPool.QueryRow(ctx, sqlStatement, deliveryId), &delivery)
+	if err != nil {
+
+		if err == pgx.ErrNoRows {
+			return false, delivery, nil
+		}
+
+		errMsg := fmt.Sprintf("GetWebhookDelivery: Could not get webhook delivery: %d from Database. Error:%s!", deliveryId, err.Error())
+		displayMsg := "Could not get webhook delivery!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2917, errMsg, displayMsg, nil)
+		return false, delivery, appError
+	}
+
+	return true, delivery, nil
+}
+
+func (w *webhookDb) GetWebhookDeliveryAttempts(ctx context.Context, deliveryId int) (attempts []models.WebhookDeliveryAttempt, appError *models.ApplicationError) {
+
+	sqlStatement := `select a."attempt_id", a."delivery_id", a."status_code", a."error", a."duration_ms", a."attempted_at"
+		from webhook_delivery_attempts a where a."delivery_id" = $1 order by a."attempt_id"`
+
+	rows, err := dbPool.Query(ctx, sqlStatement, deliveryId)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetWebhookDeliveryAttempts: Could not get attempts of webhook delivery: %d. Error:%s!", deliveryId, err.Error())
+		displayMsg := "Could not get webhook delivery attempts!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2918, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt models.WebhookDeliveryAttempt
+		if err := rows.Scan(&attempt.AttemptId, &attempt.DeliveryId, &attempt.StatusCode, &attempt.Error, &attempt.DurationMs, &attempt.AttemptedAt); err != nil {
+			errMsg := fmt.Sprintf("GetWebhookDeliveryAttempts: Could not scan webhook delivery attempt row. Error:%s!", err.Error())
+			displayMsg := "Could not get webhook delivery attempts!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError = utils.RenderAppError(ctx, 2919, errMsg, displayMsg, nil)
+			return nil, appError
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("GetWebhookDeliveryAttempts: Error while iterating webhook delivery attempt rows. Error:%s!", err.Error())
+		displayMsg := "Could not get webhook delivery attempts!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError = utils.RenderAppError(ctx, 2920, errMsg, displayMsg, nil)
+		return nil, appError
+	}
+
+	return attempts, nil
+}
+
+// RequeueWebhookDelivery makes a delivery due now with a fresh retry budget, the
+// attempts made so far are kept.
+func (w *webhookDb) RequeueWebhookDelivery(ctx context.Context, deliveryId int) *models.ApplicationError {
+
+	sqlStatement := `UPDATE webhook_deliveries SET "status" = 'pending', "attempt_count" = 0, "next_attempt_at" = NOW(), "delivered_at" = NULL WHERE "delivery_id" = $1`
+
+	_, err := dbPool.Exec(ctx, sqlStatement, deliveryId)
+	if err != nil {
+		errMsg := fmt.Sprintf("RequeueWebhookDelivery: Could not requeue webhook delivery: %d! Error:%s!", deliveryId, err.Error())
+		displayMsg := "Could not redeliver webhook!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 2921, errMsg, displayMsg, nil)
+		return appError
+	}
+
+	return nil
+}
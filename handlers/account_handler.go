@@ -21,7 +21,7 @@ func CreateAccount(c *gin.Context) {
 	err := c.BindJSON(&input)
 	if err != nil {
 		errMsg := fmt.Sprintf("CreateAccount: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3001, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
@@ -31,7 +31,7 @@ func CreateAccount(c *gin.Context) {
 	user_id, exists := c.Get("user_id")
 	if !exists {
 		errMsg := "CreateAccount: UserId not found in context claims"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3002, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
@@ -41,7 +41,7 @@ func CreateAccount(c *gin.Context) {
 	userId, ok := user_id.(int)
 	if !ok {
 		errMsg := "CreateAccount: UserId found in context claims is not of correct type"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3003, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
@@ -66,7 +66,7 @@ func FundTransaction(c *gin.Context) {
 	err := c.BindJSON(&input)
 	if err != nil {
 		errMsg := fmt.Sprintf("FundTransaction: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3004, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
@@ -76,20 +76,20 @@ func FundTransaction(c *gin.Context) {
 	userId, err := utils.GetClaimFromContext[int](c, "user_id")
 	if err != nil {
 		errMsg := fmt.Sprintf("GetTransactionHistory-> Error: %s", err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3005, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 		return
 	}
 
-	apiError := services.FundTransaction(ctx, userId, input)
+	apiResponse, apiError := services.FundTransaction(ctx, userId, input, utils.HasRecentSecondFactor(c))
 	if apiError != nil {
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: "Transaction process queued"})
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
 }
 
 func GetTransactionHistory(c *gin.Context) {
@@ -101,7 +101,7 @@ func GetTransactionHistory(c *gin.Context) {
 	err := c.BindJSON(&input)
 	if err != nil {
 		errMsg := fmt.Sprintf("GetTransactionHistory: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3006, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
@@ -111,23 +111,16 @@ func GetTransactionHistory(c *gin.Context) {
 	userId, err := utils.GetClaimFromContext[int](c, "user_id")
 	if err != nil {
 		errMsg := fmt.Sprintf("GetTransactionHistory-> Error: %s", err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3007, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 		return
 	}
 
-	role, err := utils.GetClaimFromContext[string](c, "role")
-	if err != nil {
-		errMsg := fmt.Sprintf("GetTransactionHistory-> Error: %s", err.Error())
-		logger.Log.Error(errMsg)
-		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3008, errMsg, "", nil)
-		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
-		c.JSON(apiError.StatusCode, apiError.ApplicationError)
-		return
-	}
-	apiResponse, apiError := services.GetTransactionHistory(ctx, userId, input, role)
+	readAll := utils.HasPermission(c, models.PERMISSION_LEDGER_READ_ALL)
+
+	apiResponse, apiError := services.GetTransactionHistory(ctx, userId, input, readAll)
 	if apiError != nil {
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 		return
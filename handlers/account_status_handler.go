@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ChangeAccountStatus(c *gin.Context) {
+
+	var input models.ChangeAccountStatusRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	accountId, err := strconv.Atoi(c.Param("accountId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("ChangeAccountStatus: accountId is not a valid integer.AccountId:%s", c.Param("accountId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3801, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	err = c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("ChangeAccountStatus: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3802, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	adminUserId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("ChangeAccountStatus-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3803, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.ChangeAccountStatus(ctx, adminUserId, accountId, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetAccountStatusHistory(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	accountId, err := strconv.Atoi(c.Param("accountId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("GetAccountStatusHistory: accountId is not a valid integer.AccountId:%s", c.Param("accountId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3804, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetAccountStatusHistory(ctx, accountId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
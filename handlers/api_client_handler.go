@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func CreateApiClient(c *gin.Context) {
+
+	var input models.CreateApiClientRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateApiClient: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3651, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	adminUserId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateApiClient-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3652, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.CreateApiClient(ctx, adminUserId, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetApiClients(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	apiResponse, apiError := services.GetApiClients(ctx)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func UpdateApiClientScopes(c *gin.Context) {
+
+	var input models.UpdateApiClientScopesRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	clientId, err := strconv.Atoi(c.Param("clientId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateApiClientScopes: clientId is not a valid integer.ClientId:%s", c.Param("clientId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3653, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	err = c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateApiClientScopes: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3654, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	adminUserId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("UpdateApiClientScopes-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3655, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.UpdateApiClientScopes(ctx, adminUserId, clientId, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func CreateApiKey(c *gin.Context) {
+
+	var input models.CreateApiKeyRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	clientId, err := strconv.Atoi(c.Param("clientId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateApiKey: clientId is not a valid integer.ClientId:%s", c.Param("clientId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3656, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	err = c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateApiKey: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3657, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	adminUserId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateApiKey-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3658, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.CreateApiKey(ctx, adminUserId, clientId, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetApiKeys(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	clientId, err := strconv.Atoi(c.Param("clientId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("GetApiKeys: clientId is not a valid integer.ClientId:%s", c.Param("clientId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3659, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetApiKeys(ctx, clientId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func RevokeApiKey(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	clientId, err := strconv.Atoi(c.Param("clientId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeApiKey: clientId is not a valid integer.ClientId:%s", c.Param("clientId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3660, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	keyId, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeApiKey: keyId is not a valid uuid.KeyId:%s", c.Param("keyId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3661, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	adminUserId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeApiKey-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3662, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiError := services.RevokeApiKey(ctx, adminUserId, clientId, keyId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: "Api key revoked successfully"})
+}
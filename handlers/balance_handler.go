@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetBalanceAsOf(c *gin.Context) {
+
+	var input models.GetBalanceAsOfRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindQuery(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetBalanceAsOf: Request query validation fail.Request query:%s.Error:%s", c.Request.URL.RawQuery, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3201, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	userId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("GetBalanceAsOf-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3202, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetBalanceAsOf(ctx, userId, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetBalanceHistory(c *gin.Context) {
+
+	var input models.GetBalanceHistoryRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindQuery(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetBalanceHistory: Request query validation fail.Request query:%s.Error:%s", c.Request.URL.RawQuery, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3203, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	userId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("GetBalanceHistory-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3204, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetDailyBalanceHistory(ctx, userId, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func BackfillDailyBalances(c *gin.Context) {
+
+	var input models.BackfillDailyBalancesRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("BackfillDailyBalances: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3205, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.BackfillDailyBalances(ctx, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const eventStreamHeartbeatInterval = 15 * time.Second
+
+// StreamUserEvents streams the domain events of the user as Server-Sent Events. The
+// SSE id is the event sequence, a client that reconnects with Last-Event-ID (or the
+// lastEventId query parameter) first receives the events it missed. Without one the
+// stream starts with the next event.
+func StreamUserEvents(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	userId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("StreamUserEvents-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3951, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("lastEventId")
+	}
+
+	var eventSeq int64
+	if lastEventId != "" {
+		eventSeq, err = strconv.ParseInt(lastEventId, 10, 64)
+		if err != nil || eventSeq < 0 {
+			errMsg := fmt.Sprintf("StreamUserEvents: Last-Event-ID is not a valid event id.Last-Event-ID:%s", lastEventId)
+			logger.WithContext(ctx).Error(errMsg)
+			apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3952, errMsg, "", nil)
+			misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+	} else {
+		var apiError *models.ApiError
+		eventSeq, apiError = services.GetLatestUserEventSeq(ctx, userId)
+		if apiError != nil {
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+	}
+
+	// Subscribe before the first read, so an event committed in between wakes the stream.
+	wake, unsubscribe := services.SubscribeUserEvents(userId)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	sendEvents := func() bool {
+		for {
+			events, apiError := services.GetUserEventsAfter(ctx, userId, eventSeq)
+			if apiError != nil {
+				return false
+			}
+
+			if len(events) == 0 {
+				return true
+			}
+
+			for _, event := range events {
+				c.Render(-1, sse.Event{
+					Id:    strconv.FormatInt(event.EventSeq, 10),
+					Event: event.EventType,
+					Data:  string(event.Payload),
+				})
+				eventSeq = event.EventSeq
+			}
+			c.Writer.Flush()
+		}
+	}
+
+	if !sendEvents() {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+			if !sendEvents() {
+				return
+			}
+		case <-heartbeat.C:
+			// The comment keeps proxies from closing an idle stream, reading again covers
+			// a notification that was lost while the listener reconnected.
+			c.Writer.WriteString(": heartbeat\n\n")
+			c.Writer.Flush()
+			if !sendEvents() {
+				return
+			}
+		}
+	}
+}
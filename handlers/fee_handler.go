@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetFeeCharges(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	userId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("GetFeeCharges-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3601, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetFeeCharges(ctx, userId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func CreateFeeSchedule(c *gin.Context) {
+
+	var input models.CreateFeeScheduleRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateFeeSchedule: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3602, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.CreateFeeSchedule(ctx, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetFeeSchedules(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	apiResponse, apiError := services.GetFeeSchedules(ctx)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func CreateFeeWaiver(c *gin.Context) {
+
+	var input models.CreateFeeWaiverRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateFeeWaiver: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3603, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.CreateFeeWaiver(ctx, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetFeeWaivers(c *gin.Context) {
+
+	var input models.GetFeeWaiversRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindQuery(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("GetFeeWaivers: Request query validation fail.Request query:%s.Error:%s", c.Request.URL.RawQuery, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3604, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetFeeWaivers(ctx, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func RevokeFeeWaiver(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	waiverId, err := strconv.Atoi(c.Param("waiverId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeFeeWaiver: waiverId is not a valid integer.WaiverId:%s", c.Param("waiverId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3605, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiError := services.RevokeFeeWaiver(ctx, waiverId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: "Fee waiver revoked"})
+}
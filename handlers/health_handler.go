@@ -1,18 +1,45 @@
 package handlers
 
 import (
+	"banking_ledger/logger"
 	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
+// GetHealth is the liveness probe, it only tells that the process serves requests.
+// Dependencies are checked by GetReadiness, a pod with a broken dependency must be
+// taken out of traffic, not restarted.
 func GetHealth(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: "Service is healthy!!!"})
 
 }
 
+// GetReadiness answers 200 when the service is ready or degraded and 503 with the
+// status of every dependency when one of them is down.
+func GetReadiness(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	readiness := services.CheckReadiness(ctx)
+
+	if readiness.Status == models.READINESS_UNAVAILABLE {
+		errMsg := "GetReadiness: Service is not ready!"
+		logger.WithContext(ctx).Warn(errMsg, zap.Any("dependencies", readiness.Dependencies))
+		apiError := utils.RenderApiError(ctx, http.StatusServiceUnavailable, 3151, errMsg, "Service is not ready", readiness)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: readiness})
+
+}
+
 func NoRoute(c *gin.Context) {
 
 	c.JSON(http.StatusNotFound, models.ApplicationError{Type: "error", Message: models.ApplicationErrorMessage{ErrorCode: 1000, ErrorMessage: "Route not found"}})
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"banking_ledger/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS serves the public keys tokens are verified with in the plain JWK Set
+// format, so other services can verify our tokens with any JWT library.
+func GetJWKS(c *gin.Context) {
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.GetJWKS())
+
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func RefreshSession(c *gin.Context) {
+
+	var input models.RefreshTokenRequest
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	err := c.BindJSON(&input)
+	if err != nil {
+		errMsg := fmt.Sprintf("RefreshSession: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3251, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	response, apiError := services.RefreshSession(ctx, input)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: response})
+}
+
+// Logout revokes the session of the access token, or every session of the user with
+// allSessions=true.
+func Logout(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	allSessions, err := strconv.ParseBool(c.DefaultQuery("allSessions", "false"))
+	if err != nil {
+		errMsg := fmt.Sprintf("Logout: allSessions is not a valid boolean.AllSessions:%s", c.Query("allSessions"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3252, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	userId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("Logout-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3253, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	var sessionId *uuid.UUID
+	if !allSessions {
+		sid, err := utils.GetClaimFromContext[uuid.UUID](c, "session_id")
+		if err != nil {
+			errMsg := fmt.Sprintf("Logout-> Error: %s", err.Error())
+			logger.WithContext(ctx).Error(errMsg)
+			apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3254, errMsg, "", nil)
+			misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+		sessionId = &sid
+	}
+
+	response, apiError := services.RevokeSessions(ctx, userId, sessionId, models.SESSION_REVOKED_LOGOUT)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: response})
+}
+
+func RevokeUserSessions(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("RevokeUserSessions: userId is not a valid integer.UserId:%s", c.Param("userId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3255, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	response, apiError := services.RevokeSessions(ctx, userId, nil, models.SESSION_REVOKED_ADMIN)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: response})
+}
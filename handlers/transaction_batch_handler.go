@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/services"
+	"banking_ledger/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateTransactionBatch takes a multipart upload with the file in the file field
+// and an optional format field, csv or jsonl, when the extension does not tell.
+func CreateTransactionBatch(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	adminUserId, err := utils.GetClaimFromContext[int](c, "user_id")
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateTransactionBatch-> Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3051, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateTransactionBatch: file is missing in the form.Error:%s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3052, errMsg, "Upload the batch file in the file field", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateTransactionBatch: Could not open uploaded file.FileName:%s.Error:%s", fileHeader.Filename, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3053, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+	defer file.Close()
+
+	apiResponse, apiError := services.CreateTransactionBatch(ctx, adminUserId, fileHeader.Filename, c.PostForm("format"), file)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetTransactionBatches(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	apiResponse, apiError := services.GetTransactionBatches(ctx)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetTransactionBatch(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	batchId, err := uuid.Parse(c.Param("batchId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("GetTransactionBatch: batchId is not a valid uuid.BatchId:%s", c.Param("batchId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3054, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetTransactionBatch(ctx, batchId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func GetTransactionBatchReport(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	batchId, err := uuid.Parse(c.Param("batchId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("GetTransactionBatchReport: batchId is not a valid uuid.BatchId:%s", c.Param("batchId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3055, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", "pending", "queued", "succeeded", "failed", "cancelled":
+	default:
+		errMsg := fmt.Sprintf("GetTransactionBatchReport: status is not valid.Status:%s", status)
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3056, errMsg, "status must be pending, queued, succeeded, failed or cancelled", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.GetTransactionBatchReport(ctx, batchId, status)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
+
+func CancelTransactionBatch(c *gin.Context) {
+
+	ctx := utils.GetContextFromGinContext(c)
+
+	batchId, err := uuid.Parse(c.Param("batchId"))
+	if err != nil {
+		errMsg := fmt.Sprintf("CancelTransactionBatch: batchId is not a valid uuid.BatchId:%s", c.Param("batchId"))
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusBadRequest, 3057, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	apiResponse, apiError := services.CancelTransactionBatch(ctx, batchId)
+	if apiError != nil {
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: apiResponse})
+}
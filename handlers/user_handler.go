@@ -8,6 +8,7 @@ import (
 	"banking_ledger/utils"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,7 +22,7 @@ func RegisterUser(c *gin.Context) {
 	err := c.BindJSON(&input)
 	if err != nil {
 		errMsg := fmt.Sprintf("RegisterUser: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusInternalServerError, 3101, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
@@ -34,7 +35,7 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: "User Registered successfully"})
+	c.JSON(http.StatusOK, models.SuccessResponse{Type: "success", Message: "Registration received, check your email to verify it"})
 }
 
 func UserLogin(c *gin.Context) {
@@ -46,15 +47,18 @@ func UserLogin(c *gin.Context) {
 	err := c.BindJSON(&input)
 	if err != nil {
 		errMsg := fmt.Sprintf("UserLogin: Request body validation fail.Request body:%s.Error:%s", utils.ConvertStructToString(input), err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		apiError := utils.RenderApiError(ctx, http.StatusInternalServerError, 3102, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_NO_INTERVENTION_REQUIRED, errMsg, apiError)
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 		return
 	}
 
-	response, apiError := services.UserLogin(ctx, input)
+	response, apiError := services.UserLogin(ctx, input, c.ClientIP())
 	if apiError != nil {
+		if info, ok := apiError.ApplicationError.Message.AdditionalInfo.(models.RetryAfterInfo); ok {
+			c.Header("Retry-After", strconv.Itoa(info.RetryAfterSeconds))
+		}
 		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 		return
 	}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"banking_ledger/models"
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// WithContext returns the logger with the correlation id of ctx, so every line logged
+// for a request, a Kafka message or a job run can be found by one id. Lines logged
+// inside a sampled span also carry its trace and span id.
+func WithContext(ctx context.Context) *zap.Logger {
+
+	if ctx == nil {
+		return Log
+	}
+
+	fields := []zap.Field{}
+
+	if correlationId, ok := ctx.Value(models.CONTEXT_REQUEST_ID_KEY).(string); ok && correlationId != "" {
+		fields = append(fields, zap.String("correlationId", correlationId))
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsSampled() {
+		fields = append(fields, zap.String("traceId", spanContext.TraceID().String()), zap.String("spanId", spanContext.SpanID().String()))
+	}
+
+	if len(fields) == 0 {
+		return Log
+	}
+
+	return Log.With(fields...)
+}
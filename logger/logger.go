@@ -31,7 +31,9 @@ func init() {
 
 	var err error
 
-	Log, err = logConfig.Build()
+	Log, err = logConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redactingCore{Core: core}
+	}))
 
 	if err != nil {
 		panic(err)
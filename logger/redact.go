@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"banking_ledger/redact"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// redactingCore masks passwords, tokens and email addresses in the message and the
+// string fields of every log line before it is written.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checkedEntry *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+
+	if c.Enabled(entry.Level) {
+		return checkedEntry.AddCore(entry, c)
+	}
+
+	return checkedEntry
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+
+	entry.Message = redact.String(entry.Message)
+
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+
+	redacted := make([]zapcore.Field, len(fields))
+
+	for i, field := range fields {
+		if field.Type == zapcore.StringType {
+			field.String = redact.String(field.String)
+		}
+		redacted[i] = field
+	}
+
+	return redacted
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "banking_ledger"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	transactionsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_processed_total",
+		Help:      "Transaction requests processed by the consumer by transaction type and outcome (success, failed, duplicate or error).",
+	}, []string{"transaction_type", "outcome"})
+
+	consumerProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_processing_duration_seconds",
+		Help:      "Time the consumer spent on one message by topic.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	consumerMessageDelay = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_message_delay_seconds",
+		Help:      "Time between producing a message and the consumer reading it by topic.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 60, 300, 900},
+	}, []string{"topic"})
+
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_lag_messages",
+		Help:      "Messages behind the high watermark after the last read message by topic and partition.",
+	}, []string{"topic", "partition"})
+
+	producerDeliveryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_producer_delivery_failures_total",
+		Help:      "Messages the producer could not deliver by topic.",
+	}, []string{"topic"})
+
+	droppedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_dropped_messages_total",
+		Help:      "Consumed messages saved as dropped by topic and reason.",
+	}, []string{"topic", "reason"})
+
+	processErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "process_errors_total",
+		Help:      "Errors reported through ProcessError by priority.",
+	}, []string{"priority"})
+
+	rateLimitedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests refused with 429 by rate limit policy.",
+	}, []string{"policy"})
+)
+
+func ObserveHttpRequest(method string, route string, status int, durationSeconds float64) {
+
+	statusLabel := strconv.Itoa(status)
+
+	httpRequests.WithLabelValues(method, route, statusLabel).Inc()
+	httpRequestDuration.WithLabelValues(method, route, statusLabel).Observe(durationSeconds)
+}
+
+func ObserveTransactionProcessed(transactionType string, outcome string) {
+	transactionsProcessed.WithLabelValues(transactionType, outcome).Inc()
+}
+
+func ObserveConsumerProcessing(topic string, durationSeconds float64) {
+	consumerProcessingDuration.WithLabelValues(topic).Observe(durationSeconds)
+}
+
+func ObserveConsumerMessageDelay(topic string, delaySeconds float64) {
+	consumerMessageDelay.WithLabelValues(topic).Observe(delaySeconds)
+}
+
+func SetConsumerLag(topic string, partition int32, lag int64) {
+	consumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+func IncProducerDeliveryFailure(topic string) {
+	producerDeliveryFailures.WithLabelValues(topic).Inc()
+}
+
+func IncDroppedMessage(topic string, reason string) {
+	droppedMessages.WithLabelValues(topic, reason).Inc()
+}
+
+func IncProcessError(priority int) {
+	processErrors.WithLabelValues(strconv.Itoa(priority)).Inc()
+}
+
+func IncRateLimited(policy string) {
+	rateLimitedRequests.WithLabelValues(policy).Inc()
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pgxPoolCollector reads the statistics of the connection pool on every scrape.
+type pgxPoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	totalConns           *prometheus.Desc
+}
+
+// RegisterPgxPool exports the statistics of the Postgres connection pool.
+func RegisterPgxPool(pool *pgxpool.Pool) error {
+
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgx_pool", name), help, nil, nil)
+	}
+
+	return prometheus.Register(&pgxPoolCollector{
+		pool:                 pool,
+		acquireCount:         desc("acquire_total", "Successful connection acquires from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent acquiring connections from the pool."),
+		acquiredConns:        desc("acquired_connections", "Connections currently acquired from the pool."),
+		canceledAcquireCount: desc("canceled_acquire_total", "Acquires canceled by their context."),
+		emptyAcquireCount:    desc("empty_acquire_total", "Acquires that had to wait because the pool had no idle connection."),
+		idleConns:            desc("idle_connections", "Idle connections in the pool."),
+		maxConns:             desc("max_connections", "Maximum size of the pool."),
+		totalConns:           desc("total_connections", "Connections in the pool, acquired, idle and being opened."),
+	})
+}
+
+func (c *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.acquiredConns
+	ch <- c.canceledAcquireCount
+	ch <- c.emptyAcquireCount
+	ch <- c.idleConns
+	ch <- c.maxConns
+	ch <- c.totalConns
+}
+
+func (c *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+}
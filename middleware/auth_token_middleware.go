@@ -1,17 +1,28 @@
 package middleware
 
 import (
-	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/utils"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-// Your JWT secret key (should come from env ideally)
-var jwtSecret = []byte(config.JWT_SECRET) // Replace with your env/config
+// bearerToken returns the token of an Authorization header of the form
+// "Bearer <token>".
+func bearerToken(authHeader string) (string, bool) {
+
+	tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+	tokenString = strings.TrimSpace(tokenString)
+	if !found || tokenString == "" || strings.Contains(tokenString, " ") {
+		return "", false
+	}
+
+	return tokenString, true
+}
 
 func AuthTokenMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -23,69 +34,68 @@ func AuthTokenMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Check format: "Bearer <token>"
-		var tokenString string
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) == 2 || strings.ToLower(tokenParts[0]) == "bearer" {
-			tokenString = tokenParts[1]
-		} else {
-			tokenString = tokenParts[0]
+		tokenString, ok := bearerToken(authHeader)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header must be Bearer <token>"})
+			c.Abort()
+			return
 		}
 
-		// Parse the JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
+		// Verify the signature, kid, expiry, issuer and audience of the token
+		claims, err := utils.ValidateJWT(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
 		}
 
 		// Extract user ID from the token
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			sub, ok := claims["sub"].(string) // jwt parses numbers as float64
-			if !ok {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
-				c.Abort()
-				return
-			}
-			userID, err := strconv.Atoi(sub)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid sub typ"})
-				c.Abort()
-				return
-			}
-			// Set userID in the context
-			c.Set("user_id", userID)
+		userID, err := strconv.Atoi(claims.Subject)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid sub typ"})
+			c.Abort()
+			return
+		}
 
-			role, ok := claims["role"].(string) // jwt parses numbers as float64
-			if !ok {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims for role"})
-				c.Abort()
-				return
-			}
-			name, ok := claims["role"].(string) // jwt parses numbers as float64
-			if !ok {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims for name"})
-				c.Abort()
-				return
-			}
+		if claims.Role == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims for role"})
+			c.Abort()
+			return
+		}
 
-			c.Set("role", role)
-			c.Set("name", name)
+		// Every access token carries its jti and session, older tokens are rejected
+		sessionId, err := uuid.Parse(claims.SessionId)
+		if claims.ID == "" || err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims for session"})
+			c.Abort()
+			return
+		}
 
-			// Continue to next handler
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+		revoked, appError := database.SessionDb.IsAccessTokenRevoked(utils.GetContextFromGinContext(c), claims.ID)
+		if appError != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify token"})
 			c.Abort()
 			return
 		}
+
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+			c.Abort()
+			return
+		}
+
+		// Set the claims in the context
+		c.Set("user_id", userID)
+		c.Set("role", claims.Role)
+		c.Set("name", claims.Name)
+		c.Set("jti", claims.ID)
+		c.Set("session_id", sessionId)
+		c.Set("amr", claims.Amr)
+		if claims.AuthTime != nil {
+			c.Set("auth_time", claims.AuthTime.Time)
+		}
+
+		// Continue to next handler
+		c.Next()
 	}
 }
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBearerToken(t *testing.T) {
+
+	tests := []struct {
+		header        string
+		expectedToken string
+		expectedOk    bool
+	}{
+		{header: "Bearer eyJ.abc.def", expectedToken: "eyJ.abc.def", expectedOk: true},
+		{header: "Bearer  eyJ.abc.def ", expectedToken: "eyJ.abc.def", expectedOk: true},
+		{header: "Bearer", expectedOk: false},
+		{header: "Bearer ", expectedOk: false},
+		{header: "eyJ.abc.def", expectedOk: false},
+		{header: "Basic dXNlcjpwYXNz", expectedOk: false},
+		{header: "Bearer a b", expectedOk: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			token, ok := bearerToken(test.header)
+			if token != test.expectedToken || ok != test.expectedOk {
+				t.Errorf("bearerToken(%q) = %q, %v, expected %q, %v", test.header, token, ok, test.expectedToken, test.expectedOk)
+			}
+		})
+	}
+}
+
+func TestAuthTokenMiddlewareRejectsMalformedHeader(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/v1/account", AuthTokenMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, header := range []string{"Bearer", "Bearer ", "Token abc"} {
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/account", nil)
+		req.Header.Set("Authorization", header)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q returned %d, expected 401", header, recorder.Code)
+		}
+	}
+}
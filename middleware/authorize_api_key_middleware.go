@@ -1,36 +1,52 @@
 package middleware
 
 import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/models"
 	"banking_ledger/utils"
-	"context"
+	"crypto/subtle"
 	"net/http"
-	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
-type API_KEY_TYPE int
+// apiKeyLastUsedInterval is how precise last_used_at of a key is.
+const apiKeyLastUsedInterval = time.Minute
 
-const (
-	API_KEY API_KEY_TYPE = iota
-)
+// legacyApiKeyScopes returns the scopes of the shared API_KEY, accepted is false when
+// legacyUntil is empty, not a date or a day before now. Unknown scopes are dropped.
+func legacyApiKeyScopes(legacyUntil string, legacyScopes string, now time.Time) (scopes []string, accepted bool) {
 
-var (
-	apiKeys = map[API_KEY_TYPE]string{
-		API_KEY: os.Getenv("API_KEY"),
+	lastDay, err := time.Parse(time.DateOnly, legacyUntil)
+	if err != nil || !now.Before(lastDay.AddDate(0, 0, 1)) {
+		return nil, false
 	}
-)
 
-func AuthorizeApiKey(keyType API_KEY_TYPE) gin.HandlerFunc {
+	scopes = []string{}
+	for _, scope := range strings.Split(legacyScopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if slices.Contains(models.API_SCOPES, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, true
+}
+
+// AuthorizeApiKey accepts a key of an API client from the x-api-key header and sets
+// the client and the scopes of its key in the context for RequireApiScope.
+func AuthorizeApiKey() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		xApiKey := c.GetHeader("x-api-key")
 
-		requestId := uuid.New().String()
-		type contextKey string
-		const requestIDKey contextKey = "requestId"
-		ctx := context.WithValue(c.Request.Context(), requestIDKey, requestId)
+		ctx := utils.GetContextFromGinContext(c)
 
 		if xApiKey == "" {
 			apiError := utils.RenderApiError(ctx, http.StatusUnauthorized, 4001, "X-API-KEY missing in header!", "X-API-KEY missing in header!", nil)
@@ -39,13 +55,74 @@ func AuthorizeApiKey(keyType API_KEY_TYPE) gin.HandlerFunc {
 			return
 		}
 
-		expectedApiKey, ok := apiKeys[keyType]
-		if !ok || xApiKey != expectedApiKey {
-			apiError := utils.RenderApiError(ctx, http.StatusUnauthorized, 4002, "API key mismatch!", "API key mismatch!", nil)
+		prefix, ok := utils.ApiKeyPrefix(xApiKey)
+		if !ok {
+
+			// The shared API_KEY is only accepted until every partner has its own key
+			if config.API_KEY != "" && subtle.ConstantTimeCompare([]byte(xApiKey), []byte(config.API_KEY)) == 1 {
+
+				scopes, accepted := legacyApiKeyScopes(config.API_KEY_LEGACY_UNTIL, config.API_KEY_LEGACY_SCOPES, time.Now())
+
+				logger.WithContext(ctx).Warn("AuthorizeApiKey: Request with the deprecated shared API_KEY, the client must move to its own key",
+					zap.String("clientIp", c.ClientIP()),
+					zap.String("userAgent", c.Request.UserAgent()),
+					zap.String("route", c.FullPath()),
+					zap.String("legacyUntil", config.API_KEY_LEGACY_UNTIL),
+					zap.Bool("accepted", accepted),
+				)
+
+				if accepted {
+					c.Set("api_scopes", scopes)
+					return
+				}
+			}
+
+			apiError := utils.RenderApiError(ctx, http.StatusUnauthorized, 4002, "Invalid API key!", "Invalid API key!", nil)
+			c.Abort()
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+
+		exists, apiKey, scopes, appError := database.ApiClientDb.GetApiKeyByPrefix(ctx, prefix)
+		if appError != nil {
+			apiError := utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+			c.Abort()
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+
+		keyMatches := exists && subtle.ConstantTimeCompare([]byte(utils.HashApiKey(xApiKey)), []byte(apiKey.KeyHash)) == 1
+		expired := apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt)
+
+		if !keyMatches || apiKey.RevokedAt != nil || expired {
+			apiError := utils.RenderApiError(ctx, http.StatusUnauthorized, 4002, "Invalid API key!", "Invalid API key!", nil)
 			c.Abort()
 			c.JSON(apiError.StatusCode, apiError.ApplicationError)
 			return
 		}
 
+		// A failure to record the use must not fail the request, the DAO logs it
+		database.ApiClientDb.TouchApiKey(ctx, apiKey.KeyId, apiKeyLastUsedInterval)
+
+		c.Set("api_client_id", apiKey.ClientId)
+		c.Set("api_key_id", apiKey.KeyId)
+		c.Set("api_scopes", scopes)
+	}
+}
+
+// RequireApiScope must run after AuthorizeApiKey, the client of the key needs the scope.
+func RequireApiScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		scopes, err := utils.GetClaimFromContext[[]string](c, "api_scopes")
+		if err == nil && slices.Contains(scopes, scope) {
+			c.Next()
+			return
+		}
+
+		ctx := utils.GetContextFromGinContext(c)
+		apiError := utils.RenderApiError(ctx, http.StatusForbidden, 4004, "API key lacks the scope of the route!", "This API key is not allowed to perform this action", scope)
+		c.Abort()
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
 	}
 }
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLegacyApiKeyScopes(t *testing.T) {
+
+	now := time.Date(2026, time.October, 19, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		legacyUntil      string
+		legacyScopes     string
+		expectedScopes   []string
+		expectedAccepted bool
+	}{
+		{name: "not set", legacyUntil: "", legacyScopes: "users:login"},
+		{name: "not a date", legacyUntil: "19/10/2026", legacyScopes: "users:login"},
+		{name: "expired", legacyUntil: "2026-10-18", legacyScopes: "users:login"},
+		{name: "last day", legacyUntil: "2026-10-19", legacyScopes: "users:login", expectedScopes: []string{models.API_SCOPE_USERS_LOGIN}, expectedAccepted: true},
+		{name: "several scopes", legacyUntil: "2026-12-31", legacyScopes: " users:login, users:register ", expectedScopes: []string{models.API_SCOPE_USERS_LOGIN, models.API_SCOPE_USERS_REGISTER}, expectedAccepted: true},
+		{name: "unknown and repeated scopes are dropped", legacyUntil: "2026-12-31", legacyScopes: "users:login,admin,users:login", expectedScopes: []string{models.API_SCOPE_USERS_LOGIN}, expectedAccepted: true},
+		{name: "no scopes", legacyUntil: "2026-12-31", legacyScopes: "", expectedScopes: []string{}, expectedAccepted: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scopes, accepted := legacyApiKeyScopes(test.legacyUntil, test.legacyScopes, now)
+			if accepted != test.expectedAccepted || !slices.Equal(scopes, test.expectedScopes) {
+				t.Errorf("legacyApiKeyScopes = %v, %v, expected %v, %v", scopes, accepted, test.expectedScopes, test.expectedAccepted)
+			}
+		})
+	}
+}
+
+func TestAuthorizeApiKeyLegacyKey(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	defaultKey, defaultUntil, defaultScopes := config.API_KEY, config.API_KEY_LEGACY_UNTIL, config.API_KEY_LEGACY_SCOPES
+	defer func() {
+		config.API_KEY, config.API_KEY_LEGACY_UNTIL, config.API_KEY_LEGACY_SCOPES = defaultKey, defaultUntil, defaultScopes
+	}()
+
+	config.API_KEY = "shared-partner-key"
+	config.API_KEY_LEGACY_SCOPES = models.API_SCOPE_USERS_LOGIN
+
+	router := gin.New()
+	router.Use(AuthorizeApiKey())
+	router.POST("/user/v1/login", RequireApiScope(models.API_SCOPE_USERS_LOGIN), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/user/v1/register", RequireApiScope(models.API_SCOPE_USERS_REGISTER), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name           string
+		legacyUntil    string
+		key            string
+		path           string
+		expectedStatus int
+	}{
+		{name: "legacy key is off by default", legacyUntil: "", key: "shared-partner-key", path: "/user/v1/login", expectedStatus: http.StatusUnauthorized},
+		{name: "legacy key after its last day", legacyUntil: time.Now().AddDate(0, 0, -2).Format(time.DateOnly), key: "shared-partner-key", path: "/user/v1/login", expectedStatus: http.StatusUnauthorized},
+		{name: "legacy key with its scope", legacyUntil: time.Now().AddDate(0, 0, 2).Format(time.DateOnly), key: "shared-partner-key", path: "/user/v1/login", expectedStatus: http.StatusOK},
+		{name: "legacy key without the scope", legacyUntil: time.Now().AddDate(0, 0, 2).Format(time.DateOnly), key: "shared-partner-key", path: "/user/v1/register", expectedStatus: http.StatusForbidden},
+		{name: "wrong key", legacyUntil: time.Now().AddDate(0, 0, 2).Format(time.DateOnly), key: "other-key", path: "/user/v1/login", expectedStatus: http.StatusUnauthorized},
+		{name: "missing key", legacyUntil: time.Now().AddDate(0, 0, 2).Format(time.DateOnly), key: "", path: "/user/v1/login", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			config.API_KEY_LEGACY_UNTIL = test.legacyUntil
+
+			request := httptest.NewRequest(http.MethodPost, test.path, nil)
+			if test.key != "" {
+				request.Header.Set("x-api-key", test.key)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("status = %d, expected %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"banking_ledger/models"
+	"banking_ledger/tracing"
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// requestIdPattern limits the request ids accepted from callers, anything else is
+// replaced so a caller can not inject arbitrary text into logs and Kafka headers.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// CorrelationId accepts the X-Request-Id of the caller or generates one, stores it in
+// the request context and on the request span and echoes it in the response.
+func CorrelationId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		requestId := c.GetHeader(REQUEST_ID_HEADER)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = uuid.New().String()
+		}
+
+		ctx := context.WithValue(c.Request.Context(), models.CONTEXT_REQUEST_ID_KEY, requestId)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(REQUEST_ID_HEADER, requestId)
+		trace.SpanFromContext(ctx).SetAttributes(tracing.AttributeRequestId.String(requestId))
+
+		c.Next()
+	}
+}
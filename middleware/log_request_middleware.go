@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"banking_ledger/config"
 	"banking_ledger/logger"
+	"banking_ledger/metrics"
+	"banking_ledger/redact"
 	"bytes"
 	"io"
+	"strings"
 
 	"time"
 
@@ -12,24 +16,40 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// requestBodyRules are the per-route redaction rules for logged request bodies, keyed
+// by method and route without SERVICE_BASE_PATH. Every other body is logged with
+// the default deny list of the redact package.
+var requestBodyRules = map[string]redact.Rule{
+	"POST /user/v1/register": {Allow: []string{"firstName", "lastName", "email"}},
+	"POST /user/v1/login":    {Allow: []string{"email"}},
+}
+
 func LogRequest() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 
 		var requestBodyString string
 
+		ctx := c.Request.Context()
+
 		startTime := time.Now()
 
 		requestMethod := c.Request.Method
 
-		if requestMethod == "POST" || requestMethod == "PATCH" {
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+
+			// Uploaded files are not logged, a transaction batch holds customer data.
+			requestBodyString = "[multipart body not logged]"
+
+		} else if requestMethod == "POST" || requestMethod == "PATCH" {
 
 			requestBody, err := io.ReadAll(c.Request.Body)
 			if err != nil {
-				logger.Log.Error("GetRawData returned error!", []zapcore.Field{zap.String("Error:", err.Error())}...)
+				logger.WithContext(ctx).Error("GetRawData returned error!", []zapcore.Field{zap.String("Error:", err.Error())}...)
 			} else {
 				c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-				requestBodyString = string(requestBody)
+				routeKey := requestMethod + " " + strings.TrimPrefix(c.FullPath(), config.SERVICE_BASE_PATH)
+				requestBodyString = redact.JSON(requestBody, requestBodyRules[routeKey])
 			}
 
 		}
@@ -38,10 +58,15 @@ func LogRequest() gin.HandlerFunc {
 
 		apiLatency := int64(time.Since(startTime) / time.Microsecond)
 
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHttpRequest(requestMethod, route, c.Writer.Status(), time.Since(startTime).Seconds())
+
 		if requestMethod == "POST" || requestMethod == "PATCH" {
 
 			fields := []zapcore.Field{
-				zap.String("x-request-id", c.Request.Header.Get("x-request-id")),
 				zap.String("path", c.Request.URL.Path),
 				zap.String("method", requestMethod),
 				zap.String("body", requestBodyString),
@@ -49,19 +74,18 @@ func LogRequest() gin.HandlerFunc {
 				zap.Int64("latency", apiLatency),
 			}
 
-			logger.Log.Info("api_stats", fields...)
+			logger.WithContext(ctx).Info("api_stats", fields...)
 
 		} else {
 
 			fields := []zapcore.Field{
-				zap.String("x-request-id", c.Request.Header.Get("x-request-id")),
 				zap.String("path", c.Request.URL.Path),
 				zap.String("method", requestMethod),
 				zap.String("user-agent", c.Request.UserAgent()),
 				zap.Int64("latency", apiLatency),
 			}
 
-			logger.Log.Info("api_stats", fields...)
+			logger.WithContext(ctx).Info("api_stats", fields...)
 
 		}
 
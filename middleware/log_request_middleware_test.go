@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"banking_ledger/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogRequestMasksSecondFactorCodes(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.InfoLevel)
+	defaultLog := logger.Log
+	logger.Log = zap.New(core)
+	defer func() { logger.Log = defaultLog }()
+
+	router := gin.New()
+	router.Use(LogRequest())
+	for _, route := range []string{"/user/v1/login/2fa", "/v1/2fa/totp/confirm", "/v1/2fa/totp/disable", "/v1/account/schedules"} {
+		router.POST(route, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	router.PATCH("/v1/account/transaction", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   string
+	}{
+		{method: http.MethodPost, path: "/user/v1/login/2fa", body: `{"challengeId":"c-1","code":"287082"}`, code: "287082"},
+		{method: http.MethodPost, path: "/v1/2fa/totp/confirm", body: `{"code":"081804"}`, code: "081804"},
+		{method: http.MethodPost, path: "/v1/2fa/totp/disable", body: `{"code":"abcd-efgh-ijkl"}`, code: "abcd-efgh-ijkl"},
+		{method: http.MethodPatch, path: "/v1/account/transaction", body: `{"amount":60000,"transactionType":"withdraw","otpCode":"050471"}`, code: "050471"},
+		{method: http.MethodPost, path: "/v1/account/schedules", body: `{"transactionType":"withdraw","amount":60000,"otpCode":"005924"}`, code: "005924"},
+		{method: http.MethodPost, path: "/v1/2fa/totp/confirm", body: `{"recoveryCodes":["aaaa-bbbb","cccc-dddd"]}`, code: "aaaa-bbbb"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+
+			logs.TakeAll()
+
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			request.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			entries := logs.FilterMessage("api_stats").AllUntimed()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d api_stats lines, expected 1", len(entries))
+			}
+
+			body, _ := entries[0].ContextMap()["body"].(string)
+			if strings.Contains(body, test.code) || !strings.Contains(body, "[REDACTED]") {
+				t.Errorf("logged body %s, expected the code to be masked", body)
+			}
+		})
+	}
+}
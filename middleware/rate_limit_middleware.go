@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"banking_ledger/logger"
+	"banking_ledger/metrics"
+	"banking_ledger/models"
+	"banking_ledger/ratelimit"
+	"banking_ledger/utils"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RateLimit refuses requests over the token bucket of the policy with 429. A policy
+// keyed by user must run after AuthTokenMiddleware, one keyed by API key after
+// AuthorizeApiKey. A policy with a zero capacity or rate is disabled.
+func RateLimit(policy models.RateLimitPolicy) gin.HandlerFunc {
+
+	if policy.Capacity <= 0 || policy.PerMinute <= 0 {
+		return func(c *gin.Context) {}
+	}
+
+	return func(c *gin.Context) {
+
+		ctx := utils.GetContextFromGinContext(c)
+
+		subject, ok := rateLimitSubject(c, policy.Key)
+		if !ok {
+			logger.WithContext(ctx).Error("RateLimit: Request has no subject for the policy", zap.String("policy", policy.Name), zap.String("key", policy.Key))
+			return
+		}
+
+		// The limit fails open, a broken store must not stop every request
+		decision, appError := ratelimit.Take(ctx, policy, subject)
+		if appError != nil {
+			return
+		}
+
+		setRateLimitHeaders(c, policy, decision)
+
+		if !decision.Allowed {
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			metrics.IncRateLimited(policy.Name)
+			logger.WithContext(ctx).Warn("RateLimit: Request refused", zap.String("policy", policy.Name), zap.Int("retryAfterSeconds", retryAfter))
+
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			errMsg := "Too many requests! Try again later"
+			apiError := utils.RenderApiError(ctx, http.StatusTooManyRequests, 4005, errMsg, errMsg, models.RetryAfterInfo{RetryAfterSeconds: retryAfter})
+			c.Abort()
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+		}
+	}
+}
+
+func rateLimitSubject(c *gin.Context, key string) (string, bool) {
+
+	switch key {
+	case models.RATE_LIMIT_KEY_USER:
+		userId, err := utils.GetClaimFromContext[int](c, "user_id")
+		if err != nil {
+			return "", false
+		}
+		return strconv.Itoa(userId), true
+
+	case models.RATE_LIMIT_KEY_API_KEY:
+		keyId, err := utils.GetClaimFromContext[uuid.UUID](c, "api_key_id")
+		if err != nil {
+			// Requests with the deprecated shared API_KEY share one bucket
+			return "shared", true
+		}
+		return keyId.String(), true
+
+	case models.RATE_LIMIT_KEY_IP:
+		return c.ClientIP(), true
+	}
+
+	return "", false
+}
+
+// setRateLimitHeaders sets the RateLimit headers of the IETF draft. With several
+// policies on a route the one with the fewest remaining requests is reported.
+func setRateLimitHeaders(c *gin.Context, policy models.RateLimitPolicy, decision models.RateLimitDecision) {
+
+	if current := c.Writer.Header().Get("RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining <= decision.Remaining {
+			return
+		}
+	}
+
+	window := time.Duration(policy.Capacity) * time.Minute / time.Duration(policy.PerMinute)
+
+	c.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(int(math.Ceil(decision.ResetAfter.Seconds()))))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Capacity, int(math.Ceil(window.Seconds()))))
+}
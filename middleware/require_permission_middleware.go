@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"banking_ledger/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission must run after AuthTokenMiddleware, it relies on the role claim
+// set in the context. The caller needs at least one of the permissions.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		for _, permission := range permissions {
+			if utils.HasPermission(c, permission) {
+				c.Next()
+				return
+			}
+		}
+
+		ctx := utils.GetContextFromGinContext(c)
+		apiError := utils.RenderApiError(ctx, http.StatusForbidden, 4003, "Insufficient permissions!", "You are not allowed to perform this action", permissions)
+		c.Abort()
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"banking_ledger/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequirePermission(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		role           interface{} // Role claim, nil when there is none
+		permissions    []string
+		expectedStatus int
+	}{
+		{name: "customer moves money", role: models.ROLE_CUSTOMER, permissions: []string{models.PERMISSION_OWN_ACCOUNT_WRITE}, expectedStatus: http.StatusOK},
+		{name: "customer reads every ledger", role: models.ROLE_CUSTOMER, permissions: []string{models.PERMISSION_LEDGER_READ_ALL}, expectedStatus: http.StatusForbidden},
+		{name: "any of the permissions", role: models.ROLE_AUDITOR, permissions: []string{models.PERMISSION_OWN_ACCOUNT_READ, models.PERMISSION_LEDGER_READ_ALL}, expectedStatus: http.StatusOK},
+		{name: "support logs out", role: models.ROLE_SUPPORT, permissions: []string{models.PERMISSION_OWN_SECURITY_WRITE}, expectedStatus: http.StatusOK},
+		{name: "ops assigns roles", role: models.ROLE_OPS, permissions: []string{models.PERMISSION_ROLES_ASSIGN}, expectedStatus: http.StatusForbidden},
+		{name: "admin assigns roles", role: models.ROLE_ADMIN, permissions: []string{models.PERMISSION_ROLES_ASSIGN}, expectedStatus: http.StatusOK},
+		{name: "system user logs out", role: models.ROLE_SYSTEM, permissions: []string{models.PERMISSION_OWN_SECURITY_WRITE}, expectedStatus: http.StatusForbidden},
+		{name: "old user role", role: "user", permissions: []string{models.PERMISSION_OWN_ACCOUNT_READ}, expectedStatus: http.StatusForbidden},
+		{name: "no role claim", role: nil, permissions: []string{models.PERMISSION_OWN_ACCOUNT_READ}, expectedStatus: http.StatusForbidden},
+		{name: "role claim of the wrong type", role: 5, permissions: []string{models.PERMISSION_OWN_ACCOUNT_READ}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			router := gin.New()
+			router.GET("/route", func(c *gin.Context) {
+				if test.role != nil {
+					c.Set("role", test.role)
+				}
+			}, RequirePermission(test.permissions...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/route", nil))
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("status = %d, expected %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
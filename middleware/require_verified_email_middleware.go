@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail must run after AuthTokenMiddleware. It refuses users that have
+// not verified their email yet, the check reads the user so verifying takes effect
+// without a new token. It does nothing when REQUIRE_VERIFIED_EMAIL is off.
+func RequireVerifiedEmail() gin.HandlerFunc {
+
+	if !config.REQUIRE_VERIFIED_EMAIL {
+		return func(c *gin.Context) {}
+	}
+
+	return func(c *gin.Context) {
+
+		ctx := utils.GetContextFromGinContext(c)
+
+		userId, err := utils.GetClaimFromContext[int](c, "user_id")
+		if err != nil {
+			apiError := utils.RenderApiError(ctx, http.StatusUnauthorized, 4006, "User id missing in context!", "Invalid token", nil)
+			c.Abort()
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+
+		exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+		if appError != nil {
+			apiError := utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+			c.Abort()
+			c.JSON(apiError.StatusCode, apiError.ApplicationError)
+			return
+		}
+
+		if exists && user.EmailVerifiedAt != nil {
+			c.Next()
+			return
+		}
+
+		apiError := utils.RenderApiError(ctx, http.StatusForbidden, 4007, "Email is not verified!", "Verify your email to perform this action", nil)
+		c.Abort()
+		c.JSON(apiError.StatusCode, apiError.ApplicationError)
+	}
+}
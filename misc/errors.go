@@ -3,6 +3,7 @@ package misc
 import (
 	"banking_ledger/database"
 	"banking_ledger/logger"
+	"banking_ledger/metrics"
 	"banking_ledger/models"
 	"context"
 	"fmt"
@@ -10,6 +11,8 @@ import (
 
 func ProcessError(ctx context.Context, priority int, errorMessage string, additionalInfo interface{}) {
 
+	metrics.IncProcessError(priority)
+
 	switch value := additionalInfo.(type) {
 
 	case []byte:
@@ -23,13 +26,13 @@ func ProcessError(ctx context.Context, priority int, errorMessage string, additi
 			if appError != nil {
 
 				errorMsg := fmt.Sprintf("Failed to write ProcessError message to database.Message priority:%d,Message error:%s,Message data:%v", priority, errorMessage, additionalInfo)
-				logger.Log.Error(errorMsg)
+				logger.WithContext(ctx).Error(errorMsg)
 			}
 
 		} else {
 
 			errorMsg := fmt.Sprintf("Could not write ProcessError message to database as byte array could not be converted to string.Message priority:%d,Message error:%s,Message data:%v", priority, errorMessage, additionalInfo)
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 
 		}
 
@@ -46,13 +49,13 @@ func ProcessError(ctx context.Context, priority int, errorMessage string, additi
 			if appError != nil {
 
 				errorMsg := fmt.Sprintf("Failed to write ProcessError message to database.Message priority:%d,Message error:%s,Message data:%v", priority, errorMessage, additionalInfo)
-				logger.Log.Error(errorMsg)
+				logger.WithContext(ctx).Error(errorMsg)
 			}
 
 		} else {
 
 			errorMsg := fmt.Sprintf("Could not write ProcessError message to database as *models.ApplicationError message could not be converted.Message priority:%d,Message error:%s,Message data:%v", priority, errorMessage, additionalInfo)
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 
 		}
 
@@ -69,13 +72,13 @@ func ProcessError(ctx context.Context, priority int, errorMessage string, additi
 			if appError != nil {
 
 				errorMsg := fmt.Sprintf("Failed to write ProcessError message to database.Message priority:%d,Message error:%s,Message data:%v", priority, errorMessage, additionalInfo)
-				logger.Log.Error(errorMsg)
+				logger.WithContext(ctx).Error(errorMsg)
 			}
 
 		} else {
 
 			errorMsg := fmt.Sprintf("Could not write ProcessError message to database as *models.ApplicationError message could not be converted.Message priority:%d,Message error:%s,Message data:%v", priority, errorMessage, additionalInfo)
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 
 		}
 
@@ -86,12 +89,12 @@ func ProcessError(ctx context.Context, priority int, errorMessage string, additi
 		if appError != nil {
 
 			errorMsg := fmt.Sprintf("Failed to write ProcessError message to database for nil message.Message priority:%d,Message error:%s", priority, errorMessage)
-			logger.Log.Error(errorMsg)
+			logger.WithContext(ctx).Error(errorMsg)
 		}
 
 	default:
 		errorMsg := fmt.Sprintf("Could not process ProcessError message as type of data interface is not supported.Data interface type:%v,Message priority:%d,Message error:%s,Message data:%v", value, priority, errorMessage, additionalInfo)
-		logger.Log.Error(errorMsg)
+		logger.WithContext(ctx).Error(errorMsg)
 
 	}
 
@@ -99,6 +102,8 @@ func ProcessError(ctx context.Context, priority int, errorMessage string, additi
 
 func SaveDroppedMessage(ctx context.Context, topicName string, errorType string, kafkaMessageSlice []byte) {
 
+	metrics.IncDroppedMessage(topicName, errorType)
+
 	kafkaMessage := string(kafkaMessageSlice)
 
 	droppedMessage := models.DroppedMessage{
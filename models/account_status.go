@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type AccountStatusHistory struct {
+	AccountId  int       `json:"accountId"`
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	Reason     string    `json:"reason"`
+	ChangedBy  *int      `json:"changedBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type ChangeAccountStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active frozen dormant closed"`
+	Reason string `json:"reason" binding:"required"`
+}
@@ -1,46 +1,67 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Account struct {
-	AccountID int   `json:"account_id"`
-	UserID    int   `json:"user_id"`
-	Balance   int64 `json:"balance"` // stored in rupees
+	AccountID      int        `json:"account_id"`
+	UserID         int        `json:"user_id"`
+	Balance        int64      `json:"balance"` // stored in paise
+	AccountType    string     `json:"account_type"`
+	Status         string     `json:"status"`
+	LastActivityAt time.Time  `json:"last_activity_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type CreateAccountRequest struct {
 	InitialBalance float64 `json:"initialBalance" binding:"required,gt=0"`
+	AccountType    string  `json:"accountType,omitempty" binding:"omitempty,oneof=savings current"`
 }
 
 type FundTransactionRequest struct {
 	Amount          float64 `json:"amount" binding:"required,gt=0"`
 	TransactionType string  `json:"transactionType" binding:"required,oneof=deposit withdraw"`
+	OtpCode         string  `json:"otpCode,omitempty" binding:"max=32" redact:"secret"` // Second factor of a withdrawal at or above STEP_UP_WITHDRAWAL_THRESHOLD
 }
 
-// type FundTransactionResponse struct {
-// }
+type FundTransactionResponse struct {
+	RequestId uuid.UUID `json:"requestId"` // Matches the requestId of the transaction events on the event stream
+	Status    string    `json:"status"`
+}
 
 type TransactionRequestKafka struct {
-	UserId          int       `json:"userId"`
-	Amount          float64   `json:"amount"`
-	TransactionType string    `json:"transactionType"`
-	RequestId       uuid.UUID `json:"requestId"`
-	TransactionTime int64     `json:"transactionTime"`
+	UserId          int        `json:"userId"`
+	Amount          float64    `json:"amount"`
+	TransactionType string     `json:"transactionType"`
+	RequestId       uuid.UUID  `json:"requestId"`
+	TransactionTime int64      `json:"transactionTime"`
+	FeeType         string     `json:"feeType,omitempty"`
+	LinkedRequestId *uuid.UUID `json:"linkedRequestId,omitempty"`
+	BatchId         *uuid.UUID `json:"batchId,omitempty"`
 }
 
 type TransactionCollection struct {
-	UserId            int       `bson:"userId"`
-	Amount            float64   `bson:"amount"`
-	TransactionType   string    `bson:"transactionType"`
-	TransactionStatus string    `bson:"transactionStatus"`
-	TransactionMsg    string    `bson:"transactionMessage"`
-	RequestId         uuid.UUID `bson:"requestId"`
-	TransactionTime   int64     `bson:"transactionTime"`
+	UserId            int        `bson:"userId"`
+	Amount            float64    `bson:"amount"`
+	TransactionType   string     `bson:"transactionType"`
+	TransactionStatus string     `bson:"transactionStatus"`
+	TransactionMsg    string     `bson:"transactionMessage"`
+	RequestId         uuid.UUID  `bson:"requestId"`
+	TransactionTime   int64      `bson:"transactionTime"`
+	FeeType           string     `bson:"feeType,omitempty"`
+	LinkedRequestId   *uuid.UUID `bson:"linkedRequestId,omitempty"` // RequestId of the transaction that triggered a fee
+	BatchId           *uuid.UUID `bson:"batchId,omitempty"`         // Set on rows of an uploaded transaction batch
+	CorrelationId     string     `bson:"correlationId,omitempty"`   // Request id of the API call or job run that caused the entry
 }
 
 type GetTransactionHistoryRequest struct {
 	Filters *struct {
-		TransactionType *string `json:"transactionType,omitempty" binding:"omitempty,oneof=deposit withdraw"`
+		TransactionType *string `json:"transactionType,omitempty" binding:"omitempty,oneof=deposit withdraw interest fee account_closed"`
 		StartTime       *int64  `json:"startTime,omitempty"`
 		EndTime         *int64  `json:"endTime,omitempty"`
 	} `json:"filters,omitempty"`
@@ -48,14 +69,18 @@ type GetTransactionHistoryRequest struct {
 }
 
 type TransactionHistory struct {
-	UserId            int     `json:"userId"`
-	FirstName         string  `json:"fistName"`
-	LastName          string  `json:"lastName"`
-	Amount            float64 `json:"amount"`
-	TransactionType   string  `json:"transactionType"`
-	TransactionTime   int64   `json:"transactionTime"`
-	TransactionStatus string  `json:"transactionStatus"`
-	TransactionMsg    string  `json:"transactionMessage"`
+	UserId            int        `json:"userId"`
+	FirstName         string     `json:"fistName"`
+	LastName          string     `json:"lastName"`
+	Amount            float64    `json:"amount"`
+	TransactionType   string     `json:"transactionType"`
+	TransactionTime   int64      `json:"transactionTime"`
+	TransactionStatus string     `json:"transactionStatus"`
+	TransactionMsg    string     `json:"transactionMessage"`
+	FeeType           string     `json:"feeType,omitempty"`
+	LinkedRequestId   *uuid.UUID `json:"linkedRequestId,omitempty"`
+	BatchId           *uuid.UUID `json:"batchId,omitempty"`
+	CorrelationId     string     `json:"correlationId,omitempty"`
 }
 
 type GetTransactionHistoryResponse struct {
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes of API clients, every route behind AuthorizeApiKey requires one.
+const (
+	API_SCOPE_USERS_REGISTER = "users:register"
+	API_SCOPE_USERS_LOGIN    = "users:login" // Login, its second step and refreshing tokens
+)
+
+var API_SCOPES = []string{API_SCOPE_USERS_REGISTER, API_SCOPE_USERS_LOGIN}
+
+type ApiClient struct {
+	ClientId  int       `json:"clientId"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedBy *int      `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ApiKey is a stored key, the key itself is only returned when it is created.
+type ApiKey struct {
+	KeyId      uuid.UUID  `json:"keyId"`
+	ClientId   int        `json:"clientId"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"keyPrefix"`
+	KeyHash    string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy  *int       `json:"revokedBy,omitempty"`
+	CreatedBy  *int       `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateApiClientRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=users:register users:login"`
+}
+
+type UpdateApiClientScopesRequest struct {
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=users:register users:login"`
+}
+
+type CreateApiKeyRequest struct {
+	Name          string `json:"name" binding:"required,max=100"`
+	ExpiresInDays int    `json:"expiresInDays" binding:"omitempty,min=1,max=3650"` // Defaults to API_KEY_DEFAULT_TTL_DAYS
+}
+
+type CreateApiKeyResponse struct {
+	Key    string `json:"key" redact:"secret"` // Only shown once
+	ApiKey ApiKey `json:"apiKey"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+type DailyBalance struct {
+	AccountID      int       `json:"accountId"`
+	UserID         int       `json:"userId"`
+	BalanceDate    time.Time `json:"balanceDate"`
+	ClosingBalance int64     `json:"closingBalance"` // stored in paise
+}
+
+// DailyBalanceRecompute marks the closed daily balances of an account from FromDate
+// as stale.
+type DailyBalanceRecompute struct {
+	AccountID int
+	FromDate  time.Time
+	MarkedAt  time.Time
+}
+
+type GetBalanceAsOfRequest struct {
+	AsOf int64 `form:"asOf" binding:"required,gt=0"`
+}
+
+type GetBalanceAsOfResponse struct {
+	UserId  int     `json:"userId"`
+	AsOf    int64   `json:"asOf"`
+	Balance float64 `json:"balance"`
+}
+
+type GetBalanceHistoryRequest struct {
+	StartDate string `form:"startDate" binding:"required,datetime=2006-01-02"`
+	EndDate   string `form:"endDate" binding:"required,datetime=2006-01-02"`
+}
+
+type BalanceHistoryPoint struct {
+	Date    string  `json:"date"`
+	Balance float64 `json:"balance"`
+}
+
+type GetBalanceHistoryResponse struct {
+	UserId    int                   `json:"userId"`
+	StartDate string                `json:"startDate"`
+	EndDate   string                `json:"endDate"`
+	Balances  []BalanceHistoryPoint `json:"balances"`
+}
+
+type BackfillDailyBalancesRequest struct {
+	StartDate string `json:"startDate" binding:"required,datetime=2006-01-02"`
+	EndDate   string `json:"endDate" binding:"required,datetime=2006-01-02"`
+	UserId    *int   `json:"userId,omitempty" binding:"omitempty,gt=0"`
+}
+
+type BackfillDailyBalancesResponse struct {
+	AccountsProcessed int `json:"accountsProcessed"`
+	SnapshotsWritten  int `json:"snapshotsWritten"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "github.com/google/uuid"
+
+// DOMAIN_EVENT_NAMESPACE derives the event id from the event type and the request id
+// of the ledger entry, so an event published again has the same id.
+var DOMAIN_EVENT_NAMESPACE = uuid.MustParse("9c4e2b71-0d3f-4a86-b1e5-38f7a2c6d091")
+
+const (
+	EVENT_ACCOUNT_CREATED       = "AccountCreated"
+	EVENT_TRANSACTION_COMPLETED = "TransactionCompleted"
+	EVENT_TRANSACTION_FAILED    = "TransactionFailed"
+	EVENT_BALANCE_CHANGED       = "BalanceChanged"
+)
+
+// DOMAIN_EVENT_VERSION is bumped on every breaking change of an event payload, see
+// docs/domain_events.md.
+const DOMAIN_EVENT_VERSION = 1
+
+// DomainEvent is the envelope of every message on the domain events topic.
+type DomainEvent struct {
+	EventId       uuid.UUID   `json:"eventId"`
+	EventType     string      `json:"eventType"`
+	EventVersion  int         `json:"eventVersion"`
+	CorrelationId uuid.UUID   `json:"correlationId"` // RequestId of the transaction request that caused the event
+	UserId        int         `json:"userId"`
+	OccurredAt    int64       `json:"occurredAt"`
+	Payload       interface{} `json:"payload"`
+}
+
+type AccountCreatedEvent struct {
+	AccountId      int     `json:"accountId"`
+	UserId         int     `json:"userId"`
+	AccountType    string  `json:"accountType"`
+	InitialBalance float64 `json:"initialBalance"`
+}
+
+// TransactionEvent is the payload of TransactionCompleted and TransactionFailed.
+type TransactionEvent struct {
+	RequestId       uuid.UUID  `json:"requestId"`
+	UserId          int        `json:"userId"`
+	Amount          float64    `json:"amount"`
+	TransactionType string     `json:"transactionType"`
+	TransactionTime int64      `json:"transactionTime"`
+	FeeType         string     `json:"feeType,omitempty"`
+	LinkedRequestId *uuid.UUID `json:"linkedRequestId,omitempty"`
+	BatchId         *uuid.UUID `json:"batchId,omitempty"`
+	Reason          string     `json:"reason,omitempty"` // Only set on TransactionFailed
+}
+
+type BalanceChangedEvent struct {
+	AccountId       int       `json:"accountId"`
+	UserId          int       `json:"userId"`
+	RequestId       uuid.UUID `json:"requestId"`
+	PreviousBalance float64   `json:"previousBalance"`
+	NewBalance      float64   `json:"newBalance"`
+}
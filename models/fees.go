@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FEE_NAMESPACE derives the request id of a fee ledger entry from the transaction
+// or the account and month that triggered it, so a fee is only charged once.
+var FEE_NAMESPACE = uuid.MustParse("4a2d9e71-5c08-4f3b-8e16-b97a0c5d2e83")
+
+type FeeSchedule struct {
+	FeeScheduleId  int    `json:"feeScheduleId"`
+	AccountType    string `json:"accountType"`
+	FeeType        string `json:"feeType"`
+	Amount         int64  `json:"amount"`                   // stored in paise
+	MinimumBalance *int64 `json:"minimumBalance,omitempty"` // stored in paise
+	Active         bool   `json:"active"`
+}
+
+type FeeWaiver struct {
+	WaiverId   int        `json:"waiverId"`
+	AccountId  int        `json:"accountId"`
+	FeeType    *string    `json:"feeType,omitempty"`
+	ValidFrom  time.Time  `json:"validFrom"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	Reason     string     `json:"reason"`
+	Revoked    bool       `json:"revoked"`
+}
+
+type FeeCharge struct {
+	AccountId       int        `json:"accountId"`
+	FeeType         string     `json:"feeType"`
+	Amount          int64      `json:"amount"` // stored in paise
+	RequestId       uuid.UUID  `json:"requestId"`
+	LinkedRequestId *uuid.UUID `json:"linkedRequestId,omitempty"`
+	PeriodEnd       *time.Time `json:"periodEnd,omitempty"`
+	Status          string     `json:"status"`
+	WaiverId        *int       `json:"waiverId,omitempty"`
+	FailureReason   *string    `json:"failureReason,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+type CreateFeeScheduleRequest struct {
+	AccountType    string   `json:"accountType" binding:"required,oneof=savings current"`
+	FeeType        string   `json:"feeType" binding:"required,oneof=withdrawal maintenance min_balance_penalty"`
+	Amount         float64  `json:"amount" binding:"required,gt=0"`
+	MinimumBalance *float64 `json:"minimumBalance,omitempty" binding:"omitempty,gte=0"`
+}
+
+type FeeScheduleResponse struct {
+	FeeScheduleId  int      `json:"feeScheduleId"`
+	AccountType    string   `json:"accountType"`
+	FeeType        string   `json:"feeType"`
+	Amount         float64  `json:"amount"`
+	MinimumBalance *float64 `json:"minimumBalance,omitempty"`
+	Active         bool     `json:"active"`
+}
+
+type CreateFeeWaiverRequest struct {
+	AccountId  int     `json:"accountId" binding:"required,gt=0"`
+	FeeType    *string `json:"feeType,omitempty" binding:"omitempty,oneof=withdrawal maintenance min_balance_penalty"`
+	ValidFrom  string  `json:"validFrom" binding:"required,datetime=2006-01-02"`
+	ValidUntil *string `json:"validUntil,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	Reason     string  `json:"reason" binding:"required"`
+}
+
+type GetFeeWaiversRequest struct {
+	AccountId int `form:"accountId" binding:"required,gt=0"`
+}
+
+type FeeChargeResponse struct {
+	FeeType         string     `json:"feeType"`
+	Amount          float64    `json:"amount"`
+	RequestId       uuid.UUID  `json:"requestId"`
+	LinkedRequestId *uuid.UUID `json:"linkedRequestId,omitempty"`
+	PeriodEnd       *string    `json:"periodEnd,omitempty"`
+	Status          string     `json:"status"`
+	FailureReason   *string    `json:"failureReason,omitempty"`
+	CreatedAt       int64      `json:"createdAt"`
+}
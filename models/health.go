@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	DEPENDENCY_UP   = "up"
+	DEPENDENCY_SLOW = "slow" // Answered, but slower than HEALTH_CHECK_SLOW_MILLISECONDS
+	DEPENDENCY_DOWN = "down"
+)
+
+const (
+	READINESS_READY       = "ready"
+	READINESS_DEGRADED    = "degraded"    // Every dependency answered, at least one was slow
+	READINESS_UNAVAILABLE = "unavailable" // At least one dependency is down
+)
+
+type DependencyHealth struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status       string             `json:"status"`
+	CheckedAt    time.Time          `json:"checkedAt"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
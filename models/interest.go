@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// INTEREST_POSTING_NAMESPACE derives the request id of an interest posting from the
+// account id and the end of the posting period, so a period is only credited once.
+var INTEREST_POSTING_NAMESPACE = uuid.MustParse("0b7f3c2e-8d4a-4e61-b5a9-7c3e21d9f406")
+
+type InterestProduct struct {
+	ProductId            int       `json:"productId"`
+	Name                 string    `json:"name"`
+	AccountType          string    `json:"accountType"`
+	AnnualRate           float64   `json:"annualRate"` // in percent
+	AccrualBasis         string    `json:"accrualBasis"`
+	CompoundingFrequency string    `json:"compoundingFrequency"`
+	PostingFrequency     string    `json:"postingFrequency"`
+	EffectiveFrom        time.Time `json:"effectiveFrom"`
+	Active               bool      `json:"active"`
+}
+
+type InterestAccrual struct {
+	AccountId     int       `json:"accountId"`
+	AccrualDate   time.Time `json:"accrualDate"`
+	ProductId     int       `json:"productId"`
+	BalanceBasis  int64     `json:"balanceBasis"`  // stored in paise
+	AccruedAmount float64   `json:"accruedAmount"` // stored in fractional paise
+}
+
+// InterestPosting is one interest transaction sent for an account. Its accruals count
+// as posted once ProcessTransaction applies it.
+type InterestPosting struct {
+	RequestId uuid.UUID
+	AccountId int
+	PeriodEnd time.Time
+	Amount    int64   // stored in paise
+	Remainder float64 // fraction of a paisa carried to the next posting
+	Status    string
+}
+
+type CreateInterestProductRequest struct {
+	Name                 string  `json:"name" binding:"required"`
+	AccountType          string  `json:"accountType" binding:"required,oneof=savings current"`
+	AnnualRate           float64 `json:"annualRate" binding:"gte=0,lte=100"`
+	AccrualBasis         string  `json:"accrualBasis" binding:"required,oneof=actual/365 actual/360 actual/actual"`
+	CompoundingFrequency string  `json:"compoundingFrequency" binding:"required,oneof=daily monthly"`
+	PostingFrequency     string  `json:"postingFrequency" binding:"required,oneof=monthly quarterly"`
+	EffectiveFrom        *string `json:"effectiveFrom,omitempty" binding:"omitempty,datetime=2006-01-02"`
+}
@@ -0,0 +1,62 @@
+package models
+
+type TransactionLimit struct {
+	LimitId              int     `json:"limitId"`
+	Scope                string  `json:"scope"`
+	AccountType          *string `json:"accountType,omitempty"`
+	UserId               *int    `json:"userId,omitempty"`
+	TransactionType      string  `json:"transactionType"`
+	PerTransactionAmount *int64  `json:"perTransactionAmount,omitempty"` // stored in paise
+	DailyAmount          *int64  `json:"dailyAmount,omitempty"`          // stored in paise
+	DailyCount           *int    `json:"dailyCount,omitempty"`
+	MonthlyAmount        *int64  `json:"monthlyAmount,omitempty"` // stored in paise
+	MonthlyCount         *int    `json:"monthlyCount,omitempty"`
+}
+
+type TransactionLimitUsage struct {
+	DailyAmount   int64 // stored in paise
+	DailyCount    int
+	MonthlyAmount int64 // stored in paise
+	MonthlyCount  int
+}
+
+type SetTransactionLimitRequest struct {
+	Scope                string   `json:"scope" binding:"required,oneof=global account_type user"`
+	AccountType          *string  `json:"accountType,omitempty" binding:"omitempty,oneof=savings current"`
+	UserId               *int     `json:"userId,omitempty" binding:"omitempty,gt=0"`
+	TransactionType      string   `json:"transactionType" binding:"required,oneof=deposit withdraw"`
+	PerTransactionAmount *float64 `json:"perTransactionAmount,omitempty" binding:"omitempty,gt=0"`
+	DailyAmount          *float64 `json:"dailyAmount,omitempty" binding:"omitempty,gt=0"`
+	DailyCount           *int     `json:"dailyCount,omitempty" binding:"omitempty,gt=0"`
+	MonthlyAmount        *float64 `json:"monthlyAmount,omitempty" binding:"omitempty,gt=0"`
+	MonthlyCount         *int     `json:"monthlyCount,omitempty" binding:"omitempty,gt=0"`
+}
+
+type TransactionLimitResponse struct {
+	LimitId              int      `json:"limitId"`
+	Scope                string   `json:"scope"`
+	AccountType          *string  `json:"accountType,omitempty"`
+	UserId               *int     `json:"userId,omitempty"`
+	TransactionType      string   `json:"transactionType"`
+	PerTransactionAmount *float64 `json:"perTransactionAmount,omitempty"`
+	DailyAmount          *float64 `json:"dailyAmount,omitempty"`
+	DailyCount           *int     `json:"dailyCount,omitempty"`
+	MonthlyAmount        *float64 `json:"monthlyAmount,omitempty"`
+	MonthlyCount         *int     `json:"monthlyCount,omitempty"`
+}
+
+type LimitAllowance struct {
+	LimitAmount     *float64 `json:"limitAmount,omitempty"`
+	UsedAmount      float64  `json:"usedAmount"`
+	RemainingAmount *float64 `json:"remainingAmount,omitempty"`
+	LimitCount      *int     `json:"limitCount,omitempty"`
+	UsedCount       int      `json:"usedCount"`
+	RemainingCount  *int     `json:"remainingCount,omitempty"`
+}
+
+type TransactionAllowance struct {
+	TransactionType      string         `json:"transactionType"`
+	PerTransactionAmount *float64       `json:"perTransactionAmount,omitempty"`
+	Daily                LimitAllowance `json:"daily"`
+	Monthly              LimitAllowance `json:"monthly"`
+}
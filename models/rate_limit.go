@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// What a rate limit policy counts requests by.
+const (
+	RATE_LIMIT_KEY_USER    = "user"    // user_id of the JWT
+	RATE_LIMIT_KEY_API_KEY = "api_key" // Key of the API client
+	RATE_LIMIT_KEY_IP      = "ip"      // Client IP
+)
+
+const (
+	RATE_LIMIT_STORE_MEMORY   = "memory"
+	RATE_LIMIT_STORE_POSTGRES = "postgres"
+)
+
+// RateLimitPolicy is a token bucket of Capacity requests refilled with PerMinute
+// requests a minute. Routes with a policy of the same name share its buckets.
+type RateLimitPolicy struct {
+	Name      string
+	Key       string
+	Capacity  int
+	PerMinute int
+}
+
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next request is allowed, only set when it is not
+}
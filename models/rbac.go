@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+const (
+	ROLE_CUSTOMER = "customer"
+	ROLE_SUPPORT  = "support"
+	ROLE_AUDITOR  = "auditor"
+	ROLE_OPS      = "ops"
+	ROLE_ADMIN    = "admin"
+	ROLE_SYSTEM   = "system" // Internal users like the fee income user, they can not log in
+)
+
+const (
+	PERMISSION_OWN_ACCOUNT_READ     = "own_account:read"   // Balance, ledger, schedules, fees, limits, events and webhooks of the caller
+	PERMISSION_OWN_ACCOUNT_WRITE    = "own_account:write"  // Open an account, move money, manage schedules and webhooks of the caller
+	PERMISSION_OWN_SECURITY_WRITE   = "own_security:write" // Log out, verify the email and manage the two-factor authentication of the caller
+	PERMISSION_LEDGER_READ_ALL      = "ledger:read_all"    // The transaction history of every user
+	PERMISSION_ACCOUNT_STATUS_READ  = "account_status:read"
+	PERMISSION_ACCOUNT_STATUS_WRITE = "account_status:write"
+	PERMISSION_BALANCE_BACKFILL     = "balance:backfill"
+	PERMISSION_RECONCILIATION_READ  = "reconciliation:read"
+	PERMISSION_RECONCILIATION_RUN   = "reconciliation:run"
+	PERMISSION_PRODUCTS_READ        = "products:read" // Interest products, fee schedules, fee waivers and transaction limits
+	PERMISSION_PRODUCTS_WRITE       = "products:write"
+	PERMISSION_FEE_WAIVERS_WRITE    = "fee_waivers:write"
+	PERMISSION_BATCHES_READ         = "batches:read"
+	PERMISSION_BATCHES_WRITE        = "batches:write"
+	PERMISSION_USERS_READ           = "users:read"
+	PERMISSION_ROLES_ASSIGN         = "roles:assign"
+	PERMISSION_SESSIONS_REVOKE      = "sessions:revoke"
+	PERMISSION_USERS_UNLOCK         = "users:unlock"
+	PERMISSION_API_CLIENTS_READ     = "api_clients:read"
+	PERMISSION_API_CLIENTS_WRITE    = "api_clients:write" // Create clients, change their scopes, create and revoke keys
+)
+
+// ROLE_PERMISSIONS maps every role to the permissions it grants. A route requires a
+// permission, never a role, so a new role only needs an entry here.
+var ROLE_PERMISSIONS = map[string][]string{
+	ROLE_CUSTOMER: {
+		PERMISSION_OWN_ACCOUNT_READ,
+		PERMISSION_OWN_ACCOUNT_WRITE,
+		PERMISSION_OWN_SECURITY_WRITE,
+	},
+	ROLE_SUPPORT: {
+		PERMISSION_OWN_SECURITY_WRITE,
+		PERMISSION_LEDGER_READ_ALL,
+		PERMISSION_ACCOUNT_STATUS_READ,
+		PERMISSION_ACCOUNT_STATUS_WRITE,
+		PERMISSION_PRODUCTS_READ,
+		PERMISSION_FEE_WAIVERS_WRITE,
+		PERMISSION_BATCHES_READ,
+		PERMISSION_USERS_READ,
+		PERMISSION_SESSIONS_REVOKE,
+		PERMISSION_USERS_UNLOCK,
+	},
+	ROLE_AUDITOR: {
+		PERMISSION_OWN_SECURITY_WRITE,
+		PERMISSION_LEDGER_READ_ALL,
+		PERMISSION_ACCOUNT_STATUS_READ,
+		PERMISSION_RECONCILIATION_READ,
+		PERMISSION_PRODUCTS_READ,
+		PERMISSION_BATCHES_READ,
+		PERMISSION_USERS_READ,
+		PERMISSION_API_CLIENTS_READ,
+	},
+	ROLE_OPS: {
+		PERMISSION_OWN_SECURITY_WRITE,
+		PERMISSION_ACCOUNT_STATUS_READ,
+		PERMISSION_BALANCE_BACKFILL,
+		PERMISSION_RECONCILIATION_READ,
+		PERMISSION_RECONCILIATION_RUN,
+		PERMISSION_PRODUCTS_READ,
+		PERMISSION_BATCHES_READ,
+		PERMISSION_BATCHES_WRITE,
+	},
+	ROLE_ADMIN: {
+		PERMISSION_OWN_ACCOUNT_READ,
+		PERMISSION_OWN_ACCOUNT_WRITE,
+		PERMISSION_OWN_SECURITY_WRITE,
+		PERMISSION_LEDGER_READ_ALL,
+		PERMISSION_ACCOUNT_STATUS_READ,
+		PERMISSION_ACCOUNT_STATUS_WRITE,
+		PERMISSION_BALANCE_BACKFILL,
+		PERMISSION_RECONCILIATION_READ,
+		PERMISSION_RECONCILIATION_RUN,
+		PERMISSION_PRODUCTS_READ,
+		PERMISSION_PRODUCTS_WRITE,
+		PERMISSION_FEE_WAIVERS_WRITE,
+		PERMISSION_BATCHES_READ,
+		PERMISSION_BATCHES_WRITE,
+		PERMISSION_USERS_READ,
+		PERMISSION_ROLES_ASSIGN,
+		PERMISSION_SESSIONS_REVOKE,
+		PERMISSION_USERS_UNLOCK,
+		PERMISSION_API_CLIENTS_READ,
+		PERMISSION_API_CLIENTS_WRITE,
+	},
+	ROLE_SYSTEM: {},
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	Role   string `json:"role" binding:"required,oneof=customer support auditor ops admin"`
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type UserRoleChange struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"userId"`
+	FromRole  string    `json:"fromRole"`
+	ToRole    string    `json:"toRole"`
+	Reason    string    `json:"reason"`
+	ChangedBy *int      `json:"changedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
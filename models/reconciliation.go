@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RECONCILIATION_BALANCE_MISMATCH = "BALANCE_MISMATCH"
+	RECONCILIATION_ORPHAN_RECORD    = "ORPHAN_RECORD"
+	RECONCILIATION_MISSING_RECORDS  = "MISSING_RECORDS"
+	RECONCILIATION_ACCOUNT_SKIPPED  = "ACCOUNT_SKIPPED"
+)
+
+type ReconciliationRun struct {
+	RunId              int        `json:"runId"`
+	Status             string     `json:"status"`
+	WriteServiceErrors bool       `json:"writeServiceErrors"`
+	AccountsChecked    int        `json:"accountsChecked"`
+	AccountsSkipped    int        `json:"accountsSkipped"`
+	MismatchCount      int        `json:"mismatchCount"`
+	OrphanCount        int        `json:"orphanCount"`
+	MissingCount       int        `json:"missingCount"`
+	ErrorMessage       *string    `json:"errorMessage,omitempty"`
+	StartedAt          time.Time  `json:"startedAt"`
+	FinishedAt         *time.Time `json:"finishedAt,omitempty"`
+}
+
+type ReconciliationFinding struct {
+	RunId           int        `json:"runId"`
+	FindingType     string     `json:"findingType"`
+	AccountId       *int       `json:"accountId,omitempty"`
+	UserId          *int       `json:"userId,omitempty"`
+	RequestId       *uuid.UUID `json:"requestId,omitempty"`
+	ExpectedBalance *int64     `json:"expectedBalance,omitempty"` // stored in paise
+	ActualBalance   *int64     `json:"actualBalance,omitempty"`   // stored in paise
+	Details         string     `json:"details"`
+}
+
+// ReconciliationRequestLog is one request id of the transactions collection, grouped
+// across every record written for it.
+type ReconciliationRequestLog struct {
+	RequestId       uuid.UUID `bson:"_id"`
+	UserId          int       `bson:"userId"`
+	Amount          float64   `bson:"amount"`
+	TransactionType string    `bson:"transactionType"`
+	Statuses        []string  `bson:"statuses"`
+}
+
+// ProcessedTransactionRequest is a request ProcessTransaction applied to a balance.
+type ProcessedTransactionRequest struct {
+	RequestId   uuid.UUID
+	UserId      int
+	ProcessedAt time.Time
+}
+
+type StartReconciliationRequest struct {
+	WriteServiceErrors *bool `json:"writeServiceErrors,omitempty"`
+}
+
+type GetReconciliationReportResponse struct {
+	Run      ReconciliationRun       `json:"run"`
+	Findings []ReconciliationFinding `json:"findings"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RECURRING_SCHEDULE_NAMESPACE derives the request id of a schedule run from the
+// schedule id and the scheduled date, so a run is always sent with the same id.
+var RECURRING_SCHEDULE_NAMESPACE = uuid.MustParse("6f1c0e64-3f2b-4b7e-9a51-2d6f0f3a8c11")
+
+type RecurringSchedule struct {
+	ScheduleId      int        `json:"scheduleId"`
+	UserId          int        `json:"userId"`
+	TransactionType string     `json:"transactionType"`
+	Amount          int64      `json:"amount"` // stored in paise
+	Frequency       string     `json:"frequency"`
+	DayOfWeek       *int       `json:"dayOfWeek,omitempty"`
+	DayOfMonth      *int       `json:"dayOfMonth,omitempty"`
+	StartDate       time.Time  `json:"startDate"`
+	EndDate         *time.Time `json:"endDate,omitempty"`
+	NextRunDate     *time.Time `json:"nextRunDate,omitempty"`
+	Status          string     `json:"status"`
+}
+
+type RecurringScheduleRun struct {
+	RunId         int        `json:"runId"`
+	ScheduleId    int        `json:"scheduleId"`
+	ScheduledDate time.Time  `json:"scheduledDate"`
+	RequestId     uuid.UUID  `json:"requestId"`
+	Status        string     `json:"status"`
+	Message       *string    `json:"message,omitempty"`
+	AttemptCount  int        `json:"attemptCount"`            // Failed sends of the run
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"` // Set while the run is pending
+}
+
+type CreateRecurringScheduleRequest struct {
+	TransactionType string  `json:"transactionType" binding:"required,oneof=deposit withdraw"`
+	Amount          float64 `json:"amount" binding:"required,gt=0"`
+	Frequency       string  `json:"frequency" binding:"required,oneof=daily weekly monthly"`
+	DayOfWeek       *int    `json:"dayOfWeek,omitempty" binding:"omitempty,min=0,max=6"`
+	DayOfMonth      *int    `json:"dayOfMonth,omitempty" binding:"omitempty,min=1,max=31"`
+	StartDate       string  `json:"startDate" binding:"required,datetime=2006-01-02"`
+	EndDate         *string `json:"endDate,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	OtpCode         string  `json:"otpCode,omitempty" binding:"max=32" redact:"secret"` // Second factor of a withdraw schedule at or above STEP_UP_WITHDRAWAL_THRESHOLD
+}
+
+type RecurringScheduleResponse struct {
+	ScheduleId      int     `json:"scheduleId"`
+	TransactionType string  `json:"transactionType"`
+	Amount          float64 `json:"amount"`
+	Frequency       string  `json:"frequency"`
+	DayOfWeek       *int    `json:"dayOfWeek,omitempty"`
+	DayOfMonth      *int    `json:"dayOfMonth,omitempty"`
+	StartDate       string  `json:"startDate"`
+	EndDate         *string `json:"endDate,omitempty"`
+	NextRunDate     *string `json:"nextRunDate,omitempty"`
+	Status          string  `json:"status"`
+}
+
+type RecurringScheduleRunResponse struct {
+	RunId             int       `json:"runId"`
+	ScheduledDate     string    `json:"scheduledDate"`
+	RequestId         uuid.UUID `json:"requestId"`
+	Status            string    `json:"status"`
+	Message           *string   `json:"message,omitempty"`
+	AttemptCount      int       `json:"attemptCount"` // Failed sends, a pending run with failed sends is retried
+	TransactionStatus *string   `json:"transactionStatus,omitempty"`
+	TransactionMsg    *string   `json:"transactionMessage,omitempty"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+const (
+	LOGIN_FAILURE_SCOPE_EMAIL = "email"
+	LOGIN_FAILURE_SCOPE_IP    = "ip"
+)
+
+const (
+	SECURITY_EVENT_LOGIN_LOCKED   = "login_locked"
+	SECURITY_EVENT_LOGIN_UNLOCKED = "login_unlocked"
+
+	SECURITY_EVENT_TOTP_ENABLED             = "totp_enabled"
+	SECURITY_EVENT_TOTP_DISABLED            = "totp_disabled"
+	SECURITY_EVENT_RECOVERY_CODES_GENERATED = "recovery_codes_generated"
+	SECURITY_EVENT_RECOVERY_CODE_USED       = "recovery_code_used"
+
+	SECURITY_EVENT_API_CLIENT_CREATED        = "api_client_created"
+	SECURITY_EVENT_API_CLIENT_SCOPES_CHANGED = "api_client_scopes_changed"
+	SECURITY_EVENT_API_KEY_CREATED           = "api_key_created"
+	SECURITY_EVENT_API_KEY_REVOKED           = "api_key_revoked"
+
+	SECURITY_EVENT_EMAIL_VERIFIED = "email_verified"
+	SECURITY_EVENT_PASSWORD_RESET = "password_reset"
+)
+
+// LoginFailure counts the failed logins of one email or client IP.
+type LoginFailure struct {
+	Scope        string
+	Key          string
+	FailedCount  int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// RetryAfterInfo is the additionalInfo of a 429 response, handlers copy it to the
+// Retry-After header.
+type RetryAfterInfo struct {
+	RetryAfterSeconds int `json:"retryAfterSeconds"`
+}
+
+type SecurityEvent struct {
+	Id          int       `json:"id"`
+	EventType   string    `json:"eventType"`
+	UserId      *int      `json:"userId,omitempty"`
+	IpAddress   string    `json:"ipAddress,omitempty"`
+	Details     string    `json:"details"`
+	ActorUserId *int      `json:"actorUserId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type UnlockUserResponse struct {
+	WasLocked bool `json:"wasLocked"`
+}
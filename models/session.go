@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SESSION_REVOKED_LOGOUT      = "logout"
+	SESSION_REVOKED_TOKEN_REUSE = "token_reuse"
+	SESSION_REVOKED_ADMIN       = "admin"
+
+	SESSION_REVOKED_PASSWORD_RESET = "password_reset"
+)
+
+// Authentication methods of the amr claim, from RFC 8176.
+const (
+	AMR_PASSWORD = "pwd"
+	AMR_OTP      = "otp"
+)
+
+// Session tells who a session belongs to and how the user authenticated when it
+// started, every token of the session carries it.
+type Session struct {
+	SessionId       uuid.UUID
+	UserId          int
+	Amr             []string
+	AuthenticatedAt time.Time
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required" redact:"token"`
+}
+
+// RefreshToken is a stored refresh token with the state of its session.
+type RefreshToken struct {
+	Id               int
+	Session          Session
+	ExpiresAt        time.Time
+	UsedAt           *time.Time
+	SessionRevokedAt *time.Time
+}
+
+type RevokeSessionsResponse struct {
+	SessionsRevoked int64 `json:"sessionsRevoked"`
+}
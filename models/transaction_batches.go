@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TransactionBatch struct {
+	BatchId     uuid.UUID  `json:"batchId"`
+	FileName    string     `json:"fileName"`
+	Format      string     `json:"format"`
+	TotalRows   int        `json:"totalRows"`
+	Status      string     `json:"status"`
+	CreatedBy   *int       `json:"createdBy,omitempty"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type TransactionBatchRow struct {
+	BatchId         uuid.UUID `json:"batchId"`
+	LineNumber      int       `json:"lineNumber"`
+	RequestId       uuid.UUID `json:"requestId"`
+	UserId          int       `json:"userId"`
+	TransactionType string    `json:"transactionType"`
+	Amount          int64     `json:"amount"` // stored in paise
+	Reference       *string   `json:"reference,omitempty"`
+	Status          string    `json:"status"`
+	Message         *string   `json:"message,omitempty"`
+}
+
+// TransactionBatchRowInput is one row of an uploaded file, a JSON line or a CSV
+// record with the same column names.
+type TransactionBatchRowInput struct {
+	UserId          int     `json:"userId"`
+	Amount          float64 `json:"amount"`
+	TransactionType string  `json:"transactionType"`
+	Reference       string  `json:"reference,omitempty"`
+}
+
+type TransactionBatchRowError struct {
+	LineNumber int    `json:"lineNumber"`
+	Error      string `json:"error"`
+}
+
+type TransactionBatchProgress struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"` // Not sent yet
+	Queued    int `json:"queued"`  // Sent, not processed yet
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`    // Rejected by ProcessTransaction or could not be sent
+	Cancelled int `json:"cancelled"` // Not sent because the batch was cancelled
+}
+
+type TransactionBatchResponse struct {
+	BatchId     uuid.UUID                `json:"batchId"`
+	FileName    string                   `json:"fileName"`
+	Format      string                   `json:"format"`
+	Status      string                   `json:"status"` // processing, completed or cancelled
+	CreatedBy   *int                     `json:"createdBy,omitempty"`
+	CreatedAt   time.Time                `json:"createdAt"`
+	CancelledAt *time.Time               `json:"cancelledAt,omitempty"`
+	Progress    TransactionBatchProgress `json:"progress"`
+}
+
+type TransactionBatchRowResponse struct {
+	LineNumber      int       `json:"lineNumber"`
+	RequestId       uuid.UUID `json:"requestId"`
+	UserId          int       `json:"userId"`
+	TransactionType string    `json:"transactionType"`
+	Amount          float64   `json:"amount"`
+	Reference       *string   `json:"reference,omitempty"`
+	Status          string    `json:"status"` // pending, queued, succeeded, failed or cancelled
+	Message         *string   `json:"message,omitempty"`
+}
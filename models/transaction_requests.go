@@ -0,0 +1,25 @@
+package models
+
+import "github.com/google/uuid"
+
+// KAFKA_HEADER_SCHEMA_VERSION carries the schema version of a transaction request
+// message. A message without it is version 1.
+const KAFKA_HEADER_SCHEMA_VERSION = "schema-version"
+
+// TRANSACTION_REQUEST_SCHEMA_VERSION is the newest version of the transaction request
+// message, see docs/transaction_requests.md. Version 1 is TransactionRequestKafka
+// as JSON, with the amount in rupees.
+const TRANSACTION_REQUEST_SCHEMA_VERSION = 2
+
+// TransactionRequestMessageV2 is version 2 of the transaction request message. The
+// binding tags are checked by the consumer after an older message is upcast.
+type TransactionRequestMessageV2 struct {
+	UserId          int        `json:"userId" binding:"required,gt=0"`
+	AmountPaise     int64      `json:"amountPaise" binding:"required,gt=0"`
+	TransactionType string     `json:"transactionType" binding:"required,oneof=deposit withdraw interest fee"`
+	RequestId       uuid.UUID  `json:"requestId" binding:"required"`
+	TransactionTime int64      `json:"transactionTime" binding:"required,gt=0"`
+	FeeType         string     `json:"feeType,omitempty"`
+	LinkedRequestId *uuid.UUID `json:"linkedRequestId,omitempty"`
+	BatchId         *uuid.UUID `json:"batchId,omitempty"`
+}
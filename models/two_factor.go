@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserTotp struct {
+	UserId          int
+	SecretEncrypted string
+	EnabledAt       *time.Time
+	LastUsedStep    int64
+}
+
+type TotpEnrollmentResponse struct {
+	Secret     string `json:"secret" redact:"secret"`
+	OtpAuthUrl string `json:"otpauthUrl" redact:"secret"` // Rendered as a QR code by the client
+}
+
+// TwoFactorCodeRequest takes a TOTP code or, where noted, a recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,max=32" redact:"secret"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes" redact:"secret"` // Only shown once
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeId uuid.UUID `json:"challengeId" binding:"required"`
+	Code        string    `json:"code" binding:"required,max=32" redact:"secret"`
+}
+
+// StepUpInfo is the additionalInfo of a withdrawal refused for a missing second
+// factor.
+type StepUpInfo struct {
+	StepUpRequired   bool    `json:"stepUpRequired"`
+	ThresholdAmount  float64 `json:"thresholdAmount"`
+	TwoFactorEnabled bool    `json:"twoFactorEnabled"`
+}
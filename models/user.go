@@ -1,35 +1,50 @@
 package models
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
 
 type User struct {
 	ID           int    `json:"id"`
-	Email        string `json:"email"`
-	PasswordHash string `json:"password"`
+	Email        string `json:"email" redact:"email"`
+	PasswordHash string `json:"password" redact:"secret"`
 	FirstName    string `json:"fistName"`
 	LastName     string `json:"lastName"`
 	Role         string `json:"role"`
+
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"` // NULL until the user opens the verification link
 }
 
 type RegisterUserReqBody struct {
 	FirstName string `json:"firstName" binding:"required"`
 	LastName  string `json:"lastName"`
-	Email     string `json:"email" binding:"required,email"`
-	Password  string `json:"password" binding:"required,min=7"`
-	Role      string `json:"role" binding:"required,oneof=admin user"`
+	Email     string `json:"email" binding:"required,email" redact:"email"`
+	Password  string `json:"password" binding:"required,min=7" redact:"secret"`
 }
 
 type LoginRequestBody struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"required,email" redact:"email"`
+	Password string `json:"password" binding:"required" redact:"secret"`
 }
 
+// LoginResponseBody holds the tokens, or only the challenge of the second step when
+// the user has two-factor authentication enabled.
 type LoginResponseBody struct {
-	Token string `json:"token" binding:"required"`
+	Token             string     `json:"token,omitempty" redact:"token"`
+	ExpiresAt         int64      `json:"expiresAt,omitempty"` // Unix time the access token, or the login challenge, expires at
+	RefreshToken      string     `json:"refreshToken,omitempty" redact:"token"`
+	TwoFactorRequired bool       `json:"twoFactorRequired,omitempty"`
+	ChallengeId       *uuid.UUID `json:"challengeId,omitempty"`
 }
 
 type CustomClaims struct {
-	Role string `json:"role"`
-	Name string `json:"name"`
+	Role      string           `json:"role"`
+	Name      string           `json:"name"`
+	SessionId string           `json:"sid"`
+	Amr       []string         `json:"amr,omitempty"`
+	AuthTime  *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
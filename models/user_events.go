@@ -0,0 +1,18 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserEvent is a domain event kept for the event stream of its user.
+type UserEvent struct {
+	EventSeq  int64           `json:"eventSeq"`
+	UserId    int             `json:"userId"`
+	EventId   uuid.UUID       `json:"eventId"`
+	EventType string          `json:"eventType"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	USER_TOKEN_EMAIL_VERIFICATION = "email_verification"
+	USER_TOKEN_PASSWORD_RESET     = "password_reset"
+)
+
+// UserToken is a stored email verification or password reset token.
+type UserToken struct {
+	TokenId   uuid.UUID
+	UserId    int
+	Purpose   string
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required,max=200" redact:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" redact:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required,max=200" redact:"token"`
+	NewPassword string `json:"newPassword" binding:"required,min=7" redact:"secret"`
+}
+
+// Notification is a message to a user, the notifier picks how it is delivered.
+type Notification struct {
+	Kind    string `json:"kind"`
+	To      string `json:"to" redact:"email"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"` // Holds the link with the token, the log sender masks it, the file sender writes it as is
+}
+
+const (
+	NOTIFICATION_EMAIL_VERIFICATION = "email_verification"
+	NOTIFICATION_PASSWORD_RESET     = "password_reset"
+	NOTIFICATION_ALREADY_REGISTERED = "already_registered"
+	NOTIFICATION_PASSWORD_CHANGED   = "password_changed"
+)
+
+const (
+	NOTIFIER_SENDER_LOG  = "log"
+	NOTIFIER_SENDER_FILE = "file"
+)
@@ -2,6 +2,10 @@ package models
 
 var CONTEXT_REQUEST_ID_KEY string = "requestId"
 
+// KAFKA_HEADER_CORRELATION_ID carries the request id of the context a Kafka message
+// was produced in, consumers continue with the same id.
+const KAFKA_HEADER_CORRELATION_ID = "correlation-id"
+
 type SuccessResponse struct {
 	Type    string      `json:"type"`
 	Message interface{} `json:"message"`
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookSubscription struct {
+	SubscriptionId int       `json:"subscriptionId"`
+	UserId         int       `json:"userId"`
+	Url            string    `json:"url"`
+	EventTypes     []string  `json:"eventTypes"`
+	Secret         string    `json:"secret,omitempty" redact:"secret"` // Only returned when the subscription is created
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	DeliveryId     int             `json:"deliveryId"`
+	SubscriptionId int             `json:"subscriptionId"`
+	EventId        uuid.UUID       `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	AttemptCount   int             `json:"attemptCount"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Url            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+type WebhookDeliveryAttempt struct {
+	AttemptId   int       `json:"attemptId"`
+	DeliveryId  int       `json:"deliveryId"`
+	StatusCode  *int      `json:"statusCode,omitempty"`
+	Error       *string   `json:"error,omitempty"`
+	DurationMs  int       `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+type CreateWebhookSubscriptionRequest struct {
+	Url        string   `json:"url" binding:"required,url"`
+	EventTypes []string `json:"eventTypes" binding:"required,min=1,dive,oneof=AccountCreated TransactionCompleted TransactionFailed BalanceChanged"`
+}
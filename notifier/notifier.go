@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"banking_ledger/redact"
+	"banking_ledger/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Sender delivers notifications to users. An email or SMS provider is added by
+// implementing it and picking it in InitSender.
+type Sender interface {
+	Send(ctx context.Context, notification models.Notification) error
+}
+
+var sender Sender
+
+// InitSender picks the sender of NOTIFIER_SENDER, there is no default. The log sender
+// masks the tokens of links, the file sender writes them as is for development.
+func InitSender() error {
+
+	switch config.NOTIFIER_SENDER {
+	case "":
+		return fmt.Errorf("NOTIFIER_SENDER is required, use log or file")
+	case models.NOTIFIER_SENDER_LOG:
+		sender = logSender{}
+	case models.NOTIFIER_SENDER_FILE:
+		if config.NOTIFIER_FILE_PATH == "" {
+			return fmt.Errorf("NOTIFIER_FILE_PATH is required with NOTIFIER_SENDER %q", config.NOTIFIER_SENDER)
+		}
+		sender = &fileSender{path: config.NOTIFIER_FILE_PATH}
+	default:
+		return fmt.Errorf("unknown NOTIFIER_SENDER %q, use log or file", config.NOTIFIER_SENDER)
+	}
+
+	return nil
+}
+
+// Send delivers the notification with the configured sender.
+func Send(ctx context.Context, notification models.Notification) *models.ApplicationError {
+
+	if err := sender.Send(ctx, notification); err != nil {
+		errMsg := fmt.Sprintf("Send: Could not send %s notification! Error: %s", notification.Kind, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 1101, errMsg, "Could not send notification!", nil)
+	}
+
+	return nil
+}
+
+type logSender struct{}
+
+func (logSender) Send(ctx context.Context, notification models.Notification) error {
+
+	logger.WithContext(ctx).Info("Notification",
+		zap.String("kind", notification.Kind),
+		zap.String("to", notification.To),
+		zap.String("subject", notification.Subject),
+		zap.String("body", redact.String(notification.Body)))
+
+	return nil
+}
+
+// fileSender appends every notification to a file as one JSON line.
+type fileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+type fileNotification struct {
+	models.Notification
+	SentAt        time.Time `json:"sentAt"`
+	CorrelationId string    `json:"correlationId"`
+}
+
+func (f *fileSender) Send(ctx context.Context, notification models.Notification) error {
+
+	line, err := json.Marshal(fileNotification{
+		Notification:  notification,
+		SentAt:        time.Now(),
+		CorrelationId: utils.GetRequestIdFromContext(ctx),
+	})
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
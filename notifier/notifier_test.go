@@ -0,0 +1,70 @@
+package notifier
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestInitSender(t *testing.T) {
+
+	defaultSender, defaultConfig, defaultPath := sender, config.NOTIFIER_SENDER, config.NOTIFIER_FILE_PATH
+	defer func() {
+		sender, config.NOTIFIER_SENDER, config.NOTIFIER_FILE_PATH = defaultSender, defaultConfig, defaultPath
+	}()
+
+	tests := []struct {
+		sender      string
+		filePath    string
+		expectError bool
+	}{
+		{sender: "", expectError: true},
+		{sender: models.NOTIFIER_SENDER_LOG},
+		{sender: models.NOTIFIER_SENDER_FILE, filePath: "notifications.log"},
+		{sender: models.NOTIFIER_SENDER_FILE, filePath: "", expectError: true},
+		{sender: "smtp", expectError: true},
+	}
+
+	for _, test := range tests {
+		config.NOTIFIER_SENDER, config.NOTIFIER_FILE_PATH = test.sender, test.filePath
+		if err := InitSender(); (err != nil) != test.expectError {
+			t.Errorf("InitSender with %q error = %v, expected error %v", test.sender, err, test.expectError)
+		}
+	}
+}
+
+func TestLogSenderMasksTokens(t *testing.T) {
+
+	core, logs := observer.New(zap.InfoLevel)
+	defaultLog := logger.Log
+	logger.Log = zap.New(core)
+	defer func() { logger.Log = defaultLog }()
+
+	token := "1b4e28ba2fa14d3ba3f5ef19b5a7633b.1718000000.q3Nn0xZ4_VwQe-1bT2kJYcG8sYzPZlD1fH6m9RrAaBc"
+
+	err := logSender{}.Send(context.Background(), models.Notification{
+		Kind:    models.NOTIFICATION_PASSWORD_RESET,
+		To:      "bob@example.com",
+		Subject: "Reset your password",
+		Body:    "Reset it at http://localhost:3000/reset-password?token=" + token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d lines, expected 1", len(entries))
+	}
+
+	body, _ := entries[0].ContextMap()["body"].(string)
+	if strings.Contains(body, token) || !strings.Contains(body, "token=[REDACTED]") {
+		t.Errorf("logged body %q, expected the token to be masked", body)
+	}
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/models"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps the token buckets. Take refills the bucket of the key for the time since
+// its last request and takes one token when there is one, it returns the tokens left
+// or, when the request is not allowed, the tokens there are.
+type Store interface {
+	Take(ctx context.Context, key string, capacity float64, refillPerSecond float64) (allowed bool, tokens float64, appError *models.ApplicationError)
+}
+
+var store Store
+
+// InitStore picks the store of RATE_LIMIT_STORE. The memory store limits every
+// replica on its own, the postgres store shares the limits between replicas.
+func InitStore() error {
+
+	switch config.RATE_LIMIT_STORE {
+	case models.RATE_LIMIT_STORE_MEMORY:
+		store = newMemoryStore()
+	case models.RATE_LIMIT_STORE_POSTGRES:
+		store = postgresStore{}
+	default:
+		return fmt.Errorf("unknown RATE_LIMIT_STORE %q, use memory or postgres", config.RATE_LIMIT_STORE)
+	}
+
+	return nil
+}
+
+// Take counts one request of the subject against the policy.
+func Take(ctx context.Context, policy models.RateLimitPolicy, subject string) (models.RateLimitDecision, *models.ApplicationError) {
+
+	capacity := float64(policy.Capacity)
+	refillPerSecond := float64(policy.PerMinute) / 60
+	key := fmt.Sprintf("%s:%s:%s", policy.Name, policy.Key, subject)
+
+	allowed, tokens, appError := store.Take(ctx, key, capacity, refillPerSecond)
+	if appError != nil {
+		return models.RateLimitDecision{}, appError
+	}
+
+	decision := models.RateLimitDecision{
+		Allowed:    allowed,
+		Limit:      policy.Capacity,
+		Remaining:  max(int(math.Floor(tokens)), 0),
+		ResetAfter: secondsToDuration((capacity - tokens) / refillPerSecond),
+	}
+
+	if !allowed {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / refillPerSecond)
+	}
+
+	return decision, nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Max(seconds, 0) * float64(time.Second))
+}
+
+type postgresStore struct{}
+
+func (postgresStore) Take(ctx context.Context, key string, capacity float64, refillPerSecond float64) (bool, float64, *models.ApplicationError) {
+	return database.RateLimitDb.TakeRateLimitToken(ctx, key, capacity, refillPerSecond)
+}
+
+// memorySweepInterval is how often the memory store forgets full buckets, a bucket
+// that is not there is full.
+const memorySweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: map[string]*memoryBucket{}, lastSweep: time.Now()}
+}
+
+func (m *memoryStore) Take(ctx context.Context, key string, capacity float64, refillPerSecond float64) (bool, float64, *models.ApplicationError) {
+
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > memorySweepInterval {
+		for bucketKey, bucket := range m.buckets {
+			if now.After(bucket.fullAt) {
+				delete(m.buckets, bucketKey)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	tokens := capacity
+	if bucket, ok := m.buckets[key]; ok {
+		tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*refillPerSecond)
+	}
+
+	if tokens < 1 {
+		return false, tokens, nil
+	}
+
+	tokens--
+
+	m.buckets[key] = &memoryBucket{
+		tokens:    tokens,
+		updatedAt: now,
+		fullAt:    now.Add(secondsToDuration((capacity - tokens) / refillPerSecond)),
+	}
+
+	return true, tokens, nil
+}
@@ -0,0 +1,204 @@
+package ratelimit
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"context"
+	"testing"
+	"time"
+)
+
+// useMemoryStore points Take at a new memory store for the test.
+func useMemoryStore(t *testing.T) *memoryStore {
+
+	memory := newMemoryStore()
+
+	defaultStore := store
+	store = memory
+	t.Cleanup(func() { store = defaultStore })
+
+	return memory
+}
+
+// backdate moves the last request of the bucket into the past, as if elapsed had passed.
+func backdate(memory *memoryStore, key string, elapsed time.Duration) {
+	bucket := memory.buckets[key]
+	bucket.updatedAt = bucket.updatedAt.Add(-elapsed)
+	bucket.fullAt = bucket.fullAt.Add(-elapsed)
+}
+
+func TestTakeTokenBucket(t *testing.T) {
+
+	policy := models.RateLimitPolicy{Name: "login", Key: models.RATE_LIMIT_KEY_IP, Capacity: 3, PerMinute: 60}
+	key := "login:ip:10.0.0.1"
+
+	type step struct {
+		elapsed           time.Duration // Backdated before the request
+		expectedAllowed   bool
+		expectedRemaining int
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst up to the capacity",
+			steps: []step{
+				{expectedAllowed: true, expectedRemaining: 2},
+				{expectedAllowed: true, expectedRemaining: 1},
+				{expectedAllowed: true, expectedRemaining: 0},
+				{expectedAllowed: false, expectedRemaining: 0},
+				{expectedAllowed: false, expectedRemaining: 0},
+			},
+		},
+		{
+			name: "refill of one token a second",
+			steps: []step{
+				{expectedAllowed: true, expectedRemaining: 2},
+				{expectedAllowed: true, expectedRemaining: 1},
+				{expectedAllowed: true, expectedRemaining: 0},
+				{elapsed: time.Second, expectedAllowed: true, expectedRemaining: 0},
+				{expectedAllowed: false, expectedRemaining: 0},
+				{elapsed: 2 * time.Second, expectedAllowed: true, expectedRemaining: 1},
+			},
+		},
+		{
+			name: "half a token is not enough",
+			steps: []step{
+				{expectedAllowed: true, expectedRemaining: 2},
+				{expectedAllowed: true, expectedRemaining: 1},
+				{expectedAllowed: true, expectedRemaining: 0},
+				{elapsed: 500 * time.Millisecond, expectedAllowed: false, expectedRemaining: 0},
+				{elapsed: 500 * time.Millisecond, expectedAllowed: true, expectedRemaining: 0},
+			},
+		},
+		{
+			name: "refill stops at the capacity",
+			steps: []step{
+				{expectedAllowed: true, expectedRemaining: 2},
+				{expectedAllowed: true, expectedRemaining: 1},
+				{elapsed: time.Hour, expectedAllowed: true, expectedRemaining: 2},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			memory := useMemoryStore(t)
+
+			for i, step := range test.steps {
+
+				if step.elapsed > 0 {
+					backdate(memory, key, step.elapsed)
+				}
+
+				decision, appError := Take(context.Background(), policy, "10.0.0.1")
+				if appError != nil {
+					t.Fatalf("request %d: Take returned %s", i+1, appError.Message.ErrorMessage)
+				}
+
+				if decision.Allowed != step.expectedAllowed || decision.Remaining != step.expectedRemaining || decision.Limit != policy.Capacity {
+					t.Errorf("request %d: decision = %+v, expected allowed %v remaining %d", i+1, decision, step.expectedAllowed, step.expectedRemaining)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeRetryAndResetAfter(t *testing.T) {
+
+	useMemoryStore(t)
+
+	policy := models.RateLimitPolicy{Name: "transactions", Key: models.RATE_LIMIT_KEY_USER, Capacity: 2, PerMinute: 6}
+
+	first, _ := Take(context.Background(), policy, "7")
+	if first.RetryAfter != 0 || !near(first.ResetAfter, 10*time.Second) {
+		t.Errorf("first request: retryAfter = %s resetAfter = %s, expected 0 and 10s", first.RetryAfter, first.ResetAfter)
+	}
+
+	Take(context.Background(), policy, "7")
+
+	denied, _ := Take(context.Background(), policy, "7")
+	if denied.Allowed || !near(denied.RetryAfter, 10*time.Second) || !near(denied.ResetAfter, 20*time.Second) {
+		t.Errorf("denied request: %+v, expected retryAfter 10s and resetAfter 20s", denied)
+	}
+}
+
+func TestTakeSeparatesBuckets(t *testing.T) {
+
+	useMemoryStore(t)
+
+	login := models.RateLimitPolicy{Name: "login", Key: models.RATE_LIMIT_KEY_IP, Capacity: 1, PerMinute: 1}
+	register := models.RateLimitPolicy{Name: "register", Key: models.RATE_LIMIT_KEY_IP, Capacity: 1, PerMinute: 1}
+
+	tests := []struct {
+		policy          models.RateLimitPolicy
+		subject         string
+		expectedAllowed bool
+	}{
+		{policy: login, subject: "10.0.0.1", expectedAllowed: true},
+		{policy: login, subject: "10.0.0.1", expectedAllowed: false},
+		{policy: login, subject: "10.0.0.2", expectedAllowed: true},
+		{policy: register, subject: "10.0.0.1", expectedAllowed: true},
+		{policy: register, subject: "10.0.0.1", expectedAllowed: false},
+	}
+
+	for i, test := range tests {
+		decision, appError := Take(context.Background(), test.policy, test.subject)
+		if appError != nil || decision.Allowed != test.expectedAllowed {
+			t.Errorf("request %d of %s %s: allowed = %v, expected %v", i+1, test.policy.Name, test.subject, decision.Allowed, test.expectedAllowed)
+		}
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+
+	memory := newMemoryStore()
+
+	memory.Take(context.Background(), "refilled", 2, 1)
+	memory.Take(context.Background(), "drained", 2, 1)
+	memory.Take(context.Background(), "drained", 2, 1)
+
+	backdate(memory, "refilled", 2*time.Second)
+	memory.lastSweep = memory.lastSweep.Add(-2 * memorySweepInterval)
+
+	memory.Take(context.Background(), "other", 2, 1)
+
+	if _, ok := memory.buckets["refilled"]; ok {
+		t.Error("full bucket was not swept")
+	}
+
+	if _, ok := memory.buckets["drained"]; !ok {
+		t.Error("bucket that is not full yet was swept")
+	}
+}
+
+func TestInitStore(t *testing.T) {
+
+	defaultStore, defaultConfig := store, config.RATE_LIMIT_STORE
+	defer func() { store, config.RATE_LIMIT_STORE = defaultStore, defaultConfig }()
+
+	tests := []struct {
+		config      string
+		expectError bool
+	}{
+		{config: models.RATE_LIMIT_STORE_MEMORY},
+		{config: models.RATE_LIMIT_STORE_POSTGRES},
+		{config: "redis", expectError: true},
+	}
+
+	for _, test := range tests {
+		config.RATE_LIMIT_STORE = test.config
+		if err := InitStore(); (err != nil) != test.expectError {
+			t.Errorf("InitStore with %q error = %v, expected error %v", test.config, err, test.expectError)
+		}
+	}
+}
+
+// near allows for the time that passes between the requests of a test.
+func near(actual time.Duration, expected time.Duration) bool {
+	difference := actual - expected
+	return difference > -50*time.Millisecond && difference < 50*time.Millisecond
+}
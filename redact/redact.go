@@ -0,0 +1,239 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const REDACTED = "[REDACTED]"
+
+// Kinds of the redact struct tag.
+const (
+	KIND_SECRET = "secret" // Replaced as a whole
+	KIND_EMAIL  = "email"  // Only the first letter of the local part and the domain are kept
+	KIND_TOKEN  = "token"  // Only the last 4 characters are kept
+)
+
+// deniedFields are redacted wherever they appear, matched case-insensitively.
+var deniedFields = map[string]string{
+	"password":      KIND_SECRET,
+	"passwordhash":  KIND_SECRET,
+	"newpassword":   KIND_SECRET,
+	"secret":        KIND_SECRET,
+	"token":         KIND_TOKEN,
+	"accesstoken":   KIND_TOKEN,
+	"refreshtoken":  KIND_TOKEN,
+	"apikey":        KIND_TOKEN,
+	"x-api-key":     KIND_TOKEN,
+	"authorization": KIND_TOKEN,
+	"code":          KIND_SECRET, // Second factor codes
+	"otpcode":       KIND_SECRET,
+	"recoverycodes": KIND_SECRET,
+}
+
+var (
+	emailPattern     = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	jwtPattern       = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern    = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	userTokenPattern = regexp.MustCompile(`\b[0-9a-f]{32}\.[0-9]+\.[A-Za-z0-9_-]{43}`) // Email verification and password reset tokens, see utils.SignUserToken
+	jsonFieldPattern = regexp.MustCompile(`(?i)"(password|passwordHash|newPassword|secret|token|accessToken|refreshToken|apiKey|x-api-key|authorization|code|otpCode|recoveryCodes)"\s*:\s*(?:"(?:[^"\\]|\\.)*"|\[(?:[^\]"]|"(?:[^"\\]|\\.)*")*\])`)
+)
+
+// Rule narrows what is logged of one route. When Allow is set only those top level
+// fields are kept, Deny adds fields to the default deny list.
+type Rule struct {
+	Allow []string
+	Deny  []string
+}
+
+// String masks what can be recognised in free text: denied JSON fields, email
+// addresses, JWTs, user tokens and bearer tokens. Error messages often embed request bodies.
+func String(text string) string {
+
+	text = jsonFieldPattern.ReplaceAllString(text, `"$1":"`+REDACTED+`"`)
+	text = jwtPattern.ReplaceAllString(text, REDACTED)
+	text = userTokenPattern.ReplaceAllString(text, REDACTED)
+	text = bearerPattern.ReplaceAllString(text, "${1}"+REDACTED)
+	text = emailPattern.ReplaceAllString(text, "$1***@$2")
+
+	return text
+}
+
+// JSON redacts a JSON document with the default deny list and the rule, text that is
+// not JSON is redacted as free text.
+func JSON(document []byte, rule Rule) string {
+
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return String(string(document))
+	}
+
+	fields := map[string]string{}
+	for _, field := range rule.Deny {
+		fields[strings.ToLower(field)] = KIND_SECRET
+	}
+
+	if object, ok := value.(map[string]interface{}); ok && len(rule.Allow) > 0 {
+		for key := range object {
+			if !containsFold(rule.Allow, key) {
+				object[key] = REDACTED
+			}
+		}
+	}
+
+	return marshal(redactValue(value, fields))
+}
+
+// Marshal converts a value to JSON like json.Marshal and redacts the fields tagged
+// with `redact:"<kind>"` and the default deny list.
+func Marshal(value interface{}) (string, error) {
+
+	document, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	var decoded interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", err
+	}
+
+	return marshal(redactValue(decoded, taggedFields(reflect.TypeOf(value)))), nil
+}
+
+func redactValue(value interface{}, fields map[string]string) interface{} {
+
+	switch typed := value.(type) {
+
+	case map[string]interface{}:
+		for key, fieldValue := range typed {
+			kind, ok := fields[strings.ToLower(key)]
+			if !ok {
+				kind, ok = deniedFields[strings.ToLower(key)]
+			}
+			if ok {
+				typed[key] = mask(fieldValue, kind)
+				continue
+			}
+			typed[key] = redactValue(fieldValue, fields)
+		}
+		return typed
+
+	case []interface{}:
+		for i := range typed {
+			typed[i] = redactValue(typed[i], fields)
+		}
+		return typed
+
+	case string:
+		return String(typed)
+	}
+
+	return value
+}
+
+func mask(value interface{}, kind string) interface{} {
+
+	text, ok := value.(string)
+	if !ok || text == "" {
+		if value == nil {
+			return nil
+		}
+		return REDACTED
+	}
+
+	switch kind {
+	case KIND_EMAIL:
+		return emailPattern.ReplaceAllString(text, "$1***@$2")
+	case KIND_TOKEN:
+		if len(text) > 12 {
+			return "..." + text[len(text)-4:]
+		}
+	}
+
+	return REDACTED
+}
+
+var taggedFieldsCache sync.Map
+
+// taggedFields returns the JSON names of every field tagged with redact in the type
+// and the types it contains.
+func taggedFields(t reflect.Type) map[string]string {
+
+	if t == nil {
+		return map[string]string{}
+	}
+
+	if cached, ok := taggedFieldsCache.Load(t); ok {
+		return cached.(map[string]string)
+	}
+
+	fields := map[string]string{}
+	collectTaggedFields(t, fields, map[reflect.Type]bool{})
+	taggedFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+func collectTaggedFields(t reflect.Type, fields map[string]string, seen map[reflect.Type]bool) {
+
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		if kind := field.Tag.Get("redact"); kind != "" {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			if name != "-" {
+				fields[strings.ToLower(name)] = kind
+			}
+		}
+
+		collectTaggedFields(field.Type, fields, seen)
+	}
+}
+
+func containsFold(values []string, value string) bool {
+
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func marshal(value interface{}) string {
+
+	document, err := json.Marshal(value)
+	if err != nil {
+		return REDACTED
+	}
+
+	return string(document)
+}
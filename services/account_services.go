@@ -1,22 +1,22 @@
 package services
 
 import (
-	"banking_ledger/clients"
-	"banking_ledger/config"
 	"banking_ledger/database"
 	"banking_ledger/logger"
+	"banking_ledger/metrics"
 	"banking_ledger/misc"
 	"banking_ledger/models"
+	"banking_ledger/tracing"
 	"banking_ledger/utils"
 	"context"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -26,7 +26,7 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 	tx, err := database.AccDb.BeginTx(ctx)
 	if err != nil {
 		errMsg := "CreateAccountForUser: Could not begin transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5001, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
@@ -49,6 +49,7 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 				TransactionMsg:    transactionErrMsg,
 				RequestId:         uuid.New(),
 				TransactionTime:   time.Now().Unix(),
+				CorrelationId:     utils.GetRequestIdFromContext(ctx),
 			}
 
 			txCollection := database.GetCollection("transactions")
@@ -56,7 +57,7 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 			_, err = txCollection.InsertOne(ctx, transactionToLog)
 			if err != nil {
 				errMsg := fmt.Sprintf("CreateAccountForUser: Failed to insert transaction into MongoDB! Error: %s", err.Error())
-				logger.Log.Error(errMsg)
+				logger.WithContext(ctx).Error(errMsg)
 				appError := utils.RenderAppError(ctx, 5002, errMsg, errMsg, nil)
 				misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 			}
@@ -73,13 +74,17 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 
 	if exists {
 		errMsg := "Account already exists for this user"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		return utils.RenderApiError(ctx, http.StatusBadRequest, 5003, errMsg, "", nil)
 	}
 
-	balanceInPaise := int64(req.InitialBalance * 100)
+	balanceInPaise := utils.ConvertRupeesToPaise(req.InitialBalance)
 
-	appError = database.AccDb.CreateAccountForUser(ctx, tx, userId, balanceInPaise)
+	if req.AccountType == "" {
+		req.AccountType = "savings"
+	}
+
+	accountId, appError := database.AccDb.CreateAccountForUser(ctx, tx, userId, balanceInPaise, req.AccountType)
 	if appError != nil {
 		transactionErrMsg = "Internal Error"
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateAccountForUser-> Failed to create account for user", appError)
@@ -94,6 +99,33 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 		TransactionMsg:    "Account created successfully",
 		RequestId:         uuid.New(),
 		TransactionTime:   time.Now().Unix(),
+		CorrelationId:     utils.GetRequestIdFromContext(ctx),
+	}
+
+	account := models.Account{
+		AccountID:   accountId,
+		UserID:      userId,
+		AccountType: req.AccountType,
+	}
+
+	accountCreated := models.AccountCreatedEvent{
+		AccountId:      accountId,
+		UserId:         userId,
+		AccountType:    req.AccountType,
+		InitialBalance: req.InitialBalance,
+	}
+
+	events := []models.DomainEvent{
+		newDomainEvent(models.EVENT_ACCOUNT_CREATED, transactionToLog.RequestId, transactionToLog.RequestId, userId, accountCreated),
+		newTransactionEvent(models.EVENT_TRANSACTION_COMPLETED, transactionToLog.RequestId, transactionToLog),
+		newBalanceChangedEvent(transactionToLog.RequestId, account, 0, balanceInPaise),
+	}
+
+	appError = persistDomainEvents(ctx, tx, events)
+	if appError != nil {
+		transactionErrMsg = "Internal Error"
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateAccountForUser-> Failed to persist domain events", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
 	txCollection := database.GetCollection("transactions")
@@ -101,7 +133,7 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 	_, err = txCollection.InsertOne(ctx, transactionToLog)
 	if err != nil {
 		errMsg := fmt.Sprintf("CreateAccountForUser: Failed to insert transaction into MongoDB! Error: %s", err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Internal Error"
 		appError := utils.RenderAppError(ctx, 5004, errMsg, errMsg, nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
@@ -110,7 +142,7 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 
 	if err := tx.Commit(ctx); err != nil {
 		errMsg := "CreateAccountForUser: Failed to commit transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Internal Error"
 		appError := utils.RenderAppError(ctx, 5005, errMsg, errMsg, nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
@@ -119,33 +151,88 @@ func CreateAccountForUser(ctx context.Context, userId int, req models.CreateAcco
 
 	txCommitted = true
 
+	publishDomainEvents(ctx, events...)
+
 	return nil
 
 }
 
-func FundTransaction(ctx context.Context, userId int, req models.FundTransactionRequest) *models.ApiError {
+// isWholePaiseAmount reports whether a rupee amount is at least one paisa once rounded
+// to paise. binding only checks the amount before rounding, a request for 0.004
+// would be queued for 0 paise and dropped by the consumer.
+func isWholePaiseAmount(amount float64) bool {
+	return utils.ConvertRupeesToPaise(amount) > 0
+}
+
+// FundTransaction queues a deposit or withdrawal. secondFactorRecent tells whether the
+// access token proves a recent second factor, see checkWithdrawalStepUp.
+func FundTransaction(ctx context.Context, userId int, req models.FundTransactionRequest, secondFactorRecent bool) (_ *models.FundTransactionResponse, apiError *models.ApiError) {
+
+	ctx, span := tracing.Tracer.Start(ctx, "FundTransaction", trace.WithAttributes(
+		tracing.AttributeUserId.Int(userId),
+		tracing.AttributeTransactionType.String(req.TransactionType),
+	))
+	defer func() {
+		if apiError != nil {
+			tracing.EndSpan(span, &apiError.ApplicationError)
+			return
+		}
+		tracing.EndSpan(span, nil)
+	}()
+
+	if !isWholePaiseAmount(req.Amount) {
+		errMsg := "amount must be at least 0.01"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5028, errMsg, errMsg, nil)
+	}
+
+	apiError = checkWithdrawalStepUp(ctx, userId, req.TransactionType, req.Amount, req.OtpCode, secondFactorRecent)
+	if apiError != nil {
+		return nil, apiError
+	}
 
 	tx, err := database.AccDb.BeginTx(ctx)
 	if err != nil {
 		errMsg := "FundTransaction: Could not begin transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5006, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
-		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
 	defer tx.Rollback(ctx)
 
-	exists, _, appError := database.AccDb.GetAccountByUserId(ctx, tx, userId)
+	exists, account, appError := database.AccDb.GetAccountByUserId(ctx, tx, userId)
 	if appError != nil {
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "Failed to check if account exists", appError)
-		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
 	if !exists {
 		errMsg := "Account does not exists for this user!"
-		logger.Log.Error(errMsg)
-		return utils.RenderApiError(ctx, http.StatusBadRequest, 5007, errMsg, "", nil)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5007, errMsg, "", nil)
+	}
+
+	statusBlock := accountStatusBlocksTransaction(account.Status, req.TransactionType)
+	if statusBlock != "" {
+		errMsg := fmt.Sprintf("FundTransaction: Transaction not allowed on %s account! UserId: %d", account.Status, userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5025, errMsg, statusBlock, nil)
+	}
+
+	// Only the transactions already processed are counted here, ProcessTransaction
+	// enforces the limits again when the request is applied.
+	limitBreach, appError := checkTransactionLimits(ctx, tx, account, req.TransactionType, utils.ConvertRupeesToPaise(req.Amount), startOfDay(time.Now()))
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "FundTransaction-> Failed to check transaction limits", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if limitBreach != "" {
+		errMsg := fmt.Sprintf("FundTransaction: Transaction limit exceeded for user! UserId: %d. %s", userId, limitBreach)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5024, errMsg, limitBreach, nil)
 	}
 
 	kafkaMsg := models.TransactionRequestKafka{
@@ -156,22 +243,27 @@ func FundTransaction(ctx context.Context, userId int, req models.FundTransaction
 		TransactionTime: time.Now().Unix(),
 	}
 
-	appError = clients.SendMessageToKafkaTopic(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, kafkaMsg, strconv.Itoa(userId))
+	appError = queueTransactionRequest(ctx, kafkaMsg)
 	if appError != nil {
 		errMsg := fmt.Sprintf("FundTransaction: Failed to send message to Kafka topic! Error: %s", appError.Message.ErrorMessage)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
-		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		errMsg := "FundTransaction: Failed to commit transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5008, errMsg, errMsg, nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
-		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
-	return nil
+	response := models.FundTransactionResponse{
+		RequestId: kafkaMsg.RequestId,
+		Status:    "queued",
+	}
+
+	return &response, nil
 
 }
 
@@ -180,12 +272,28 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 	tx, err := database.AccDb.BeginTx(ctx)
 	if err != nil {
 		errMsg := "ProcessTransaction: Could not begin transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5009, errMsg, "", nil)
 		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		metrics.ObserveTransactionProcessed(transaction.TransactionType, "error")
+		return appError
+	}
+
+	isNew, appError := database.AccDb.MarkTransactionRequestProcessed(ctx, tx, transaction.RequestId, transaction.UserId)
+	if appError != nil {
+		tx.Rollback(ctx)
+		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "ProcessTransaction: Failed to record transaction request", appError)
+		metrics.ObserveTransactionProcessed(transaction.TransactionType, "error")
 		return appError
 	}
 
+	if !isNew {
+		tx.Rollback(ctx)
+		logger.WithContext(ctx).Info(fmt.Sprintf("ProcessTransaction: Skipping already processed request! RequestId: %s", transaction.RequestId.String()))
+		metrics.ObserveTransactionProcessed(transaction.TransactionType, "duplicate")
+		return nil
+	}
+
 	transactionErrMsg := "Transaction failed"
 	txCommitted := false
 
@@ -194,6 +302,7 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 		if !txCommitted {
 
 			tx.Rollback(ctx)
+			metrics.ObserveTransactionProcessed(transaction.TransactionType, "failed")
 
 			transactionToLog := models.TransactionCollection{
 				UserId:            transaction.UserId,
@@ -203,6 +312,10 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 				TransactionMsg:    transactionErrMsg,
 				RequestId:         transaction.RequestId,
 				TransactionTime:   transaction.TransactionTime,
+				FeeType:           transaction.FeeType,
+				LinkedRequestId:   transaction.LinkedRequestId,
+				BatchId:           transaction.BatchId,
+				CorrelationId:     utils.GetRequestIdFromContext(ctx),
 			}
 
 			txCollection := database.GetCollection("transactions")
@@ -210,18 +323,51 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 			_, err = txCollection.InsertOne(ctx, transactionToLog)
 			if err != nil {
 				errMsg := fmt.Sprintf("ProcessTransaction: Failed to insert transaction into MongoDB! Error: %s", err.Error())
-				logger.Log.Error(errMsg)
+				logger.WithContext(ctx).Error(errMsg)
 				appError := utils.RenderAppError(ctx, 5010, errMsg, errMsg, nil)
 				misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 			}
+
+			if transaction.TransactionType == "interest" {
+				appError := database.InterestDb.FailInterestPosting(ctx, transaction.RequestId)
+				if appError != nil {
+					misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "ProcessTransaction: Failed to release rejected interest posting", appError)
+				}
+			}
+
+			if appError := failRejectedFee(ctx, transaction, transactionErrMsg); appError != nil {
+				misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "ProcessTransaction: Failed to mark rejected fee failed", appError)
+			}
+
+			failedEvent := newTransactionEvent(models.EVENT_TRANSACTION_FAILED, transaction.RequestId, transactionToLog)
+			persistRolledBackDomainEvents(ctx, failedEvent)
+			publishDomainEvents(ctx, failedEvent)
 		}
 
 	}()
 
+	if transaction.BatchId != nil {
+
+		batchStatus, appError := database.BatchDb.GetBatchStatus(ctx, tx, *transaction.BatchId)
+		if appError != nil {
+			transactionErrMsg = "Internal Error!"
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to get transaction batch status", appError)
+			return appError
+		}
+
+		if batchStatus == "cancelled" {
+			errMsg := fmt.Sprintf("ProcessTransaction: Transaction batch was cancelled! BatchId: %s, RequestId: %s", transaction.BatchId.String(), transaction.RequestId.String())
+			logger.WithContext(ctx).Error(errMsg)
+			transactionErrMsg = "Transaction batch was cancelled"
+			appError := utils.RenderAppError(ctx, 5027, errMsg, errMsg, nil)
+			return appError
+		}
+	}
+
 	exists, balance, appError := database.AccDb.GetBalanceForUserId(ctx, tx, transaction.UserId)
 	if appError != nil {
 		errMsg := fmt.Sprintf("ProcessTransaction: Failed to get balance for user %d", transaction.UserId)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Failed to get balance for user"
 		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 		return appError
@@ -229,30 +375,78 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 
 	if !exists {
 		errMsg := fmt.Sprintf("ProcessTransaction: Account details not found for user! UserId: %d", transaction.UserId)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Failed to get balance for user"
 		appError := utils.RenderAppError(ctx, 5011, errMsg, "", nil)
 		return appError
 	}
 
-	if transaction.TransactionType == "withdraw" && balance < int64(transaction.Amount*100) {
+	_, account, appError := database.AccDb.GetAccountByUserId(ctx, tx, transaction.UserId)
+	if appError != nil {
+		transactionErrMsg = "Internal Error!"
+		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to get account for user", appError)
+		return appError
+	}
+
+	statusBlock := accountStatusBlocksTransaction(account.Status, transaction.TransactionType)
+	if statusBlock != "" {
+		errMsg := fmt.Sprintf("ProcessTransaction: Transaction not allowed on %s account! UserId: %d", account.Status, transaction.UserId)
+		logger.WithContext(ctx).Error(errMsg)
+		transactionErrMsg = statusBlock
+		appError := utils.RenderAppError(ctx, 5026, errMsg, errMsg, nil)
+		return appError
+	}
+
+	amountInPaise := utils.ConvertRupeesToPaise(transaction.Amount)
+
+	var withdrawalFee *models.FeeCharge
+	if transaction.TransactionType == "withdraw" {
+		withdrawalFee, appError = assessWithdrawalFee(ctx, tx, account, transaction)
+		if appError != nil {
+			transactionErrMsg = "Internal Error!"
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to assess withdrawal fee", appError)
+			return appError
+		}
+	}
+
+	debitAmount := amountInPaise
+	if withdrawalFee != nil && withdrawalFee.Status == "charged" {
+		debitAmount += withdrawalFee.Amount
+	}
+
+	if (transaction.TransactionType == "withdraw" || transaction.TransactionType == "fee") && balance < debitAmount {
 		errMsg := fmt.Sprintf("ProcessTransaction: Insufficient balance for user! UserId: %d", transaction.UserId)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Insufficient balance for user!"
 		appError := utils.RenderAppError(ctx, 5012, errMsg, errMsg, nil)
 		return appError
 	}
 
+	limitBreach, appError := enforceTransactionLimits(ctx, tx, account, transaction)
+	if appError != nil {
+		transactionErrMsg = "Internal Error!"
+		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to enforce transaction limits", appError)
+		return appError
+	}
+
+	if limitBreach != "" {
+		errMsg := fmt.Sprintf("ProcessTransaction: Transaction limit exceeded for user! UserId: %d. %s", transaction.UserId, limitBreach)
+		logger.WithContext(ctx).Error(errMsg)
+		transactionErrMsg = limitBreach
+		appError := utils.RenderAppError(ctx, 5023, errMsg, errMsg, nil)
+		return appError
+	}
+
 	var newBalance int64
 	switch transaction.TransactionType {
 
-	case "deposit":
-		newBalance = balance + int64(transaction.Amount*100)
-	case "withdraw":
-		newBalance = balance - int64(transaction.Amount*100)
+	case "deposit", "interest":
+		newBalance = balance + amountInPaise
+	case "withdraw", "fee":
+		newBalance = balance - debitAmount
 	default:
 		errMsg := fmt.Sprintf("ProcessTransaction: Invalid Transaction Type! TransactionType: %s", transaction.TransactionType)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Invalid Request!"
 		appError := utils.RenderAppError(ctx, 5013, errMsg, errMsg, nil)
 		return appError
@@ -265,6 +459,31 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 		return appError
 	}
 
+	feeEntries, appError := postTransactionFees(ctx, tx, account, transaction, withdrawalFee)
+	if appError != nil {
+		transactionErrMsg = "Internal Error!"
+		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to post transaction fees", appError)
+		return appError
+	}
+
+	if transaction.TransactionType == "interest" {
+		appError = database.InterestDb.CompleteInterestPosting(ctx, tx, transaction.RequestId)
+		if appError != nil {
+			transactionErrMsg = "Internal Error!"
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to complete interest posting", appError)
+			return appError
+		}
+	}
+
+	if transaction.TransactionType == "deposit" || transaction.TransactionType == "withdraw" {
+		appError = database.AccDb.UpdateAccountActivity(ctx, tx, account.AccountID)
+		if appError != nil {
+			transactionErrMsg = "Internal Error!"
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to update account activity", appError)
+			return appError
+		}
+	}
+
 	transactionToLog := models.TransactionCollection{
 		UserId:            transaction.UserId,
 		Amount:            transaction.Amount,
@@ -273,14 +492,44 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 		TransactionMsg:    "Transaction completed successfully",
 		RequestId:         transaction.RequestId,
 		TransactionTime:   transaction.TransactionTime,
+		FeeType:           transaction.FeeType,
+		LinkedRequestId:   transaction.LinkedRequestId,
+		BatchId:           transaction.BatchId,
+		CorrelationId:     utils.GetRequestIdFromContext(ctx),
+	}
+
+	events := []models.DomainEvent{newTransactionEvent(models.EVENT_TRANSACTION_COMPLETED, transaction.RequestId, transactionToLog)}
+	for _, feeEntry := range feeEntries {
+		events = append(events, newTransactionEvent(models.EVENT_TRANSACTION_COMPLETED, transaction.RequestId, feeEntry))
+	}
+	events = append(events, newBalanceChangedEvent(transaction.RequestId, account, balance, newBalance))
+
+	appError = persistDomainEvents(ctx, tx, events)
+	if appError != nil {
+		transactionErrMsg = "Internal Error!"
+		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to persist domain events", appError)
+		return appError
+	}
+
+	transactionsToLog := []interface{}{transactionToLog}
+	for _, feeEntry := range feeEntries {
+		transactionsToLog = append(transactionsToLog, feeEntry)
+	}
+
+	// A request processed after its day was closed changes the closed balances
+	appError = markLateDailyBalances(ctx, tx, append([]models.TransactionCollection{transactionToLog}, feeEntries...))
+	if appError != nil {
+		transactionErrMsg = "Internal Error!"
+		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, "Failed to mark late daily balances", appError)
+		return appError
 	}
 
 	txCollection := database.GetCollection("transactions")
 
-	_, err = txCollection.InsertOne(ctx, transactionToLog)
+	_, err = txCollection.InsertMany(ctx, transactionsToLog)
 	if err != nil {
 		errMsg := fmt.Sprintf("ProcessTransaction: Failed to insert transaction into MongoDB! Error: %s", err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Internal Error!"
 		appError := utils.RenderAppError(ctx, 5014, errMsg, errMsg, nil)
 		return appError
@@ -288,24 +537,29 @@ func ProcessTransaction(ctx context.Context, transaction models.TransactionReque
 
 	if err := tx.Commit(ctx); err != nil {
 		errMsg := "ProcessTransaction: Failed to commit transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		transactionErrMsg = "Internal Error!"
 		appError := utils.RenderAppError(ctx, 5015, errMsg, errMsg, nil)
 		return appError
 	}
 
 	txCommitted = true
+	metrics.ObserveTransactionProcessed(transaction.TransactionType, "success")
+
+	publishDomainEvents(ctx, events...)
 
 	return nil
 
 }
 
-func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransactionHistoryRequest, role string) (*models.GetTransactionHistoryResponse, *models.ApiError) {
+// GetTransactionHistory returns the ledger of the caller, or of every user when the
+// caller has the ledger:read_all permission.
+func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransactionHistoryRequest, readAll bool) (*models.GetTransactionHistoryResponse, *models.ApiError) {
 
 	tx, err := database.AccDb.BeginTx(ctx)
 	if err != nil {
 		errMsg := "ProcessTransaction: Could not begin transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5016, errMsg, "", nil)
 		misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
@@ -319,9 +573,9 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
-	if !exists {
+	if !exists && !readAll {
 		errMsg := "Account does not exists for this user!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5017, errMsg, "", nil)
 	}
 
@@ -333,7 +587,7 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 
 	if !userExists {
 		errMsg := fmt.Sprintf("User does not exists UserId: %d!", userId)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5018, errMsg, "", nil)
 	}
 
@@ -341,13 +595,13 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 
 	filter := bson.M{}
 
-	if role != "admin" {
+	if !readAll {
 		filter["userId"] = userId
 	}
 
 	if req.Filters != nil && req.Filters.TransactionType != nil {
 		switch *req.Filters.TransactionType {
-		case "deposit", "withdraw":
+		case "deposit", "withdraw", "interest", "fee", "account_closed":
 			filter["transactionType"] = *req.Filters.TransactionType
 		}
 	}
@@ -381,12 +635,12 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 		zap.Any("findOptions ", findOptions),
 	}
 
-	logger.Log.Info("GetTransactionHistory: MongoDB query", fields...)
+	logger.WithContext(ctx).Info("GetTransactionHistory: MongoDB query", fields...)
 
 	cursor, err := txCollection.Find(ctx, filter, findOptions)
 	if err != nil {
 		errMsg := fmt.Sprintf("GetTransactionHistory: Failed to find transactions in MongoDB! Error: %s", err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5019, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 		apiError := utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
@@ -399,7 +653,7 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 		var transaction models.TransactionCollection
 		if err := cursor.Decode(&transaction); err != nil {
 			errMsg := fmt.Sprintf("GetTransactionHistory: Failed to decode transaction! Error: %s", err.Error())
-			logger.Log.Error(errMsg)
+			logger.WithContext(ctx).Error(errMsg)
 			appError := utils.RenderAppError(ctx, 5020, errMsg, "", nil)
 			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 			apiError := utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
@@ -415,6 +669,10 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 			TransactionTime:   transaction.TransactionTime,
 			TransactionStatus: transaction.TransactionStatus,
 			TransactionMsg:    transaction.TransactionMsg,
+			FeeType:           transaction.FeeType,
+			LinkedRequestId:   transaction.LinkedRequestId,
+			BatchId:           transaction.BatchId,
+			CorrelationId:     transaction.CorrelationId,
 		}
 
 		transactions = append(transactions, transactionHistory)
@@ -422,7 +680,7 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 
 	if err := cursor.Err(); err != nil {
 		errMsg := fmt.Sprintf("GetTransactionHistory: Cursor error! Error: %s", err.Error())
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5021, errMsg, "", nil)
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
 		apiError := utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
@@ -431,7 +689,7 @@ func GetTransactionHistory(ctx context.Context, userId int, req models.GetTransa
 
 	if err := tx.Commit(ctx); err != nil {
 		errMsg := "ProcessTransaction: Failed to commit transaction!"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		appError := utils.RenderAppError(ctx, 5022, errMsg, errMsg, nil)
 		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"banking_ledger/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsWholePaiseAmount(t *testing.T) {
+
+	tests := []struct {
+		amount   float64
+		expected bool
+	}{
+		{amount: 0.01, expected: true},
+		{amount: 0.005, expected: true},
+		{amount: 0.0049, expected: false},
+		{amount: 0.001, expected: false},
+		{amount: 1e-10, expected: false},
+		{amount: 0, expected: false},
+		{amount: 150.25, expected: true},
+	}
+
+	for _, test := range tests {
+		if whole := isWholePaiseAmount(test.amount); whole != test.expected {
+			t.Errorf("isWholePaiseAmount(%v) = %v, expected %v", test.amount, whole, test.expected)
+		}
+	}
+}
+
+// TestSubPaiseAmountsAreRejected checks the requests are rejected before anything is
+// queued, the consumer would drop a message for 0 paise after the API answered queued.
+func TestSubPaiseAmountsAreRejected(t *testing.T) {
+
+	startDate := time.Now().AddDate(0, 0, 1).Format(balanceDateLayout)
+
+	for _, amount := range []float64{0.004, 0.001, 1e-10} {
+
+		_, apiError := FundTransaction(context.Background(), 7, models.FundTransactionRequest{Amount: amount, TransactionType: "deposit"}, false)
+		if apiError == nil || apiError.StatusCode != http.StatusBadRequest || apiError.ApplicationError.Message.ErrorCode != 5028 {
+			t.Errorf("FundTransaction with amount %v returned %+v, expected error 5028", amount, apiError)
+		}
+
+		schedule := models.CreateRecurringScheduleRequest{TransactionType: "deposit", Amount: amount, Frequency: "daily", StartDate: startDate}
+		_, apiError = CreateRecurringSchedule(context.Background(), 7, schedule, false)
+		if apiError == nil || apiError.StatusCode != http.StatusBadRequest || apiError.ApplicationError.Message.ErrorCode != 5412 {
+			t.Errorf("CreateRecurringSchedule with amount %v returned %+v, expected error 5412", amount, apiError)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const dormancyBatchSize = 100
+
+// accountStatusTransitions lists the states every state can move to, closed is final.
+var accountStatusTransitions = map[string][]string{
+	"active":  {"frozen", "dormant", "closed"},
+	"frozen":  {"active", "closed"},
+	"dormant": {"active", "frozen", "closed"},
+	"closed":  {},
+}
+
+// accountStatusBlocksTransaction returns why an account in the given state can not
+// take the transaction, or an empty string when it can. Frozen accounts only take
+// credits, dormant accounts still take credits and bank fees but no withdrawals.
+func accountStatusBlocksTransaction(status string, transactionType string) string {
+
+	switch status {
+	case "closed":
+		return "Account is closed!"
+	case "frozen":
+		if transactionType == "withdraw" || transactionType == "fee" {
+			return "Account is frozen, debits are not allowed!"
+		}
+	case "dormant":
+		if transactionType == "withdraw" {
+			return "Account is dormant, contact support to reactivate it!"
+		}
+	}
+
+	return ""
+}
+
+// ChangeAccountStatus moves an account to a new state and records the reason.
+// Closing an account requires a zero balance and writes a final entry to the
+// transaction log.
+func ChangeAccountStatus(ctx context.Context, adminUserId int, accountId int, req models.ChangeAccountStatusRequest) (*models.AccountStatusHistory, *models.ApiError) {
+
+	tx, err := database.AccDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "ChangeAccountStatus: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5801, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	exists, account, appError := database.AccDb.GetAccountByIdForUpdate(ctx, tx, accountId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ChangeAccountStatus-> Failed to get account", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Account does not exists AccountId: %d!", accountId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5802, errMsg, "", nil)
+	}
+
+	if account.AccountType == "internal_income" {
+		errMsg := fmt.Sprintf("ChangeAccountStatus: Status of internal account can not be changed! AccountId: %d", accountId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5803, errMsg, "", nil)
+	}
+
+	if !slices.Contains(accountStatusTransitions[account.Status], req.Status) {
+		errMsg := fmt.Sprintf("ChangeAccountStatus: Account can not move from %s to %s! AccountId: %d", account.Status, req.Status, accountId)
+		logger.WithContext(ctx).Error(errMsg)
+		displayMsg := fmt.Sprintf("Account can not move from %s to %s", account.Status, req.Status)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5804, errMsg, displayMsg, nil)
+	}
+
+	if req.Status == "closed" && account.Balance != 0 {
+		errMsg := fmt.Sprintf("ChangeAccountStatus: Account with a non zero balance can not be closed! AccountId: %d", accountId)
+		logger.WithContext(ctx).Error(errMsg)
+		displayMsg := fmt.Sprintf("Account balance must be zero to close it, current balance is %.2f", utils.ConvertPaiseToRupees(account.Balance))
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5805, errMsg, displayMsg, nil)
+	}
+
+	appError = database.AccDb.UpdateAccountStatus(ctx, tx, accountId, req.Status)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ChangeAccountStatus-> Failed to update account status", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	history := models.AccountStatusHistory{
+		AccountId:  accountId,
+		FromStatus: account.Status,
+		ToStatus:   req.Status,
+		Reason:     req.Reason,
+		ChangedBy:  &adminUserId,
+		CreatedAt:  time.Now(),
+	}
+
+	appError = database.AccDb.InsertAccountStatusHistory(ctx, tx, history)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ChangeAccountStatus-> Failed to save account status history", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if req.Status == "closed" {
+
+		transactionToLog := models.TransactionCollection{
+			UserId:            account.UserID,
+			Amount:            0,
+			TransactionType:   "account_closed",
+			TransactionStatus: "success",
+			TransactionMsg:    fmt.Sprintf("Account closed. Reason: %s", req.Reason),
+			RequestId:         uuid.New(),
+			TransactionTime:   history.CreatedAt.Unix(),
+			CorrelationId:     utils.GetRequestIdFromContext(ctx),
+		}
+
+		txCollection := database.GetCollection("transactions")
+
+		_, err = txCollection.InsertOne(ctx, transactionToLog)
+		if err != nil {
+			errMsg := fmt.Sprintf("ChangeAccountStatus: Failed to insert final statement entry into MongoDB! Error: %s", err.Error())
+			logger.WithContext(ctx).Error(errMsg)
+			appError := utils.RenderAppError(ctx, 5806, errMsg, "", nil)
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "ChangeAccountStatus: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5807, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return &history, nil
+}
+
+func GetAccountStatusHistory(ctx context.Context, accountId int) ([]models.AccountStatusHistory, *models.ApiError) {
+
+	history, appError := database.AccDb.GetAccountStatusHistory(ctx, accountId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetAccountStatusHistory-> Failed to get account status history", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if history == nil {
+		history = []models.AccountStatusHistory{}
+	}
+
+	return history, nil
+}
+
+// markDormantAccounts moves one batch of inactive accounts to dormant and returns
+// how many were moved.
+func markDormantAccounts(ctx context.Context, inactiveSince time.Time) (int, *models.ApplicationError) {
+
+	tx, err := database.AccDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "markDormantAccounts: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return 0, utils.RenderAppError(ctx, 5808, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	accounts, appError := database.AccDb.GetInactiveAccountsForUpdate(ctx, tx, inactiveSince, dormancyBatchSize)
+	if appError != nil {
+		return 0, appError
+	}
+
+	reason := fmt.Sprintf("No activity for %d days", config.ACCOUNT_DORMANCY_DAYS)
+
+	for _, account := range accounts {
+
+		appError = database.AccDb.UpdateAccountStatus(ctx, tx, account.AccountID, "dormant")
+		if appError != nil {
+			return 0, appError
+		}
+
+		history := models.AccountStatusHistory{
+			AccountId:  account.AccountID,
+			FromStatus: account.Status,
+			ToStatus:   "dormant",
+			Reason:     reason,
+		}
+
+		appError = database.AccDb.InsertAccountStatusHistory(ctx, tx, history)
+		if appError != nil {
+			return 0, appError
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := fmt.Sprintf("markDormantAccounts: Failed to commit transaction! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return 0, utils.RenderAppError(ctx, 5809, errMsg, "", nil)
+	}
+
+	return len(accounts), nil
+}
+
+// RunDormancyCheck marks every active account without a deposit or withdrawal in
+// the last ACCOUNT_DORMANCY_DAYS days as dormant.
+func RunDormancyCheck(ctx context.Context) *models.ApplicationError {
+
+	inactiveSince := time.Now().AddDate(0, 0, -config.ACCOUNT_DORMANCY_DAYS)
+	accountsMarked := 0
+
+	for {
+		marked, appError := markDormantAccounts(ctx, inactiveSince)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "RunDormancyCheck-> Failed to mark dormant accounts", appError)
+			return appError
+		}
+
+		accountsMarked += marked
+		if marked < dormancyBatchSize {
+			break
+		}
+	}
+
+	logger.WithContext(ctx).Info("RunDormancyCheck: Completed", zap.Int("accountsMarked", accountsMarked))
+
+	return nil
+}
+
+func StartDormancyJob() {
+
+	if config.ACCOUNT_DORMANCY_DAYS <= 0 || config.ACCOUNT_DORMANCY_JOB_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartDormancyJob: Dormancy job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.ACCOUNT_DORMANCY_JOB_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+		RunDormancyCheck(ctx)
+		<-ticker.C
+	}
+}
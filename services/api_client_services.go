@@ -0,0 +1,236 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func recordApiClientEvent(ctx context.Context, adminUserId int, eventType string, details string) *models.ApplicationError {
+
+	return database.SecurityEventDb.InsertSecurityEvent(ctx, models.SecurityEvent{
+		EventType:   eventType,
+		Details:     details,
+		ActorUserId: &adminUserId,
+	})
+}
+
+func normaliseApiScopes(scopes []string) []string {
+
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+func CreateApiClient(ctx context.Context, adminUserId int, req models.CreateApiClientRequest) (*models.ApiClient, *models.ApiError) {
+
+	created, client, appError := database.ApiClientDb.CreateApiClient(ctx, models.ApiClient{
+		Name:      req.Name,
+		Scopes:    normaliseApiScopes(req.Scopes),
+		CreatedBy: &adminUserId,
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateApiClient-> Failed to create api client", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !created {
+		errMsg := fmt.Sprintf("Api client with name %s already exists!", req.Name)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusConflict, 5651, errMsg, errMsg, nil)
+	}
+
+	details := fmt.Sprintf("Api client %s created with scopes %s", client.Name, strings.Join(client.Scopes, ","))
+	appError = recordApiClientEvent(ctx, adminUserId, models.SECURITY_EVENT_API_CLIENT_CREATED, details)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateApiClient-> Failed to save api client event", appError)
+	}
+
+	return &client, nil
+}
+
+func GetApiClients(ctx context.Context) ([]models.ApiClient, *models.ApiError) {
+
+	clients, appError := database.ApiClientDb.GetApiClients(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetApiClients-> Failed to get api clients", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if clients == nil {
+		clients = []models.ApiClient{}
+	}
+
+	return clients, nil
+}
+
+func getApiClient(ctx context.Context, clientId int) (*models.ApiClient, *models.ApiError) {
+
+	exists, client, appError := database.ApiClientDb.GetApiClientById(ctx, clientId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "getApiClient-> Failed to get api client", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Api client does not exists ClientId: %d!", clientId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5652, errMsg, "", nil)
+	}
+
+	return &client, nil
+}
+
+// UpdateApiClientScopes replaces the scopes of a client, they apply to every key of
+// the client from the next request.
+func UpdateApiClientScopes(ctx context.Context, adminUserId int, clientId int, req models.UpdateApiClientScopesRequest) (*models.ApiClient, *models.ApiError) {
+
+	client, apiError := getApiClient(ctx, clientId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	scopes := normaliseApiScopes(req.Scopes)
+
+	_, appError := database.ApiClientDb.UpdateApiClientScopes(ctx, clientId, scopes)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "UpdateApiClientScopes-> Failed to update scopes", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	details := fmt.Sprintf("Scopes of api client %s changed from %s to %s", client.Name, strings.Join(client.Scopes, ","), strings.Join(scopes, ","))
+	appError = recordApiClientEvent(ctx, adminUserId, models.SECURITY_EVENT_API_CLIENT_SCOPES_CHANGED, details)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "UpdateApiClientScopes-> Failed to save api client event", appError)
+	}
+
+	client.Scopes = scopes
+
+	return client, nil
+}
+
+// CreateApiKey issues a new key of a client. A client can have up to
+// API_KEYS_MAX_ACTIVE_PER_CLIENT active keys, so a key is rotated by creating the new
+// key, moving the partner to it and revoking the old one.
+func CreateApiKey(ctx context.Context, adminUserId int, clientId int, req models.CreateApiKeyRequest) (*models.CreateApiKeyResponse, *models.ApiError) {
+
+	client, apiError := getApiClient(ctx, clientId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	activeKeys, appError := database.ApiClientDb.CountActiveApiKeys(ctx, clientId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateApiKey-> Failed to count active api keys", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if config.API_KEYS_MAX_ACTIVE_PER_CLIENT > 0 && activeKeys >= config.API_KEYS_MAX_ACTIVE_PER_CLIENT {
+		errMsg := fmt.Sprintf("Api client already has %d active keys! Revoke one first", activeKeys)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusConflict, 5653, errMsg, errMsg, nil)
+	}
+
+	key, prefix, keyHash, err := utils.GenerateApiKey()
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateApiKey: Error generating api key! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5654, errMsg, "Could not create api key", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	apiKey := models.ApiKey{
+		KeyId:     uuid.New(),
+		ClientId:  clientId,
+		Name:      req.Name,
+		KeyPrefix: prefix,
+		KeyHash:   keyHash,
+		CreatedBy: &adminUserId,
+		CreatedAt: time.Now(),
+	}
+
+	ttlDays := req.ExpiresInDays
+	if ttlDays == 0 {
+		ttlDays = config.API_KEY_DEFAULT_TTL_DAYS
+	}
+
+	if ttlDays > 0 {
+		expiresAt := apiKey.CreatedAt.AddDate(0, 0, ttlDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	appError = database.ApiClientDb.InsertApiKey(ctx, apiKey)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateApiKey-> Failed to save api key", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	details := fmt.Sprintf("Api key %s (%s) of api client %s created", apiKey.Name, apiKey.KeyPrefix, client.Name)
+	appError = recordApiClientEvent(ctx, adminUserId, models.SECURITY_EVENT_API_KEY_CREATED, details)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateApiKey-> Failed to save api key event", appError)
+	}
+
+	return &models.CreateApiKeyResponse{Key: key, ApiKey: apiKey}, nil
+}
+
+func GetApiKeys(ctx context.Context, clientId int) ([]models.ApiKey, *models.ApiError) {
+
+	_, apiError := getApiClient(ctx, clientId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	keys, appError := database.ApiClientDb.GetApiKeysByClientId(ctx, clientId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetApiKeys-> Failed to get api keys", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if keys == nil {
+		keys = []models.ApiKey{}
+	}
+
+	return keys, nil
+}
+
+// RevokeApiKey stops a key from working from the next request.
+func RevokeApiKey(ctx context.Context, adminUserId int, clientId int, keyId uuid.UUID) *models.ApiError {
+
+	client, apiError := getApiClient(ctx, clientId)
+	if apiError != nil {
+		return apiError
+	}
+
+	revoked, appError := database.ApiClientDb.RevokeApiKey(ctx, clientId, keyId, adminUserId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RevokeApiKey-> Failed to revoke api key", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !revoked {
+		errMsg := fmt.Sprintf("Active api key does not exists KeyId: %s!", keyId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusNotFound, 5655, errMsg, "", nil)
+	}
+
+	details := fmt.Sprintf("Api key %s of api client %s revoked", keyId, client.Name)
+	appError = recordApiClientEvent(ctx, adminUserId, models.SECURITY_EVENT_API_KEY_REVOKED, details)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RevokeApiKey-> Failed to save api key event", appError)
+	}
+
+	return nil
+}
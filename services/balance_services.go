@@ -0,0 +1,423 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	balanceDateLayout     = "2006-01-02"
+	maxBalanceHistoryDays = 366
+)
+
+// Balance snapshots are kept per calendar day in UTC. A day's closing balance
+// covers every successful transaction whose transactionTime is before the
+// start of the next day.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// lastClosableDay is the most recent day whose snapshot can be materialized.
+// Transactions are queued with the request time and processed asynchronously,
+// so a day is only closed once the settlement grace period has passed.
+func lastClosableDay() time.Time {
+	grace := time.Duration(config.DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES) * time.Minute
+	return startOfDay(time.Now().Add(-grace)).AddDate(0, 0, -1)
+}
+
+func signedAmountInPaise(transactionType string, amount float64) int64 {
+
+	switch transactionType {
+	case "deposit", "interest", "fee_income":
+		return utils.ConvertRupeesToPaise(amount)
+	case "withdraw", "fee":
+		return -utils.ConvertRupeesToPaise(amount)
+	}
+
+	return 0
+}
+
+// getSuccessfulTransactions returns the successful ledger entries of a user with
+// fromTime <= transactionTime < toTime, oldest first.
+func getSuccessfulTransactions(ctx context.Context, userId int, fromTime int64, toTime int64) ([]models.TransactionCollection, *models.ApplicationError) {
+
+	txCollection := database.GetCollection("transactions")
+
+	filter := bson.M{
+		"userId":            userId,
+		"transactionStatus": "success",
+		"transactionTime":   bson.M{"$gte": fromTime, "$lt": toTime},
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "transactionTime", Value: 1}})
+
+	cursor, err := txCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		errMsg := fmt.Sprintf("getSuccessfulTransactions: Failed to find transactions in MongoDB! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderAppError(ctx, 5201, errMsg, "", nil)
+	}
+	defer cursor.Close(ctx)
+
+	transactions := []models.TransactionCollection{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		errMsg := fmt.Sprintf("getSuccessfulTransactions: Failed to decode transactions! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderAppError(ctx, 5202, errMsg, "", nil)
+	}
+
+	return transactions, nil
+}
+
+// materializeDailyBalances recomputes the closing balances of an account for every
+// day in [startDate, endDate] from the transaction log, starting from the latest
+// snapshot before startDate.
+func materializeDailyBalances(ctx context.Context, account models.Account, startDate time.Time, endDate time.Time) (int, *models.ApplicationError) {
+
+	createdDay := startOfDay(account.CreatedAt)
+	if startDate.Before(createdDay) {
+		startDate = createdDay
+	}
+
+	if startDate.After(endDate) {
+		return 0, nil
+	}
+
+	exists, openingSnapshot, appError := database.BalSnapDb.GetLatestDailyBalanceBefore(ctx, account.AccountID, startDate)
+	if appError != nil {
+		return 0, appError
+	}
+
+	var balance int64
+	var fromTime int64
+	if exists {
+		balance = openingSnapshot.ClosingBalance
+		fromTime = openingSnapshot.BalanceDate.AddDate(0, 0, 1).Unix()
+	}
+
+	transactions, appError := getSuccessfulTransactions(ctx, account.UserID, fromTime, endDate.AddDate(0, 0, 1).Unix())
+	if appError != nil {
+		return 0, appError
+	}
+
+	snapshotsWritten := 0
+	index := 0
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+
+		dayEnd := day.AddDate(0, 0, 1).Unix()
+		for index < len(transactions) && transactions[index].TransactionTime < dayEnd {
+			balance += signedAmountInPaise(transactions[index].TransactionType, transactions[index].Amount)
+			index++
+		}
+
+		dailyBalance := models.DailyBalance{
+			AccountID:      account.AccountID,
+			UserID:         account.UserID,
+			BalanceDate:    day,
+			ClosingBalance: balance,
+		}
+
+		appError = database.BalSnapDb.UpsertDailyBalance(ctx, dailyBalance)
+		if appError != nil {
+			return snapshotsWritten, appError
+		}
+
+		snapshotsWritten++
+	}
+
+	return snapshotsWritten, nil
+}
+
+// markLateDailyBalances marks the daily balances of the users of the ledger entries
+// stale when an entry belongs to a day that may already be closed, the next run of
+// the daily balance job recomputes them.
+func markLateDailyBalances(ctx context.Context, tx pgx.Tx, entries []models.TransactionCollection) *models.ApplicationError {
+
+	lastClosed := lastClosableDay()
+
+	for _, entry := range entries {
+
+		entryDay := startOfDay(time.Unix(entry.TransactionTime, 0))
+		if entryDay.After(lastClosed) {
+			continue
+		}
+
+		appError := database.BalSnapDb.MarkDailyBalanceRecompute(ctx, tx, entry.UserId, entryDay)
+		if appError != nil {
+			return appError
+		}
+	}
+
+	return nil
+}
+
+// MaterializeDailyBalances closes every account's balance for the days that have
+// ended since its latest snapshot, and recomputes the days marked stale by a late
+// transaction.
+func MaterializeDailyBalances(ctx context.Context) *models.ApplicationError {
+
+	accounts, appError := database.AccDb.GetAllAccounts(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "MaterializeDailyBalances-> Failed to get accounts", appError)
+		return appError
+	}
+
+	recomputes, appError := database.BalSnapDb.GetDailyBalanceRecomputes(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "MaterializeDailyBalances-> Failed to get stale daily balances", appError)
+		return appError
+	}
+
+	staleAccounts := map[int]models.DailyBalanceRecompute{}
+	for _, recompute := range recomputes {
+		staleAccounts[recompute.AccountID] = recompute
+	}
+
+	endDate := lastClosableDay()
+	snapshotsWritten := 0
+
+	for _, account := range accounts {
+
+		exists, latestSnapshot, appError := database.BalSnapDb.GetLatestDailyBalance(ctx, account.AccountID)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "MaterializeDailyBalances-> Failed to get latest daily balance", appError)
+			continue
+		}
+
+		startDate := startOfDay(account.CreatedAt)
+		if exists {
+			startDate = latestSnapshot.BalanceDate.AddDate(0, 0, 1)
+		}
+
+		recompute, stale := staleAccounts[account.AccountID]
+		if stale && recompute.FromDate.Before(startDate) {
+			startDate = recompute.FromDate
+		}
+
+		written, appError := materializeDailyBalances(ctx, account, startDate, endDate)
+		snapshotsWritten += written
+		if appError != nil {
+			errMsg := fmt.Sprintf("MaterializeDailyBalances-> Failed to materialize daily balances for accountId: %d", account.AccountID)
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			continue
+		}
+
+		if stale {
+			appError = database.BalSnapDb.ClearDailyBalanceRecompute(ctx, recompute)
+			if appError != nil {
+				misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "MaterializeDailyBalances-> Failed to clear stale daily balances", appError)
+			}
+		}
+	}
+
+	logger.WithContext(ctx).Info("MaterializeDailyBalances: Completed", zap.Int("accounts", len(accounts)), zap.Int("snapshotsWritten", snapshotsWritten))
+
+	return nil
+}
+
+func StartDailyBalanceJob() {
+
+	if config.DAILY_BALANCE_JOB_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartDailyBalanceJob: Daily balance job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.DAILY_BALANCE_JOB_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+		MaterializeDailyBalances(ctx)
+		<-ticker.C
+	}
+}
+
+func BackfillDailyBalances(ctx context.Context, req models.BackfillDailyBalancesRequest) (*models.BackfillDailyBalancesResponse, *models.ApiError) {
+
+	startDate, _ := time.Parse(balanceDateLayout, req.StartDate)
+	endDate, _ := time.Parse(balanceDateLayout, req.EndDate)
+
+	if startDate.After(endDate) {
+		errMsg := "startDate must not be after endDate"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5203, errMsg, errMsg, nil)
+	}
+
+	if endDate.After(lastClosableDay()) {
+		errMsg := fmt.Sprintf("endDate must not be after %s, later days are not closed yet", lastClosableDay().Format(balanceDateLayout))
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5204, errMsg, errMsg, nil)
+	}
+
+	accounts, appError := database.AccDb.GetAllAccounts(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "BackfillDailyBalances-> Failed to get accounts", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := models.BackfillDailyBalancesResponse{}
+
+	for _, account := range accounts {
+
+		if req.UserId != nil && account.UserID != *req.UserId {
+			continue
+		}
+
+		written, appError := materializeDailyBalances(ctx, account, startDate, endDate)
+		response.SnapshotsWritten += written
+		if appError != nil {
+			errMsg := fmt.Sprintf("BackfillDailyBalances-> Failed to backfill daily balances for accountId: %d", account.AccountID)
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		response.AccountsProcessed++
+	}
+
+	if req.UserId != nil && response.AccountsProcessed == 0 {
+		errMsg := fmt.Sprintf("Account does not exists for userId: %d!", *req.UserId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5205, errMsg, "", nil)
+	}
+
+	return &response, nil
+}
+
+func getAccountForUser(ctx context.Context, userId int) (*models.Account, *models.ApiError) {
+
+	tx, err := database.AccDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "getAccountForUser: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5206, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	exists, account, appError := database.AccDb.GetAccountByUserId(ctx, tx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "Failed to check if account exists", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := "Account does not exists for this user!"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5207, errMsg, "", nil)
+	}
+
+	return &account, nil
+}
+
+func GetBalanceAsOf(ctx context.Context, userId int, req models.GetBalanceAsOfRequest) (*models.GetBalanceAsOfResponse, *models.ApiError) {
+
+	if req.AsOf > time.Now().Unix() {
+		errMsg := "asOf must not be in the future"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5208, errMsg, errMsg, nil)
+	}
+
+	account, apiError := getAccountForUser(ctx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	response := models.GetBalanceAsOfResponse{
+		UserId: userId,
+		AsOf:   req.AsOf,
+	}
+
+	if req.AsOf < account.CreatedAt.Unix() {
+		return &response, nil
+	}
+
+	asOfDay := startOfDay(time.Unix(req.AsOf, 0))
+
+	exists, openingSnapshot, appError := database.BalSnapDb.GetLatestDailyBalanceBefore(ctx, account.AccountID, asOfDay)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetBalanceAsOf-> Failed to get opening daily balance", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	var balance int64
+	var fromTime int64
+	if exists {
+		balance = openingSnapshot.ClosingBalance
+		fromTime = openingSnapshot.BalanceDate.AddDate(0, 0, 1).Unix()
+	}
+
+	transactions, appError := getSuccessfulTransactions(ctx, userId, fromTime, req.AsOf+1)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetBalanceAsOf-> Failed to get transactions", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	for _, transaction := range transactions {
+		balance += signedAmountInPaise(transaction.TransactionType, transaction.Amount)
+	}
+
+	response.Balance = utils.ConvertPaiseToRupees(balance)
+
+	return &response, nil
+}
+
+func GetDailyBalanceHistory(ctx context.Context, userId int, req models.GetBalanceHistoryRequest) (*models.GetBalanceHistoryResponse, *models.ApiError) {
+
+	startDate, _ := time.Parse(balanceDateLayout, req.StartDate)
+	endDate, _ := time.Parse(balanceDateLayout, req.EndDate)
+
+	if startDate.After(endDate) {
+		errMsg := "startDate must not be after endDate"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5209, errMsg, errMsg, nil)
+	}
+
+	if endDate.Sub(startDate) > maxBalanceHistoryDays*24*time.Hour {
+		errMsg := fmt.Sprintf("Date range must not exceed %d days", maxBalanceHistoryDays)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5210, errMsg, errMsg, nil)
+	}
+
+	account, apiError := getAccountForUser(ctx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	dailyBalances, appError := database.BalSnapDb.GetDailyBalances(ctx, account.AccountID, startDate, endDate)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetDailyBalanceHistory-> Failed to get daily balances", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := models.GetBalanceHistoryResponse{
+		UserId:    userId,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Balances:  []models.BalanceHistoryPoint{},
+	}
+
+	for _, dailyBalance := range dailyBalances {
+		response.Balances = append(response.Balances, models.BalanceHistoryPoint{
+			Date:    dailyBalance.BalanceDate.Format(balanceDateLayout),
+			Balance: utils.ConvertPaiseToRupees(dailyBalance.ClosingBalance),
+		})
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeAccountDb looks the accounts up by user id in memory.
+type fakeAccountDb struct {
+	accounts []models.Account
+}
+
+func (f *fakeAccountDb) BeginTx(ctx context.Context) (pgx.Tx, error) { return fakeTx{}, nil }
+
+func (f *fakeAccountDb) GetAccountByUserId(ctx context.Context, tx pgx.Tx, userId int) (bool, models.Account, *models.ApplicationError) {
+
+	for _, account := range f.accounts {
+		if account.UserID == userId {
+			return true, account, nil
+		}
+	}
+
+	return false, models.Account{}, nil
+}
+
+func (f *fakeAccountDb) CreateAccountForUser(ctx context.Context, tx pgx.Tx, userId int, balance int64, accountType string) (int, *models.ApplicationError) {
+	return 0, nil
+}
+
+func (f *fakeAccountDb) GetBalanceForUserId(ctx context.Context, tx pgx.Tx, userId int) (bool, int64, *models.ApplicationError) {
+	return false, 0, nil
+}
+
+func (f *fakeAccountDb) UpdateBalanceForUserId(ctx context.Context, tx pgx.Tx, userId int, balance int64) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeAccountDb) GetAllAccounts(ctx context.Context) ([]models.Account, *models.ApplicationError) {
+	return f.accounts, nil
+}
+
+func (f *fakeAccountDb) MarkTransactionRequestProcessed(ctx context.Context, tx pgx.Tx, requestId uuid.UUID, userId int) (bool, *models.ApplicationError) {
+	return false, nil
+}
+
+func (f *fakeAccountDb) GetAccountByIdForUpdate(ctx context.Context, tx pgx.Tx, accountId int) (bool, models.Account, *models.ApplicationError) {
+	return false, models.Account{}, nil
+}
+
+func (f *fakeAccountDb) UpdateAccountStatus(ctx context.Context, tx pgx.Tx, accountId int, status string) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeAccountDb) InsertAccountStatusHistory(ctx context.Context, tx pgx.Tx, history models.AccountStatusHistory) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeAccountDb) GetAccountStatusHistory(ctx context.Context, accountId int) ([]models.AccountStatusHistory, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeAccountDb) UpdateAccountActivity(ctx context.Context, tx pgx.Tx, accountId int) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeAccountDb) GetInactiveAccountsForUpdate(ctx context.Context, tx pgx.Tx, inactiveSince time.Time, limit int) ([]models.Account, *models.ApplicationError) {
+	return nil, nil
+}
+
+// fakeBalanceSnapshotDb returns no daily balances and keeps the date ranges queried.
+type fakeBalanceSnapshotDb struct {
+	queriedRanges [][2]time.Time
+}
+
+func (f *fakeBalanceSnapshotDb) UpsertDailyBalance(ctx context.Context, dailyBalance models.DailyBalance) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeBalanceSnapshotDb) GetLatestDailyBalance(ctx context.Context, accountId int) (bool, models.DailyBalance, *models.ApplicationError) {
+	return false, models.DailyBalance{}, nil
+}
+
+func (f *fakeBalanceSnapshotDb) GetLatestDailyBalanceBefore(ctx context.Context, accountId int, balanceDate time.Time) (bool, models.DailyBalance, *models.ApplicationError) {
+	return false, models.DailyBalance{}, nil
+}
+
+func (f *fakeBalanceSnapshotDb) GetDailyBalances(ctx context.Context, accountId int, startDate time.Time, endDate time.Time) ([]models.DailyBalance, *models.ApplicationError) {
+	f.queriedRanges = append(f.queriedRanges, [2]time.Time{startDate, endDate})
+	return nil, nil
+}
+
+func (f *fakeBalanceSnapshotDb) MarkDailyBalanceRecompute(ctx context.Context, tx pgx.Tx, userId int, fromDate time.Time) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeBalanceSnapshotDb) GetDailyBalanceRecomputes(ctx context.Context) ([]models.DailyBalanceRecompute, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeBalanceSnapshotDb) ClearDailyBalanceRecompute(ctx context.Context, recompute models.DailyBalanceRecompute) *models.ApplicationError {
+	return nil
+}
+
+// useSettlementGrace sets DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES for the test.
+func useSettlementGrace(t *testing.T, minutes int) {
+	defaultGrace := config.DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES
+	config.DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES = minutes
+	t.Cleanup(func() { config.DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES = defaultGrace })
+}
+
+func TestSignedAmountInPaise(t *testing.T) {
+
+	tests := []struct {
+		transactionType string
+		amount          float64
+		expected        int64
+	}{
+		{transactionType: "deposit", amount: 150.25, expected: 15025},
+		{transactionType: "interest", amount: 0.01, expected: 1},
+		{transactionType: "fee_income", amount: 25, expected: 2500},
+		{transactionType: "withdraw", amount: 99.99, expected: -9999},
+		{transactionType: "fee", amount: 25, expected: -2500},
+		{transactionType: "transfer", amount: 10, expected: 0},
+		{transactionType: "", amount: 10, expected: 0},
+	}
+
+	for _, test := range tests {
+		if signed := signedAmountInPaise(test.transactionType, test.amount); signed != test.expected {
+			t.Errorf("signedAmountInPaise(%q, %v) = %d, expected %d", test.transactionType, test.amount, signed, test.expected)
+		}
+	}
+}
+
+func TestLastClosableDay(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		grace        func(elapsedToday time.Duration) int // Grace in minutes for the time elapsed since midnight
+		expectedDays int                                  // Days before today
+	}{
+		{name: "no grace", grace: func(elapsed time.Duration) int { return 0 }, expectedDays: 1},
+		{name: "grace ends today", grace: func(elapsed time.Duration) int { return int(elapsed / time.Minute) }, expectedDays: 1},
+		{name: "grace reaches into yesterday", grace: func(elapsed time.Duration) int { return int(elapsed/time.Minute) + 1 }, expectedDays: 2},
+		{name: "grace of a day", grace: func(elapsed time.Duration) int { return int(elapsed/time.Minute) + 24*60 }, expectedDays: 2},
+		{name: "grace over a day", grace: func(elapsed time.Duration) int { return int(elapsed/time.Minute) + 24*60 + 1 }, expectedDays: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			now := time.Now()
+			today := startOfDay(now)
+
+			useSettlementGrace(t, test.grace(now.Sub(today)))
+
+			expected := today.AddDate(0, 0, -test.expectedDays)
+			if closable := lastClosableDay(); !closable.Equal(expected) {
+				t.Errorf("lastClosableDay with %d minutes of grace = %s, expected %s", config.DAILY_BALANCE_SETTLEMENT_GRACE_MINUTES, closable.Format(balanceDateLayout), expected.Format(balanceDateLayout))
+			}
+		})
+	}
+}
+
+func TestBackfillDailyBalancesEndsAtLastClosableDay(t *testing.T) {
+
+	defaultAccDb := database.AccDb
+	defer func() { database.AccDb = defaultAccDb }()
+	database.AccDb = &fakeAccountDb{}
+
+	useSettlementGrace(t, 60)
+
+	lastClosed := lastClosableDay()
+
+	tests := []struct {
+		name              string
+		endDate           time.Time
+		expectedErrorCode int
+	}{
+		{name: "day before the last closable day", endDate: lastClosed.AddDate(0, 0, -1)},
+		{name: "last closable day", endDate: lastClosed},
+		{name: "day after the last closable day", endDate: lastClosed.AddDate(0, 0, 1), expectedErrorCode: 5204},
+		{name: "today", endDate: startOfDay(time.Now()), expectedErrorCode: 5204},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			req := models.BackfillDailyBalancesRequest{
+				StartDate: lastClosed.AddDate(0, 0, -7).Format(balanceDateLayout),
+				EndDate:   test.endDate.Format(balanceDateLayout),
+			}
+
+			_, apiError := BackfillDailyBalances(context.Background(), req)
+
+			if test.expectedErrorCode == 0 && apiError != nil {
+				t.Fatalf("BackfillDailyBalances to %s returned %d", req.EndDate, apiError.ApplicationError.Message.ErrorCode)
+			}
+
+			if test.expectedErrorCode != 0 && (apiError == nil || apiError.StatusCode != http.StatusBadRequest || apiError.ApplicationError.Message.ErrorCode != test.expectedErrorCode) {
+				t.Fatalf("BackfillDailyBalances to %s returned %+v, expected error %d", req.EndDate, apiError, test.expectedErrorCode)
+			}
+		})
+	}
+}
+
+func TestGetDailyBalanceHistoryRange(t *testing.T) {
+
+	defaultAccDb, defaultBalSnapDb := database.AccDb, database.BalSnapDb
+	defer func() { database.AccDb, database.BalSnapDb = defaultAccDb, defaultBalSnapDb }()
+	database.AccDb = &fakeAccountDb{accounts: []models.Account{{AccountID: 11, UserID: 7}}}
+
+	tests := []struct {
+		name              string
+		startDate         string
+		endDate           string
+		expectedErrorCode int
+	}{
+		{name: "single day", startDate: "2024-03-01", endDate: "2024-03-01"},
+		{name: "365 days", startDate: "2023-01-01", endDate: "2024-01-01"},
+		{name: "366 days across a leap day", startDate: "2024-01-01", endDate: "2025-01-01"},
+		{name: "367 days", startDate: "2024-01-01", endDate: "2025-01-02", expectedErrorCode: 5210},
+		{name: "two years", startDate: "2023-01-01", endDate: "2025-01-01", expectedErrorCode: 5210},
+		{name: "start after end", startDate: "2024-03-02", endDate: "2024-03-01", expectedErrorCode: 5209},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			balSnapDb := &fakeBalanceSnapshotDb{}
+			database.BalSnapDb = balSnapDb
+
+			req := models.GetBalanceHistoryRequest{StartDate: test.startDate, EndDate: test.endDate}
+			response, apiError := GetDailyBalanceHistory(context.Background(), 7, req)
+
+			if test.expectedErrorCode != 0 {
+				if apiError == nil || apiError.StatusCode != http.StatusBadRequest || apiError.ApplicationError.Message.ErrorCode != test.expectedErrorCode {
+					t.Fatalf("GetDailyBalanceHistory returned %+v, expected error %d", apiError, test.expectedErrorCode)
+				}
+
+				if len(balSnapDb.queriedRanges) != 0 {
+					t.Errorf("rejected range was queried: %v", balSnapDb.queriedRanges)
+				}
+				return
+			}
+
+			if apiError != nil {
+				t.Fatalf("GetDailyBalanceHistory returned %d", apiError.ApplicationError.Message.ErrorCode)
+			}
+
+			if len(balSnapDb.queriedRanges) != 1 || balSnapDb.queriedRanges[0][0].Format(balanceDateLayout) != test.startDate || balSnapDb.queriedRanges[0][1].Format(balanceDateLayout) != test.endDate {
+				t.Errorf("queried ranges %v, expected %s to %s", balSnapDb.queriedRanges, test.startDate, test.endDate)
+			}
+
+			if response.StartDate != test.startDate || response.EndDate != test.endDate {
+				t.Errorf("response range %s to %s, expected %s to %s", response.StartDate, response.EndDate, test.startDate, test.endDate)
+			}
+		})
+	}
+}
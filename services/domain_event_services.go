@@ -0,0 +1,144 @@
+package services
+
+import (
+	"banking_ledger/clients"
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// newDomainEvent builds the envelope of an event about the ledger entry with the
+// given request id. The event id only depends on the event type and that request id,
+// so consumers can drop an event published twice.
+func newDomainEvent(eventType string, correlationId uuid.UUID, entryRequestId uuid.UUID, userId int, payload interface{}) models.DomainEvent {
+
+	return models.DomainEvent{
+		EventId:       uuid.NewSHA1(models.DOMAIN_EVENT_NAMESPACE, []byte(eventType+":"+entryRequestId.String())),
+		EventType:     eventType,
+		EventVersion:  models.DOMAIN_EVENT_VERSION,
+		CorrelationId: correlationId,
+		UserId:        userId,
+		OccurredAt:    time.Now().Unix(),
+		Payload:       payload,
+	}
+}
+
+func newTransactionEvent(eventType string, correlationId uuid.UUID, entry models.TransactionCollection) models.DomainEvent {
+
+	payload := models.TransactionEvent{
+		RequestId:       entry.RequestId,
+		UserId:          entry.UserId,
+		Amount:          entry.Amount,
+		TransactionType: entry.TransactionType,
+		TransactionTime: entry.TransactionTime,
+		FeeType:         entry.FeeType,
+		LinkedRequestId: entry.LinkedRequestId,
+		BatchId:         entry.BatchId,
+	}
+
+	if eventType == models.EVENT_TRANSACTION_FAILED {
+		payload.Reason = entry.TransactionMsg
+	}
+
+	return newDomainEvent(eventType, correlationId, entry.RequestId, entry.UserId, payload)
+}
+
+func newBalanceChangedEvent(correlationId uuid.UUID, account models.Account, previousBalance int64, newBalance int64) models.DomainEvent {
+
+	payload := models.BalanceChangedEvent{
+		AccountId:       account.AccountID,
+		UserId:          account.UserID,
+		RequestId:       correlationId,
+		PreviousBalance: utils.ConvertPaiseToRupees(previousBalance),
+		NewBalance:      utils.ConvertPaiseToRupees(newBalance),
+	}
+
+	return newDomainEvent(models.EVENT_BALANCE_CHANGED, correlationId, correlationId, account.UserID, payload)
+}
+
+// publishDomainEvents sends the events to the domain events topic keyed by user id,
+// so the events of one user stay in order. It is called after the database
+// transaction is committed and only reports failures, the ledger change stands.
+func publishDomainEvents(ctx context.Context, events ...models.DomainEvent) {
+
+	if config.DOMAIN_EVENTS_KAFKA_TOPIC == "" {
+		return
+	}
+
+	for _, event := range events {
+
+		appError := clients.SendMessageToKafkaTopic(ctx, config.DOMAIN_EVENTS_KAFKA_TOPIC, event, strconv.Itoa(event.UserId))
+		if appError != nil {
+			errMsg := fmt.Sprintf("publishDomainEvents-> Failed to publish %s event! EventId: %s", event.EventType, event.EventId.String())
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		}
+	}
+}
+
+// persistDomainEvents queues the events for the webhook subscriptions of their users
+// and appends them to the event stream log in the database transaction that produced
+// them, so a committed change is always delivered and a rolled back one never is.
+func persistDomainEvents(ctx context.Context, tx pgx.Tx, events []models.DomainEvent) *models.ApplicationError {
+
+	for _, event := range events {
+
+		payload, err := json.Marshal(event)
+		if err != nil {
+			errMsg := fmt.Sprintf("persistDomainEvents: Could not marshal %s event! Error: %s", event.EventType, err.Error())
+			logger.WithContext(ctx).Error(errMsg)
+			return utils.RenderAppError(ctx, 5951, errMsg, "", nil)
+		}
+
+		appError := database.WebhookDb.EnqueueWebhookDeliveries(ctx, tx, event, payload)
+		if appError != nil {
+			return appError
+		}
+
+		appError = database.UserEventDb.AppendUserEvent(ctx, tx, event, payload)
+		if appError != nil {
+			return appError
+		}
+	}
+
+	return nil
+}
+
+// persistRolledBackDomainEvents persists events that are not part of a committed
+// database transaction, such as the failure of a transaction that was rolled back.
+func persistRolledBackDomainEvents(ctx context.Context, events ...models.DomainEvent) {
+
+	tx, err := database.UserEventDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "persistRolledBackDomainEvents: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5952, errMsg, "", nil)
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := persistDomainEvents(ctx, tx, events)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "persistRolledBackDomainEvents-> Failed to persist domain events", appError)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := fmt.Sprintf("persistRolledBackDomainEvents: Failed to commit transaction! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5953, errMsg, "", nil)
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	userEventsBatchSize         = 100
+	userEventListenerRetryDelay = 5 * time.Second
+)
+
+// userEventHub wakes the open event streams of a user on this replica when a
+// user_events notification arrives. Streams read the events themselves from
+// user_event_log, so a missed wake up only delays them until the next one.
+type userEventHub struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan struct{}]struct{}
+}
+
+var eventHub = &userEventHub{subscribers: map[int]map[chan struct{}]struct{}{}}
+
+func (h *userEventHub) subscribe(userId int) (chan struct{}, func()) {
+
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subscribers[userId] == nil {
+		h.subscribers[userId] = map[chan struct{}]struct{}{}
+	}
+	h.subscribers[userId][wake] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		delete(h.subscribers[userId], wake)
+		if len(h.subscribers[userId]) == 0 {
+			delete(h.subscribers, userId)
+		}
+		h.mu.Unlock()
+	}
+
+	return wake, unsubscribe
+}
+
+func (h *userEventHub) notify(userId int) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.subscribers[userId] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *userEventHub) notifyAll() {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subscribers := range h.subscribers {
+		for wake := range subscribers {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// StartUserEventListener listens for user event notifications and reconnects when
+// the connection is lost. Every stream is woken after a reconnect to pick up the
+// events committed while no replica connection was listening.
+func StartUserEventListener() {
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+
+		eventHub.notifyAll()
+
+		appError := database.UserEventDb.ListenForUserEvents(ctx, eventHub.notify)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartUserEventListener-> Stopped listening for user events", appError)
+		}
+
+		time.Sleep(userEventListenerRetryDelay)
+	}
+}
+
+func StartUserEventRetentionJob() {
+
+	if config.USER_EVENT_RETENTION_HOURS <= 0 {
+		logger.Log.Info("StartUserEventRetentionJob: User event retention job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+
+		deleted, appError := database.UserEventDb.DeleteUserEventsBefore(ctx, time.Now().Add(-time.Duration(config.USER_EVENT_RETENTION_HOURS)*time.Hour))
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartUserEventRetentionJob-> Failed to delete old user events", appError)
+		} else {
+			logger.WithContext(ctx).Info("StartUserEventRetentionJob: Completed", zap.Int64("deleted", deleted))
+		}
+
+		<-ticker.C
+	}
+}
+
+// SubscribeUserEvents returns a channel that receives a value whenever new events of
+// the user may be available, and the function to stop receiving them.
+func SubscribeUserEvents(userId int) (<-chan struct{}, func()) {
+	return eventHub.subscribe(userId)
+}
+
+// GetUserEventsAfter returns the next events of the user after eventSeq, at most
+// userEventsBatchSize of them.
+func GetUserEventsAfter(ctx context.Context, userId int, eventSeq int64) ([]models.UserEvent, *models.ApiError) {
+
+	events, appError := database.UserEventDb.GetUserEventsAfter(ctx, userId, eventSeq, userEventsBatchSize)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetUserEventsAfter-> Failed to get user events", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return events, nil
+}
+
+func GetLatestUserEventSeq(ctx context.Context, userId int) (int64, *models.ApiError) {
+
+	eventSeq, appError := database.UserEventDb.GetLatestUserEventSeq(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetLatestUserEventSeq-> Failed to get latest user event", appError)
+		return 0, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return eventSeq, nil
+}
@@ -0,0 +1,616 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const feeChargesListLimit = 100
+
+var periodicFeeTypes = []string{"maintenance", "min_balance_penalty"}
+
+func withdrawalFeeRequestId(withdrawalRequestId uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(models.FEE_NAMESPACE, []byte("fee:withdrawal:"+withdrawalRequestId.String()))
+}
+
+func periodicFeeRequestId(feeType string, accountId int, periodEnd time.Time) uuid.UUID {
+	return uuid.NewSHA1(models.FEE_NAMESPACE, []byte(fmt.Sprintf("fee:%s:%d:%s", feeType, accountId, periodEnd.Format(balanceDateLayout))))
+}
+
+func feeIncomeRequestId(feeRequestId uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(models.FEE_NAMESPACE, []byte("fee-income:"+feeRequestId.String()))
+}
+
+func toFeeScheduleResponse(schedule models.FeeSchedule) models.FeeScheduleResponse {
+
+	response := models.FeeScheduleResponse{
+		FeeScheduleId: schedule.FeeScheduleId,
+		AccountType:   schedule.AccountType,
+		FeeType:       schedule.FeeType,
+		Amount:        utils.ConvertPaiseToRupees(schedule.Amount),
+		Active:        schedule.Active,
+	}
+
+	if schedule.MinimumBalance != nil {
+		minimumBalance := utils.ConvertPaiseToRupees(*schedule.MinimumBalance)
+		response.MinimumBalance = &minimumBalance
+	}
+
+	return response
+}
+
+// assessWithdrawalFee returns the withdrawal fee of the account's type for a
+// withdrawal, or nil when its account type has none. A waived fee is returned
+// with the waived status so it is still recorded.
+func assessWithdrawalFee(ctx context.Context, tx pgx.Tx, account models.Account, transaction models.TransactionRequestKafka) (*models.FeeCharge, *models.ApplicationError) {
+
+	exists, schedule, appError := database.FeeDb.GetActiveFeeSchedule(ctx, tx, account.AccountType, "withdrawal")
+	if appError != nil || !exists {
+		return nil, appError
+	}
+
+	linkedRequestId := transaction.RequestId
+
+	charge := models.FeeCharge{
+		AccountId:       account.AccountID,
+		FeeType:         "withdrawal",
+		Amount:          schedule.Amount,
+		RequestId:       withdrawalFeeRequestId(transaction.RequestId),
+		LinkedRequestId: &linkedRequestId,
+		Status:          "charged",
+	}
+
+	waived, waiverId, appError := database.FeeDb.GetApplicableFeeWaiver(ctx, tx, account.AccountID, "withdrawal", startOfDay(time.Unix(transaction.TransactionTime, 0)))
+	if appError != nil {
+		return nil, appError
+	}
+
+	if waived {
+		charge.Status = "waived"
+		charge.WaiverId = &waiverId
+	}
+
+	return &charge, nil
+}
+
+// incomeAccountUserId caches the internal user of the internal income account, it
+// never changes once the migration created it.
+var incomeAccountUserId struct {
+	sync.Mutex
+	userId int
+}
+
+func getIncomeAccountUserId(ctx context.Context) (int, *models.ApplicationError) {
+
+	incomeAccountUserId.Lock()
+	defer incomeAccountUserId.Unlock()
+
+	if incomeAccountUserId.userId != 0 {
+		return incomeAccountUserId.userId, nil
+	}
+
+	exists, userId, appError := database.FeeDb.GetIncomeAccountUserId(ctx)
+	if appError != nil {
+		return 0, appError
+	}
+
+	if !exists {
+		errMsg := "getIncomeAccountUserId: Internal income account does not exists!"
+		logger.WithContext(ctx).Error(errMsg)
+		return 0, utils.RenderAppError(ctx, 5601, errMsg, "", nil)
+	}
+
+	incomeAccountUserId.userId = userId
+
+	return userId, nil
+}
+
+// feeIncomeEntry returns the internal income account's ledger entry for a charged
+// fee. The charged fee in fee_charges is the income record, the income account's
+// balance is updated by the fee income roll up.
+func feeIncomeEntry(ctx context.Context, charge models.FeeCharge, transactionTime int64) (*models.TransactionCollection, *models.ApplicationError) {
+
+	incomeUserId, appError := getIncomeAccountUserId(ctx)
+	if appError != nil {
+		return nil, appError
+	}
+
+	feeRequestId := charge.RequestId
+
+	incomeEntry := models.TransactionCollection{
+		UserId:            incomeUserId,
+		Amount:            utils.ConvertPaiseToRupees(charge.Amount),
+		TransactionType:   "fee_income",
+		TransactionStatus: "success",
+		TransactionMsg:    "Fee income",
+		RequestId:         feeIncomeRequestId(charge.RequestId),
+		TransactionTime:   transactionTime,
+		FeeType:           charge.FeeType,
+		LinkedRequestId:   &feeRequestId,
+		CorrelationId:     utils.GetRequestIdFromContext(ctx),
+	}
+
+	return &incomeEntry, nil
+}
+
+// postTransactionFees records the fees of a transaction in the same database
+// transaction its balance is updated in and returns the extra ledger entries to
+// log with it.
+func postTransactionFees(ctx context.Context, tx pgx.Tx, account models.Account, transaction models.TransactionRequestKafka, withdrawalFee *models.FeeCharge) ([]models.TransactionCollection, *models.ApplicationError) {
+
+	feeEntries := []models.TransactionCollection{}
+
+	if transaction.TransactionType == "fee" {
+
+		charge := models.FeeCharge{
+			AccountId: account.AccountID,
+			FeeType:   transaction.FeeType,
+			Amount:    utils.ConvertRupeesToPaise(transaction.Amount),
+			RequestId: transaction.RequestId,
+		}
+
+		appError := database.FeeDb.RecordFeeCharged(ctx, tx, charge)
+		if appError != nil {
+			return nil, appError
+		}
+
+		incomeEntry, appError := feeIncomeEntry(ctx, charge, transaction.TransactionTime)
+		if appError != nil {
+			return nil, appError
+		}
+
+		return append(feeEntries, *incomeEntry), nil
+	}
+
+	if withdrawalFee == nil {
+		return feeEntries, nil
+	}
+
+	_, appError := database.FeeDb.InsertFeeCharge(ctx, tx, *withdrawalFee)
+	if appError != nil {
+		return nil, appError
+	}
+
+	if withdrawalFee.Status != "charged" {
+		return feeEntries, nil
+	}
+
+	feeEntry := models.TransactionCollection{
+		UserId:            transaction.UserId,
+		Amount:            utils.ConvertPaiseToRupees(withdrawalFee.Amount),
+		TransactionType:   "fee",
+		TransactionStatus: "success",
+		TransactionMsg:    "Withdrawal fee",
+		RequestId:         withdrawalFee.RequestId,
+		TransactionTime:   transaction.TransactionTime,
+		FeeType:           withdrawalFee.FeeType,
+		LinkedRequestId:   withdrawalFee.LinkedRequestId,
+		CorrelationId:     utils.GetRequestIdFromContext(ctx),
+	}
+
+	incomeEntry, appError := feeIncomeEntry(ctx, *withdrawalFee, transaction.TransactionTime)
+	if appError != nil {
+		return nil, appError
+	}
+
+	return append(feeEntries, feeEntry, *incomeEntry), nil
+}
+
+// failRejectedFee marks a monthly fee ProcessTransaction rejected failed, so it does
+// not stay pending. The fee is not charged again for the month.
+func failRejectedFee(ctx context.Context, transaction models.TransactionRequestKafka, reason string) *models.ApplicationError {
+
+	if transaction.TransactionType != "fee" {
+		return nil
+	}
+
+	charge := models.FeeCharge{
+		FeeType:       transaction.FeeType,
+		Amount:        utils.ConvertRupeesToPaise(transaction.Amount),
+		RequestId:     transaction.RequestId,
+		Status:        "failed",
+		FailureReason: &reason,
+	}
+
+	return database.FeeDb.FailFeeCharge(ctx, transaction.UserId, charge)
+}
+
+// averageDailyBalance returns the average closing balance in paise, a fraction of a
+// paisa is rounded down so an average just below the minimum balance is not rounded
+// up to it.
+func averageDailyBalance(dailyBalances []models.DailyBalance) int64 {
+
+	if len(dailyBalances) == 0 {
+		return 0
+	}
+
+	var balanceTotal int64
+	for _, dailyBalance := range dailyBalances {
+		balanceTotal += dailyBalance.ClosingBalance
+	}
+
+	days := int64(len(dailyBalances))
+	average := balanceTotal / days
+	if balanceTotal%days < 0 {
+		average--
+	}
+
+	return average
+}
+
+// assessPeriodicFee charges a monthly fee for the month ending on periodEnd. The
+// charge is recorded as pending in the same database transaction the fee message
+// is sent in, so a month is assessed once and a message sent again after a crash
+// keeps its request id.
+func assessPeriodicFee(ctx context.Context, account models.Account, feeType string, periodEnd time.Time) (bool, *models.ApplicationError) {
+
+	tx, err := database.FeeDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "assessPeriodicFee: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return false, utils.RenderAppError(ctx, 5602, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	exists, schedule, appError := database.FeeDb.GetActiveFeeSchedule(ctx, tx, account.AccountType, feeType)
+	if appError != nil || !exists {
+		return false, appError
+	}
+
+	if feeType == "min_balance_penalty" {
+
+		periodStart := time.Date(periodEnd.Year(), periodEnd.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if createdDay := startOfDay(account.CreatedAt); periodStart.Before(createdDay) {
+			periodStart = createdDay
+		}
+
+		dailyBalances, appError := database.BalSnapDb.GetDailyBalances(ctx, account.AccountID, periodStart, periodEnd)
+		if appError != nil {
+			return false, appError
+		}
+
+		// The month is assessed once every day of it has a snapshot.
+		expectedDays := int(periodEnd.Sub(periodStart).Hours()/24) + 1
+		if len(dailyBalances) < expectedDays {
+			return false, nil
+		}
+
+		if averageDailyBalance(dailyBalances) >= *schedule.MinimumBalance {
+			return false, nil
+		}
+	}
+
+	charge := models.FeeCharge{
+		AccountId: account.AccountID,
+		FeeType:   feeType,
+		Amount:    schedule.Amount,
+		RequestId: periodicFeeRequestId(feeType, account.AccountID, periodEnd),
+		PeriodEnd: &periodEnd,
+		Status:    "pending",
+	}
+
+	waived, waiverId, appError := database.FeeDb.GetApplicableFeeWaiver(ctx, tx, account.AccountID, feeType, periodEnd)
+	if appError != nil {
+		return false, appError
+	}
+
+	if waived {
+		charge.Status = "waived"
+		charge.WaiverId = &waiverId
+	}
+
+	inserted, appError := database.FeeDb.InsertFeeCharge(ctx, tx, charge)
+	if appError != nil || !inserted {
+		return false, appError
+	}
+
+	if charge.Status == "pending" {
+
+		kafkaMsg := models.TransactionRequestKafka{
+			UserId:          account.UserID,
+			Amount:          utils.ConvertPaiseToRupees(charge.Amount),
+			TransactionType: "fee",
+			RequestId:       charge.RequestId,
+			TransactionTime: time.Now().Unix(),
+			FeeType:         feeType,
+		}
+
+		appError = queueTransactionRequest(ctx, kafkaMsg)
+		if appError != nil {
+			return false, appError
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "assessPeriodicFee: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return false, utils.RenderAppError(ctx, 5603, errMsg, "", nil)
+	}
+
+	return charge.Status == "pending", nil
+}
+
+// AssessPeriodicFees charges the maintenance fee and the below minimum balance
+// penalty of the last completed month to every account once the month is closed.
+func AssessPeriodicFees(ctx context.Context) *models.ApplicationError {
+
+	periodEnd := lastCompletedPostingPeriodEnd("monthly", time.Now())
+	if lastClosableDay().Before(periodEnd) {
+		return nil
+	}
+
+	accounts, appError := database.AccDb.GetAllAccounts(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "AssessPeriodicFees-> Failed to get accounts", appError)
+		return appError
+	}
+
+	feesSent := 0
+
+	for _, account := range accounts {
+
+		if startOfDay(account.CreatedAt).After(periodEnd) || accountStatusBlocksTransaction(account.Status, "fee") != "" {
+			continue
+		}
+
+		for _, feeType := range periodicFeeTypes {
+
+			sent, appError := assessPeriodicFee(ctx, account, feeType, periodEnd)
+			if appError != nil {
+				errMsg := fmt.Sprintf("AssessPeriodicFees-> Failed to assess %s fee for accountId: %d", feeType, account.AccountID)
+				misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+				continue
+			}
+
+			if sent {
+				feesSent++
+			}
+		}
+	}
+
+	logger.WithContext(ctx).Info("AssessPeriodicFees: Completed", zap.Int("accounts", len(accounts)), zap.Int("feesSent", feesSent))
+
+	return nil
+}
+
+func StartFeeJob() {
+
+	if config.FEE_JOB_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartFeeJob: Fee job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.FEE_JOB_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+		AssessPeriodicFees(ctx)
+		<-ticker.C
+	}
+}
+
+// RollUpFeeIncome adds the fees charged since the last roll up to the internal
+// income account.
+func RollUpFeeIncome(ctx context.Context) *models.ApplicationError {
+
+	feesRolledUp, amount, appError := database.FeeDb.RollUpFeeIncome(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "RollUpFeeIncome-> Failed to roll up fee income", appError)
+		return appError
+	}
+
+	logger.WithContext(ctx).Info("RollUpFeeIncome: Completed", zap.Int64("feesRolledUp", feesRolledUp), zap.Int64("amount", amount))
+
+	return nil
+}
+
+func StartFeeIncomeRollupJob() {
+
+	if config.FEE_INCOME_ROLLUP_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartFeeIncomeRollupJob: Fee income roll up job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.FEE_INCOME_ROLLUP_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+		RollUpFeeIncome(ctx)
+		<-ticker.C
+	}
+}
+
+// CreateFeeSchedule adds a fee schedule and makes it the active one for its
+// account type and fee type.
+func CreateFeeSchedule(ctx context.Context, req models.CreateFeeScheduleRequest) (*models.FeeScheduleResponse, *models.ApiError) {
+
+	if req.FeeType == "min_balance_penalty" && req.MinimumBalance == nil {
+		errMsg := "CreateFeeSchedule: minimumBalance is required for a min_balance_penalty fee!"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5604, errMsg, "", nil)
+	}
+
+	schedule := models.FeeSchedule{
+		AccountType: req.AccountType,
+		FeeType:     req.FeeType,
+		Amount:      utils.ConvertRupeesToPaise(req.Amount),
+		Active:      true,
+	}
+
+	if req.FeeType == "min_balance_penalty" {
+		minimumBalance := utils.ConvertRupeesToPaise(*req.MinimumBalance)
+		schedule.MinimumBalance = &minimumBalance
+	}
+
+	tx, err := database.FeeDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "CreateFeeSchedule: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusInternalServerError, 5605, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, apiError)
+		return nil, apiError
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := database.FeeDb.DeactivateFeeSchedule(ctx, tx, schedule.AccountType, schedule.FeeType)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateFeeSchedule-> Failed to deactivate fee schedule", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	feeScheduleId, appError := database.FeeDb.CreateFeeSchedule(ctx, tx, schedule)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateFeeSchedule-> Failed to create fee schedule", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "CreateFeeSchedule: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusInternalServerError, 5606, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, apiError)
+		return nil, apiError
+	}
+
+	schedule.FeeScheduleId = feeScheduleId
+
+	response := toFeeScheduleResponse(schedule)
+
+	return &response, nil
+}
+
+func GetFeeSchedules(ctx context.Context) ([]models.FeeScheduleResponse, *models.ApiError) {
+
+	schedules, appError := database.FeeDb.GetFeeSchedules(ctx, false)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetFeeSchedules-> Failed to get fee schedules", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := []models.FeeScheduleResponse{}
+	for _, schedule := range schedules {
+		response = append(response, toFeeScheduleResponse(schedule))
+	}
+
+	return response, nil
+}
+
+func CreateFeeWaiver(ctx context.Context, req models.CreateFeeWaiverRequest) (*models.FeeWaiver, *models.ApiError) {
+
+	validFrom, _ := time.Parse(balanceDateLayout, req.ValidFrom)
+
+	waiver := models.FeeWaiver{
+		AccountId: req.AccountId,
+		FeeType:   req.FeeType,
+		ValidFrom: validFrom,
+		Reason:    req.Reason,
+	}
+
+	if req.ValidUntil != nil {
+
+		validUntil, _ := time.Parse(balanceDateLayout, *req.ValidUntil)
+		if validUntil.Before(validFrom) {
+			errMsg := "CreateFeeWaiver: validUntil must not be before validFrom!"
+			logger.WithContext(ctx).Error(errMsg)
+			return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5607, errMsg, "", nil)
+		}
+
+		waiver.ValidUntil = &validUntil
+	}
+
+	waiverId, appError := database.FeeDb.CreateFeeWaiver(ctx, waiver)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateFeeWaiver-> Failed to create fee waiver", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	waiver.WaiverId = waiverId
+
+	return &waiver, nil
+}
+
+func GetFeeWaivers(ctx context.Context, req models.GetFeeWaiversRequest) ([]models.FeeWaiver, *models.ApiError) {
+
+	waivers, appError := database.FeeDb.GetFeeWaivers(ctx, req.AccountId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetFeeWaivers-> Failed to get fee waivers", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if waivers == nil {
+		waivers = []models.FeeWaiver{}
+	}
+
+	return waivers, nil
+}
+
+func RevokeFeeWaiver(ctx context.Context, waiverId int) *models.ApiError {
+
+	exists, appError := database.FeeDb.RevokeFeeWaiver(ctx, waiverId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RevokeFeeWaiver-> Failed to revoke fee waiver", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Fee waiver does not exists WaiverId: %d!", waiverId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusNotFound, 5608, errMsg, "", nil)
+	}
+
+	return nil
+}
+
+func GetFeeCharges(ctx context.Context, userId int) ([]models.FeeChargeResponse, *models.ApiError) {
+
+	account, apiError := getAccountForUser(ctx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	charges, appError := database.FeeDb.GetFeeChargesByAccountId(ctx, account.AccountID, feeChargesListLimit)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetFeeCharges-> Failed to get fee charges", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := []models.FeeChargeResponse{}
+	for _, charge := range charges {
+
+		chargeResponse := models.FeeChargeResponse{
+			FeeType:         charge.FeeType,
+			Amount:          utils.ConvertPaiseToRupees(charge.Amount),
+			RequestId:       charge.RequestId,
+			LinkedRequestId: charge.LinkedRequestId,
+			Status:          charge.Status,
+			FailureReason:   charge.FailureReason,
+			CreatedAt:       charge.CreatedAt.Unix(),
+		}
+
+		if charge.PeriodEnd != nil {
+			periodEnd := charge.PeriodEnd.Format(balanceDateLayout)
+			chargeResponse.PeriodEnd = &periodEnd
+		}
+
+		response = append(response, chargeResponse)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"banking_ledger/database"
+	"banking_ledger/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAverageDailyBalance(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		closingBalances []int64
+		expected        int64
+	}{
+		{name: "no days", closingBalances: nil, expected: 0},
+		{name: "even average", closingBalances: []int64{1000, 2000, 3000}, expected: 2000},
+		{name: "fraction is rounded down", closingBalances: []int64{99999, 100000}, expected: 99999},
+		{name: "negative fraction is rounded down", closingBalances: []int64{-1, 0}, expected: -1},
+		{name: "negative average", closingBalances: []int64{-300, -100}, expected: -200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			dailyBalances := []models.DailyBalance{}
+			for _, closingBalance := range test.closingBalances {
+				dailyBalances = append(dailyBalances, models.DailyBalance{ClosingBalance: closingBalance})
+			}
+
+			if average := averageDailyBalance(dailyBalances); average != test.expected {
+				t.Errorf("averageDailyBalance = %d, expected %d", average, test.expected)
+			}
+		})
+	}
+}
+
+func TestFeeRequestIds(t *testing.T) {
+
+	withdrawalRequestId := uuid.MustParse("5f0c8a4e-3b1d-4c2a-9e7f-1a2b3c4d5e6f")
+
+	feeRequestId := withdrawalFeeRequestId(withdrawalRequestId)
+
+	if feeRequestId != withdrawalFeeRequestId(withdrawalRequestId) {
+		t.Error("withdrawal fee request id is not deterministic")
+	}
+
+	if feeRequestId == withdrawalRequestId {
+		t.Error("withdrawal fee request id matches the withdrawal")
+	}
+
+	if feeIncomeRequestId(feeRequestId) == feeRequestId {
+		t.Error("fee income request id matches the fee")
+	}
+}
+
+// fakeFeeDb keeps the fee charges marked failed.
+type fakeFeeDb struct {
+	failedUserIds []int
+	failed        []models.FeeCharge
+}
+
+func (f *fakeFeeDb) BeginTx(ctx context.Context) (pgx.Tx, error) { return fakeTx{}, nil }
+
+func (f *fakeFeeDb) DeactivateFeeSchedule(ctx context.Context, tx pgx.Tx, accountType string, feeType string) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeFeeDb) CreateFeeSchedule(ctx context.Context, tx pgx.Tx, schedule models.FeeSchedule) (int, *models.ApplicationError) {
+	return 0, nil
+}
+
+func (f *fakeFeeDb) GetFeeSchedules(ctx context.Context, activeOnly bool) ([]models.FeeSchedule, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeFeeDb) GetActiveFeeSchedule(ctx context.Context, tx pgx.Tx, accountType string, feeType string) (bool, models.FeeSchedule, *models.ApplicationError) {
+	return false, models.FeeSchedule{}, nil
+}
+
+func (f *fakeFeeDb) CreateFeeWaiver(ctx context.Context, waiver models.FeeWaiver) (int, *models.ApplicationError) {
+	return 0, nil
+}
+
+func (f *fakeFeeDb) GetFeeWaivers(ctx context.Context, accountId int) ([]models.FeeWaiver, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeFeeDb) RevokeFeeWaiver(ctx context.Context, waiverId int) (bool, *models.ApplicationError) {
+	return false, nil
+}
+
+func (f *fakeFeeDb) GetApplicableFeeWaiver(ctx context.Context, tx pgx.Tx, accountId int, feeType string, feeDate time.Time) (bool, int, *models.ApplicationError) {
+	return false, 0, nil
+}
+
+func (f *fakeFeeDb) InsertFeeCharge(ctx context.Context, tx pgx.Tx, charge models.FeeCharge) (bool, *models.ApplicationError) {
+	return false, nil
+}
+
+func (f *fakeFeeDb) RecordFeeCharged(ctx context.Context, tx pgx.Tx, charge models.FeeCharge) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeFeeDb) FailFeeCharge(ctx context.Context, userId int, charge models.FeeCharge) *models.ApplicationError {
+	f.failedUserIds = append(f.failedUserIds, userId)
+	f.failed = append(f.failed, charge)
+	return nil
+}
+
+func (f *fakeFeeDb) GetIncomeAccountUserId(ctx context.Context) (bool, int, *models.ApplicationError) {
+	return false, 0, nil
+}
+
+func (f *fakeFeeDb) RollUpFeeIncome(ctx context.Context) (int64, int64, *models.ApplicationError) {
+	return 0, 0, nil
+}
+
+func (f *fakeFeeDb) GetPendingFeeIncome(ctx context.Context, chargedBefore time.Time) (int64, *models.ApplicationError) {
+	return 0, nil
+}
+
+func (f *fakeFeeDb) GetFeeChargesByAccountId(ctx context.Context, accountId int, limit int) ([]models.FeeCharge, *models.ApplicationError) {
+	return nil, nil
+}
+
+func TestFailRejectedFee(t *testing.T) {
+
+	defaultFeeDb := database.FeeDb
+	defer func() { database.FeeDb = defaultFeeDb }()
+
+	requestId := periodicFeeRequestId("maintenance", 3, time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name           string
+		transaction    models.TransactionRequestKafka
+		reason         string
+		expectedFailed bool
+	}{
+		{
+			name:           "fee rejected for the balance",
+			transaction:    models.TransactionRequestKafka{UserId: 7, Amount: 150.25, TransactionType: "fee", RequestId: requestId, FeeType: "maintenance"},
+			reason:         "Insufficient balance for user!",
+			expectedFailed: true,
+		},
+		{
+			name:           "fee rejected for a frozen account",
+			transaction:    models.TransactionRequestKafka{UserId: 7, Amount: 150.25, TransactionType: "fee", RequestId: requestId, FeeType: "maintenance"},
+			reason:         "Account is frozen!",
+			expectedFailed: true,
+		},
+		{
+			name:        "withdrawal",
+			transaction: models.TransactionRequestKafka{UserId: 7, Amount: 100, TransactionType: "withdraw", RequestId: requestId},
+			reason:      "Insufficient balance for user!",
+		},
+		{
+			name:        "interest",
+			transaction: models.TransactionRequestKafka{UserId: 7, Amount: 1.5, TransactionType: "interest", RequestId: requestId},
+			reason:      "Account is closed!",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			feeDb := &fakeFeeDb{}
+			database.FeeDb = feeDb
+
+			if appError := failRejectedFee(context.Background(), test.transaction, test.reason); appError != nil {
+				t.Fatalf("failRejectedFee returned %s", appError.Message.ErrorMessage)
+			}
+
+			if !test.expectedFailed {
+				if len(feeDb.failed) != 0 {
+					t.Errorf("%s transaction marked a fee failed: %+v", test.transaction.TransactionType, feeDb.failed)
+				}
+				return
+			}
+
+			if len(feeDb.failed) != 1 {
+				t.Fatalf("marked %d fees failed, expected 1", len(feeDb.failed))
+			}
+
+			charge := feeDb.failed[0]
+			if feeDb.failedUserIds[0] != 7 || charge.RequestId != requestId || charge.FeeType != "maintenance" || charge.Amount != 15025 || charge.Status != "failed" {
+				t.Errorf("failed charge = %+v of user %d, expected the maintenance fee of 15025 paise of user 7", charge, feeDb.failedUserIds[0])
+			}
+
+			if charge.FailureReason == nil || *charge.FailureReason != test.reason {
+				t.Errorf("failure reason = %v, expected %q", charge.FailureReason, test.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"banking_ledger/clients"
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/models"
+	"context"
+	"sync"
+	"time"
+)
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context, timeout time.Duration) error
+}
+
+// readinessChecks are the dependencies a pod needs to take traffic. The consumer
+// check fails once the consumer loop has exited, the process itself keeps running.
+var readinessChecks = []readinessCheck{
+	{name: "postgres", check: func(ctx context.Context, timeout time.Duration) error {
+		return database.PingDatabasePool(ctx)
+	}},
+	{name: "mongodb", check: func(ctx context.Context, timeout time.Duration) error {
+		return database.PingMongoDB(ctx)
+	}},
+	{name: "kafka", check: func(ctx context.Context, timeout time.Duration) error {
+		return clients.CheckKafkaBrokers(timeout)
+	}},
+	{name: "transactionConsumer", check: func(ctx context.Context, timeout time.Duration) error {
+		return clients.CheckKafkaConsumer(config.TRANSACTION_PROCESSING_KAFKA_TOPIC, time.Duration(config.KAFKA_CONSUMER_HEARTBEAT_TIMEOUT_SECONDS)*time.Second)
+	}},
+}
+
+// CheckReadiness runs every readiness check in parallel, each bounded by
+// HEALTH_CHECK_TIMEOUT_MILLISECONDS.
+func CheckReadiness(ctx context.Context) models.ReadinessResponse {
+
+	timeout := time.Duration(config.HEALTH_CHECK_TIMEOUT_MILLISECONDS) * time.Millisecond
+	slow := time.Duration(config.HEALTH_CHECK_SLOW_MILLISECONDS) * time.Millisecond
+
+	dependencies := make([]models.DependencyHealth, len(readinessChecks))
+
+	var wg sync.WaitGroup
+
+	for i, readiness := range readinessChecks {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := readiness.check(checkCtx, timeout)
+			latency := time.Since(start)
+
+			dependency := models.DependencyHealth{
+				Name:      readiness.name,
+				Status:    models.DEPENDENCY_UP,
+				LatencyMs: latency.Milliseconds(),
+			}
+
+			if err != nil {
+				dependency.Status = models.DEPENDENCY_DOWN
+				dependency.Error = err.Error()
+			} else if slow > 0 && latency > slow {
+				dependency.Status = models.DEPENDENCY_SLOW
+			}
+
+			dependencies[i] = dependency
+		}()
+	}
+
+	wg.Wait()
+
+	status := models.READINESS_READY
+
+	for _, dependency := range dependencies {
+		if dependency.Status == models.DEPENDENCY_DOWN {
+			status = models.READINESS_UNAVAILABLE
+			break
+		}
+		if dependency.Status == models.DEPENDENCY_SLOW {
+			status = models.READINESS_DEGRADED
+		}
+	}
+
+	return models.ReadinessResponse{
+		Status:       status,
+		CheckedAt:    time.Now(),
+		Dependencies: dependencies,
+	}
+}
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// interestRoundingTolerance absorbs float error in sums of fractional paise, so
+// 0.999999999 paise is posted as one paisa rather than carried.
+const interestRoundingTolerance = 1e-6
+
+// interestPostingRequestId derives the request id of a posting from the account, the
+// period and the number of earlier attempts for the period that were rejected.
+func interestPostingRequestId(accountId int, periodEnd time.Time, attempt int) uuid.UUID {
+
+	name := fmt.Sprintf("interest:%d:%s", accountId, periodEnd.Format(balanceDateLayout))
+	if attempt > 0 {
+		name = fmt.Sprintf("%s:%d", name, attempt)
+	}
+
+	return uuid.NewSHA1(models.INTEREST_POSTING_NAMESPACE, []byte(name))
+}
+
+// splitInterestPosting rounds the accrued interest plus the remainder carried from
+// the previous posting down to whole paise, the fraction left is carried forward.
+func splitInterestPosting(accruedAmount float64, carriedRemainder float64) (amountInPaise int64, remainder float64) {
+
+	total := accruedAmount + carriedRemainder
+	if total <= 0 {
+		return 0, total
+	}
+
+	amountInPaise = int64(math.Floor(total + interestRoundingTolerance))
+
+	return amountInPaise, total - float64(amountInPaise)
+}
+
+// daysInAccrualYear returns the day count the annual rate is divided by for the
+// product's accrual basis.
+func daysInAccrualYear(accrualBasis string, day time.Time) float64 {
+
+	switch accrualBasis {
+	case "actual/360":
+		return 360
+	case "actual/actual":
+		return float64(time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, day.Location()).YearDay())
+	}
+
+	return 365
+}
+
+// lastCompletedPostingPeriodEnd returns the last day of the latest posting period
+// that has already ended.
+func lastCompletedPostingPeriodEnd(postingFrequency string, now time.Time) time.Time {
+
+	today := startOfDay(now)
+	periodStartMonth := today.Month()
+	if postingFrequency == "quarterly" {
+		periodStartMonth = time.Month((int(today.Month())-1)/3*3 + 1)
+	}
+
+	return time.Date(today.Year(), periodStartMonth, 1, 0, 0, 0, 0, today.Location()).AddDate(0, 0, -1)
+}
+
+func getActiveInterestProductsByAccountType(ctx context.Context) (map[string]models.InterestProduct, *models.ApplicationError) {
+
+	products, appError := database.InterestDb.GetInterestProducts(ctx, true)
+	if appError != nil {
+		return nil, appError
+	}
+
+	productsByType := map[string]models.InterestProduct{}
+	for _, product := range products {
+		productsByType[product.AccountType] = product
+	}
+
+	return productsByType, nil
+}
+
+// accrueInterest records the interest earned by an account for every closed day
+// since its latest accrual. Days are accrued on the closing balance snapshots, so
+// accrual stops at the first day that has no snapshot yet.
+func accrueInterest(ctx context.Context, account models.Account, product models.InterestProduct, endDate time.Time) (int, *models.ApplicationError) {
+
+	startDate := startOfDay(account.CreatedAt)
+	if effectiveFrom := startOfDay(product.EffectiveFrom); startDate.Before(effectiveFrom) {
+		startDate = effectiveFrom
+	}
+
+	exists, latestAccrualDate, appError := database.InterestDb.GetLatestAccrualDate(ctx, account.AccountID)
+	if appError != nil {
+		return 0, appError
+	}
+
+	if exists && !latestAccrualDate.Before(startDate) {
+		startDate = startOfDay(latestAccrualDate).AddDate(0, 0, 1)
+	}
+
+	if startDate.After(endDate) {
+		return 0, nil
+	}
+
+	dailyBalances, appError := database.BalSnapDb.GetDailyBalances(ctx, account.AccountID, startDate, endDate)
+	if appError != nil {
+		return 0, appError
+	}
+
+	// With daily compounding interest accrued but not yet posted earns interest too.
+	var unpostedInterest float64
+	if product.CompoundingFrequency == "daily" {
+		unpostedInterest, appError = database.InterestDb.GetUnpostedAccruedAmount(ctx, account.AccountID)
+		if appError != nil {
+			return 0, appError
+		}
+	}
+
+	accrualsWritten := 0
+	expectedDate := startDate
+	for _, dailyBalance := range dailyBalances {
+
+		accrualDate := startOfDay(dailyBalance.BalanceDate)
+		if !accrualDate.Equal(expectedDate) {
+			break
+		}
+
+		balanceBasis := dailyBalance.ClosingBalance
+		if balanceBasis < 0 {
+			balanceBasis = 0
+		}
+
+		accruedAmount := (float64(balanceBasis) + unpostedInterest) * product.AnnualRate / 100 / daysInAccrualYear(product.AccrualBasis, accrualDate)
+		if balanceBasis == 0 && unpostedInterest == 0 {
+			accruedAmount = 0
+		}
+
+		accrual := models.InterestAccrual{
+			AccountId:     account.AccountID,
+			AccrualDate:   accrualDate,
+			ProductId:     product.ProductId,
+			BalanceBasis:  balanceBasis,
+			AccruedAmount: accruedAmount,
+		}
+
+		appError = database.InterestDb.InsertInterestAccrual(ctx, accrual)
+		if appError != nil {
+			return accrualsWritten, appError
+		}
+
+		if product.CompoundingFrequency == "daily" {
+			unpostedInterest += accruedAmount
+		}
+
+		accrualsWritten++
+		expectedDate = expectedDate.AddDate(0, 0, 1)
+	}
+
+	return accrualsWritten, nil
+}
+
+// postInterest sends the interest accrued up to periodEnd as an interest transaction.
+// The accruals are assigned to a pending posting in the same database transaction the
+// message is sent in, and count as posted only once ProcessTransaction applies the
+// posting. A rejected posting releases its accruals and is sent again under a new
+// request id by the next run. The request id is derived from the period, so a posting
+// sent again after a crash is applied only once.
+func postInterest(ctx context.Context, account models.Account, periodEnd time.Time) (bool, *models.ApplicationError) {
+
+	appError := database.InterestDb.CompleteProcessedInterestPostings(ctx, account.AccountID)
+	if appError != nil {
+		return false, appError
+	}
+
+	tx, err := database.InterestDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "postInterest: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return false, utils.RenderAppError(ctx, 5501, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// Postings of an account are applied one at a time, so the remainder carried
+	// forward is always the one of the latest applied posting.
+	pending, appError := database.InterestDb.HasPendingInterestPosting(ctx, tx, account.AccountID)
+	if appError != nil {
+		return false, appError
+	}
+
+	if pending {
+		logger.WithContext(ctx).Info(fmt.Sprintf("postInterest: Interest posting is still pending for accountId: %d", account.AccountID))
+		return false, nil
+	}
+
+	accruedAmount, appError := database.InterestDb.LockUnpostedAccruals(ctx, tx, account.AccountID, periodEnd)
+	if appError != nil {
+		return false, appError
+	}
+
+	carriedRemainder, appError := database.InterestDb.GetCarriedInterestRemainder(ctx, tx, account.AccountID)
+	if appError != nil {
+		return false, appError
+	}
+
+	failedAttempts, appError := database.InterestDb.GetFailedInterestPostingCount(ctx, tx, account.AccountID, periodEnd)
+	if appError != nil {
+		return false, appError
+	}
+
+	amountInPaise, remainder := splitInterestPosting(accruedAmount, carriedRemainder)
+
+	posting := models.InterestPosting{
+		RequestId: interestPostingRequestId(account.AccountID, periodEnd, failedAttempts),
+		AccountId: account.AccountID,
+		PeriodEnd: periodEnd,
+		Amount:    amountInPaise,
+		Remainder: remainder,
+		Status:    "pending",
+	}
+
+	// Nothing is sent for less than a paisa, the accruals only move to the remainder
+	if amountInPaise == 0 {
+		posting.Status = "posted"
+	}
+
+	appError = database.InterestDb.CreateInterestPosting(ctx, tx, posting)
+	if appError != nil {
+		return false, appError
+	}
+
+	appError = database.InterestDb.AssignAccrualsToPosting(ctx, tx, account.AccountID, periodEnd, posting.RequestId)
+	if appError != nil {
+		return false, appError
+	}
+
+	if amountInPaise > 0 {
+
+		kafkaMsg := models.TransactionRequestKafka{
+			UserId:          account.UserID,
+			Amount:          utils.ConvertPaiseToRupees(amountInPaise),
+			TransactionType: "interest",
+			RequestId:       posting.RequestId,
+			TransactionTime: time.Now().Unix(),
+		}
+
+		appError = queueTransactionRequest(ctx, kafkaMsg)
+		if appError != nil {
+			return false, appError
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "postInterest: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return false, utils.RenderAppError(ctx, 5502, errMsg, "", nil)
+	}
+
+	return amountInPaise > 0, nil
+}
+
+// RunInterestEngine accrues interest for every account with an active product and
+// posts the interest of the posting periods that have been fully accrued.
+func RunInterestEngine(ctx context.Context) *models.ApplicationError {
+
+	productsByType, appError := getActiveInterestProductsByAccountType(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "RunInterestEngine-> Failed to get interest products", appError)
+		return appError
+	}
+
+	if len(productsByType) == 0 {
+		return nil
+	}
+
+	accounts, appError := database.AccDb.GetAllAccounts(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "RunInterestEngine-> Failed to get accounts", appError)
+		return appError
+	}
+
+	endDate := lastClosableDay()
+	accrualsWritten := 0
+	postings := 0
+
+	for _, account := range accounts {
+
+		if account.Status == "closed" {
+			continue
+		}
+
+		product, exists := productsByType[account.AccountType]
+		if !exists {
+			continue
+		}
+
+		written, appError := accrueInterest(ctx, account, product, endDate)
+		accrualsWritten += written
+		if appError != nil {
+			errMsg := fmt.Sprintf("RunInterestEngine-> Failed to accrue interest for accountId: %d", account.AccountID)
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			continue
+		}
+
+		periodEnd := lastCompletedPostingPeriodEnd(product.PostingFrequency, time.Now())
+
+		exists, latestAccrualDate, appError := database.InterestDb.GetLatestAccrualDate(ctx, account.AccountID)
+		if appError != nil {
+			errMsg := fmt.Sprintf("RunInterestEngine-> Failed to get latest accrual for accountId: %d", account.AccountID)
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			continue
+		}
+
+		if !exists || latestAccrualDate.Before(periodEnd) {
+			continue
+		}
+
+		posted, appError := postInterest(ctx, account, periodEnd)
+		if appError != nil {
+			errMsg := fmt.Sprintf("RunInterestEngine-> Failed to post interest for accountId: %d", account.AccountID)
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			continue
+		}
+
+		if posted {
+			postings++
+		}
+	}
+
+	logger.WithContext(ctx).Info("RunInterestEngine: Completed", zap.Int("accounts", len(accounts)), zap.Int("accrualsWritten", accrualsWritten), zap.Int("postings", postings))
+
+	return nil
+}
+
+func StartInterestJob() {
+
+	if config.INTEREST_JOB_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartInterestJob: Interest job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.INTEREST_JOB_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+		RunInterestEngine(ctx)
+		<-ticker.C
+	}
+}
+
+// CreateInterestProduct adds a product and makes it the active one for its account
+// type, the product it replaces stops accruing from the next run.
+func CreateInterestProduct(ctx context.Context, req models.CreateInterestProductRequest) (*models.InterestProduct, *models.ApiError) {
+
+	product := models.InterestProduct{
+		Name:                 req.Name,
+		AccountType:          req.AccountType,
+		AnnualRate:           req.AnnualRate,
+		AccrualBasis:         req.AccrualBasis,
+		CompoundingFrequency: req.CompoundingFrequency,
+		PostingFrequency:     req.PostingFrequency,
+		EffectiveFrom:        startOfDay(time.Now()),
+		Active:               true,
+	}
+
+	if req.EffectiveFrom != nil {
+		product.EffectiveFrom, _ = time.Parse(balanceDateLayout, *req.EffectiveFrom)
+	}
+
+	tx, err := database.InterestDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "CreateInterestProduct: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusInternalServerError, 5503, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, apiError)
+		return nil, apiError
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := database.InterestDb.DeactivateInterestProducts(ctx, tx, product.AccountType)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateInterestProduct-> Failed to deactivate interest products", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	productId, appError := database.InterestDb.CreateInterestProduct(ctx, tx, product)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateInterestProduct-> Failed to create interest product", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "CreateInterestProduct: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusInternalServerError, 5504, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, apiError)
+		return nil, apiError
+	}
+
+	product.ProductId = productId
+
+	return &product, nil
+}
+
+func GetInterestProducts(ctx context.Context) ([]models.InterestProduct, *models.ApiError) {
+
+	products, appError := database.InterestDb.GetInterestProducts(ctx, false)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetInterestProducts-> Failed to get interest products", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if products == nil {
+		products = []models.InterestProduct{}
+	}
+
+	return products, nil
+}
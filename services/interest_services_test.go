@@ -0,0 +1,119 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSplitInterestPosting(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		accruedAmount     float64
+		carriedRemainder  float64
+		expectedAmount    int64
+		expectedRemainder float64
+	}{
+		{name: "whole paise", accruedAmount: 250, carriedRemainder: 0, expectedAmount: 250, expectedRemainder: 0},
+		{name: "fraction is carried", accruedAmount: 12.75, carriedRemainder: 0, expectedAmount: 12, expectedRemainder: 0.75},
+		{name: "carried fraction completes a paisa", accruedAmount: 12.75, carriedRemainder: 0.5, expectedAmount: 13, expectedRemainder: 0.25},
+		{name: "less than a paisa is carried", accruedAmount: 0.4, carriedRemainder: 0.3, expectedAmount: 0, expectedRemainder: 0.7},
+		{name: "float error does not drop a paisa", accruedAmount: 0.1 + 0.2 + 0.7 - 1e-9, carriedRemainder: 0, expectedAmount: 1, expectedRemainder: 0},
+		{name: "nothing accrued", accruedAmount: 0, carriedRemainder: 0, expectedAmount: 0, expectedRemainder: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			amount, remainder := splitInterestPosting(test.accruedAmount, test.carriedRemainder)
+
+			if amount != test.expectedAmount {
+				t.Errorf("amount = %d, expected %d", amount, test.expectedAmount)
+			}
+
+			if math.Abs(remainder-test.expectedRemainder) > 1e-6 {
+				t.Errorf("remainder = %f, expected %f", remainder, test.expectedRemainder)
+			}
+		})
+	}
+}
+
+func TestSplitInterestPostingLosesNothing(t *testing.T) {
+
+	// 30 days of 1/3 paisa a day posted monthly add up to exactly 10 paise a month
+	var posted int64
+	var remainder float64
+	for month := 0; month < 12; month++ {
+		var amount int64
+		amount, remainder = splitInterestPosting(30*(1.0/3), remainder)
+		posted += amount
+	}
+
+	if posted != 120 {
+		t.Errorf("posted = %d paise over a year, expected 120", posted)
+	}
+}
+
+func TestDaysInAccrualYear(t *testing.T) {
+
+	tests := []struct {
+		accrualBasis string
+		day          time.Time
+		expected     float64
+	}{
+		{accrualBasis: "actual/365", day: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), expected: 365},
+		{accrualBasis: "actual/360", day: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), expected: 360},
+		{accrualBasis: "actual/actual", day: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), expected: 366},
+		{accrualBasis: "actual/actual", day: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), expected: 365},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accrualBasis+" "+test.day.Format(balanceDateLayout), func(t *testing.T) {
+			if days := daysInAccrualYear(test.accrualBasis, test.day); days != test.expected {
+				t.Errorf("daysInAccrualYear = %v, expected %v", days, test.expected)
+			}
+		})
+	}
+}
+
+func TestLastCompletedPostingPeriodEnd(t *testing.T) {
+
+	tests := []struct {
+		postingFrequency string
+		now              time.Time
+		expected         time.Time
+	}{
+		{postingFrequency: "monthly", now: time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC), expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{postingFrequency: "monthly", now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), expected: time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{postingFrequency: "quarterly", now: time.Date(2024, time.May, 20, 0, 0, 0, 0, time.UTC), expected: time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{postingFrequency: "quarterly", now: time.Date(2024, time.March, 31, 23, 0, 0, 0, time.UTC), expected: time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.postingFrequency+" "+test.now.Format(balanceDateLayout), func(t *testing.T) {
+			if periodEnd := lastCompletedPostingPeriodEnd(test.postingFrequency, test.now); !periodEnd.Equal(test.expected) {
+				t.Errorf("lastCompletedPostingPeriodEnd = %s, expected %s", periodEnd, test.expected)
+			}
+		})
+	}
+}
+
+func TestInterestPostingRequestId(t *testing.T) {
+
+	periodEnd := time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)
+
+	first := interestPostingRequestId(7, periodEnd, 0)
+
+	if first != interestPostingRequestId(7, periodEnd, 0) {
+		t.Error("request id of the same posting is not deterministic")
+	}
+
+	if first == interestPostingRequestId(7, periodEnd, 1) {
+		t.Error("request id of a retried posting matches the rejected one")
+	}
+
+	if first == interestPostingRequestId(8, periodEnd, 0) {
+		t.Error("request id does not depend on the account")
+	}
+}
@@ -1,94 +1,83 @@
 package services
 
 import (
+	"banking_ledger/clients"
 	"banking_ledger/config"
 	"banking_ledger/logger"
 	"banking_ledger/misc"
 	"banking_ledger/models"
+	"banking_ledger/tracing"
 	"banking_ledger/utils"
-	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 func KafkaConsumerProcessTransactions(msg *kafka.Message) (err error) {
 
-	ctx := utils.CreateContextWithNewRequestId()
+	ctx := utils.CreateContextWithRequestId(clients.GetKafkaHeader(msg.Headers, models.KAFKA_HEADER_CORRELATION_ID))
 
-	var jsonData map[string]interface{}
-	logger.Log.Debug("Incoming message is", zap.String("Message", string(msg.Value)))
-	err = json.Unmarshal(msg.Value, &jsonData)
-	if err != nil {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:Could not unmarshal kafka message,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "JSON_UNMARSHAL_FAIL", msg.Value)
-		return nil
-	}
-
-	userId, ok := jsonData["userId"].(float64)
-	if !ok {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:userId is not of correct type,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "INCORRECT_USERID", msg.Value)
-		return nil
-	}
+	// The processing span continues the trace of the request that produced the
+	// message and links to its publish span.
+	producerCtx := tracing.ExtractKafkaHeaders(ctx, msg.Headers)
+	ctx, span := tracing.Tracer.Start(producerCtx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.LinkFromContext(producerCtx)),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(config.TRANSACTION_PROCESSING_KAFKA_TOPIC),
+			semconv.MessagingKafkaConsumerGroup(config.TRANSACTION_PROCESSING_KAFKA_CG),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(int(msg.TopicPartition.Partition))),
+			semconv.MessagingKafkaMessageOffset(int(msg.TopicPartition.Offset)),
+			tracing.AttributeRequestId.String(utils.GetRequestIdFromContext(ctx)),
+		))
+	defer span.End()
 
-	amount, ok := jsonData["amount"].(float64)
-	if !ok {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:amount is not of correct type,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "INCORRECT_AMOUNT", msg.Value)
-		return nil
-	}
+	logger.WithContext(ctx).Debug("Incoming message is", zap.String("Message", string(msg.Value)))
 
-	transactionType, ok := jsonData["transactionType"].(string)
-	if !ok {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:transactionType is not of correct type,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "INCORRECT_TRANSACTION_TYPE", msg.Value)
-		return nil
-	}
-
-	requestIdStr, ok := jsonData["requestId"].(string)
-	if !ok {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:requestId is not of correct type,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "INCORRECT_REQUESTID", msg.Value)
+	schemaVersion, err := transactionRequestSchemaVersion(msg.Headers)
+	if err != nil {
+		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:%s,Kafka topic:%s,Kafka message:%s!", err.Error(), config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
+		logger.WithContext(ctx).Error(errMsg)
+		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, droppedInvalidSchemaVersion, msg.Value)
+		span.SetStatus(codes.Error, droppedInvalidSchemaVersion)
 		return nil
 	}
 
-	requestId, err := uuid.Parse(requestIdStr)
+	transactionRequest, reason, err := decodeTransactionRequest(schemaVersion, msg.Value)
 	if err != nil {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:requestId is not of type uuid,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "INCORRECT_REQUEST_ID_TYPE", msg.Value)
-		return nil
-	}
+		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:Could not decode version %d message,Kafka topic:%s,Kafka message:%s,Error:%s!", schemaVersion, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value), err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, reason, msg.Value)
+		span.SetStatus(codes.Error, reason)
 
-	transactionTime, ok := jsonData["transactionTime"].(float64)
-	if !ok {
-		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:transactionTime is not of correct type,Kafka topic:%s,Kafka message:%s!", config.TRANSACTION_PROCESSING_KAFKA_TOPIC, string(msg.Value))
-		logger.Log.Error(errMsg)
-		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "INCORRECT_TRANSACTION_TIME", msg.Value)
+		// A newer producer than this consumer is a deployment problem, the message has
+		// to be replayed once every consumer is upgraded.
+		if reason == droppedUnsupportedSchemaVersion {
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, msg.Value)
+		}
 		return nil
 	}
 
-	transactionRequest := models.TransactionRequestKafka{
-		UserId:          int(userId),
-		Amount:          amount,
-		TransactionType: transactionType,
-		RequestId:       requestId,
-		TransactionTime: int64(transactionTime),
-	}
+	span.SetAttributes(
+		tracing.AttributeTransactionRequestId.String(transactionRequest.RequestId.String()),
+		tracing.AttributeUserId.Int(transactionRequest.UserId),
+		tracing.AttributeTransactionType.String(transactionRequest.TransactionType),
+	)
 
 	appError := ProcessTransaction(ctx, transactionRequest)
 	if appError != nil {
 		errMsg := fmt.Sprintf("KafkaConsumerProcessTransactions:Could not process transaction,Transaction request:%v,Error message:%s", transactionRequest, appError.Message.ErrorMessage)
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		misc.SaveDroppedMessage(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, "TRANSACTION_PROCESSING_FAIL", msg.Value)
+		span.SetStatus(codes.Error, appError.Message.ErrorMessage)
+		span.SetAttributes(tracing.AttributeErrorCode.Int(appError.Message.ErrorCode))
 		return nil
 	}
 
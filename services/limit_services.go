@@ -0,0 +1,337 @@
+package services
+
+import (
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var limitedTransactionTypes = []string{"deposit", "withdraw"}
+
+func isLimitedTransactionType(transactionType string) bool {
+
+	for _, limitedType := range limitedTransactionTypes {
+		if transactionType == limitedType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// effectiveTransactionLimit merges the applicable limits, ordered from the least to
+// the most specific scope. A more specific scope only overrides the limits it sets.
+func effectiveTransactionLimit(limits []models.TransactionLimit) models.TransactionLimit {
+
+	var effective models.TransactionLimit
+
+	for _, limit := range limits {
+
+		effective.TransactionType = limit.TransactionType
+
+		if limit.PerTransactionAmount != nil {
+			effective.PerTransactionAmount = limit.PerTransactionAmount
+		}
+		if limit.DailyAmount != nil {
+			effective.DailyAmount = limit.DailyAmount
+		}
+		if limit.DailyCount != nil {
+			effective.DailyCount = limit.DailyCount
+		}
+		if limit.MonthlyAmount != nil {
+			effective.MonthlyAmount = limit.MonthlyAmount
+		}
+		if limit.MonthlyCount != nil {
+			effective.MonthlyCount = limit.MonthlyCount
+		}
+	}
+
+	return effective
+}
+
+// transactionLimitBreach returns the limit a transaction of amount would exceed,
+// or an empty string when it is within every limit.
+func transactionLimitBreach(limit models.TransactionLimit, usage models.TransactionLimitUsage, amount int64) string {
+
+	switch {
+	case limit.PerTransactionAmount != nil && amount > *limit.PerTransactionAmount:
+		return "Per transaction amount limit exceeded!"
+	case limit.DailyAmount != nil && usage.DailyAmount+amount > *limit.DailyAmount:
+		return "Daily amount limit exceeded!"
+	case limit.DailyCount != nil && usage.DailyCount+1 > *limit.DailyCount:
+		return "Daily transaction count limit exceeded!"
+	case limit.MonthlyAmount != nil && usage.MonthlyAmount+amount > *limit.MonthlyAmount:
+		return "Monthly amount limit exceeded!"
+	case limit.MonthlyCount != nil && usage.MonthlyCount+1 > *limit.MonthlyCount:
+		return "Monthly transaction count limit exceeded!"
+	}
+
+	return ""
+}
+
+func getTransactionLimitAndUsage(ctx context.Context, tx pgx.Tx, account models.Account, transactionType string, usageDate time.Time) (models.TransactionLimit, models.TransactionLimitUsage, *models.ApplicationError) {
+
+	limits, appError := database.LimitDb.GetApplicableTransactionLimits(ctx, tx, account.UserID, account.AccountType, transactionType)
+	if appError != nil {
+		return models.TransactionLimit{}, models.TransactionLimitUsage{}, appError
+	}
+
+	usage, appError := database.LimitDb.GetTransactionLimitUsage(ctx, tx, account.UserID, transactionType, usageDate)
+	if appError != nil {
+		return models.TransactionLimit{}, models.TransactionLimitUsage{}, appError
+	}
+
+	limit := effectiveTransactionLimit(limits)
+	limit.TransactionType = transactionType
+
+	return limit, usage, nil
+}
+
+// checkTransactionLimits returns the limit a transaction would exceed given the
+// transactions already processed on usageDate.
+func checkTransactionLimits(ctx context.Context, tx pgx.Tx, account models.Account, transactionType string, amount int64, usageDate time.Time) (string, *models.ApplicationError) {
+
+	if !isLimitedTransactionType(transactionType) {
+		return "", nil
+	}
+
+	limit, usage, appError := getTransactionLimitAndUsage(ctx, tx, account, transactionType, usageDate)
+	if appError != nil {
+		return "", appError
+	}
+
+	return transactionLimitBreach(limit, usage, amount), nil
+}
+
+// enforceTransactionLimits checks a transaction against its limits and records it
+// in the usage of its day, in the database transaction that applies it.
+func enforceTransactionLimits(ctx context.Context, tx pgx.Tx, account models.Account, transaction models.TransactionRequestKafka) (string, *models.ApplicationError) {
+
+	if !isLimitedTransactionType(transaction.TransactionType) {
+		return "", nil
+	}
+
+	amount := utils.ConvertRupeesToPaise(transaction.Amount)
+	usageDate := startOfDay(time.Unix(transaction.TransactionTime, 0))
+
+	breach, appError := checkTransactionLimits(ctx, tx, account, transaction.TransactionType, amount, usageDate)
+	if appError != nil || breach != "" {
+		return breach, appError
+	}
+
+	appError = database.LimitDb.AddTransactionLimitUsage(ctx, tx, account.UserID, transaction.TransactionType, usageDate, amount)
+	if appError != nil {
+		return "", appError
+	}
+
+	return "", nil
+}
+
+func toLimitAllowance(limitAmount *int64, usedAmount int64, limitCount *int, usedCount int) models.LimitAllowance {
+
+	allowance := models.LimitAllowance{
+		UsedAmount: utils.ConvertPaiseToRupees(usedAmount),
+		LimitCount: limitCount,
+		UsedCount:  usedCount,
+	}
+
+	if limitAmount != nil {
+		limit := utils.ConvertPaiseToRupees(*limitAmount)
+		remaining := utils.ConvertPaiseToRupees(max(*limitAmount-usedAmount, 0))
+		allowance.LimitAmount = &limit
+		allowance.RemainingAmount = &remaining
+	}
+
+	if limitCount != nil {
+		remaining := max(*limitCount-usedCount, 0)
+		allowance.RemainingCount = &remaining
+	}
+
+	return allowance
+}
+
+// GetTransactionAllowance returns what is left of the user's daily and monthly
+// limits for every limited transaction type.
+func GetTransactionAllowance(ctx context.Context, userId int) ([]models.TransactionAllowance, *models.ApiError) {
+
+	tx, err := database.AccDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "GetTransactionAllowance: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5701, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	exists, account, appError := database.AccDb.GetAccountByUserId(ctx, tx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "Failed to check if account exists", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := "Account does not exists for this user!"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5702, errMsg, "", nil)
+	}
+
+	today := startOfDay(time.Now())
+	allowances := []models.TransactionAllowance{}
+
+	for _, transactionType := range limitedTransactionTypes {
+
+		limit, usage, appError := getTransactionLimitAndUsage(ctx, tx, account, transactionType, today)
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetTransactionAllowance-> Failed to get transaction limits", appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		allowance := models.TransactionAllowance{
+			TransactionType: transactionType,
+			Daily:           toLimitAllowance(limit.DailyAmount, usage.DailyAmount, limit.DailyCount, usage.DailyCount),
+			Monthly:         toLimitAllowance(limit.MonthlyAmount, usage.MonthlyAmount, limit.MonthlyCount, usage.MonthlyCount),
+		}
+
+		if limit.PerTransactionAmount != nil {
+			perTransactionAmount := utils.ConvertPaiseToRupees(*limit.PerTransactionAmount)
+			allowance.PerTransactionAmount = &perTransactionAmount
+		}
+
+		allowances = append(allowances, allowance)
+	}
+
+	return allowances, nil
+}
+
+func rupeesToPaisePtr(amount *float64) *int64 {
+
+	if amount == nil {
+		return nil
+	}
+
+	amountInPaise := utils.ConvertRupeesToPaise(*amount)
+	return &amountInPaise
+}
+
+func paiseToRupeesPtr(amount *int64) *float64 {
+
+	if amount == nil {
+		return nil
+	}
+
+	amountInRupees := utils.ConvertPaiseToRupees(*amount)
+	return &amountInRupees
+}
+
+func toTransactionLimitResponse(limit models.TransactionLimit) models.TransactionLimitResponse {
+
+	return models.TransactionLimitResponse{
+		LimitId:              limit.LimitId,
+		Scope:                limit.Scope,
+		AccountType:          limit.AccountType,
+		UserId:               limit.UserId,
+		TransactionType:      limit.TransactionType,
+		PerTransactionAmount: paiseToRupeesPtr(limit.PerTransactionAmount),
+		DailyAmount:          paiseToRupeesPtr(limit.DailyAmount),
+		DailyCount:           limit.DailyCount,
+		MonthlyAmount:        paiseToRupeesPtr(limit.MonthlyAmount),
+		MonthlyCount:         limit.MonthlyCount,
+	}
+}
+
+// SetTransactionLimit creates or replaces the limit of a scope and transaction
+// type. Limits left out of the request are not enforced at that scope.
+func SetTransactionLimit(ctx context.Context, req models.SetTransactionLimitRequest) (*models.TransactionLimitResponse, *models.ApiError) {
+
+	validScope := (req.Scope == "global" && req.AccountType == nil && req.UserId == nil) ||
+		(req.Scope == "account_type" && req.AccountType != nil && req.UserId == nil) ||
+		(req.Scope == "user" && req.AccountType == nil && req.UserId != nil)
+
+	if !validScope {
+		errMsg := fmt.Sprintf("SetTransactionLimit: accountType is required only for the account_type scope and userId only for the user scope! Scope: %s", req.Scope)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5703, errMsg, "", nil)
+	}
+
+	if req.UserId != nil {
+
+		userExists, _, appError := database.UserDb.GetUserByUserId(ctx, *req.UserId)
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "SetTransactionLimit-> Failed to check if user exists", appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		if !userExists {
+			errMsg := fmt.Sprintf("User does not exists UserId: %d!", *req.UserId)
+			logger.WithContext(ctx).Error(errMsg)
+			return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5704, errMsg, "", nil)
+		}
+	}
+
+	limit := models.TransactionLimit{
+		Scope:                req.Scope,
+		AccountType:          req.AccountType,
+		UserId:               req.UserId,
+		TransactionType:      req.TransactionType,
+		PerTransactionAmount: rupeesToPaisePtr(req.PerTransactionAmount),
+		DailyAmount:          rupeesToPaisePtr(req.DailyAmount),
+		DailyCount:           req.DailyCount,
+		MonthlyAmount:        rupeesToPaisePtr(req.MonthlyAmount),
+		MonthlyCount:         req.MonthlyCount,
+	}
+
+	limitId, appError := database.LimitDb.UpsertTransactionLimit(ctx, limit)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "SetTransactionLimit-> Failed to save transaction limit", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	limit.LimitId = limitId
+
+	response := toTransactionLimitResponse(limit)
+
+	return &response, nil
+}
+
+func GetTransactionLimits(ctx context.Context) ([]models.TransactionLimitResponse, *models.ApiError) {
+
+	limits, appError := database.LimitDb.GetTransactionLimits(ctx)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetTransactionLimits-> Failed to get transaction limits", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := []models.TransactionLimitResponse{}
+	for _, limit := range limits {
+		response = append(response, toTransactionLimitResponse(limit))
+	}
+
+	return response, nil
+}
+
+func DeleteTransactionLimit(ctx context.Context, limitId int) *models.ApiError {
+
+	exists, appError := database.LimitDb.DeleteTransactionLimit(ctx, limitId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "DeleteTransactionLimit-> Failed to delete transaction limit", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Transaction limit does not exists LimitId: %d!", limitId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusNotFound, 5705, errMsg, "", nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const securityEventsLimit = 100
+
+// dummyPasswordHash is compared with the password of a login with an unregistered
+// email, so it takes as long as a login with a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("banking_ledger dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// loginEmailKey is the key failed logins of an email are counted under, registered
+// or not, so a lockout does not tell whether the email exists.
+func loginEmailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginFailureWindow() time.Duration {
+	return time.Duration(config.LOGIN_FAILURE_WINDOW_MINUTES) * time.Minute
+}
+
+// loginDelay is how long the next login of an email waits after the given number of
+// failures. The first LOGIN_FREE_ATTEMPTS failures cost nothing, then the delay
+// doubles with every failure up to LOGIN_MAX_DELAY_SECONDS.
+func loginDelay(failedCount int) time.Duration {
+
+	if config.LOGIN_DELAY_BASE_SECONDS <= 0 || failedCount < config.LOGIN_FREE_ATTEMPTS {
+		return 0
+	}
+
+	delay := time.Duration(config.LOGIN_DELAY_BASE_SECONDS) * time.Second
+	maxDelay := time.Duration(config.LOGIN_MAX_DELAY_SECONDS) * time.Second
+
+	for i := config.LOGIN_FREE_ATTEMPTS; i < failedCount && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
+
+// loginBlockedUntil returns the time before which the next login is refused. Client
+// IPs are only locked out, a delay per IP would slow down every user behind a NAT.
+func loginBlockedUntil(failure models.LoginFailure) time.Time {
+
+	if failure.LockedUntil != nil {
+		return *failure.LockedUntil
+	}
+
+	if failure.Scope != models.LOGIN_FAILURE_SCOPE_EMAIL || time.Since(failure.LastFailedAt) > loginFailureWindow() {
+		return time.Time{}
+	}
+
+	return failure.LastFailedAt.Add(loginDelay(failure.FailedCount))
+}
+
+// checkLoginAllowed refuses a login while the email or the client IP waits for its
+// delay or is locked out. Both cases get the same answer.
+func checkLoginAllowed(ctx context.Context, emailKey string, ipAddress string) *models.ApiError {
+
+	var blockedUntil time.Time
+
+	failureKeys := [][2]string{{models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey}}
+	if ipAddress != "" {
+		failureKeys = append(failureKeys, [2]string{models.LOGIN_FAILURE_SCOPE_IP, ipAddress})
+	}
+
+	for _, failureKey := range failureKeys {
+
+		exists, failure, appError := database.LoginProtectionDb.GetLoginFailure(ctx, failureKey[0], failureKey[1])
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "checkLoginAllowed-> Failed to get failed logins", appError)
+			return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		if exists {
+			if until := loginBlockedUntil(failure); until.After(blockedUntil) {
+				blockedUntil = until
+			}
+		}
+	}
+
+	if time.Now().Before(blockedUntil) {
+		retryAfter := int(math.Ceil(time.Until(blockedUntil).Seconds()))
+		logger.WithContext(ctx).Warn("checkLoginAllowed: Login refused after failed logins", zap.String("ipAddress", ipAddress), zap.Int("retryAfterSeconds", retryAfter))
+		errMsg := "Too many failed logins! Try again later"
+		return utils.RenderApiError(ctx, http.StatusTooManyRequests, 5351, errMsg, errMsg, models.RetryAfterInfo{RetryAfterSeconds: retryAfter})
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed login for the email and the client IP and locks
+// out the one that reached its threshold.
+func recordLoginFailure(ctx context.Context, emailKey string, ipAddress string, userId *int) {
+
+	failure, appError := database.LoginProtectionDb.RecordLoginFailure(ctx, models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey, loginFailureWindow())
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "recordLoginFailure-> Failed to save failed login of email", appError)
+	} else if config.LOGIN_LOCKOUT_THRESHOLD > 0 && failure.FailedCount >= config.LOGIN_LOCKOUT_THRESHOLD && failure.LockedUntil == nil {
+		lockLogin(ctx, failure, userId, ipAddress)
+	}
+
+	if ipAddress == "" {
+		return
+	}
+
+	failure, appError = database.LoginProtectionDb.RecordLoginFailure(ctx, models.LOGIN_FAILURE_SCOPE_IP, ipAddress, loginFailureWindow())
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "recordLoginFailure-> Failed to save failed login of ip", appError)
+	} else if config.LOGIN_IP_LOCKOUT_THRESHOLD > 0 && failure.FailedCount >= config.LOGIN_IP_LOCKOUT_THRESHOLD && failure.LockedUntil == nil {
+		lockLogin(ctx, failure, nil, ipAddress)
+	}
+}
+
+func lockLogin(ctx context.Context, failure models.LoginFailure, userId *int, ipAddress string) {
+
+	lockedUntil := time.Now().Add(time.Duration(config.LOGIN_LOCKOUT_MINUTES) * time.Minute)
+
+	appError := database.LoginProtectionDb.LockLogin(ctx, failure.Scope, failure.Key, lockedUntil)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "lockLogin-> Failed to lock login", appError)
+		return
+	}
+
+	details := fmt.Sprintf("%d failed logins for the %s, locked until %s", failure.FailedCount, failure.Scope, lockedUntil.UTC().Format(time.RFC3339))
+	if failure.Scope == models.LOGIN_FAILURE_SCOPE_EMAIL && userId == nil {
+		details += ", the email is not registered"
+	}
+
+	logger.WithContext(ctx).Warn("lockLogin: Login locked", zap.String("scope", failure.Scope), zap.String("ipAddress", ipAddress), zap.Int("failedCount", failure.FailedCount))
+
+	appError = database.SecurityEventDb.InsertSecurityEvent(ctx, models.SecurityEvent{
+		EventType: models.SECURITY_EVENT_LOGIN_LOCKED,
+		UserId:    userId,
+		IpAddress: ipAddress,
+		Details:   details,
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "lockLogin-> Failed to save lockout event", appError)
+	}
+}
+
+// clearLoginFailures forgets the failed logins of an email after a successful login.
+// Failures of the client IP are kept, a valid account must not reset them.
+func clearLoginFailures(ctx context.Context, emailKey string) {
+
+	_, appError := database.LoginProtectionDb.ClearLoginFailure(ctx, models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "clearLoginFailures-> Failed to clear failed logins", appError)
+	}
+}
+
+// UnlockUserLogin ends the lockout and forgets the failed logins of the email of a
+// user, the unlock is recorded in the audit trail.
+func UnlockUserLogin(ctx context.Context, adminUserId int, userId int) (*models.UnlockUserResponse, *models.ApiError) {
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "UnlockUserLogin-> Failed to get user", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("User does not exists UserId: %d!", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5352, errMsg, "", nil)
+	}
+
+	wasLocked, appError := database.LoginProtectionDb.ClearLoginFailure(ctx, models.LOGIN_FAILURE_SCOPE_EMAIL, loginEmailKey(user.Email))
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "UnlockUserLogin-> Failed to clear failed logins", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError = database.SecurityEventDb.InsertSecurityEvent(ctx, models.SecurityEvent{
+		EventType:   models.SECURITY_EVENT_LOGIN_UNLOCKED,
+		UserId:      &userId,
+		Details:     fmt.Sprintf("Failed logins cleared by an admin, the login was locked: %t", wasLocked),
+		ActorUserId: &adminUserId,
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "UnlockUserLogin-> Failed to save unlock event", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return &models.UnlockUserResponse{WasLocked: wasLocked}, nil
+}
+
+func GetUserSecurityEvents(ctx context.Context, userId int) ([]models.SecurityEvent, *models.ApiError) {
+
+	events, appError := database.SecurityEventDb.GetSecurityEventsByUserId(ctx, userId, securityEventsLimit)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetUserSecurityEvents-> Failed to get security events", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if events == nil {
+		events = []models.SecurityEvent{}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,534 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/models"
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeLoginProtectionDb counts failed logins like login_failures, with the time of
+// the test instead of the database's NOW().
+type fakeLoginProtectionDb struct {
+	failures map[[2]string]*models.LoginFailure
+}
+
+func (f *fakeLoginProtectionDb) GetLoginFailure(ctx context.Context, scope string, key string) (bool, models.LoginFailure, *models.ApplicationError) {
+
+	failure, ok := f.failures[[2]string{scope, key}]
+	if !ok {
+		return false, models.LoginFailure{}, nil
+	}
+
+	return true, *failure, nil
+}
+
+func (f *fakeLoginProtectionDb) RecordLoginFailure(ctx context.Context, scope string, key string, window time.Duration) (models.LoginFailure, *models.ApplicationError) {
+
+	now := time.Now()
+
+	failure, ok := f.failures[[2]string{scope, key}]
+	if !ok {
+		failure = &models.LoginFailure{Scope: scope, Key: key}
+		f.failures[[2]string{scope, key}] = failure
+	}
+
+	lockEnded := failure.LockedUntil != nil && failure.LockedUntil.Before(now)
+	if failure.LastFailedAt.Before(now.Add(-window)) || lockEnded {
+		failure.FailedCount = 0
+	}
+	if lockEnded {
+		failure.LockedUntil = nil
+	}
+
+	failure.FailedCount++
+	failure.LastFailedAt = now
+
+	return *failure, nil
+}
+
+func (f *fakeLoginProtectionDb) LockLogin(ctx context.Context, scope string, key string, lockedUntil time.Time) *models.ApplicationError {
+	f.failures[[2]string{scope, key}].LockedUntil = &lockedUntil
+	return nil
+}
+
+func (f *fakeLoginProtectionDb) ClearLoginFailure(ctx context.Context, scope string, key string) (bool, *models.ApplicationError) {
+
+	failure, ok := f.failures[[2]string{scope, key}]
+	if !ok {
+		return false, nil
+	}
+
+	delete(f.failures, [2]string{scope, key})
+
+	return failure.LockedUntil != nil && failure.LockedUntil.After(time.Now()), nil
+}
+
+func (f *fakeLoginProtectionDb) DeleteStaleLoginFailures(ctx context.Context, before time.Time) (int64, *models.ApplicationError) {
+	return 0, nil
+}
+
+// backdate moves the failures of a key into the past, as if elapsed had passed.
+func (f *fakeLoginProtectionDb) backdate(scope string, key string, elapsed time.Duration) {
+
+	failure := f.failures[[2]string{scope, key}]
+	failure.LastFailedAt = failure.LastFailedAt.Add(-elapsed)
+
+	if failure.LockedUntil != nil {
+		lockedUntil := failure.LockedUntil.Add(-elapsed)
+		failure.LockedUntil = &lockedUntil
+	}
+}
+
+func (f *fakeLoginProtectionDb) failedCount(scope string, key string) int {
+
+	failure, ok := f.failures[[2]string{scope, key}]
+	if !ok {
+		return 0
+	}
+
+	return failure.FailedCount
+}
+
+// fakeSecurityEventDb keeps the security events recorded.
+type fakeSecurityEventDb struct {
+	events []models.SecurityEvent
+}
+
+func (f *fakeSecurityEventDb) InsertSecurityEvent(ctx context.Context, event models.SecurityEvent) *models.ApplicationError {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeSecurityEventDb) GetSecurityEventsByUserId(ctx context.Context, userId int, limit int) ([]models.SecurityEvent, *models.ApplicationError) {
+	return f.events, nil
+}
+
+// fakeUserDb looks users up in memory and counts the lookups by email.
+type fakeUserDb struct {
+	users        []models.User
+	emailLookups int
+}
+
+func (f *fakeUserDb) BeginTx(ctx context.Context) (pgx.Tx, error) { return fakeTx{}, nil }
+
+func (f *fakeUserDb) GetUserByEmail(ctx context.Context, email string) (bool, models.User, *models.ApplicationError) {
+
+	f.emailLookups++
+
+	for _, user := range f.users {
+		if user.Email == email {
+			return true, user, nil
+		}
+	}
+
+	return false, models.User{}, nil
+}
+
+func (f *fakeUserDb) CreateUser(ctx context.Context, userDetails models.User) (int, *models.ApplicationError) {
+	return 0, nil
+}
+
+func (f *fakeUserDb) GetUserByUserId(ctx context.Context, userId int) (bool, models.User, *models.ApplicationError) {
+
+	for _, user := range f.users {
+		if user.ID == userId {
+			return true, user, nil
+		}
+	}
+
+	return false, models.User{}, nil
+}
+
+func (f *fakeUserDb) GetUserByUserIdForUpdate(ctx context.Context, tx pgx.Tx, userId int) (bool, models.User, *models.ApplicationError) {
+	return f.GetUserByUserId(ctx, userId)
+}
+
+func (f *fakeUserDb) CountUsersWithRoleForUpdate(ctx context.Context, tx pgx.Tx, role string) (int, *models.ApplicationError) {
+	return 0, nil
+}
+
+func (f *fakeUserDb) UpdateUserRole(ctx context.Context, tx pgx.Tx, userId int, role string) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeUserDb) InsertUserRoleChange(ctx context.Context, tx pgx.Tx, change models.UserRoleChange) (models.UserRoleChange, *models.ApplicationError) {
+	return change, nil
+}
+
+func (f *fakeUserDb) GetUserRoleChanges(ctx context.Context, userId int) ([]models.UserRoleChange, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeUserDb) MarkEmailVerified(ctx context.Context, tx pgx.Tx, userId int) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeUserDb) UpdateUserPassword(ctx context.Context, tx pgx.Tx, userId int, passwordHash string) *models.ApplicationError {
+	return nil
+}
+
+const (
+	testLoginEmail    = "ann@example.com"
+	testLoginPassword = "correct horse battery staple"
+	testLoginIp       = "10.0.0.1"
+)
+
+// useLoginProtection swaps the login DAOs for fakes with one registered user, user 7,
+// and sets a lockout after 5 failures of an email or 8 of an IP within 15 minutes.
+// The delay between failures is off unless a test turns it on.
+func useLoginProtection(t *testing.T) (*fakeLoginProtectionDb, *fakeSecurityEventDb, *fakeUserDb) {
+
+	defaultLoginProtectionDb, defaultSecurityEventDb, defaultUserDb := database.LoginProtectionDb, database.SecurityEventDb, database.UserDb
+	defaultFree, defaultBase, defaultMax := config.LOGIN_FREE_ATTEMPTS, config.LOGIN_DELAY_BASE_SECONDS, config.LOGIN_MAX_DELAY_SECONDS
+	defaultThreshold, defaultIpThreshold := config.LOGIN_LOCKOUT_THRESHOLD, config.LOGIN_IP_LOCKOUT_THRESHOLD
+	defaultLockout, defaultWindow := config.LOGIN_LOCKOUT_MINUTES, config.LOGIN_FAILURE_WINDOW_MINUTES
+	t.Cleanup(func() {
+		database.LoginProtectionDb, database.SecurityEventDb, database.UserDb = defaultLoginProtectionDb, defaultSecurityEventDb, defaultUserDb
+		config.LOGIN_FREE_ATTEMPTS, config.LOGIN_DELAY_BASE_SECONDS, config.LOGIN_MAX_DELAY_SECONDS = defaultFree, defaultBase, defaultMax
+		config.LOGIN_LOCKOUT_THRESHOLD, config.LOGIN_IP_LOCKOUT_THRESHOLD = defaultThreshold, defaultIpThreshold
+		config.LOGIN_LOCKOUT_MINUTES, config.LOGIN_FAILURE_WINDOW_MINUTES = defaultLockout, defaultWindow
+	})
+
+	config.LOGIN_FREE_ATTEMPTS, config.LOGIN_DELAY_BASE_SECONDS, config.LOGIN_MAX_DELAY_SECONDS = 3, 0, 30
+	config.LOGIN_LOCKOUT_THRESHOLD, config.LOGIN_IP_LOCKOUT_THRESHOLD = 5, 8
+	config.LOGIN_LOCKOUT_MINUTES, config.LOGIN_FAILURE_WINDOW_MINUTES = 15, 15
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(testLoginPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loginProtectionDb := &fakeLoginProtectionDb{failures: map[[2]string]*models.LoginFailure{}}
+	securityEventDb := &fakeSecurityEventDb{}
+	userDb := &fakeUserDb{users: []models.User{{ID: 7, Email: testLoginEmail, PasswordHash: string(passwordHash), Role: models.ROLE_CUSTOMER}}}
+
+	database.LoginProtectionDb, database.SecurityEventDb, database.UserDb = loginProtectionDb, securityEventDb, userDb
+
+	return loginProtectionDb, securityEventDb, userDb
+}
+
+func TestLoginDelay(t *testing.T) {
+
+	useLoginProtection(t)
+	config.LOGIN_DELAY_BASE_SECONDS = 1
+
+	tests := []struct {
+		failedCount int
+		expected    time.Duration
+	}{
+		{failedCount: 0, expected: 0},
+		{failedCount: 2, expected: 0},
+		{failedCount: 3, expected: time.Second},
+		{failedCount: 4, expected: 2 * time.Second},
+		{failedCount: 7, expected: 16 * time.Second},
+		{failedCount: 8, expected: 30 * time.Second},
+		{failedCount: 50, expected: 30 * time.Second},
+	}
+
+	for _, test := range tests {
+		if delay := loginDelay(test.failedCount); delay != test.expected {
+			t.Errorf("loginDelay(%d) = %s, expected %s", test.failedCount, delay, test.expected)
+		}
+	}
+
+	config.LOGIN_DELAY_BASE_SECONDS = 0
+	if delay := loginDelay(8); delay != 0 {
+		t.Errorf("loginDelay with LOGIN_DELAY_BASE_SECONDS=0 = %s, expected 0", delay)
+	}
+}
+
+func TestLoginBlockedUntil(t *testing.T) {
+
+	useLoginProtection(t)
+	config.LOGIN_DELAY_BASE_SECONDS = 1
+
+	now := time.Now()
+	lockedUntil := now.Add(10 * time.Minute)
+
+	tests := []struct {
+		name     string
+		failure  models.LoginFailure
+		expected time.Time
+	}{
+		{name: "free attempts", failure: models.LoginFailure{Scope: models.LOGIN_FAILURE_SCOPE_EMAIL, FailedCount: 2, LastFailedAt: now}, expected: now},
+		{name: "delay of an email", failure: models.LoginFailure{Scope: models.LOGIN_FAILURE_SCOPE_EMAIL, FailedCount: 4, LastFailedAt: now}, expected: now.Add(2 * time.Second)},
+		{name: "last failure outside the window", failure: models.LoginFailure{Scope: models.LOGIN_FAILURE_SCOPE_EMAIL, FailedCount: 4, LastFailedAt: now.Add(-16 * time.Minute)}},
+		{name: "no delay for an IP", failure: models.LoginFailure{Scope: models.LOGIN_FAILURE_SCOPE_IP, FailedCount: 7, LastFailedAt: now}},
+		{name: "locked email", failure: models.LoginFailure{Scope: models.LOGIN_FAILURE_SCOPE_EMAIL, FailedCount: 5, LastFailedAt: now, LockedUntil: &lockedUntil}, expected: lockedUntil},
+		{name: "locked IP", failure: models.LoginFailure{Scope: models.LOGIN_FAILURE_SCOPE_IP, FailedCount: 8, LastFailedAt: now, LockedUntil: &lockedUntil}, expected: lockedUntil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if until := loginBlockedUntil(test.failure); !until.Equal(test.expected) {
+				t.Errorf("loginBlockedUntil = %s, expected %s", until, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+
+	type step struct {
+		email           string
+		elapsed         time.Duration // Backdated before the failure
+		expectedAllowed bool          // Whether the next login of the email is allowed after the failure
+		expectedCount   int           // Failures counted for the email after the failure
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "locked at the threshold",
+			steps: []step{
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 1},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 2},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 3},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 4},
+				{email: testLoginEmail, expectedAllowed: false, expectedCount: 5},
+			},
+		},
+		{
+			name: "count restarts after the window",
+			steps: []step{
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 1},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 2},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 3},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 4},
+				{email: testLoginEmail, elapsed: 16 * time.Minute, expectedAllowed: true, expectedCount: 1},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 2},
+			},
+		},
+		{
+			name: "failures within the window keep counting",
+			steps: []step{
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 1},
+				{email: testLoginEmail, elapsed: 14 * time.Minute, expectedAllowed: true, expectedCount: 2},
+				{email: testLoginEmail, elapsed: 14 * time.Minute, expectedAllowed: true, expectedCount: 3},
+				{email: testLoginEmail, elapsed: 14 * time.Minute, expectedAllowed: true, expectedCount: 4},
+				{email: testLoginEmail, elapsed: 14 * time.Minute, expectedAllowed: false, expectedCount: 5},
+			},
+		},
+		{
+			name: "count restarts after the lockout ended",
+			steps: []step{
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 1},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 2},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 3},
+				{email: testLoginEmail, expectedAllowed: true, expectedCount: 4},
+				{email: testLoginEmail, expectedAllowed: false, expectedCount: 5},
+				{email: testLoginEmail, elapsed: 16 * time.Minute, expectedAllowed: true, expectedCount: 1},
+			},
+		},
+		{
+			name: "unregistered emails are counted under the same key",
+			steps: []step{
+				{email: "nobody@example.com", expectedAllowed: true, expectedCount: 1},
+				{email: " Nobody@Example.com", expectedAllowed: true, expectedCount: 2},
+				{email: "NOBODY@example.com ", expectedAllowed: true, expectedCount: 3},
+				{email: "nobody@example.com", expectedAllowed: true, expectedCount: 4},
+				{email: "nobody@example.com", expectedAllowed: false, expectedCount: 5},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			loginProtectionDb, _, _ := useLoginProtection(t)
+
+			for i, step := range test.steps {
+
+				emailKey := loginEmailKey(step.email)
+
+				if step.elapsed > 0 {
+					loginProtectionDb.backdate(models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey, step.elapsed)
+				}
+
+				// A different IP every time, so only the email is locked
+				ipAddress := fmt.Sprintf("10.0.1.%d", i+1)
+				recordLoginFailure(context.Background(), emailKey, ipAddress, nil)
+
+				if count := loginProtectionDb.failedCount(models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey); count != step.expectedCount {
+					t.Errorf("failure %d: counted %d failures, expected %d", i+1, count, step.expectedCount)
+				}
+
+				apiError := checkLoginAllowed(context.Background(), emailKey, "10.0.2.1")
+				if (apiError == nil) != step.expectedAllowed {
+					t.Fatalf("failure %d: checkLoginAllowed = %+v, expected allowed %v", i+1, apiError, step.expectedAllowed)
+				}
+
+				if apiError != nil && (apiError.StatusCode != http.StatusTooManyRequests || apiError.ApplicationError.Message.ErrorCode != 5351) {
+					t.Errorf("failure %d: refused with %+v, expected 429 and error 5351", i+1, apiError)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginLockoutOfIp(t *testing.T) {
+
+	loginProtectionDb, securityEventDb, _ := useLoginProtection(t)
+
+	// Failures of different emails, none reaches the threshold of an email
+	for i := range config.LOGIN_IP_LOCKOUT_THRESHOLD {
+		emailKey := loginEmailKey(fmt.Sprintf("user%d@example.com", i+1))
+		if apiError := checkLoginAllowed(context.Background(), emailKey, testLoginIp); apiError != nil {
+			t.Fatalf("failure %d: IP refused before the threshold", i+1)
+		}
+		recordLoginFailure(context.Background(), emailKey, testLoginIp, nil)
+	}
+
+	if apiError := checkLoginAllowed(context.Background(), loginEmailKey(testLoginEmail), testLoginIp); apiError == nil {
+		t.Error("login of another email from the locked IP was allowed")
+	}
+
+	if apiError := checkLoginAllowed(context.Background(), loginEmailKey(testLoginEmail), "10.0.0.2"); apiError != nil {
+		t.Errorf("login from another IP was refused: %+v", apiError)
+	}
+
+	if count := loginProtectionDb.failedCount(models.LOGIN_FAILURE_SCOPE_IP, testLoginIp); count != config.LOGIN_IP_LOCKOUT_THRESHOLD {
+		t.Errorf("counted %d failures of the IP, expected %d", count, config.LOGIN_IP_LOCKOUT_THRESHOLD)
+	}
+
+	if len(securityEventDb.events) != 1 || securityEventDb.events[0].EventType != models.SECURITY_EVENT_LOGIN_LOCKED || securityEventDb.events[0].IpAddress != testLoginIp {
+		t.Errorf("security events = %+v, expected one lockout of the IP", securityEventDb.events)
+	}
+}
+
+func TestUnlockUserLoginResetsFailures(t *testing.T) {
+
+	loginProtectionDb, securityEventDb, _ := useLoginProtection(t)
+
+	emailKey := loginEmailKey(testLoginEmail)
+	userId := 7
+
+	for range config.LOGIN_LOCKOUT_THRESHOLD {
+		recordLoginFailure(context.Background(), emailKey, testLoginIp, &userId)
+	}
+
+	if apiError := checkLoginAllowed(context.Background(), emailKey, ""); apiError == nil {
+		t.Fatal("email was not locked at the threshold")
+	}
+
+	response, apiError := UnlockUserLogin(context.Background(), 1, userId)
+	if apiError != nil {
+		t.Fatalf("UnlockUserLogin returned %+v", apiError)
+	}
+
+	if !response.WasLocked {
+		t.Error("UnlockUserLogin answered the login was not locked")
+	}
+
+	if apiError := checkLoginAllowed(context.Background(), emailKey, ""); apiError != nil {
+		t.Errorf("login refused after the unlock: %+v", apiError)
+	}
+
+	// The failures of the IP are not the user's to reset
+	if count := loginProtectionDb.failedCount(models.LOGIN_FAILURE_SCOPE_IP, testLoginIp); count != config.LOGIN_LOCKOUT_THRESHOLD {
+		t.Errorf("unlock left %d failures of the IP, expected %d", count, config.LOGIN_LOCKOUT_THRESHOLD)
+	}
+
+	recordLoginFailure(context.Background(), emailKey, testLoginIp, &userId)
+	if count := loginProtectionDb.failedCount(models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey); count != 1 {
+		t.Errorf("counted %d failures after the unlock, expected the count to start again", count)
+	}
+
+	unlock := securityEventDb.events[len(securityEventDb.events)-1]
+	if unlock.EventType != models.SECURITY_EVENT_LOGIN_UNLOCKED || unlock.ActorUserId == nil || *unlock.ActorUserId != 1 || unlock.UserId == nil || *unlock.UserId != userId {
+		t.Errorf("last security event = %+v, expected the unlock of user 7 by admin 1", unlock)
+	}
+
+	response, apiError = UnlockUserLogin(context.Background(), 1, userId)
+	if apiError != nil || response.WasLocked {
+		t.Errorf("second unlock = %+v, %+v, expected it was not locked", response, apiError)
+	}
+
+	if _, apiError := UnlockUserLogin(context.Background(), 1, 8); apiError == nil || apiError.StatusCode != http.StatusNotFound {
+		t.Errorf("unlock of an unknown user returned %+v, expected 404", apiError)
+	}
+}
+
+func TestUserLoginSameErrorForUnknownEmailAndWrongPassword(t *testing.T) {
+
+	loginProtectionDb, _, _ := useLoginProtection(t)
+
+	_, unknownEmailError := UserLogin(context.Background(), models.LoginRequestBody{Email: "nobody@example.com", Password: testLoginPassword}, testLoginIp)
+	_, wrongPasswordError := UserLogin(context.Background(), models.LoginRequestBody{Email: testLoginEmail, Password: "wrong password"}, testLoginIp)
+
+	if unknownEmailError == nil || wrongPasswordError == nil {
+		t.Fatalf("errors = %+v and %+v, expected both logins to fail", unknownEmailError, wrongPasswordError)
+	}
+
+	if !reflect.DeepEqual(unknownEmailError, wrongPasswordError) {
+		t.Errorf("unknown email answered %+v, wrong password answered %+v", unknownEmailError, wrongPasswordError)
+	}
+
+	if unknownEmailError.StatusCode != http.StatusUnauthorized || unknownEmailError.ApplicationError.Message.ErrorCode != 5104 {
+		t.Errorf("error = %+v, expected 401 and error 5104", unknownEmailError)
+	}
+
+	for _, emailKey := range []string{"nobody@example.com", testLoginEmail} {
+		if count := loginProtectionDb.failedCount(models.LOGIN_FAILURE_SCOPE_EMAIL, emailKey); count != 1 {
+			t.Errorf("counted %d failures of %s, expected 1", count, emailKey)
+		}
+	}
+
+	if count := loginProtectionDb.failedCount(models.LOGIN_FAILURE_SCOPE_IP, testLoginIp); count != 2 {
+		t.Errorf("counted %d failures of the IP, expected 2", count)
+	}
+}
+
+func TestUserLoginChecksLockoutBeforePassword(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		lockScope string
+		lockKey   string
+	}{
+		{name: "locked email", lockScope: models.LOGIN_FAILURE_SCOPE_EMAIL, lockKey: loginEmailKey(testLoginEmail)},
+		{name: "locked IP", lockScope: models.LOGIN_FAILURE_SCOPE_IP, lockKey: testLoginIp},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			loginProtectionDb, _, userDb := useLoginProtection(t)
+
+			lockedUntil := time.Now().Add(time.Duration(config.LOGIN_LOCKOUT_MINUTES) * time.Minute)
+			loginProtectionDb.failures[[2]string{test.lockScope, test.lockKey}] = &models.LoginFailure{
+				Scope: test.lockScope, Key: test.lockKey, FailedCount: 5, LastFailedAt: time.Now(), LockedUntil: &lockedUntil,
+			}
+
+			// The correct password is refused too, the password is never compared
+			_, apiError := UserLogin(context.Background(), models.LoginRequestBody{Email: testLoginEmail, Password: testLoginPassword}, testLoginIp)
+			if apiError == nil || apiError.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("UserLogin returned %+v, expected 429", apiError)
+			}
+
+			if userDb.emailLookups != 0 {
+				t.Errorf("user was looked up %d times before the lockout was checked", userDb.emailLookups)
+			}
+
+			retryAfter, ok := apiError.ApplicationError.Message.AdditionalInfo.(models.RetryAfterInfo)
+			if !ok || retryAfter.RetryAfterSeconds <= 0 || retryAfter.RetryAfterSeconds > config.LOGIN_LOCKOUT_MINUTES*60 {
+				t.Errorf("additionalInfo = %+v, expected the seconds until the lockout ends", apiError.ApplicationError.Message.AdditionalInfo)
+			}
+
+			if count := loginProtectionDb.failedCount(test.lockScope, test.lockKey); count != 5 {
+				t.Errorf("refused login counted as a failure, %d failures", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StartRateLimitCleanupJob deletes the full token buckets of the postgres rate limit
+// store, the memory store forgets them itself.
+func StartRateLimitCleanupJob() {
+
+	if config.RATE_LIMIT_STORE != models.RATE_LIMIT_STORE_POSTGRES || config.RATE_LIMIT_CLEANUP_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartRateLimitCleanupJob: Rate limit cleanup job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.RATE_LIMIT_CLEANUP_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+
+		deleted, appError := database.RateLimitDb.DeleteFullRateLimitBuckets(ctx)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartRateLimitCleanupJob-> Failed to delete full rate limit buckets", appError)
+		} else {
+			logger.WithContext(ctx).Info("StartRateLimitCleanupJob: Completed", zap.Int64("deleted", deleted))
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// roleOrder is the order roles are listed in, from the least to the most privileged.
+var roleOrder = []string{models.ROLE_CUSTOMER, models.ROLE_SUPPORT, models.ROLE_AUDITOR, models.ROLE_OPS, models.ROLE_ADMIN}
+
+func GetRoles(ctx context.Context) []models.Role {
+
+	roles := make([]models.Role, 0, len(roleOrder))
+	for _, role := range roleOrder {
+		roles = append(roles, models.Role{Name: role, Permissions: models.ROLE_PERMISSIONS[role]})
+	}
+
+	return roles
+}
+
+// AssignUserRole changes the role of a user and records who changed it and why. The
+// last admin can not be demoted. The new role is only in the JWT of the user after
+// the next login.
+func AssignUserRole(ctx context.Context, adminUserId int, userId int, req models.AssignRoleRequest) (*models.UserRoleChange, *models.ApiError) {
+
+	tx, err := database.UserDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "AssignUserRole: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5107, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	exists, user, appError := database.UserDb.GetUserByUserIdForUpdate(ctx, tx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "AssignUserRole-> Failed to get user", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("User does not exists UserId: %d!", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5108, errMsg, "", nil)
+	}
+
+	if user.Role == models.ROLE_SYSTEM {
+		errMsg := fmt.Sprintf("AssignUserRole: The role of a system user can not be changed! UserId: %d", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5112, errMsg, "The role of a system user can not be changed", nil)
+	}
+
+	if user.Role == req.Role {
+		errMsg := fmt.Sprintf("AssignUserRole: User already has role %s! UserId: %d", req.Role, userId)
+		logger.WithContext(ctx).Error(errMsg)
+		displayMsg := fmt.Sprintf("User already has role %s", req.Role)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5109, errMsg, displayMsg, nil)
+	}
+
+	if user.Role == models.ROLE_ADMIN {
+
+		admins, appError := database.UserDb.CountUsersWithRoleForUpdate(ctx, tx, models.ROLE_ADMIN)
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "AssignUserRole-> Failed to count admins", appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		if admins <= 1 {
+			errMsg := fmt.Sprintf("AssignUserRole: The last admin can not be demoted! UserId: %d", userId)
+			logger.WithContext(ctx).Error(errMsg)
+			return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5110, errMsg, "The last admin can not be demoted", nil)
+		}
+	}
+
+	appError = database.UserDb.UpdateUserRole(ctx, tx, userId, req.Role)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "AssignUserRole-> Failed to update user role", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	change, appError := database.UserDb.InsertUserRoleChange(ctx, tx, models.UserRoleChange{
+		UserId:    userId,
+		FromRole:  user.Role,
+		ToRole:    req.Role,
+		Reason:    req.Reason,
+		ChangedBy: &adminUserId,
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "AssignUserRole-> Failed to save user role change", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "AssignUserRole: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5111, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return &change, nil
+}
+
+func GetUserRoleChanges(ctx context.Context, userId int) ([]models.UserRoleChange, *models.ApiError) {
+
+	changes, appError := database.UserDb.GetUserRoleChanges(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetUserRoleChanges-> Failed to get user role changes", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if changes == nil {
+		changes = []models.UserRoleChange{}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	reconciliationRunsListLimit = 20
+	reconciliationBatchSize     = 1000
+)
+
+// reconciliationLogTotals is what the transaction log adds up to for one user.
+type reconciliationLogTotals struct {
+	expectedBalance   int64
+	successfulEntries int
+}
+
+// streamRequestLogsBefore groups every record of the transactions collection written
+// before cutoff by request id, so a request that was logged both as success and as
+// failed can be told apart from a clean one. The groups are streamed to handle one
+// at a time instead of loading the whole collection.
+func streamRequestLogsBefore(ctx context.Context, cutoff int64, handle func(requestLog models.ReconciliationRequestLog)) *models.ApplicationError {
+
+	txCollection := database.GetCollection("transactions")
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"transactionTime": bson.M{"$lt": cutoff}}},
+		bson.M{"$group": bson.M{
+			"_id":             "$requestId",
+			"userId":          bson.M{"$first": "$userId"},
+			"amount":          bson.M{"$first": "$amount"},
+			"transactionType": bson.M{"$first": "$transactionType"},
+			"statuses":        bson.M{"$push": "$transactionStatus"},
+		}},
+	}
+
+	cursor, err := txCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true).SetBatchSize(reconciliationBatchSize))
+	if err != nil {
+		errMsg := fmt.Sprintf("streamRequestLogsBefore: Failed to aggregate transactions in MongoDB! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5301, errMsg, "", nil)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var requestLog models.ReconciliationRequestLog
+		if err := cursor.Decode(&requestLog); err != nil {
+			errMsg := fmt.Sprintf("streamRequestLogsBefore: Failed to decode aggregated transaction! Error: %s", err.Error())
+			logger.WithContext(ctx).Error(errMsg)
+			return utils.RenderAppError(ctx, 5302, errMsg, "", nil)
+		}
+		handle(requestLog)
+	}
+
+	if err := cursor.Err(); err != nil {
+		errMsg := fmt.Sprintf("streamRequestLogsBefore: Failed to read aggregated transactions! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5304, errMsg, "", nil)
+	}
+
+	return nil
+}
+
+// reconciliationLedger adds up the transaction log per user and collects the
+// findings of the run.
+type reconciliationLedger struct {
+	runId          int
+	accountsByUser map[int]models.Account
+	logTotals      map[int]reconciliationLogTotals
+	findings       []models.ReconciliationFinding
+}
+
+func newReconciliationLedger(runId int, accounts []models.Account) *reconciliationLedger {
+
+	accountsByUser := map[int]models.Account{}
+	for _, account := range accounts {
+		accountsByUser[account.UserID] = account
+	}
+
+	return &reconciliationLedger{
+		runId:          runId,
+		accountsByUser: accountsByUser,
+		logTotals:      map[int]reconciliationLogTotals{},
+		findings:       []models.ReconciliationFinding{},
+	}
+}
+
+// addRequestLog adds the success records of a request to the totals of its user, or
+// reports them as orphans when the request was also logged as failed or the user
+// has no account.
+func (l *reconciliationLedger) addRequestLog(requestLog models.ReconciliationRequestLog) {
+
+	successCount := 0
+	for _, status := range requestLog.Statuses {
+		if status == "success" {
+			successCount++
+		}
+	}
+
+	if successCount == 0 {
+		return
+	}
+
+	userId := requestLog.UserId
+	requestId := requestLog.RequestId
+
+	if slices.Contains(requestLog.Statuses, "failed") {
+		l.findings = append(l.findings, models.ReconciliationFinding{
+			RunId:       l.runId,
+			FindingType: models.RECONCILIATION_ORPHAN_RECORD,
+			UserId:      &userId,
+			RequestId:   &requestId,
+			Details:     fmt.Sprintf("Request has a success record but was also logged as failed, the %s of %.2f was most likely rolled back", requestLog.TransactionType, requestLog.Amount),
+		})
+		return
+	}
+
+	if _, exists := l.accountsByUser[userId]; !exists {
+		l.findings = append(l.findings, models.ReconciliationFinding{
+			RunId:       l.runId,
+			FindingType: models.RECONCILIATION_ORPHAN_RECORD,
+			UserId:      &userId,
+			RequestId:   &requestId,
+			Details:     fmt.Sprintf("Success record for a %s of %.2f but the user has no account", requestLog.TransactionType, requestLog.Amount),
+		})
+		return
+	}
+
+	totals := l.logTotals[userId]
+	totals.expectedBalance += int64(successCount) * signedAmountInPaise(requestLog.TransactionType, requestLog.Amount)
+	totals.successfulEntries += successCount
+	l.logTotals[userId] = totals
+}
+
+// checkAccounts compares the balance of every account with its totals. Accounts
+// updated after the run started are skipped, their log entries may not be complete
+// yet, and are reported in the run so they can be checked by the next one.
+func (l *reconciliationLedger) checkAccounts(run *models.ReconciliationRun, accounts []models.Account, pendingFeeIncome int64) {
+
+	for _, account := range accounts {
+
+		accountId := account.AccountID
+		userId := account.UserID
+		actualBalance := account.Balance
+
+		if !account.UpdatedAt.Before(run.StartedAt) {
+			run.AccountsSkipped++
+			l.findings = append(l.findings, models.ReconciliationFinding{
+				RunId:         l.runId,
+				FindingType:   models.RECONCILIATION_ACCOUNT_SKIPPED,
+				AccountId:     &accountId,
+				UserId:        &userId,
+				ActualBalance: &actualBalance,
+				Details:       fmt.Sprintf("Account was updated at %s after the run started, it was not checked", account.UpdatedAt.UTC().Format(time.RFC3339)),
+			})
+			continue
+		}
+
+		run.AccountsChecked++
+
+		if account.AccountType == "internal_income" {
+			actualBalance += pendingFeeIncome
+		}
+
+		totals := l.logTotals[userId]
+		expectedBalance := totals.expectedBalance
+
+		if expectedBalance == actualBalance {
+			continue
+		}
+
+		l.findings = append(l.findings, models.ReconciliationFinding{
+			RunId:           l.runId,
+			FindingType:     models.RECONCILIATION_BALANCE_MISMATCH,
+			AccountId:       &accountId,
+			UserId:          &userId,
+			ExpectedBalance: &expectedBalance,
+			ActualBalance:   &actualBalance,
+			Details:         fmt.Sprintf("Transaction log adds up to %.2f over %d entries but the account balance is %.2f", utils.ConvertPaiseToRupees(expectedBalance), totals.successfulEntries, utils.ConvertPaiseToRupees(actualBalance)),
+		})
+	}
+}
+
+// addMissingRecords reports the processed requests without a success record in the
+// transaction log, transactionLogs holds the outcome logged for each of them.
+func (l *reconciliationLedger) addMissingRecords(requests []models.ProcessedTransactionRequest, transactionLogs map[uuid.UUID]models.TransactionCollection) {
+
+	for _, request := range requests {
+
+		transactionLog, logged := transactionLogs[request.RequestId]
+		if logged && transactionLog.TransactionStatus == "success" {
+			continue
+		}
+
+		userId := request.UserId
+		requestId := request.RequestId
+
+		finding := models.ReconciliationFinding{
+			RunId:       l.runId,
+			FindingType: models.RECONCILIATION_MISSING_RECORDS,
+			UserId:      &userId,
+			RequestId:   &requestId,
+			Details:     fmt.Sprintf("Request was applied to the balance at %s but has no success record in the transaction log", request.ProcessedAt.UTC().Format(time.RFC3339)),
+		}
+
+		if logged {
+			finding.Details = fmt.Sprintf("Request was applied to the balance at %s but is only logged as %s", request.ProcessedAt.UTC().Format(time.RFC3339), transactionLog.TransactionStatus)
+		}
+
+		if account, exists := l.accountsByUser[userId]; exists {
+			accountId := account.AccountID
+			finding.AccountId = &accountId
+		}
+
+		l.findings = append(l.findings, finding)
+	}
+}
+
+// findMissingRecords pages through the requests applied before the run started and
+// looks up their records in the transaction log. ProcessTransaction writes the log
+// before it commits, so every request visible here has its records written already.
+func (l *reconciliationLedger) findMissingRecords(ctx context.Context, startedAt time.Time) *models.ApplicationError {
+
+	afterRequestId := uuid.Nil
+
+	for {
+
+		requests, appError := database.ReconDb.GetProcessedRequestsBefore(ctx, startedAt, afterRequestId, reconciliationBatchSize)
+		if appError != nil {
+			return appError
+		}
+
+		if len(requests) == 0 {
+			return nil
+		}
+
+		requestIds := []uuid.UUID{}
+		for _, request := range requests {
+			requestIds = append(requestIds, request.RequestId)
+		}
+
+		transactionLogs, appError := getTransactionLogsByRequestIds(ctx, requestIds)
+		if appError != nil {
+			return appError
+		}
+
+		l.addMissingRecords(requests, transactionLogs)
+
+		if len(requests) < reconciliationBatchSize {
+			return nil
+		}
+
+		afterRequestId = requests[len(requests)-1].RequestId
+	}
+}
+
+// runReconciliation recomputes every account balance from its successful entries in
+// the transaction log and compares it with accounts.balance, and checks every
+// request applied to a balance has a success record.
+func runReconciliation(ctx context.Context, run models.ReconciliationRun) models.ReconciliationRun {
+
+	run.Status = "failed"
+
+	accounts, appError := database.AccDb.GetAllAccounts(ctx)
+	if appError != nil {
+		run.ErrorMessage = &appError.Message.ErrorMessage
+		completeReconciliationRun(ctx, run)
+		return run
+	}
+
+	// Fee income is added to the internal income account by the roll up job, the
+	// fees charged since the last roll up are part of its balance already
+	pendingFeeIncome, appError := database.FeeDb.GetPendingFeeIncome(ctx, run.StartedAt)
+	if appError != nil {
+		run.ErrorMessage = &appError.Message.ErrorMessage
+		completeReconciliationRun(ctx, run)
+		return run
+	}
+
+	ledger := newReconciliationLedger(run.RunId, accounts)
+
+	appError = streamRequestLogsBefore(ctx, run.StartedAt.Unix(), ledger.addRequestLog)
+	if appError != nil {
+		run.ErrorMessage = &appError.Message.ErrorMessage
+		completeReconciliationRun(ctx, run)
+		return run
+	}
+
+	ledger.checkAccounts(&run, accounts, pendingFeeIncome)
+
+	appError = ledger.findMissingRecords(ctx, run.StartedAt)
+	if appError != nil {
+		run.ErrorMessage = &appError.Message.ErrorMessage
+		completeReconciliationRun(ctx, run)
+		return run
+	}
+
+	for _, finding := range ledger.findings {
+
+		switch finding.FindingType {
+		case models.RECONCILIATION_BALANCE_MISMATCH:
+			run.MismatchCount++
+		case models.RECONCILIATION_ORPHAN_RECORD:
+			run.OrphanCount++
+		case models.RECONCILIATION_MISSING_RECORDS:
+			run.MissingCount++
+		}
+
+		appError = database.ReconDb.SaveReconciliationFinding(ctx, finding)
+		if appError != nil {
+			run.ErrorMessage = &appError.Message.ErrorMessage
+			completeReconciliationRun(ctx, run)
+			return run
+		}
+
+		if run.WriteServiceErrors && finding.FindingType != models.RECONCILIATION_ACCOUNT_SKIPPED {
+			errMsg := fmt.Sprintf("Reconciliation run %d: %s", run.RunId, finding.FindingType)
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, []byte(utils.ConvertStructToString(finding)))
+		}
+	}
+
+	run.Status = "completed"
+	completeReconciliationRun(ctx, run)
+
+	fields := []zap.Field{
+		zap.Int("runId", run.RunId),
+		zap.Int("accountsChecked", run.AccountsChecked),
+		zap.Int("accountsSkipped", run.AccountsSkipped),
+		zap.Int("mismatchCount", run.MismatchCount),
+		zap.Int("orphanCount", run.OrphanCount),
+		zap.Int("missingCount", run.MissingCount),
+	}
+	logger.WithContext(ctx).Info("runReconciliation: Completed", fields...)
+
+	return run
+}
+
+func completeReconciliationRun(ctx context.Context, run models.ReconciliationRun) {
+
+	appError := database.ReconDb.CompleteReconciliationRun(ctx, run)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "completeReconciliationRun-> Failed to update reconciliation run", appError)
+	}
+}
+
+func StartReconciliationJob() {
+
+	if config.RECONCILIATION_JOB_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartReconciliationJob: Reconciliation job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.RECONCILIATION_JOB_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		ctx := utils.CreateContextWithNewRequestId()
+
+		run, appError := database.ReconDb.CreateReconciliationRun(ctx, config.RECONCILIATION_WRITE_SERVICE_ERRORS)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartReconciliationJob-> Failed to create reconciliation run", appError)
+			continue
+		}
+
+		runReconciliation(ctx, run)
+	}
+}
+
+// StartReconciliation records a new run and reconciles in the background, the
+// report is available through GetReconciliationReport once the run completes.
+func StartReconciliation(ctx context.Context, req models.StartReconciliationRequest) (*models.ReconciliationRun, *models.ApiError) {
+
+	writeServiceErrors := config.RECONCILIATION_WRITE_SERVICE_ERRORS
+	if req.WriteServiceErrors != nil {
+		writeServiceErrors = *req.WriteServiceErrors
+	}
+
+	run, appError := database.ReconDb.CreateReconciliationRun(ctx, writeServiceErrors)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "StartReconciliation-> Failed to create reconciliation run", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	go runReconciliation(utils.CreateContextWithNewRequestId(), run)
+
+	return &run, nil
+}
+
+func GetReconciliationRuns(ctx context.Context) ([]models.ReconciliationRun, *models.ApiError) {
+
+	runs, appError := database.ReconDb.GetReconciliationRuns(ctx, reconciliationRunsListLimit)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetReconciliationRuns-> Failed to get reconciliation runs", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if runs == nil {
+		runs = []models.ReconciliationRun{}
+	}
+
+	return runs, nil
+}
+
+func GetReconciliationReport(ctx context.Context, runId int) (*models.GetReconciliationReportResponse, *models.ApiError) {
+
+	exists, run, appError := database.ReconDb.GetReconciliationRun(ctx, runId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetReconciliationReport-> Failed to get reconciliation run", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Reconciliation run does not exists RunId: %d!", runId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5303, errMsg, "", nil)
+	}
+
+	findings, appError := database.ReconDb.GetReconciliationFindings(ctx, runId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetReconciliationReport-> Failed to get reconciliation findings", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if findings == nil {
+		findings = []models.ReconciliationFinding{}
+	}
+
+	response := models.GetReconciliationReportResponse{
+		Run:      run,
+		Findings: findings,
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"banking_ledger/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// reconciliationAccounts are the accounts of users 1 to 3 and the internal income
+// account of user 9, none updated since the run started.
+func reconciliationAccounts(startedAt time.Time, balances map[int]int64) []models.Account {
+
+	accounts := []models.Account{}
+	for _, userId := range []int{1, 2, 3, 9} {
+
+		accountType := "savings"
+		if userId == 9 {
+			accountType = "internal_income"
+		}
+
+		accounts = append(accounts, models.Account{
+			AccountID:   100 + userId,
+			UserID:      userId,
+			Balance:     balances[userId],
+			AccountType: accountType,
+			UpdatedAt:   startedAt.Add(-time.Hour),
+		})
+	}
+
+	return accounts
+}
+
+func TestReconciliationLedgerRequestLogs(t *testing.T) {
+
+	requestId := uuid.MustParse("0f8fad5b-d9cb-569f-a165-70867728950e")
+
+	tests := []struct {
+		name             string
+		requestLog       models.ReconciliationRequestLog
+		expectedFinding  string // Finding type, empty when the log is added to the totals
+		expectedBalance  int64
+		expectedEntries  int
+		expectedInDetail string
+	}{
+		{
+			name:            "deposit",
+			requestLog:      models.ReconciliationRequestLog{RequestId: requestId, UserId: 1, Amount: 150.25, TransactionType: "deposit", Statuses: []string{"success"}},
+			expectedBalance: 15025,
+			expectedEntries: 1,
+		},
+		{
+			name:            "withdrawal",
+			requestLog:      models.ReconciliationRequestLog{RequestId: requestId, UserId: 1, Amount: 20, TransactionType: "withdraw", Statuses: []string{"success"}},
+			expectedBalance: -2000,
+			expectedEntries: 1,
+		},
+		{
+			name:       "failed only",
+			requestLog: models.ReconciliationRequestLog{RequestId: requestId, UserId: 1, Amount: 20, TransactionType: "withdraw", Statuses: []string{"failed"}},
+		},
+		{
+			name:            "success logged twice",
+			requestLog:      models.ReconciliationRequestLog{RequestId: requestId, UserId: 1, Amount: 10, TransactionType: "deposit", Statuses: []string{"success", "success"}},
+			expectedBalance: 2000,
+			expectedEntries: 2,
+		},
+		{
+			name:             "success and failed",
+			requestLog:       models.ReconciliationRequestLog{RequestId: requestId, UserId: 1, Amount: 20, TransactionType: "deposit", Statuses: []string{"success", "failed"}},
+			expectedFinding:  models.RECONCILIATION_ORPHAN_RECORD,
+			expectedInDetail: "also logged as failed",
+		},
+		{
+			name:             "user without an account",
+			requestLog:       models.ReconciliationRequestLog{RequestId: requestId, UserId: 4, Amount: 20, TransactionType: "deposit", Statuses: []string{"success"}},
+			expectedFinding:  models.RECONCILIATION_ORPHAN_RECORD,
+			expectedInDetail: "no account",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			ledger := newReconciliationLedger(5, reconciliationAccounts(time.Now(), nil))
+			ledger.addRequestLog(test.requestLog)
+
+			totals := ledger.logTotals[test.requestLog.UserId]
+			if totals.expectedBalance != test.expectedBalance || totals.successfulEntries != test.expectedEntries {
+				t.Errorf("totals = %+v, expected balance %d over %d entries", totals, test.expectedBalance, test.expectedEntries)
+			}
+
+			if test.expectedFinding == "" {
+				if len(ledger.findings) != 0 {
+					t.Errorf("findings = %+v, expected none", ledger.findings)
+				}
+				return
+			}
+
+			if len(ledger.findings) != 1 {
+				t.Fatalf("findings = %+v, expected one %s", ledger.findings, test.expectedFinding)
+			}
+
+			finding := ledger.findings[0]
+			if finding.FindingType != test.expectedFinding || finding.RunId != 5 || finding.RequestId == nil || *finding.RequestId != requestId || !strings.Contains(finding.Details, test.expectedInDetail) {
+				t.Errorf("finding = %+v, expected %s of the request mentioning %q", finding, test.expectedFinding, test.expectedInDetail)
+			}
+		})
+	}
+}
+
+func TestReconciliationLedgerCheckAccounts(t *testing.T) {
+
+	startedAt := time.Date(2024, time.June, 1, 2, 0, 0, 0, time.UTC)
+
+	// User 1 deposited 100 and withdrew 30, user 2 deposited 50, user 3 has no records
+	// and the income account received a fee of 5 that is not rolled up yet
+	requestLogs := []models.ReconciliationRequestLog{
+		{RequestId: uuid.New(), UserId: 1, Amount: 100, TransactionType: "deposit", Statuses: []string{"success"}},
+		{RequestId: uuid.New(), UserId: 1, Amount: 30, TransactionType: "withdraw", Statuses: []string{"success"}},
+		{RequestId: uuid.New(), UserId: 2, Amount: 50, TransactionType: "deposit", Statuses: []string{"success"}},
+		{RequestId: uuid.New(), UserId: 9, Amount: 5, TransactionType: "fee_income", Statuses: []string{"success"}},
+	}
+
+	tests := []struct {
+		name             string
+		balances         map[int]int64
+		updatedUserId    int // Account updated after the run started
+		expectedFindings map[int]string
+		expectedChecked  int
+		expectedSkipped  int
+	}{
+		{
+			name:             "every balance matches",
+			balances:         map[int]int64{1: 7000, 2: 5000},
+			expectedFindings: map[int]string{},
+			expectedChecked:  4,
+		},
+		{
+			name:             "balance off by a paisa",
+			balances:         map[int]int64{1: 7001, 2: 5000},
+			expectedFindings: map[int]string{1: models.RECONCILIATION_BALANCE_MISMATCH},
+			expectedChecked:  4,
+		},
+		{
+			name:             "balance without records is a mismatch",
+			balances:         map[int]int64{1: 7000, 2: 5000, 3: 1000},
+			expectedFindings: map[int]string{3: models.RECONCILIATION_BALANCE_MISMATCH},
+			expectedChecked:  4,
+		},
+		{
+			name:             "updated account is skipped",
+			balances:         map[int]int64{1: 9000, 2: 5000},
+			updatedUserId:    1,
+			expectedFindings: map[int]string{1: models.RECONCILIATION_ACCOUNT_SKIPPED},
+			expectedChecked:  3,
+			expectedSkipped:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			accounts := reconciliationAccounts(startedAt, test.balances)
+			for i := range accounts {
+				if accounts[i].UserID == test.updatedUserId {
+					accounts[i].UpdatedAt = startedAt
+				}
+			}
+
+			run := models.ReconciliationRun{RunId: 5, StartedAt: startedAt}
+
+			ledger := newReconciliationLedger(run.RunId, accounts)
+			for _, requestLog := range requestLogs {
+				ledger.addRequestLog(requestLog)
+			}
+
+			// The income account's balance does not include the fee yet
+			ledger.checkAccounts(&run, accounts, 500)
+
+			if run.AccountsChecked != test.expectedChecked || run.AccountsSkipped != test.expectedSkipped {
+				t.Errorf("checked %d and skipped %d accounts, expected %d and %d", run.AccountsChecked, run.AccountsSkipped, test.expectedChecked, test.expectedSkipped)
+			}
+
+			findings := map[int]string{}
+			for _, finding := range ledger.findings {
+				findings[*finding.UserId] = finding.FindingType
+			}
+
+			if len(findings) != len(test.expectedFindings) || len(ledger.findings) != len(test.expectedFindings) {
+				t.Fatalf("findings = %+v, expected %v", ledger.findings, test.expectedFindings)
+			}
+
+			for userId, findingType := range test.expectedFindings {
+				if findings[userId] != findingType {
+					t.Errorf("finding of user %d = %q, expected %s", userId, findings[userId], findingType)
+				}
+			}
+		})
+	}
+}
+
+func TestReconciliationLedgerMissingRecords(t *testing.T) {
+
+	processedAt := time.Date(2024, time.June, 1, 1, 0, 0, 0, time.UTC)
+
+	logged := uuid.MustParse("11111111-1111-4111-8111-111111111111")
+	loggedAsFailed := uuid.MustParse("22222222-2222-4222-8222-222222222222")
+	notLogged := uuid.MustParse("33333333-3333-4333-8333-333333333333")
+	notLoggedWithoutAccount := uuid.MustParse("44444444-4444-4444-8444-444444444444")
+
+	requests := []models.ProcessedTransactionRequest{
+		{RequestId: logged, UserId: 1, ProcessedAt: processedAt},
+		{RequestId: loggedAsFailed, UserId: 1, ProcessedAt: processedAt},
+		{RequestId: notLogged, UserId: 2, ProcessedAt: processedAt},
+		{RequestId: notLoggedWithoutAccount, UserId: 4, ProcessedAt: processedAt},
+	}
+
+	transactionLogs := map[uuid.UUID]models.TransactionCollection{
+		logged:         {RequestId: logged, TransactionStatus: "success"},
+		loggedAsFailed: {RequestId: loggedAsFailed, TransactionStatus: "failed"},
+	}
+
+	ledger := newReconciliationLedger(5, reconciliationAccounts(processedAt, nil))
+	ledger.addMissingRecords(requests, transactionLogs)
+
+	tests := []struct {
+		requestId         uuid.UUID
+		expectedUserId    int
+		expectedAccountId *int
+		expectedInDetail  string
+	}{
+		{requestId: loggedAsFailed, expectedUserId: 1, expectedAccountId: &[]int{101}[0], expectedInDetail: "only logged as failed"},
+		{requestId: notLogged, expectedUserId: 2, expectedAccountId: &[]int{102}[0], expectedInDetail: "no success record"},
+		{requestId: notLoggedWithoutAccount, expectedUserId: 4, expectedInDetail: "no success record"},
+	}
+
+	if len(ledger.findings) != len(tests) {
+		t.Fatalf("findings = %+v, expected one for each request without a success record", ledger.findings)
+	}
+
+	for i, test := range tests {
+
+		finding := ledger.findings[i]
+
+		if finding.FindingType != models.RECONCILIATION_MISSING_RECORDS || *finding.RequestId != test.requestId || *finding.UserId != test.expectedUserId {
+			t.Errorf("finding %d = %+v, expected %s of request %s", i, finding, models.RECONCILIATION_MISSING_RECORDS, test.requestId)
+		}
+
+		if (finding.AccountId == nil) != (test.expectedAccountId == nil) || (finding.AccountId != nil && *finding.AccountId != *test.expectedAccountId) {
+			t.Errorf("finding %d account = %v, expected %v", i, finding.AccountId, test.expectedAccountId)
+		}
+
+		if !strings.Contains(finding.Details, test.expectedInDetail) {
+			t.Errorf("finding %d details %q, expected to mention %q", i, finding.Details, test.expectedInDetail)
+		}
+	}
+}
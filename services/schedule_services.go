@@ -0,0 +1,521 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	scheduleBatchSize        = 100
+	scheduleRunMaxRetryDelay = time.Hour
+)
+
+func monthlyOccurrence(year int, month time.Month, dayOfMonth int) time.Time {
+
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if dayOfMonth > lastDay {
+		dayOfMonth = lastDay
+	}
+
+	return time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC)
+}
+
+// scheduleOccurrenceOnOrAfter returns the first date on or after from on which the
+// schedule runs.
+func scheduleOccurrenceOnOrAfter(schedule models.RecurringSchedule, from time.Time) time.Time {
+
+	if from.Before(schedule.StartDate) {
+		from = schedule.StartDate
+	}
+
+	switch schedule.Frequency {
+	case "weekly":
+		offset := (*schedule.DayOfWeek - int(from.Weekday()) + 7) % 7
+		return from.AddDate(0, 0, offset)
+	case "monthly":
+		occurrence := monthlyOccurrence(from.Year(), from.Month(), *schedule.DayOfMonth)
+		if occurrence.Before(from) {
+			occurrence = monthlyOccurrence(from.Year(), from.Month()+1, *schedule.DayOfMonth)
+		}
+		return occurrence
+	}
+
+	return from
+}
+
+// scheduleRequestId is the request id used for the run of a schedule on a date. It
+// is the same every time the run is sent, ProcessTransaction applies it only once.
+func scheduleRequestId(scheduleId int, scheduledDate time.Time) uuid.UUID {
+	return uuid.NewSHA1(models.RECURRING_SCHEDULE_NAMESPACE, []byte(fmt.Sprintf("recurring-schedule:%d:%s", scheduleId, scheduledDate.Format(balanceDateLayout))))
+}
+
+func toRecurringScheduleResponse(schedule models.RecurringSchedule) models.RecurringScheduleResponse {
+
+	response := models.RecurringScheduleResponse{
+		ScheduleId:      schedule.ScheduleId,
+		TransactionType: schedule.TransactionType,
+		Amount:          utils.ConvertPaiseToRupees(schedule.Amount),
+		Frequency:       schedule.Frequency,
+		DayOfWeek:       schedule.DayOfWeek,
+		DayOfMonth:      schedule.DayOfMonth,
+		StartDate:       schedule.StartDate.Format(balanceDateLayout),
+		Status:          schedule.Status,
+	}
+
+	if schedule.EndDate != nil {
+		endDate := schedule.EndDate.Format(balanceDateLayout)
+		response.EndDate = &endDate
+	}
+
+	if schedule.NextRunDate != nil {
+		nextRunDate := schedule.NextRunDate.Format(balanceDateLayout)
+		response.NextRunDate = &nextRunDate
+	}
+
+	return response
+}
+
+// getTransactionLogsByRequestIds returns the outcome logged for each request id, a
+// success record wins over a failed one.
+func getTransactionLogsByRequestIds(ctx context.Context, requestIds []uuid.UUID) (map[uuid.UUID]models.TransactionCollection, *models.ApplicationError) {
+
+	transactionLogs := map[uuid.UUID]models.TransactionCollection{}
+
+	if len(requestIds) == 0 {
+		return transactionLogs, nil
+	}
+
+	txCollection := database.GetCollection("transactions")
+
+	cursor, err := txCollection.Find(ctx, bson.M{"requestId": bson.M{"$in": requestIds}})
+	if err != nil {
+		errMsg := fmt.Sprintf("getTransactionLogsByRequestIds: Failed to find transactions in MongoDB! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderAppError(ctx, 5401, errMsg, "", nil)
+	}
+	defer cursor.Close(ctx)
+
+	transactions := []models.TransactionCollection{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		errMsg := fmt.Sprintf("getTransactionLogsByRequestIds: Failed to decode transactions! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderAppError(ctx, 5402, errMsg, "", nil)
+	}
+
+	for _, transaction := range transactions {
+		if existing, ok := transactionLogs[transaction.RequestId]; ok && existing.TransactionStatus == "success" {
+			continue
+		}
+		transactionLogs[transaction.RequestId] = transaction
+	}
+
+	return transactionLogs, nil
+}
+
+// CreateRecurringSchedule creates a deposit or withdraw schedule. A withdraw schedule
+// needs the same step-up as a single withdrawal of its amount, see checkWithdrawalStepUp.
+func CreateRecurringSchedule(ctx context.Context, userId int, req models.CreateRecurringScheduleRequest, secondFactorRecent bool) (*models.RecurringScheduleResponse, *models.ApiError) {
+
+	if !isWholePaiseAmount(req.Amount) {
+		errMsg := "amount must be at least 0.01"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5412, errMsg, errMsg, nil)
+	}
+
+	startDate, _ := time.Parse(balanceDateLayout, req.StartDate)
+
+	if startDate.Before(startOfDay(time.Now())) {
+		errMsg := "startDate must not be in the past"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5403, errMsg, errMsg, nil)
+	}
+
+	schedule := models.RecurringSchedule{
+		UserId:          userId,
+		TransactionType: req.TransactionType,
+		Amount:          utils.ConvertRupeesToPaise(req.Amount),
+		Frequency:       req.Frequency,
+		StartDate:       startDate,
+		Status:          "active",
+	}
+
+	if req.EndDate != nil {
+		endDate, _ := time.Parse(balanceDateLayout, *req.EndDate)
+		if endDate.Before(startDate) {
+			errMsg := "endDate must not be before startDate"
+			logger.WithContext(ctx).Error(errMsg)
+			return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5404, errMsg, errMsg, nil)
+		}
+		schedule.EndDate = &endDate
+	}
+
+	switch req.Frequency {
+	case "weekly":
+		dayOfWeek := int(startDate.Weekday())
+		if req.DayOfWeek != nil {
+			dayOfWeek = *req.DayOfWeek
+		}
+		schedule.DayOfWeek = &dayOfWeek
+	case "monthly":
+		dayOfMonth := startDate.Day()
+		if req.DayOfMonth != nil {
+			dayOfMonth = *req.DayOfMonth
+		}
+		schedule.DayOfMonth = &dayOfMonth
+	}
+
+	nextRunDate := scheduleOccurrenceOnOrAfter(schedule, startDate)
+	if schedule.EndDate != nil && nextRunDate.After(*schedule.EndDate) {
+		errMsg := "Schedule has no run between startDate and endDate"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5405, errMsg, errMsg, nil)
+	}
+	schedule.NextRunDate = &nextRunDate
+
+	apiError := checkWithdrawalStepUp(ctx, userId, req.TransactionType, req.Amount, req.OtpCode, secondFactorRecent)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	_, apiError = getAccountForUser(ctx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	scheduleId, appError := database.SchedDb.CreateSchedule(ctx, schedule)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateRecurringSchedule-> Failed to create recurring schedule", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+	schedule.ScheduleId = scheduleId
+
+	response := toRecurringScheduleResponse(schedule)
+
+	return &response, nil
+}
+
+func GetRecurringSchedules(ctx context.Context, userId int) ([]models.RecurringScheduleResponse, *models.ApiError) {
+
+	schedules, appError := database.SchedDb.GetSchedulesByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetRecurringSchedules-> Failed to get recurring schedules", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := []models.RecurringScheduleResponse{}
+	for _, schedule := range schedules {
+		response = append(response, toRecurringScheduleResponse(schedule))
+	}
+
+	return response, nil
+}
+
+func getScheduleOfUser(ctx context.Context, userId int, scheduleId int) (*models.RecurringSchedule, *models.ApiError) {
+
+	exists, schedule, appError := database.SchedDb.GetScheduleById(ctx, scheduleId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "getScheduleOfUser-> Failed to get recurring schedule", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists || schedule.UserId != userId {
+		errMsg := fmt.Sprintf("Recurring schedule does not exists ScheduleId: %d!", scheduleId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5406, errMsg, "", nil)
+	}
+
+	return &schedule, nil
+}
+
+func CancelRecurringSchedule(ctx context.Context, userId int, scheduleId int) *models.ApiError {
+
+	schedule, apiError := getScheduleOfUser(ctx, userId, scheduleId)
+	if apiError != nil {
+		return apiError
+	}
+
+	if schedule.Status != "active" {
+		errMsg := fmt.Sprintf("Recurring schedule is already %s!", schedule.Status)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusBadRequest, 5407, errMsg, errMsg, nil)
+	}
+
+	appError := database.SchedDb.CancelSchedule(ctx, scheduleId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CancelRecurringSchedule-> Failed to cancel recurring schedule", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return nil
+}
+
+func GetRecurringScheduleRuns(ctx context.Context, userId int, scheduleId int) ([]models.RecurringScheduleRunResponse, *models.ApiError) {
+
+	_, apiError := getScheduleOfUser(ctx, userId, scheduleId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	runs, appError := database.SchedDb.GetScheduleRuns(ctx, scheduleId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetRecurringScheduleRuns-> Failed to get recurring schedule runs", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	requestIds := []uuid.UUID{}
+	for _, run := range runs {
+		requestIds = append(requestIds, run.RequestId)
+	}
+
+	transactionLogs, appError := getTransactionLogsByRequestIds(ctx, requestIds)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetRecurringScheduleRuns-> Failed to get transaction outcomes", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := []models.RecurringScheduleRunResponse{}
+	for _, run := range runs {
+
+		runResponse := models.RecurringScheduleRunResponse{
+			RunId:         run.RunId,
+			ScheduledDate: run.ScheduledDate.Format(balanceDateLayout),
+			RequestId:     run.RequestId,
+			Status:        run.Status,
+			Message:       run.Message,
+			AttemptCount:  run.AttemptCount,
+		}
+
+		if transactionLog, ok := transactionLogs[run.RequestId]; ok {
+			runResponse.TransactionStatus = &transactionLog.TransactionStatus
+			runResponse.TransactionMsg = &transactionLog.TransactionMsg
+		}
+
+		response = append(response, runResponse)
+	}
+
+	return response, nil
+}
+
+// claimDueScheduleRuns records a run for every due schedule and moves the schedule
+// to its next date in one transaction. When several runs were missed only the
+// latest one is executed, the older ones are recorded as skipped.
+func claimDueScheduleRuns(ctx context.Context) *models.ApplicationError {
+
+	tx, err := database.SchedDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "claimDueScheduleRuns: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5408, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	today := startOfDay(time.Now())
+
+	schedules, appError := database.SchedDb.GetDueSchedulesForUpdate(ctx, tx, today, scheduleBatchSize)
+	if appError != nil {
+		return appError
+	}
+
+	for _, schedule := range schedules {
+
+		accountExists, _, appError := database.AccDb.GetAccountByUserId(ctx, tx, schedule.UserId)
+		if appError != nil {
+			return appError
+		}
+
+		runDates := []time.Time{}
+		nextRunDate := *schedule.NextRunDate
+		for !nextRunDate.After(today) && (schedule.EndDate == nil || !nextRunDate.After(*schedule.EndDate)) {
+			runDates = append(runDates, nextRunDate)
+			nextRunDate = scheduleOccurrenceOnOrAfter(schedule, nextRunDate.AddDate(0, 0, 1))
+		}
+
+		for index, runDate := range runDates {
+
+			run := models.RecurringScheduleRun{
+				ScheduleId:    schedule.ScheduleId,
+				ScheduledDate: runDate,
+				RequestId:     scheduleRequestId(schedule.ScheduleId, runDate),
+				Status:        "pending",
+			}
+
+			if index < len(runDates)-1 {
+				message := "Run was missed, only the latest due run is executed"
+				run.Status = "skipped"
+				run.Message = &message
+			} else if !accountExists {
+				message := "Account does not exists for this user"
+				run.Status = "skipped"
+				run.Message = &message
+			}
+
+			appError = database.SchedDb.InsertScheduleRun(ctx, tx, run)
+			if appError != nil {
+				return appError
+			}
+		}
+
+		status := "active"
+		nextRunDatePtr := &nextRunDate
+		if schedule.EndDate != nil && nextRunDate.After(*schedule.EndDate) {
+			status = "completed"
+			nextRunDatePtr = nil
+		}
+
+		appError = database.SchedDb.UpdateScheduleNextRun(ctx, tx, schedule.ScheduleId, nextRunDatePtr, status)
+		if appError != nil {
+			return appError
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "claimDueScheduleRuns: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5409, errMsg, "", nil)
+	}
+
+	return nil
+}
+
+// scheduleRunRetryDelay doubles the base delay with every failed send, up to
+// scheduleRunMaxRetryDelay.
+func scheduleRunRetryDelay(attemptCount int) time.Duration {
+
+	delay := time.Duration(config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS) * time.Second
+	for i := 1; i < attemptCount && delay < scheduleRunMaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, scheduleRunMaxRetryDelay)
+}
+
+// scheduleRunSendFailed records a failed send of a run. The run stays pending and is
+// sent again with backoff until RECURRING_SCHEDULE_MAX_ATTEMPTS sends failed, then it
+// is marked failed.
+func scheduleRunSendFailed(run models.RecurringScheduleRun, errMsg string, failedAt time.Time) models.RecurringScheduleRun {
+
+	run.AttemptCount++
+	run.Message = &errMsg
+	run.NextAttemptAt = nil
+
+	if run.AttemptCount >= config.RECURRING_SCHEDULE_MAX_ATTEMPTS {
+		run.Status = "failed"
+		return run
+	}
+
+	nextAttemptAt := failedAt.Add(scheduleRunRetryDelay(run.AttemptCount))
+	run.NextAttemptAt = &nextAttemptAt
+
+	return run
+}
+
+// dispatchPendingScheduleRuns sends the pending runs that are due to the transaction
+// processing topic. A run sent again after a crash or a failed send keeps its request
+// id, so it is still applied only once.
+func dispatchPendingScheduleRuns(ctx context.Context) *models.ApplicationError {
+
+	tx, err := database.SchedDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "dispatchPendingScheduleRuns: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5410, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	runs, appError := database.SchedDb.GetPendingScheduleRunsForUpdate(ctx, tx, scheduleBatchSize)
+	if appError != nil {
+		return appError
+	}
+
+	for _, run := range runs {
+
+		exists, schedule, appError := database.SchedDb.GetScheduleById(ctx, run.ScheduleId)
+		if appError != nil {
+			return appError
+		}
+
+		if !exists || schedule.Status == "cancelled" {
+			cancelledMsg := "Schedule was cancelled before the run was sent"
+			run.Status = "skipped"
+			run.Message = &cancelledMsg
+			run.NextAttemptAt = nil
+		} else {
+
+			kafkaMsg := models.TransactionRequestKafka{
+				UserId:          schedule.UserId,
+				Amount:          utils.ConvertPaiseToRupees(schedule.Amount),
+				TransactionType: schedule.TransactionType,
+				RequestId:       run.RequestId,
+				TransactionTime: time.Now().Unix(),
+			}
+
+			appError = queueTransactionRequest(ctx, kafkaMsg)
+			if appError != nil {
+				run = scheduleRunSendFailed(run, appError.Message.ErrorMessage, time.Now())
+				errMsg := fmt.Sprintf("dispatchPendingScheduleRuns: Failed to send message to Kafka topic! RunId: %d, attempt %d of %d", run.RunId, run.AttemptCount, config.RECURRING_SCHEDULE_MAX_ATTEMPTS)
+				if run.Status == "failed" {
+					misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+				} else {
+					logger.WithContext(ctx).Warn(errMsg)
+				}
+			} else {
+				run.Status = "queued"
+				run.Message = nil
+				run.NextAttemptAt = nil
+			}
+		}
+
+		appError = database.SchedDb.UpdateScheduleRunResult(ctx, tx, run)
+		if appError != nil {
+			return appError
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "dispatchPendingScheduleRuns: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5411, errMsg, "", nil)
+	}
+
+	return nil
+}
+
+func StartRecurringScheduler() {
+
+	if config.RECURRING_SCHEDULER_INTERVAL_SECONDS <= 0 {
+		logger.Log.Info("StartRecurringScheduler: Recurring scheduler is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.RECURRING_SCHEDULER_INTERVAL_SECONDS) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+
+		appError := claimDueScheduleRuns(ctx)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartRecurringScheduler-> Failed to claim due schedule runs", appError)
+		}
+
+		appError = dispatchPendingScheduleRuns(ctx)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartRecurringScheduler-> Failed to dispatch pending schedule runs", appError)
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"testing"
+	"time"
+)
+
+func TestScheduleRunRetryDelay(t *testing.T) {
+
+	defaultBackoff := config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS
+	config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS = 60
+	defer func() { config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS = defaultBackoff }()
+
+	tests := []struct {
+		attemptCount int
+		expected     time.Duration
+	}{
+		{attemptCount: 1, expected: time.Minute},
+		{attemptCount: 2, expected: 2 * time.Minute},
+		{attemptCount: 6, expected: 32 * time.Minute},
+		{attemptCount: 7, expected: scheduleRunMaxRetryDelay},
+		{attemptCount: 40, expected: scheduleRunMaxRetryDelay},
+	}
+
+	for _, test := range tests {
+		if delay := scheduleRunRetryDelay(test.attemptCount); delay != test.expected {
+			t.Errorf("scheduleRunRetryDelay(%d) = %s, expected %s", test.attemptCount, delay, test.expected)
+		}
+	}
+}
+
+func TestScheduleRunSendFailed(t *testing.T) {
+
+	defaultMaxAttempts, defaultBackoff := config.RECURRING_SCHEDULE_MAX_ATTEMPTS, config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS
+	config.RECURRING_SCHEDULE_MAX_ATTEMPTS, config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS = 3, 60
+	defer func() {
+		config.RECURRING_SCHEDULE_MAX_ATTEMPTS, config.RECURRING_SCHEDULE_BACKOFF_BASE_SECONDS = defaultMaxAttempts, defaultBackoff
+	}()
+
+	failedAt := time.Date(2024, time.June, 1, 0, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		attemptCount       int
+		expectedStatus     string
+		expectedRetryAfter time.Duration // 0 when the run is not sent again
+	}{
+		{name: "first failed send", attemptCount: 0, expectedStatus: "pending", expectedRetryAfter: time.Minute},
+		{name: "second failed send", attemptCount: 1, expectedStatus: "pending", expectedRetryAfter: 2 * time.Minute},
+		{name: "last failed send", attemptCount: 2, expectedStatus: "failed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			run := models.RecurringScheduleRun{RunId: 17, Status: "pending", AttemptCount: test.attemptCount, NextAttemptAt: &failedAt}
+
+			run = scheduleRunSendFailed(run, "Kafka is down", failedAt)
+
+			if run.Status != test.expectedStatus || run.AttemptCount != test.attemptCount+1 {
+				t.Errorf("status = %s attemptCount = %d, expected %s and %d", run.Status, run.AttemptCount, test.expectedStatus, test.attemptCount+1)
+			}
+
+			if test.expectedRetryAfter == 0 && run.NextAttemptAt != nil {
+				t.Errorf("nextAttemptAt = %s, expected no retry", run.NextAttemptAt)
+			}
+
+			if test.expectedRetryAfter > 0 && (run.NextAttemptAt == nil || !run.NextAttemptAt.Equal(failedAt.Add(test.expectedRetryAfter))) {
+				t.Errorf("nextAttemptAt = %v, expected %s after the failed send", run.NextAttemptAt, test.expectedRetryAfter)
+			}
+
+			if run.Message == nil || *run.Message != "Kafka is down" {
+				t.Errorf("message = %v, expected the send error", run.Message)
+			}
+		})
+	}
+}
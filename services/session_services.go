@@ -0,0 +1,264 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// issueSessionTokens issues an access token and a refresh token of the session and
+// stores the refresh token with the jti of the access token.
+func issueSessionTokens(ctx context.Context, tx pgx.Tx, user models.User, session models.Session) (*models.LoginResponseBody, *models.ApplicationError) {
+
+	fullName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
+
+	token, claims, err := utils.GenerateJWTForUser(user.Role, fullName, session)
+	if err != nil {
+		errMsg := fmt.Sprintf("issueSessionTokens: Error generating token! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderAppError(ctx, 5251, errMsg, "Could not generate token", nil)
+	}
+
+	refreshToken, refreshTokenHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		errMsg := fmt.Sprintf("issueSessionTokens: Error generating refresh token! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderAppError(ctx, 5252, errMsg, "Could not generate token", nil)
+	}
+
+	refreshTokenExpiresAt := time.Now().Add(time.Duration(config.REFRESH_TOKEN_TTL_HOURS) * time.Hour)
+
+	appError := database.SessionDb.InsertRefreshToken(ctx, tx, session.SessionId, refreshTokenHash, claims.ID, claims.ExpiresAt.Time, refreshTokenExpiresAt)
+	if appError != nil {
+		return nil, appError
+	}
+
+	return &models.LoginResponseBody{
+		Token:        token,
+		ExpiresAt:    claims.ExpiresAt.Unix(),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// startSession creates a new session for a user that logged in with the given
+// authentication methods.
+func startSession(ctx context.Context, user models.User, amr []string) (*models.LoginResponseBody, *models.ApiError) {
+
+	tx, err := database.SessionDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "startSession: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5253, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	session := models.Session{
+		SessionId:       uuid.New(),
+		UserId:          user.ID,
+		Amr:             amr,
+		AuthenticatedAt: time.Now(),
+	}
+
+	appError := database.SessionDb.CreateSession(ctx, tx, session)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "startSession-> Failed to create session", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response, appError := issueSessionTokens(ctx, tx, user, session)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "startSession-> Failed to issue tokens", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "startSession: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5254, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return response, nil
+}
+
+// RefreshSession rotates a refresh token: the token is marked used and a new access
+// token and refresh token are issued. A refresh token that was already used means it
+// was copied, so the whole session is revoked.
+func RefreshSession(ctx context.Context, req models.RefreshTokenRequest) (*models.LoginResponseBody, *models.ApiError) {
+
+	tx, err := database.SessionDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "RefreshSession: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5255, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	exists, refreshToken, appError := database.SessionDb.GetRefreshTokenByHashForUpdate(ctx, tx, utils.HashRefreshToken(req.RefreshToken))
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RefreshSession-> Failed to get refresh token", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists || refreshToken.SessionRevokedAt != nil || time.Now().After(refreshToken.ExpiresAt) {
+		errMsg := "RefreshSession: Refresh token is unknown, expired or its session was revoked!"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusUnauthorized, 5256, errMsg, "Invalid refresh token! Please login again", nil)
+	}
+
+	if refreshToken.UsedAt != nil {
+
+		_, appError = database.SessionDb.RevokeSessions(ctx, tx, refreshToken.Session.UserId, &refreshToken.Session.SessionId, models.SESSION_REVOKED_TOKEN_REUSE)
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RefreshSession-> Failed to revoke session after token reuse", appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			errMsg := "RefreshSession: Failed to commit session revocation!"
+			logger.WithContext(ctx).Error(errMsg)
+			appError := utils.RenderAppError(ctx, 5257, errMsg, errMsg, nil)
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		errMsg := fmt.Sprintf("RefreshSession: Refresh token was used again, revoked session: %s of user: %d!", refreshToken.Session.SessionId, refreshToken.Session.UserId)
+		logger.WithContext(ctx).Error(errMsg)
+		apiError := utils.RenderApiError(ctx, http.StatusUnauthorized, 5258, errMsg, "Invalid refresh token! Please login again", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, apiError)
+		return nil, apiError
+	}
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, refreshToken.Session.UserId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RefreshSession-> Failed to get user details", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("RefreshSession: User of the session does not exist! UserId: %d", refreshToken.Session.UserId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusUnauthorized, 5259, errMsg, "Invalid refresh token! Please login again", nil)
+	}
+
+	appError = database.SessionDb.MarkRefreshTokenUsed(ctx, tx, refreshToken.Id)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RefreshSession-> Failed to mark refresh token used", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	// The new tokens keep the authentication methods and time of the login
+	response, appError := issueSessionTokens(ctx, tx, user, refreshToken.Session)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RefreshSession-> Failed to issue tokens", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "RefreshSession: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5260, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return response, nil
+}
+
+// RevokeSessions revokes one session of the user, or every session when sessionId is
+// nil. Its refresh tokens stop working and its access tokens are denied until they
+// expire.
+func RevokeSessions(ctx context.Context, userId int, sessionId *uuid.UUID, reason string) (*models.RevokeSessionsResponse, *models.ApiError) {
+
+	tx, err := database.SessionDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "RevokeSessions: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5261, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	revoked, appError := database.SessionDb.RevokeSessions(ctx, tx, userId, sessionId, reason)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RevokeSessions-> Failed to revoke sessions", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "RevokeSessions: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5262, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return &models.RevokeSessionsResponse{SessionsRevoked: revoked}, nil
+}
+
+// StartAuthTokenCleanupJob deletes expired refresh tokens, denylist entries, login
+// challenges, email verification and password reset tokens and old failed logins.
+func StartAuthTokenCleanupJob() {
+
+	if config.AUTH_TOKEN_CLEANUP_INTERVAL_MINUTES <= 0 {
+		logger.Log.Info("StartAuthTokenCleanupJob: Auth token cleanup job is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.AUTH_TOKEN_CLEANUP_INTERVAL_MINUTES) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+
+		deleted, appError := database.SessionDb.DeleteExpiredTokens(ctx, time.Now())
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartAuthTokenCleanupJob-> Failed to delete expired tokens", appError)
+		} else {
+			logger.WithContext(ctx).Info("StartAuthTokenCleanupJob: Completed", zap.Int64("deleted", deleted))
+		}
+
+		deleted, appError = database.TwoFactorDb.DeleteExpiredLoginChallenges(ctx, time.Now())
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartAuthTokenCleanupJob-> Failed to delete expired login challenges", appError)
+		} else {
+			logger.WithContext(ctx).Info("StartAuthTokenCleanupJob: Deleted expired login challenges", zap.Int64("deleted", deleted))
+		}
+
+		deleted, appError = database.UserTokenDb.DeleteExpiredUserTokens(ctx, time.Now())
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartAuthTokenCleanupJob-> Failed to delete expired user tokens", appError)
+		} else {
+			logger.WithContext(ctx).Info("StartAuthTokenCleanupJob: Deleted expired user tokens", zap.Int64("deleted", deleted))
+		}
+
+		deleted, appError = database.LoginProtectionDb.DeleteStaleLoginFailures(ctx, time.Now().Add(-loginFailureWindow()))
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartAuthTokenCleanupJob-> Failed to delete old failed logins", appError)
+		} else {
+			logger.WithContext(ctx).Info("StartAuthTokenCleanupJob: Deleted old failed logins", zap.Int64("deleted", deleted))
+		}
+
+		<-ticker.C
+	}
+}
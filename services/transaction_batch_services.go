@@ -0,0 +1,658 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	transactionBatchListLimit    = 50
+	transactionBatchMaxReference = 140
+)
+
+// transactionBatchLine is a parsed row with the line it was read from.
+type transactionBatchLine struct {
+	lineNumber int
+	row        models.TransactionBatchRowInput
+}
+
+// transactionBatchFormat returns the format of an uploaded file, an explicit format
+// wins over the file extension.
+func transactionBatchFormat(fileName string, format string) string {
+
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return "csv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	}
+
+	return ""
+}
+
+// validateTransactionBatchRow returns everything wrong with a row that can be
+// checked without the database.
+func validateTransactionBatchRow(row models.TransactionBatchRowInput) []string {
+
+	problems := []string{}
+
+	if row.UserId <= 0 {
+		problems = append(problems, "userId must be a positive integer")
+	}
+
+	if row.Amount <= 0 {
+		problems = append(problems, "amount must be greater than 0")
+	} else if !isWholePaiseAmount(row.Amount) {
+		problems = append(problems, "amount must be at least 0.01")
+	} else if math.Abs(row.Amount*100-math.Round(row.Amount*100)) > 1e-6 {
+		problems = append(problems, "amount can not have more than 2 decimal places")
+	}
+
+	if row.TransactionType != "deposit" && row.TransactionType != "withdraw" {
+		problems = append(problems, "transactionType must be deposit or withdraw")
+	}
+
+	if len(row.Reference) > transactionBatchMaxReference {
+		problems = append(problems, fmt.Sprintf("reference can not be longer than %d characters", transactionBatchMaxReference))
+	}
+
+	return problems
+}
+
+// parseTransactionBatchCsv reads a CSV file with a header line naming the userId,
+// amount, transactionType and optional reference columns in any order.
+func parseTransactionBatchCsv(file io.Reader) ([]transactionBatchLine, []models.TransactionBatchRowError) {
+
+	lines := []transactionBatchLine{}
+	rowErrors := []models.TransactionBatchRowError{}
+
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return lines, rowErrors
+		}
+		return nil, append(rowErrors, models.TransactionBatchRowError{LineNumber: 1, Error: fmt.Sprintf("Could not read header: %s", err.Error())})
+	}
+
+	columns := map[string]int{}
+	for index, name := range header {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")
+		columns[strings.ToLower(name)] = index
+	}
+
+	missingColumns := []string{}
+	for _, name := range []string{"userId", "amount", "transactionType"} {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			missingColumns = append(missingColumns, name)
+		}
+	}
+
+	if len(missingColumns) > 0 {
+		return nil, append(rowErrors, models.TransactionBatchRowError{LineNumber: 1, Error: fmt.Sprintf("Header is missing the columns: %s", strings.Join(missingColumns, ", "))})
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				rowErrors = append(rowErrors, models.TransactionBatchRowError{LineNumber: parseError.Line, Error: parseError.Err.Error()})
+				continue
+			}
+			return nil, append(rowErrors, models.TransactionBatchRowError{Error: fmt.Sprintf("Could not read file: %s", err.Error())})
+		}
+
+		lineNumber, _ := csvReader.FieldPos(0)
+
+		field := func(name string) string {
+			index, ok := columns[strings.ToLower(name)]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		problems := []string{}
+		row := models.TransactionBatchRowInput{
+			TransactionType: field("transactionType"),
+			Reference:       field("reference"),
+		}
+
+		row.UserId, err = strconv.Atoi(field("userId"))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("userId is not a valid integer: %q", field("userId")))
+		}
+
+		row.Amount, err = strconv.ParseFloat(field("amount"), 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("amount is not a valid number: %q", field("amount")))
+		}
+
+		if len(problems) == 0 {
+			problems = validateTransactionBatchRow(row)
+		}
+
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, models.TransactionBatchRowError{LineNumber: lineNumber, Error: strings.Join(problems, "; ")})
+			continue
+		}
+
+		lines = append(lines, transactionBatchLine{lineNumber: lineNumber, row: row})
+	}
+
+	return lines, rowErrors
+}
+
+// parseTransactionBatchJsonl reads one JSON object per line, blank lines are skipped.
+func parseTransactionBatchJsonl(file io.Reader) ([]transactionBatchLine, []models.TransactionBatchRowError) {
+
+	lines := []transactionBatchLine{}
+	rowErrors := []models.TransactionBatchRowError{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var row models.TransactionBatchRowInput
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&row); err != nil {
+			rowErrors = append(rowErrors, models.TransactionBatchRowError{LineNumber: lineNumber, Error: fmt.Sprintf("Invalid JSON: %s", err.Error())})
+			continue
+		}
+
+		problems := validateTransactionBatchRow(row)
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, models.TransactionBatchRowError{LineNumber: lineNumber, Error: strings.Join(problems, "; ")})
+			continue
+		}
+
+		lines = append(lines, transactionBatchLine{lineNumber: lineNumber, row: row})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, append(rowErrors, models.TransactionBatchRowError{LineNumber: lineNumber + 1, Error: fmt.Sprintf("Could not read file: %s", err.Error())})
+	}
+
+	return lines, rowErrors
+}
+
+// CreateTransactionBatch validates every row of an uploaded file and stores the
+// batch for the dispatcher. Nothing is stored when any row is invalid, the error
+// lists every invalid line in additionalInfo.
+func CreateTransactionBatch(ctx context.Context, adminUserId int, fileName string, format string, file io.Reader) (*models.TransactionBatchResponse, *models.ApiError) {
+
+	format = transactionBatchFormat(fileName, format)
+
+	var lines []transactionBatchLine
+	var rowErrors []models.TransactionBatchRowError
+
+	switch format {
+	case "csv":
+		lines, rowErrors = parseTransactionBatchCsv(file)
+	case "jsonl":
+		lines, rowErrors = parseTransactionBatchJsonl(file)
+	default:
+		errMsg := fmt.Sprintf("CreateTransactionBatch: Unsupported file format! FileName: %s, Format: %s", fileName, format)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5051, errMsg, "File must be a .csv or .jsonl file, or set format to csv or jsonl", nil)
+	}
+
+	if len(lines)+len(rowErrors) == 0 {
+		errMsg := fmt.Sprintf("CreateTransactionBatch: File has no rows! FileName: %s", fileName)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5052, errMsg, "File has no rows", nil)
+	}
+
+	if len(lines)+len(rowErrors) > config.TRANSACTION_BATCH_MAX_ROWS {
+		errMsg := fmt.Sprintf("CreateTransactionBatch: File has more than %d rows! FileName: %s", config.TRANSACTION_BATCH_MAX_ROWS, fileName)
+		logger.WithContext(ctx).Error(errMsg)
+		displayMsg := fmt.Sprintf("A batch can have at most %d rows", config.TRANSACTION_BATCH_MAX_ROWS)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5053, errMsg, displayMsg, nil)
+	}
+
+	tx, err := database.BatchDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "CreateTransactionBatch: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5054, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// Balances and limits are only known when a row is processed, the account itself
+	// is checked here so a file for a wrong or closed account is rejected as a whole.
+	accounts := map[int]*models.Account{}
+	for _, line := range lines {
+
+		account, checked := accounts[line.row.UserId]
+		if !checked {
+			exists, userAccount, appError := database.AccDb.GetAccountByUserId(ctx, tx, line.row.UserId)
+			if appError != nil {
+				misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateTransactionBatch-> Failed to get account", appError)
+				return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+			}
+
+			if exists {
+				account = &userAccount
+			}
+			accounts[line.row.UserId] = account
+		}
+
+		if account == nil {
+			rowErrors = append(rowErrors, models.TransactionBatchRowError{LineNumber: line.lineNumber, Error: "Account does not exists for this user"})
+			continue
+		}
+
+		statusBlock := accountStatusBlocksTransaction(account.Status, line.row.TransactionType)
+		if statusBlock != "" {
+			rowErrors = append(rowErrors, models.TransactionBatchRowError{LineNumber: line.lineNumber, Error: statusBlock})
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		slices.SortFunc(rowErrors, func(a, b models.TransactionBatchRowError) int { return a.LineNumber - b.LineNumber })
+		errMsg := fmt.Sprintf("CreateTransactionBatch: File has %d invalid rows! FileName: %s", len(rowErrors), fileName)
+		logger.WithContext(ctx).Error(errMsg)
+		displayMsg := fmt.Sprintf("%d rows of the file are invalid, nothing was queued", len(rowErrors))
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5055, errMsg, displayMsg, rowErrors)
+	}
+
+	batch := models.TransactionBatch{
+		BatchId:   uuid.New(),
+		FileName:  fileName,
+		Format:    format,
+		TotalRows: len(lines),
+		Status:    "processing",
+		CreatedBy: &adminUserId,
+		CreatedAt: time.Now(),
+	}
+
+	appError := database.BatchDb.CreateBatch(ctx, tx, batch)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateTransactionBatch-> Failed to create transaction batch", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	for _, line := range lines {
+
+		row := models.TransactionBatchRow{
+			BatchId:         batch.BatchId,
+			LineNumber:      line.lineNumber,
+			RequestId:       uuid.New(),
+			UserId:          line.row.UserId,
+			TransactionType: line.row.TransactionType,
+			Amount:          utils.ConvertRupeesToPaise(line.row.Amount),
+			Status:          "pending",
+		}
+
+		if line.row.Reference != "" {
+			reference := line.row.Reference
+			row.Reference = &reference
+		}
+
+		appError = database.BatchDb.InsertBatchRow(ctx, tx, row)
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateTransactionBatch-> Failed to save transaction batch row", appError)
+			return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "CreateTransactionBatch: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5056, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := toTransactionBatchResponse(batch, models.TransactionBatchProgress{Total: batch.TotalRows, Pending: batch.TotalRows})
+
+	return &response, nil
+}
+
+func toTransactionBatchResponse(batch models.TransactionBatch, progress models.TransactionBatchProgress) models.TransactionBatchResponse {
+
+	status := batch.Status
+	if status == "processing" && progress.Pending+progress.Queued == 0 {
+		status = "completed"
+	}
+
+	return models.TransactionBatchResponse{
+		BatchId:     batch.BatchId,
+		FileName:    batch.FileName,
+		Format:      batch.Format,
+		Status:      status,
+		CreatedBy:   batch.CreatedBy,
+		CreatedAt:   batch.CreatedAt,
+		CancelledAt: batch.CancelledAt,
+		Progress:    progress,
+	}
+}
+
+// getTransactionBatchRowResults returns the outcome of every row, rows that were sent
+// take their outcome from the transaction log once they are processed.
+func getTransactionBatchRowResults(ctx context.Context, batchId uuid.UUID) ([]models.TransactionBatchRowResponse, *models.ApplicationError) {
+
+	rows, appError := database.BatchDb.GetBatchRows(ctx, batchId)
+	if appError != nil {
+		return nil, appError
+	}
+
+	requestIds := []uuid.UUID{}
+	for _, row := range rows {
+		if row.Status == "queued" {
+			requestIds = append(requestIds, row.RequestId)
+		}
+	}
+
+	transactionLogs, appError := getTransactionLogsByRequestIds(ctx, requestIds)
+	if appError != nil {
+		return nil, appError
+	}
+
+	results := []models.TransactionBatchRowResponse{}
+	for _, row := range rows {
+
+		result := models.TransactionBatchRowResponse{
+			LineNumber:      row.LineNumber,
+			RequestId:       row.RequestId,
+			UserId:          row.UserId,
+			TransactionType: row.TransactionType,
+			Amount:          utils.ConvertPaiseToRupees(row.Amount),
+			Reference:       row.Reference,
+			Status:          row.Status,
+			Message:         row.Message,
+		}
+
+		if transactionLog, ok := transactionLogs[row.RequestId]; ok {
+			result.Status = "failed"
+			if transactionLog.TransactionStatus == "success" {
+				result.Status = "succeeded"
+			}
+			result.Message = &transactionLog.TransactionMsg
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func transactionBatchProgress(results []models.TransactionBatchRowResponse) models.TransactionBatchProgress {
+
+	progress := models.TransactionBatchProgress{Total: len(results)}
+
+	for _, result := range results {
+		switch result.Status {
+		case "pending":
+			progress.Pending++
+		case "queued":
+			progress.Queued++
+		case "succeeded":
+			progress.Succeeded++
+		case "failed":
+			progress.Failed++
+		case "cancelled":
+			progress.Cancelled++
+		}
+	}
+
+	return progress
+}
+
+func GetTransactionBatches(ctx context.Context) ([]models.TransactionBatch, *models.ApiError) {
+
+	batches, appError := database.BatchDb.GetBatches(ctx, transactionBatchListLimit)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetTransactionBatches-> Failed to get transaction batches", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if batches == nil {
+		batches = []models.TransactionBatch{}
+	}
+
+	return batches, nil
+}
+
+func getTransactionBatch(ctx context.Context, batchId uuid.UUID) (*models.TransactionBatch, *models.ApiError) {
+
+	exists, batch, appError := database.BatchDb.GetBatchById(ctx, batchId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "getTransactionBatch-> Failed to get transaction batch", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Transaction batch does not exists BatchId: %s!", batchId.String())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5057, errMsg, "", nil)
+	}
+
+	return &batch, nil
+}
+
+// GetTransactionBatch returns the batch with the progress of its rows.
+func GetTransactionBatch(ctx context.Context, batchId uuid.UUID) (*models.TransactionBatchResponse, *models.ApiError) {
+
+	batch, apiError := getTransactionBatch(ctx, batchId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	results, appError := getTransactionBatchRowResults(ctx, batchId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetTransactionBatch-> Failed to get transaction batch rows", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	response := toTransactionBatchResponse(*batch, transactionBatchProgress(results))
+
+	return &response, nil
+}
+
+// GetTransactionBatchReport returns the outcome of every row of a batch, only the
+// rows with the given outcome when status is set.
+func GetTransactionBatchReport(ctx context.Context, batchId uuid.UUID, status string) ([]models.TransactionBatchRowResponse, *models.ApiError) {
+
+	_, apiError := getTransactionBatch(ctx, batchId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	results, appError := getTransactionBatchRowResults(ctx, batchId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetTransactionBatchReport-> Failed to get transaction batch rows", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if status == "" {
+		return results, nil
+	}
+
+	filtered := []models.TransactionBatchRowResponse{}
+	for _, result := range results {
+		if result.Status == status {
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered, nil
+}
+
+// CancelTransactionBatch stops the rows of a batch that were not processed yet. Rows
+// not sent yet are cancelled here, rows already sent are rejected by
+// ProcessTransaction when they arrive.
+func CancelTransactionBatch(ctx context.Context, batchId uuid.UUID) (*models.TransactionBatchResponse, *models.ApiError) {
+
+	_, apiError := getTransactionBatch(ctx, batchId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	tx, err := database.BatchDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "CancelTransactionBatch: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5058, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	cancelled, appError := database.BatchDb.CancelBatch(ctx, tx, batchId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CancelTransactionBatch-> Failed to cancel transaction batch", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !cancelled {
+		errMsg := fmt.Sprintf("CancelTransactionBatch: Transaction batch is already cancelled! BatchId: %s", batchId.String())
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5059, errMsg, "Transaction batch is already cancelled", nil)
+	}
+
+	rowsCancelled, appError := database.BatchDb.CancelPendingBatchRows(ctx, tx, batchId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CancelTransactionBatch-> Failed to cancel pending batch rows", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "CancelTransactionBatch: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5060, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	logger.WithContext(ctx).Info("CancelTransactionBatch: Cancelled", zap.String("batchId", batchId.String()), zap.Int64("rowsCancelled", rowsCancelled))
+
+	return GetTransactionBatch(ctx, batchId)
+}
+
+// dispatchPendingBatchRows sends the next unsent rows to the transaction processing
+// topic, at most TRANSACTION_BATCH_DISPATCH_SIZE per run so a large batch does not
+// hold up the other transactions and can still be cancelled part way.
+func dispatchPendingBatchRows(ctx context.Context) *models.ApplicationError {
+
+	tx, err := database.BatchDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "dispatchPendingBatchRows: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5061, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, appError := database.BatchDb.GetPendingBatchRowsForUpdate(ctx, tx, config.TRANSACTION_BATCH_DISPATCH_SIZE)
+	if appError != nil {
+		return appError
+	}
+
+	for _, row := range rows {
+
+		batchId := row.BatchId
+		kafkaMsg := models.TransactionRequestKafka{
+			UserId:          row.UserId,
+			Amount:          utils.ConvertPaiseToRupees(row.Amount),
+			TransactionType: row.TransactionType,
+			RequestId:       row.RequestId,
+			TransactionTime: time.Now().Unix(),
+			BatchId:         &batchId,
+		}
+
+		status := "queued"
+		var message *string
+
+		appError = queueTransactionRequest(ctx, kafkaMsg)
+		if appError != nil {
+			errMsg := fmt.Sprintf("dispatchPendingBatchRows: Failed to send message to Kafka topic! BatchId: %s, LineNumber: %d", row.BatchId.String(), row.LineNumber)
+			misc.ProcessError(ctx, models.KAFKA_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			status = "failed"
+			message = &appError.Message.ErrorMessage
+		}
+
+		appError = database.BatchDb.UpdateBatchRowStatus(ctx, tx, row.RequestId, status, message)
+		if appError != nil {
+			return appError
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "dispatchPendingBatchRows: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5062, errMsg, "", nil)
+	}
+
+	return nil
+}
+
+func StartTransactionBatchDispatcher() {
+
+	if config.TRANSACTION_BATCH_DISPATCH_INTERVAL_SECONDS <= 0 {
+		logger.Log.Info("StartTransactionBatchDispatcher: Transaction batch dispatcher is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.TRANSACTION_BATCH_DISPATCH_INTERVAL_SECONDS) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+
+		appError := dispatchPendingBatchRows(ctx)
+		if appError != nil {
+			misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "StartTransactionBatchDispatcher-> Failed to dispatch pending batch rows", appError)
+		}
+
+		<-ticker.C
+	}
+}
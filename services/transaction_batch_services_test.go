@@ -0,0 +1,31 @@
+package services
+
+import (
+	"banking_ledger/models"
+	"slices"
+	"testing"
+)
+
+func TestValidateTransactionBatchRow(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		row      models.TransactionBatchRowInput
+		expected []string
+	}{
+		{name: "valid", row: models.TransactionBatchRowInput{UserId: 7, Amount: 0.01, TransactionType: "deposit"}, expected: []string{}},
+		{name: "zero amount", row: models.TransactionBatchRowInput{UserId: 7, Amount: 0, TransactionType: "deposit"}, expected: []string{"amount must be greater than 0"}},
+		{name: "rounds to 0 paise", row: models.TransactionBatchRowInput{UserId: 7, Amount: 0.004, TransactionType: "deposit"}, expected: []string{"amount must be at least 0.01"}},
+		{name: "below the decimal tolerance", row: models.TransactionBatchRowInput{UserId: 7, Amount: 1e-10, TransactionType: "withdraw"}, expected: []string{"amount must be at least 0.01"}},
+		{name: "more than 2 decimal places", row: models.TransactionBatchRowInput{UserId: 7, Amount: 1.005, TransactionType: "deposit"}, expected: []string{"amount can not have more than 2 decimal places"}},
+		{name: "every field wrong", row: models.TransactionBatchRowInput{UserId: 0, Amount: -1, TransactionType: "fee"}, expected: []string{"userId must be a positive integer", "amount must be greater than 0", "transactionType must be deposit or withdraw"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if problems := validateTransactionBatchRow(test.row); !slices.Equal(problems, test.expected) {
+				t.Errorf("validateTransactionBatchRow = %q, expected %q", problems, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"banking_ledger/clients"
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Reasons a transaction request message is dropped before it is processed.
+const (
+	droppedInvalidSchemaVersion     = "INVALID_SCHEMA_VERSION"
+	droppedUnsupportedSchemaVersion = "UNSUPPORTED_SCHEMA_VERSION"
+	droppedJsonUnmarshalFail        = "JSON_UNMARSHAL_FAIL"
+	droppedUpcastFail               = "UPCAST_FAIL"
+	droppedSchemaValidationFail     = "SCHEMA_VALIDATION_FAIL"
+)
+
+// transactionRequestUpcasters moves a message from the version it is keyed by to the
+// next version. A new version adds its upcaster here and bumps
+// models.TRANSACTION_REQUEST_SCHEMA_VERSION.
+var transactionRequestUpcasters = map[int]func(message map[string]json.RawMessage) error{
+	1: upcastTransactionRequestV1,
+}
+
+// upcastTransactionRequestV1 replaces the amount in rupees of version 1 with amountPaise.
+func upcastTransactionRequestV1(message map[string]json.RawMessage) error {
+
+	amountRaw, ok := message["amount"]
+	if !ok {
+		return errors.New("amount is missing")
+	}
+
+	var amount float64
+	if err := json.Unmarshal(amountRaw, &amount); err != nil {
+		return fmt.Errorf("amount is not a number: %s", err.Error())
+	}
+
+	amountPaise, err := json.Marshal(utils.ConvertRupeesToPaise(amount))
+	if err != nil {
+		return err
+	}
+
+	delete(message, "amount")
+	message["amountPaise"] = amountPaise
+
+	return nil
+}
+
+// transactionRequestSchemaVersion reads the schema version header of a message.
+func transactionRequestSchemaVersion(headers []kafka.Header) (int, error) {
+
+	for _, header := range headers {
+		if header.Key == models.KAFKA_HEADER_SCHEMA_VERSION {
+			version, err := strconv.Atoi(string(header.Value))
+			if err != nil || version < 1 {
+				return 0, fmt.Errorf("%s header is not a valid version: %q", models.KAFKA_HEADER_SCHEMA_VERSION, string(header.Value))
+			}
+			return version, nil
+		}
+	}
+
+	return 1, nil
+}
+
+// decodeTransactionRequest upcasts a message to the current version and decodes it
+// strictly, unknown fields, missing fields and wrong types are all errors. The
+// returned reason is set when the message has to be dropped.
+func decodeTransactionRequest(version int, value []byte) (request models.TransactionRequestKafka, reason string, err error) {
+
+	if version > models.TRANSACTION_REQUEST_SCHEMA_VERSION {
+		return request, droppedUnsupportedSchemaVersion, fmt.Errorf("schema version %d is newer than the supported version %d", version, models.TRANSACTION_REQUEST_SCHEMA_VERSION)
+	}
+
+	if version < models.TRANSACTION_REQUEST_SCHEMA_VERSION {
+
+		var message map[string]json.RawMessage
+		if err := json.Unmarshal(value, &message); err != nil {
+			return request, droppedJsonUnmarshalFail, err
+		}
+
+		for ; version < models.TRANSACTION_REQUEST_SCHEMA_VERSION; version++ {
+			if err := transactionRequestUpcasters[version](message); err != nil {
+				return request, droppedUpcastFail, fmt.Errorf("upcast from version %d failed: %s", version, err.Error())
+			}
+		}
+
+		value, err = json.Marshal(message)
+		if err != nil {
+			return request, droppedUpcastFail, err
+		}
+	}
+
+	var message models.TransactionRequestMessageV2
+
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&message); err != nil {
+		// A truncated message ends the decoder with EOF rather than a syntax error
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return request, droppedJsonUnmarshalFail, err
+		}
+		return request, droppedSchemaValidationFail, err
+	}
+
+	if err := binding.Validator.ValidateStruct(&message); err != nil {
+		return request, droppedSchemaValidationFail, err
+	}
+
+	request = models.TransactionRequestKafka{
+		UserId:          message.UserId,
+		Amount:          utils.ConvertPaiseToRupees(message.AmountPaise),
+		TransactionType: message.TransactionType,
+		RequestId:       message.RequestId,
+		TransactionTime: message.TransactionTime,
+		FeeType:         message.FeeType,
+		LinkedRequestId: message.LinkedRequestId,
+		BatchId:         message.BatchId,
+	}
+
+	return request, "", nil
+}
+
+// queueTransactionRequest sends a transaction request to the transaction processing
+// topic in TRANSACTION_REQUEST_PRODUCE_SCHEMA_VERSION. Producing version 1 keeps
+// replicas that only read version 1 working during a rolling upgrade.
+func queueTransactionRequest(ctx context.Context, request models.TransactionRequestKafka) *models.ApplicationError {
+
+	version := models.TRANSACTION_REQUEST_SCHEMA_VERSION
+	var message interface{}
+
+	switch config.TRANSACTION_REQUEST_PRODUCE_SCHEMA_VERSION {
+	case 1:
+		version = 1
+		message = request
+	default:
+		message = models.TransactionRequestMessageV2{
+			UserId:          request.UserId,
+			AmountPaise:     utils.ConvertRupeesToPaise(request.Amount),
+			TransactionType: request.TransactionType,
+			RequestId:       request.RequestId,
+			TransactionTime: request.TransactionTime,
+			FeeType:         request.FeeType,
+			LinkedRequestId: request.LinkedRequestId,
+			BatchId:         request.BatchId,
+		}
+	}
+
+	versionHeader := kafka.Header{Key: models.KAFKA_HEADER_SCHEMA_VERSION, Value: []byte(strconv.Itoa(version))}
+
+	return clients.SendMessageToKafkaTopic(ctx, config.TRANSACTION_PROCESSING_KAFKA_TOPIC, message, strconv.Itoa(request.UserId), versionHeader)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"banking_ledger/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/uuid"
+)
+
+func TestUpcastTransactionRequestV1(t *testing.T) {
+
+	tests := []struct {
+		name                string
+		message             string
+		expectedAmountPaise string
+		expectError         bool
+	}{
+		{name: "whole rupees", message: `{"amount":250}`, expectedAmountPaise: "25000"},
+		{name: "paise", message: `{"amount":19.99}`, expectedAmountPaise: "1999"},
+		{name: "float error is rounded", message: `{"amount":0.29}`, expectedAmountPaise: "29"},
+		{name: "amount is missing", message: `{"userId":7}`, expectError: true},
+		{name: "amount is a string", message: `{"amount":"250"}`, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var message map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(test.message), &message); err != nil {
+				t.Fatal(err)
+			}
+
+			err := upcastTransactionRequestV1(message)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("upcast of %s succeeded, expected an error", test.message)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("upcast of %s failed: %s", test.message, err.Error())
+			}
+
+			if _, exists := message["amount"]; exists {
+				t.Error("amount is still set after the upcast")
+			}
+
+			if amountPaise := string(message["amountPaise"]); amountPaise != test.expectedAmountPaise {
+				t.Errorf("amountPaise = %s, expected %s", amountPaise, test.expectedAmountPaise)
+			}
+		})
+	}
+}
+
+func TestTransactionRequestSchemaVersion(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		headers         []kafka.Header
+		expectedVersion int
+		expectError     bool
+	}{
+		{name: "no header is version 1", headers: nil, expectedVersion: 1},
+		{name: "version 2", headers: []kafka.Header{{Key: models.KAFKA_HEADER_SCHEMA_VERSION, Value: []byte("2")}}, expectedVersion: 2},
+		{name: "other headers are ignored", headers: []kafka.Header{{Key: "traceparent", Value: []byte("x")}, {Key: models.KAFKA_HEADER_SCHEMA_VERSION, Value: []byte("1")}}, expectedVersion: 1},
+		{name: "not a number", headers: []kafka.Header{{Key: models.KAFKA_HEADER_SCHEMA_VERSION, Value: []byte("two")}}, expectError: true},
+		{name: "zero", headers: []kafka.Header{{Key: models.KAFKA_HEADER_SCHEMA_VERSION, Value: []byte("0")}}, expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			version, err := transactionRequestSchemaVersion(test.headers)
+			if (err != nil) != test.expectError {
+				t.Fatalf("error = %v, expected error %v", err, test.expectError)
+			}
+
+			if !test.expectError && version != test.expectedVersion {
+				t.Errorf("version = %d, expected %d", version, test.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestDecodeTransactionRequest(t *testing.T) {
+
+	requestId := uuid.MustParse("1b4e28ba-2fa1-4d3b-a3f5-ef19b5a7633b")
+
+	tests := []struct {
+		name           string
+		version        int
+		value          string
+		expectedAmount float64
+		expectedReason string
+	}{
+		{
+			name:           "version 1 is upcast",
+			version:        1,
+			value:          `{"userId":7,"amount":19.99,"transactionType":"deposit","requestId":"` + requestId.String() + `","transactionTime":1718000000}`,
+			expectedAmount: 19.99,
+		},
+		{
+			name:           "version 2",
+			version:        2,
+			value:          `{"userId":7,"amountPaise":1999,"transactionType":"withdraw","requestId":"` + requestId.String() + `","transactionTime":1718000000}`,
+			expectedAmount: 19.99,
+		},
+		{
+			name:           "newer version is dropped",
+			version:        models.TRANSACTION_REQUEST_SCHEMA_VERSION + 1,
+			value:          `{}`,
+			expectedReason: droppedUnsupportedSchemaVersion,
+		},
+		{
+			name:           "version 1 that is not json",
+			version:        1,
+			value:          `{"userId":`,
+			expectedReason: droppedJsonUnmarshalFail,
+		},
+		{
+			name:           "version 1 without an amount",
+			version:        1,
+			value:          `{"userId":7,"transactionType":"deposit","requestId":"` + requestId.String() + `","transactionTime":1718000000}`,
+			expectedReason: droppedUpcastFail,
+		},
+		{
+			name:           "version 2 with the version 1 amount",
+			version:        2,
+			value:          `{"userId":7,"amount":19.99,"transactionType":"deposit","requestId":"` + requestId.String() + `","transactionTime":1718000000}`,
+			expectedReason: droppedSchemaValidationFail,
+		},
+		{
+			name:           "version 2 with an unknown transaction type",
+			version:        2,
+			value:          `{"userId":7,"amountPaise":1999,"transactionType":"refund","requestId":"` + requestId.String() + `","transactionTime":1718000000}`,
+			expectedReason: droppedSchemaValidationFail,
+		},
+		{
+			name:           "version 2 that is not json",
+			version:        2,
+			value:          `{"userId":`,
+			expectedReason: droppedJsonUnmarshalFail,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			request, reason, err := decodeTransactionRequest(test.version, []byte(test.value))
+
+			if reason != test.expectedReason {
+				t.Fatalf("reason = %q error = %v, expected %q", reason, err, test.expectedReason)
+			}
+
+			if test.expectedReason != "" {
+				if err == nil {
+					t.Error("dropped message has no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("decode failed: %s", err.Error())
+			}
+
+			if request.Amount != test.expectedAmount || request.UserId != 7 || request.RequestId != requestId {
+				t.Errorf("request = %+v, expected userId 7 amount %v requestId %s", request, test.expectedAmount, requestId)
+			}
+		})
+	}
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const recoveryCodeCount = 10
+
+// invalidSecondFactorError is the same for a wrong, reused or unknown code.
+func invalidSecondFactorError(ctx context.Context) *models.ApiError {
+
+	errMsg := "Invalid two-factor code!"
+	return utils.RenderApiError(ctx, http.StatusUnauthorized, 5551, errMsg, errMsg, nil)
+}
+
+func recordUserSecurityEvent(ctx context.Context, userId int, eventType string, details string, ipAddress string) *models.ApplicationError {
+
+	return database.SecurityEventDb.InsertSecurityEvent(ctx, models.SecurityEvent{
+		EventType:   eventType,
+		UserId:      &userId,
+		IpAddress:   ipAddress,
+		Details:     details,
+		ActorUserId: &userId,
+	})
+}
+
+// getEnabledTotp returns the TOTP of the user, exists is false when 2FA is not enabled.
+func getEnabledTotp(ctx context.Context, userId int) (bool, models.UserTotp, *models.ApiError) {
+
+	exists, totp, appError := database.TwoFactorDb.GetUserTotp(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "getEnabledTotp-> Failed to get TOTP of user", appError)
+		return false, totp, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return exists && totp.EnabledAt != nil, totp, nil
+}
+
+// matchTotp checks a code against the secret of the user and returns its time step.
+func matchTotp(ctx context.Context, totp models.UserTotp, code string) (int64, bool, *models.ApiError) {
+
+	secret, err := utils.DecryptTotpSecret(totp.SecretEncrypted)
+	if err != nil {
+		errMsg := fmt.Sprintf("matchTotp: Could not decrypt TOTP secret of user: %d! Error: %s", totp.UserId, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5552, errMsg, "Could not verify two-factor code", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return 0, false, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	step, ok := utils.MatchTotpCode(secret, code, time.Now())
+	return step, ok, nil
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code of a user with 2FA enabled.
+// Wrong codes are counted as failed logins of the email, so guessing codes runs into
+// the same delays and lockout as guessing passwords.
+func verifySecondFactor(ctx context.Context, user models.User, totp models.UserTotp, code string, ipAddress string) *models.ApiError {
+
+	emailKey := loginEmailKey(user.Email)
+
+	apiError := checkLoginAllowed(ctx, emailKey, ipAddress)
+	if apiError != nil {
+		return apiError
+	}
+
+	verified := false
+
+	if utils.IsTotpCodeFormat(code) {
+
+		step, ok, apiError := matchTotp(ctx, totp, code)
+		if apiError != nil {
+			return apiError
+		}
+
+		if ok {
+			// A code that was already used is refused like a wrong one
+			consumed, appError := database.TwoFactorDb.ConsumeTotpStep(ctx, user.ID, step)
+			if appError != nil {
+				misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "verifySecondFactor-> Failed to save used TOTP step", appError)
+				return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+			}
+			verified = consumed
+		}
+
+	} else {
+
+		used, appError := database.TwoFactorDb.UseRecoveryCode(ctx, user.ID, utils.HashRecoveryCode(code))
+		if appError != nil {
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "verifySecondFactor-> Failed to use recovery code", appError)
+			return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+		}
+
+		if used {
+			verified = true
+			appError = recordUserSecurityEvent(ctx, user.ID, models.SECURITY_EVENT_RECOVERY_CODE_USED, "A recovery code was used", ipAddress)
+			if appError != nil {
+				misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "verifySecondFactor-> Failed to save recovery code event", appError)
+			}
+		}
+	}
+
+	if !verified {
+		logger.WithContext(ctx).Info("verifySecondFactor: Wrong two-factor code", zap.Int("userId", user.ID), zap.String("ipAddress", ipAddress))
+		recordLoginFailure(ctx, emailKey, ipAddress, &user.ID)
+		return invalidSecondFactorError(ctx)
+	}
+
+	return nil
+}
+
+// startLoginChallenge is the first step of a login of a user with 2FA enabled, the
+// tokens are only issued by CompleteLoginTwoFactor.
+func startLoginChallenge(ctx context.Context, user models.User) (*models.LoginResponseBody, *models.ApiError) {
+
+	challengeId := uuid.New()
+	expiresAt := time.Now().Add(time.Duration(config.LOGIN_CHALLENGE_TTL_SECONDS) * time.Second)
+
+	appError := database.TwoFactorDb.CreateLoginChallenge(ctx, challengeId, user.ID, expiresAt)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "startLoginChallenge-> Failed to create login challenge", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return &models.LoginResponseBody{
+		TwoFactorRequired: true,
+		ChallengeId:       &challengeId,
+		ExpiresAt:         expiresAt.Unix(),
+	}, nil
+}
+
+// CompleteLoginTwoFactor is the second step of a login. The session it starts records
+// both factors, which is what the step-up of large withdrawals asks for.
+func CompleteLoginTwoFactor(ctx context.Context, req models.LoginTwoFactorRequest, ipAddress string) (*models.LoginResponseBody, *models.ApiError) {
+
+	exists, userId, appError := database.TwoFactorDb.StartLoginChallengeAttempt(ctx, req.ChallengeId, config.LOGIN_CHALLENGE_MAX_ATTEMPTS)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CompleteLoginTwoFactor-> Failed to get login challenge", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := "Login challenge is invalid or expired! Please login again"
+		logger.WithContext(ctx).Error(errMsg, zap.String("challengeId", req.ChallengeId.String()))
+		return nil, utils.RenderApiError(ctx, http.StatusUnauthorized, 5553, errMsg, errMsg, nil)
+	}
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CompleteLoginTwoFactor-> Failed to get user details", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	enabled, totp, apiError := getEnabledTotp(ctx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	if !exists || !enabled {
+		errMsg := fmt.Sprintf("CompleteLoginTwoFactor: User of the challenge does not exist or disabled 2FA! UserId: %d", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusUnauthorized, 5554, errMsg, "Login challenge is invalid or expired! Please login again", nil)
+	}
+
+	apiError = verifySecondFactor(ctx, user, totp, req.Code, ipAddress)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	completed, appError := database.TwoFactorDb.CompleteLoginChallenge(ctx, req.ChallengeId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CompleteLoginTwoFactor-> Failed to complete login challenge", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !completed {
+		errMsg := "Login challenge is invalid or expired! Please login again"
+		logger.WithContext(ctx).Error("CompleteLoginTwoFactor: Login challenge was completed by another request", zap.String("challengeId", req.ChallengeId.String()))
+		return nil, utils.RenderApiError(ctx, http.StatusUnauthorized, 5555, errMsg, errMsg, nil)
+	}
+
+	clearLoginFailures(ctx, loginEmailKey(user.Email))
+
+	return startSession(ctx, user, []string{models.AMR_PASSWORD, models.AMR_OTP})
+}
+
+// EnrollTotp creates a new TOTP secret for the user. It only takes effect once a code
+// of it is confirmed, until then enrolling again replaces it.
+func EnrollTotp(ctx context.Context, userId int) (*models.TotpEnrollmentResponse, *models.ApiError) {
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "EnrollTotp-> Failed to get user details", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("User does not exists UserId: %d!", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5556, errMsg, "", nil)
+	}
+
+	secret, err := utils.GenerateTotpSecret()
+	if err != nil {
+		errMsg := fmt.Sprintf("EnrollTotp: Error generating TOTP secret! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5557, errMsg, "Could not enroll two-factor authentication", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	secretEncrypted, err := utils.EncryptTotpSecret(secret)
+	if err != nil {
+		errMsg := fmt.Sprintf("EnrollTotp: Error encrypting TOTP secret! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5558, errMsg, "Could not enroll two-factor authentication", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	stored, appError := database.TwoFactorDb.UpsertPendingTotp(ctx, userId, secretEncrypted)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "EnrollTotp-> Failed to save TOTP secret", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !stored {
+		errMsg := "Two-factor authentication is already enabled!"
+		logger.WithContext(ctx).Error(errMsg, zap.Int("userId", userId))
+		return nil, utils.RenderApiError(ctx, http.StatusConflict, 5559, errMsg, errMsg, nil)
+	}
+
+	return &models.TotpEnrollmentResponse{
+		Secret:     secret,
+		OtpAuthUrl: utils.TotpUrl(user.Email, secret),
+	}, nil
+}
+
+// ConfirmTotp enables 2FA with the first code of the enrolled secret and returns the
+// recovery codes, they are not shown again.
+func ConfirmTotp(ctx context.Context, userId int, req models.TwoFactorCodeRequest, ipAddress string) (*models.RecoveryCodesResponse, *models.ApiError) {
+
+	exists, totp, appError := database.TwoFactorDb.GetUserTotp(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ConfirmTotp-> Failed to get TOTP of user", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists || totp.EnabledAt != nil {
+		errMsg := "No pending two-factor enrolment! Enroll first"
+		logger.WithContext(ctx).Error(errMsg, zap.Int("userId", userId))
+		return nil, utils.RenderApiError(ctx, http.StatusConflict, 5560, errMsg, errMsg, nil)
+	}
+
+	step, ok, apiError := matchTotp(ctx, totp, req.Code)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	if !ok {
+		errMsg := "Invalid two-factor code!"
+		logger.WithContext(ctx).Info("ConfirmTotp: Wrong two-factor code", zap.Int("userId", userId))
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5561, errMsg, errMsg, nil)
+	}
+
+	tx, err := database.TwoFactorDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "ConfirmTotp: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5562, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	enabled, appError := database.TwoFactorDb.EnableTotp(ctx, tx, userId, step)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ConfirmTotp-> Failed to enable TOTP", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !enabled {
+		errMsg := "No pending two-factor enrolment! Enroll first"
+		logger.WithContext(ctx).Error("ConfirmTotp: Enrolment was confirmed or replaced by another request", zap.Int("userId", userId))
+		return nil, utils.RenderApiError(ctx, http.StatusConflict, 5560, errMsg, errMsg, nil)
+	}
+
+	response, apiError := replaceRecoveryCodes(ctx, tx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	appError = recordUserSecurityEvent(ctx, userId, models.SECURITY_EVENT_TOTP_ENABLED, "TOTP two-factor authentication enabled", ipAddress)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ConfirmTotp-> Failed to save TOTP enabled event", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "ConfirmTotp: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5563, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return response, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userId int) (*models.RecoveryCodesResponse, *models.ApiError) {
+
+	codes, hashes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		errMsg := fmt.Sprintf("replaceRecoveryCodes: Error generating recovery codes! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5564, errMsg, "Could not generate recovery codes", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError := database.TwoFactorDb.ReplaceRecoveryCodes(ctx, tx, userId, hashes)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "replaceRecoveryCodes-> Failed to save recovery codes", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// requireSecondFactor loads the user and checks a code before 2FA settings change.
+func requireSecondFactor(ctx context.Context, userId int, code string, ipAddress string) *models.ApiError {
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "requireSecondFactor-> Failed to get user details", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	enabled, totp, apiError := getEnabledTotp(ctx, userId)
+	if apiError != nil {
+		return apiError
+	}
+
+	if !exists || !enabled {
+		errMsg := "Two-factor authentication is not enabled!"
+		logger.WithContext(ctx).Error(errMsg, zap.Int("userId", userId))
+		return utils.RenderApiError(ctx, http.StatusConflict, 5565, errMsg, errMsg, nil)
+	}
+
+	return verifySecondFactor(ctx, user, totp, code, ipAddress)
+}
+
+// DisableTotp turns 2FA off with a TOTP or recovery code and deletes the recovery codes.
+func DisableTotp(ctx context.Context, userId int, req models.TwoFactorCodeRequest, ipAddress string) *models.ApiError {
+
+	apiError := requireSecondFactor(ctx, userId, req.Code, ipAddress)
+	if apiError != nil {
+		return apiError
+	}
+
+	tx, err := database.TwoFactorDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "DisableTotp: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5566, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := database.TwoFactorDb.DeleteTotp(ctx, tx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "DisableTotp-> Failed to delete TOTP", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError = recordUserSecurityEvent(ctx, userId, models.SECURITY_EVENT_TOTP_DISABLED, "TOTP two-factor authentication disabled", ipAddress)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "DisableTotp-> Failed to save TOTP disabled event", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "DisableTotp: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5567, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user, used or not.
+func RegenerateRecoveryCodes(ctx context.Context, userId int, req models.TwoFactorCodeRequest, ipAddress string) (*models.RecoveryCodesResponse, *models.ApiError) {
+
+	apiError := requireSecondFactor(ctx, userId, req.Code, ipAddress)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	tx, err := database.TwoFactorDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "RegenerateRecoveryCodes: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5568, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	response, apiError := replaceRecoveryCodes(ctx, tx, userId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	appError := recordUserSecurityEvent(ctx, userId, models.SECURITY_EVENT_RECOVERY_CODES_GENERATED, "Recovery codes regenerated", ipAddress)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RegenerateRecoveryCodes-> Failed to save recovery codes event", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "RegenerateRecoveryCodes: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5569, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return response, nil
+}
+
+// checkWithdrawalStepUp asks for a fresh second factor for a withdrawal, or a withdraw
+// schedule, at or above STEP_UP_WITHDRAWAL_THRESHOLD. A session that logged in with
+// 2FA within STEP_UP_MAX_AGE_SECONDS is fresh, otherwise the request must carry an otpCode.
+func checkWithdrawalStepUp(ctx context.Context, userId int, transactionType string, amount float64, otpCode string, secondFactorRecent bool) *models.ApiError {
+
+	if config.STEP_UP_WITHDRAWAL_THRESHOLD <= 0 || transactionType != "withdraw" ||
+		amount < float64(config.STEP_UP_WITHDRAWAL_THRESHOLD) || secondFactorRecent {
+		return nil
+	}
+
+	enabled, totp, apiError := getEnabledTotp(ctx, userId)
+	if apiError != nil {
+		return apiError
+	}
+
+	stepUpInfo := models.StepUpInfo{
+		StepUpRequired:   true,
+		ThresholdAmount:  float64(config.STEP_UP_WITHDRAWAL_THRESHOLD),
+		TwoFactorEnabled: enabled,
+	}
+
+	if !enabled {
+		errMsg := fmt.Sprintf("Withdrawals of %d or more need two-factor authentication! Enable it first", config.STEP_UP_WITHDRAWAL_THRESHOLD)
+		logger.WithContext(ctx).Error("checkWithdrawalStepUp: Large withdrawal of a user without 2FA", zap.Int("userId", userId))
+		return utils.RenderApiError(ctx, http.StatusForbidden, 5571, errMsg, errMsg, stepUpInfo)
+	}
+
+	if otpCode == "" {
+		errMsg := fmt.Sprintf("Withdrawals of %d or more need a two-factor code!", config.STEP_UP_WITHDRAWAL_THRESHOLD)
+		logger.WithContext(ctx).Info("checkWithdrawalStepUp: Large withdrawal without a two-factor code", zap.Int("userId", userId))
+		return utils.RenderApiError(ctx, http.StatusForbidden, 5572, errMsg, errMsg, stepUpInfo)
+	}
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "checkWithdrawalStepUp-> Failed to get user details", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("User does not exists UserId: %d!", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusNotFound, 5570, errMsg, "", nil)
+	}
+
+	return verifySecondFactor(ctx, user, totp, otpCode, "")
+}
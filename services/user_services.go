@@ -11,38 +11,42 @@ import (
 	"net/http"
 	"strings"
 
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// RegisterUser creates a customer and sends a link to verify the email. A taken email
+// gets the same answer and its owner is told about the attempt, so registration does
+// not reveal which emails have an account.
 func RegisterUser(ctx context.Context, req models.RegisterUserReqBody) *models.ApiError {
 	// Validate the input
 	req.Email = strings.TrimSpace(req.Email)
 	if req.Email == "" || req.Password == "" {
 		errMsg := "email and password are required"
-		logger.Log.Error(errMsg)
+		logger.WithContext(ctx).Error(errMsg)
 		return utils.RenderApiError(ctx, http.StatusBadRequest, 5101, errMsg, "", nil)
 	}
 
+	// Hash the password first, a taken email answers as slowly as a new one
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to generate password hash! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, nil)
+		return utils.RenderApiError(ctx, http.StatusInternalServerError, 5103, errMsg, "", nil)
+	}
+
 	// Check if user already exists
-	exists, _, appError := database.UserDb.GetUserByEmail(ctx, req.Email)
+	exists, existingUser, appError := database.UserDb.GetUserByEmail(ctx, req.Email)
 	if appError != nil {
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "Failed to check if user exists", appError)
 		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
 	if exists {
-		errMsg := "user already exists with this email"
-		logger.Log.Error(errMsg)
-		return utils.RenderApiError(ctx, http.StatusBadRequest, 5102, errMsg, "", nil)
-	}
-
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to generate password hash! Error: %s", err.Error())
-		logger.Log.Error(errMsg)
-		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, nil)
-		return utils.RenderApiError(ctx, http.StatusInternalServerError, 5103, errMsg, "", nil)
+		logger.WithContext(ctx).Info("RegisterUser: Registration with a taken email", zap.Int("userId", existingUser.ID))
+		notifyAlreadyRegistered(ctx, existingUser)
+		return nil
 	}
 
 	// Create user model
@@ -51,20 +55,35 @@ func RegisterUser(ctx context.Context, req models.RegisterUserReqBody) *models.A
 		PasswordHash: string(hashedPassword),
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
-		Role:         req.Role,
+		Role:         models.ROLE_CUSTOMER, // Other roles are only assigned by an admin
 	}
 
 	// Save user to the database
-	appError = database.UserDb.CreateUser(ctx, *user)
+	user.ID, appError = database.UserDb.CreateUser(ctx, *user)
 	if appError != nil {
 		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "Failed to create user", appError)
 		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
 	}
 
+	// The user can ask for a new link after logging in, registration still succeeds
+	appError = sendEmailVerification(ctx, *user)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RegisterUser-> Failed to send verification email", appError)
+	}
+
 	return nil
 }
 
-func UserLogin(ctx context.Context, req models.LoginRequestBody) (response *models.LoginResponseBody, apiError *models.ApiError) {
+// UserLogin answers an unregistered email and a wrong password the same way, and
+// refuses logins of an email or client IP with too many recent failures.
+func UserLogin(ctx context.Context, req models.LoginRequestBody, ipAddress string) (response *models.LoginResponseBody, apiError *models.ApiError) {
+
+	emailKey := loginEmailKey(req.Email)
+
+	apiError = checkLoginAllowed(ctx, emailKey, ipAddress)
+	if apiError != nil {
+		return nil, apiError
+	}
 
 	exists, user, appError := database.UserDb.GetUserByEmail(ctx, req.Email)
 	if appError != nil {
@@ -73,36 +92,42 @@ func UserLogin(ctx context.Context, req models.LoginRequestBody) (response *mode
 	}
 
 	if !exists {
-		errMsg := fmt.Sprintf("user not found for email: %s", req.Email)
-		displayMsg := "User not found! Please Register first"
-		logger.Log.Error(errMsg)
-		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5104, errMsg, displayMsg, nil)
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+		logger.WithContext(ctx).Info("UserLogin: Login with an unregistered email", zap.String("ipAddress", ipAddress))
+		recordLoginFailure(ctx, emailKey, ipAddress, nil)
+		return nil, invalidCredentialsError(ctx)
 	}
 
 	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		errMsg := fmt.Sprintf("Error comparing password! Error: %s", err.Error())
-		displayMsg := "Could not verify password"
-		logger.Log.Error(errMsg)
-		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, nil)
-		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5105, errMsg, displayMsg, nil)
+		if err != bcrypt.ErrMismatchedHashAndPassword {
+			errMsg := fmt.Sprintf("Error comparing password! Error: %s", err.Error())
+			logger.WithContext(ctx).Error(errMsg)
+			misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, nil)
+		}
+		logger.WithContext(ctx).Info("UserLogin: Login with a wrong password", zap.Int("userId", user.ID), zap.String("ipAddress", ipAddress))
+		recordLoginFailure(ctx, emailKey, ipAddress, &user.ID)
+		return nil, invalidCredentialsError(ctx)
 	}
 
-	fullName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
-
-	token, err := utils.GenerateJWTForUser(user.ID, user.Role, fullName)
-	if err != nil {
-		errMsg := fmt.Sprintf("Error generating token! Error: %s", err.Error())
-		displayMsg := "Could not generate token"
-		logger.Log.Error(errMsg)
-		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, nil)
-		return nil, utils.RenderApiError(ctx, http.StatusInternalServerError, 5106, errMsg, displayMsg, nil)
+	enabled, _, apiError := getEnabledTotp(ctx, user.ID)
+	if apiError != nil {
+		return nil, apiError
 	}
 
-	apiResponse := models.LoginResponseBody{
-		Token: token,
+	// Failed logins are only cleared once the second factor is verified as well
+	if enabled {
+		return startLoginChallenge(ctx, user)
 	}
 
-	return &apiResponse, nil
+	clearLoginFailures(ctx, emailKey)
+
+	return startSession(ctx, user, []string{models.AMR_PASSWORD})
+
+}
+
+func invalidCredentialsError(ctx context.Context) *models.ApiError {
 
+	errMsg := "Invalid email or password!"
+	return utils.RenderApiError(ctx, http.StatusUnauthorized, 5104, errMsg, errMsg, nil)
 }
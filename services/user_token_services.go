@@ -0,0 +1,386 @@
+package services
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/notifier"
+	"banking_ledger/utils"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// invalidUserTokenError is the same for a forged, expired, used or superseded token.
+func invalidUserTokenError(ctx context.Context) *models.ApiError {
+
+	errMsg := "Link is invalid or has expired!"
+	return utils.RenderApiError(ctx, http.StatusBadRequest, 5751, errMsg, errMsg, nil)
+}
+
+func userTokenLink(baseUrl string, token string) string {
+	return baseUrl + "?token=" + url.QueryEscape(token)
+}
+
+// issueUserToken stores a new token of the purpose for the user, the older unused
+// tokens of the purpose stop working. It returns the signed token.
+func issueUserToken(ctx context.Context, user models.User, purpose string, ttl time.Duration) (string, *models.ApplicationError) {
+
+	userToken := models.UserToken{
+		TokenId:   uuid.New(),
+		UserId:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	token, err := utils.SignUserToken(purpose, userToken.TokenId, userToken.ExpiresAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("issueUserToken: Could not sign %s token! Error: %s", purpose, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return "", utils.RenderAppError(ctx, 5752, errMsg, "Could not create token", nil)
+	}
+
+	tx, err := database.UserTokenDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "issueUserToken: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return "", utils.RenderAppError(ctx, 5753, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := database.UserTokenDb.SupersedeUserTokens(ctx, tx, user.ID, purpose)
+	if appError != nil {
+		return "", appError
+	}
+
+	appError = database.UserTokenDb.InsertUserToken(ctx, tx, userToken)
+	if appError != nil {
+		return "", appError
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := fmt.Sprintf("issueUserToken: Failed to commit transaction! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return "", utils.RenderAppError(ctx, 5754, errMsg, "", nil)
+	}
+
+	return token, nil
+}
+
+// userTokenRetryAfter returns how long the user waits before another token of the
+// purpose is sent, zero when one can be sent now.
+func userTokenRetryAfter(ctx context.Context, userId int, purpose string) (time.Duration, *models.ApplicationError) {
+
+	exists, createdAt, appError := database.UserTokenDb.GetLatestUserTokenCreatedAt(ctx, userId, purpose)
+	if appError != nil {
+		return 0, appError
+	}
+
+	if !exists {
+		return 0, nil
+	}
+
+	return max(time.Until(createdAt.Add(time.Duration(config.USER_TOKEN_RESEND_INTERVAL_SECONDS)*time.Second)), 0), nil
+}
+
+// consumeUserToken checks a token of the purpose and marks every unused token of the
+// purpose of its user used. The caller commits tx with its own changes, so a token
+// is only used once.
+func consumeUserToken(ctx context.Context, tx pgx.Tx, purpose string, token string) (models.User, *models.ApiError) {
+
+	tokenId, ok, err := utils.ParseUserToken(purpose, token)
+	if err != nil {
+		errMsg := fmt.Sprintf("consumeUserToken: Could not check %s token! Error: %s", purpose, err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5755, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return models.User{}, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !ok {
+		logger.WithContext(ctx).Info("consumeUserToken: Token with a bad signature or expired", zap.String("purpose", purpose))
+		return models.User{}, invalidUserTokenError(ctx)
+	}
+
+	exists, userToken, appError := database.UserTokenDb.GetUserTokenForUpdate(ctx, tx, tokenId, purpose)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "consumeUserToken-> Failed to get token", appError)
+		return models.User{}, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists || userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		logger.WithContext(ctx).Info("consumeUserToken: Token is unknown, used or expired", zap.String("purpose", purpose), zap.String("tokenId", tokenId.String()))
+		return models.User{}, invalidUserTokenError(ctx)
+	}
+
+	exists, user, appError := database.UserDb.GetUserByUserIdForUpdate(ctx, tx, userToken.UserId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "consumeUserToken-> Failed to get user", appError)
+		return models.User{}, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	// A token only proves access to the address it was sent to
+	if !exists || user.Email != userToken.Email {
+		logger.WithContext(ctx).Info("consumeUserToken: Token of another email of the user", zap.Int("userId", userToken.UserId))
+		return models.User{}, invalidUserTokenError(ctx)
+	}
+
+	appError = database.UserTokenDb.SupersedeUserTokens(ctx, tx, user.ID, purpose)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "consumeUserToken-> Failed to mark tokens used", appError)
+		return models.User{}, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return user, nil
+}
+
+func sendEmailVerification(ctx context.Context, user models.User) *models.ApplicationError {
+
+	token, appError := issueUserToken(ctx, user, models.USER_TOKEN_EMAIL_VERIFICATION, time.Duration(config.EMAIL_VERIFICATION_TOKEN_TTL_HOURS)*time.Hour)
+	if appError != nil {
+		return appError
+	}
+
+	return notifier.Send(ctx, models.Notification{
+		Kind:    models.NOTIFICATION_EMAIL_VERIFICATION,
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link to verify your email, it expires in %d hours:\n%s\n",
+			user.FirstName, config.EMAIL_VERIFICATION_TOKEN_TTL_HOURS, userTokenLink(config.EMAIL_VERIFICATION_URL, token)),
+	})
+}
+
+// notifyAlreadyRegistered tells the owner of an email that someone registered with
+// it again, registration answers the same whether the email is taken or not.
+func notifyAlreadyRegistered(ctx context.Context, user models.User) {
+
+	appError := notifier.Send(ctx, models.Notification{
+		Kind:    models.NOTIFICATION_ALREADY_REGISTERED,
+		To:      user.Email,
+		Subject: "You already have an account",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone tried to register with your email. If it was you, log in, or reset your password at:\n%s\nOtherwise you can ignore this message.\n",
+			user.FirstName, config.PASSWORD_RESET_URL),
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "notifyAlreadyRegistered-> Failed to notify user", appError)
+	}
+}
+
+// ResendEmailVerification sends a new verification link, the older links stop
+// working.
+func ResendEmailVerification(ctx context.Context, userId int) *models.ApiError {
+
+	exists, user, appError := database.UserDb.GetUserByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResendEmailVerification-> Failed to get user", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("ResendEmailVerification: User does not exist! UserId: %d", userId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusNotFound, 5756, errMsg, "User does not exist", nil)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		errMsg := "Email is already verified!"
+		logger.WithContext(ctx).Info("ResendEmailVerification: Email is already verified", zap.Int("userId", userId))
+		return utils.RenderApiError(ctx, http.StatusBadRequest, 5757, errMsg, errMsg, nil)
+	}
+
+	retryAfter, appError := userTokenRetryAfter(ctx, userId, models.USER_TOKEN_EMAIL_VERIFICATION)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResendEmailVerification-> Failed to get latest token", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if retryAfter > 0 {
+		errMsg := "A verification email was sent recently! Try again later"
+		info := models.RetryAfterInfo{RetryAfterSeconds: int(math.Ceil(retryAfter.Seconds()))}
+		return utils.RenderApiError(ctx, http.StatusTooManyRequests, 5758, errMsg, errMsg, info)
+	}
+
+	appError = sendEmailVerification(ctx, user)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResendEmailVerification-> Failed to send verification email", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return nil
+}
+
+func VerifyEmail(ctx context.Context, req models.VerifyEmailRequest, ipAddress string) *models.ApiError {
+
+	tx, err := database.UserTokenDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "VerifyEmail: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5759, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	user, apiError := consumeUserToken(ctx, tx, models.USER_TOKEN_EMAIL_VERIFICATION, req.Token)
+	if apiError != nil {
+		return apiError
+	}
+
+	appError := database.UserDb.MarkEmailVerified(ctx, tx, user.ID)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "VerifyEmail-> Failed to mark email verified", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "VerifyEmail: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5760, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError = recordUserSecurityEvent(ctx, user.ID, models.SECURITY_EVENT_EMAIL_VERIFIED, "Email verified", ipAddress)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "VerifyEmail-> Failed to record security event", appError)
+	}
+
+	return nil
+}
+
+// ForgotPassword sends a password reset link when the email is registered. It
+// answers the same for an unknown email, and sends at most one link every
+// USER_TOKEN_RESEND_INTERVAL_SECONDS.
+func ForgotPassword(ctx context.Context, req models.ForgotPasswordRequest, ipAddress string) *models.ApiError {
+
+	exists, user, appError := database.UserDb.GetUserByEmail(ctx, req.Email)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ForgotPassword-> Failed to get user", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		logger.WithContext(ctx).Info("ForgotPassword: Password reset of an unregistered email", zap.String("ipAddress", ipAddress))
+		return nil
+	}
+
+	retryAfter, appError := userTokenRetryAfter(ctx, user.ID, models.USER_TOKEN_PASSWORD_RESET)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ForgotPassword-> Failed to get latest token", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if retryAfter > 0 {
+		logger.WithContext(ctx).Info("ForgotPassword: Reset link was sent recently", zap.Int("userId", user.ID), zap.String("ipAddress", ipAddress))
+		return nil
+	}
+
+	token, appError := issueUserToken(ctx, user, models.USER_TOKEN_PASSWORD_RESET, time.Duration(config.PASSWORD_RESET_TOKEN_TTL_MINUTES)*time.Minute)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ForgotPassword-> Failed to issue reset token", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError = notifier.Send(ctx, models.Notification{
+		Kind:    models.NOTIFICATION_PASSWORD_RESET,
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link to choose a new password, it expires in %d minutes:\n%s\nIf you did not ask for it, you can ignore this message.\n",
+			user.FirstName, config.PASSWORD_RESET_TOKEN_TTL_MINUTES, userTokenLink(config.PASSWORD_RESET_URL, token)),
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ForgotPassword-> Failed to send reset link", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token. Every session of the user is
+// revoked and its failed logins are forgotten, the reset link also proves the user
+// reads the email so it is marked verified. Two-factor authentication stays on.
+func ResetPassword(ctx context.Context, req models.ResetPasswordRequest, ipAddress string) *models.ApiError {
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		errMsg := fmt.Sprintf("ResetPassword: Failed to generate password hash! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5761, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	tx, err := database.UserTokenDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "ResetPassword: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5762, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	user, apiError := consumeUserToken(ctx, tx, models.USER_TOKEN_PASSWORD_RESET, req.Token)
+	if apiError != nil {
+		return apiError
+	}
+
+	appError := database.UserDb.UpdateUserPassword(ctx, tx, user.ID, string(hashedPassword))
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResetPassword-> Failed to update password", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError = database.UserDb.MarkEmailVerified(ctx, tx, user.ID)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResetPassword-> Failed to mark email verified", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	revoked, appError := database.SessionDb.RevokeSessions(ctx, tx, user.ID, nil, models.SESSION_REVOKED_PASSWORD_RESET)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResetPassword-> Failed to revoke sessions", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "ResetPassword: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5763, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	clearLoginFailures(ctx, loginEmailKey(user.Email))
+
+	appError = recordUserSecurityEvent(ctx, user.ID, models.SECURITY_EVENT_PASSWORD_RESET, fmt.Sprintf("Password reset, %d sessions revoked", revoked), ipAddress)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResetPassword-> Failed to record security event", appError)
+	}
+
+	appError = notifier.Send(ctx, models.Notification{
+		Kind:    models.NOTIFICATION_PASSWORD_CHANGED,
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour password was reset and you were logged out everywhere. If it was not you, contact support.\n", user.FirstName),
+	})
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "ResetPassword-> Failed to notify user", appError)
+	}
+
+	return nil
+}
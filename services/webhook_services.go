@@ -0,0 +1,376 @@
+package services
+
+import (
+	"banking_ledger/clients"
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/logger"
+	"banking_ledger/misc"
+	"banking_ledger/models"
+	"banking_ledger/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	webhookDispatchBatchSize = 50
+	webhookDeliveriesLimit   = 50
+	webhookMaxRetryDelay     = 6 * time.Hour
+	webhookClaimLeaseMargin  = 30 * time.Second
+)
+
+func generateWebhookSecret() (string, error) {
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// webhookRetryDelay doubles the base delay with every failed attempt, up to
+// webhookMaxRetryDelay.
+func webhookRetryDelay(attemptCount int) time.Duration {
+
+	delay := time.Duration(config.WEBHOOK_BACKOFF_BASE_SECONDS) * time.Second
+	for i := 1; i < attemptCount && delay < webhookMaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, webhookMaxRetryDelay)
+}
+
+// deliverWebhook sends one claimed delivery and records the attempt. A failed
+// delivery is retried with exponential backoff until WEBHOOK_MAX_ATTEMPTS attempts
+// were made, then it is marked failed and only a redelivery sends it again.
+func deliverWebhook(ctx context.Context, delivery models.WebhookDelivery) *models.ApplicationError {
+
+	sendCtx, cancel := context.WithTimeout(ctx, time.Duration(config.WEBHOOK_TIMEOUT_SECONDS)*time.Second)
+	defer cancel()
+
+	attemptedAt := time.Now()
+	statusCode, err := clients.SendWebhook(sendCtx, delivery.Url, delivery.Secret, delivery.DeliveryId, delivery.EventType, delivery.Payload)
+
+	attempt := models.WebhookDeliveryAttempt{
+		DeliveryId:  delivery.DeliveryId,
+		DurationMs:  int(time.Since(attemptedAt).Milliseconds()),
+		AttemptedAt: attemptedAt,
+	}
+
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+
+	delivery.AttemptCount++
+	delivery.LastAttemptAt = &attemptedAt
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case err == nil:
+		delivery.Status = "delivered"
+		delivery.DeliveredAt = &attemptedAt
+	case delivery.AttemptCount >= config.WEBHOOK_MAX_ATTEMPTS:
+		errMsg := err.Error()
+		attempt.Error = &errMsg
+		delivery.Status = "failed"
+	default:
+		errMsg := err.Error()
+		attempt.Error = &errMsg
+		nextAttemptAt := attemptedAt.Add(webhookRetryDelay(delivery.AttemptCount))
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+
+	tx, err := database.WebhookDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "deliverWebhook: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5904, errMsg, "", nil)
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := database.WebhookDb.RecordWebhookDeliveryAttempt(ctx, tx, attempt)
+	if appError != nil {
+		return appError
+	}
+
+	appError = database.WebhookDb.UpdateWebhookDeliveryResult(ctx, tx, delivery)
+	if appError != nil {
+		return appError
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := fmt.Sprintf("deliverWebhook: Failed to commit transaction! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderAppError(ctx, 5905, errMsg, "", nil)
+	}
+
+	return nil
+}
+
+// RunWebhookDispatcher sends the deliveries that are due, in parallel.
+func RunWebhookDispatcher(ctx context.Context) *models.ApplicationError {
+
+	leaseUntil := time.Now().Add(time.Duration(config.WEBHOOK_TIMEOUT_SECONDS)*time.Second + webhookClaimLeaseMargin)
+
+	deliveries, appError := database.WebhookDb.ClaimDueWebhookDeliveries(ctx, leaseUntil, webhookDispatchBatchSize)
+	if appError != nil {
+		misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, "RunWebhookDispatcher-> Failed to claim due webhook deliveries", appError)
+		return appError
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery models.WebhookDelivery) {
+			defer wg.Done()
+			appError := deliverWebhook(ctx, delivery)
+			if appError != nil {
+				errMsg := fmt.Sprintf("RunWebhookDispatcher-> Failed to record webhook delivery: %d", delivery.DeliveryId)
+				misc.ProcessError(ctx, models.ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	logger.WithContext(ctx).Info("RunWebhookDispatcher: Completed", zap.Int("deliveries", len(deliveries)))
+
+	return nil
+}
+
+func StartWebhookDispatcher() {
+
+	if config.WEBHOOK_DISPATCH_INTERVAL_SECONDS <= 0 {
+		logger.Log.Info("StartWebhookDispatcher: Webhook dispatcher is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.WEBHOOK_DISPATCH_INTERVAL_SECONDS) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ctx := utils.CreateContextWithNewRequestId()
+		RunWebhookDispatcher(ctx)
+		<-ticker.C
+	}
+}
+
+// CreateWebhookSubscription subscribes a url to domain events of the user. The
+// signing secret is only returned here.
+func CreateWebhookSubscription(ctx context.Context, userId int, req models.CreateWebhookSubscriptionRequest) (*models.WebhookSubscription, *models.ApiError) {
+
+	err := clients.ValidateWebhookUrl(ctx, req.Url)
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateWebhookSubscription: Webhook url is not allowed! Url: %s Error: %s", req.Url, err.Error())
+		displayMsg := "Webhook url must be https and point to a public address!"
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusBadRequest, 5906, errMsg, displayMsg, nil)
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		errMsg := fmt.Sprintf("CreateWebhookSubscription: Could not generate webhook secret! Error: %s", err.Error())
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5907, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	subscription := models.WebhookSubscription{
+		UserId:     userId,
+		Url:        req.Url,
+		EventTypes: req.EventTypes,
+		Secret:     secret,
+	}
+
+	created, appError := database.WebhookDb.CreateWebhookSubscription(ctx, subscription)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "CreateWebhookSubscription-> Failed to create webhook subscription", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	created.Secret = secret
+
+	return &created, nil
+}
+
+func GetWebhookSubscriptions(ctx context.Context, userId int) ([]models.WebhookSubscription, *models.ApiError) {
+
+	subscriptions, appError := database.WebhookDb.GetWebhookSubscriptionsByUserId(ctx, userId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetWebhookSubscriptions-> Failed to get webhook subscriptions", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if subscriptions == nil {
+		subscriptions = []models.WebhookSubscription{}
+	}
+
+	return subscriptions, nil
+}
+
+// getUserWebhookSubscription returns the subscription when it belongs to the user,
+// subscriptions of other users are reported as not found.
+func getUserWebhookSubscription(ctx context.Context, userId int, subscriptionId int) (*models.WebhookSubscription, *models.ApiError) {
+
+	exists, subscription, appError := database.WebhookDb.GetWebhookSubscription(ctx, subscriptionId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "getUserWebhookSubscription-> Failed to get webhook subscription", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists || subscription.UserId != userId {
+		errMsg := fmt.Sprintf("Webhook subscription does not exists SubscriptionId: %d!", subscriptionId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, utils.RenderApiError(ctx, http.StatusNotFound, 5908, errMsg, "", nil)
+	}
+
+	return &subscription, nil
+}
+
+// DeleteWebhookSubscription deactivates the subscription and cancels its pending
+// deliveries, the delivery log is kept.
+func DeleteWebhookSubscription(ctx context.Context, userId int, subscriptionId int) *models.ApiError {
+
+	_, apiError := getUserWebhookSubscription(ctx, userId, subscriptionId)
+	if apiError != nil {
+		return apiError
+	}
+
+	tx, err := database.WebhookDb.BeginTx(ctx)
+	if err != nil {
+		errMsg := "DeleteWebhookSubscription: Could not begin transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5909, errMsg, "", nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	defer tx.Rollback(ctx)
+
+	appError := database.WebhookDb.DeactivateWebhookSubscription(ctx, tx, subscriptionId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "DeleteWebhookSubscription-> Failed to deactivate webhook subscription", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	appError = database.WebhookDb.CancelPendingWebhookDeliveries(ctx, tx, subscriptionId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "DeleteWebhookSubscription-> Failed to cancel pending webhook deliveries", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		errMsg := "DeleteWebhookSubscription: Failed to commit transaction!"
+		logger.WithContext(ctx).Error(errMsg)
+		appError := utils.RenderAppError(ctx, 5910, errMsg, errMsg, nil)
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, errMsg, appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return nil
+}
+
+func GetWebhookDeliveries(ctx context.Context, userId int, subscriptionId int) ([]models.WebhookDelivery, *models.ApiError) {
+
+	_, apiError := getUserWebhookSubscription(ctx, userId, subscriptionId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	deliveries, appError := database.WebhookDb.GetWebhookDeliveries(ctx, subscriptionId, webhookDeliveriesLimit)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetWebhookDeliveries-> Failed to get webhook deliveries", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	return deliveries, nil
+}
+
+func getUserWebhookDelivery(ctx context.Context, userId int, deliveryId int) (*models.WebhookDelivery, *models.WebhookSubscription, *models.ApiError) {
+
+	exists, delivery, appError := database.WebhookDb.GetWebhookDelivery(ctx, deliveryId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "getUserWebhookDelivery-> Failed to get webhook delivery", appError)
+		return nil, nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if !exists {
+		errMsg := fmt.Sprintf("Webhook delivery does not exists DeliveryId: %d!", deliveryId)
+		logger.WithContext(ctx).Error(errMsg)
+		return nil, nil, utils.RenderApiError(ctx, http.StatusNotFound, 5911, errMsg, "", nil)
+	}
+
+	subscription, apiError := getUserWebhookSubscription(ctx, userId, delivery.SubscriptionId)
+	if apiError != nil {
+		return nil, nil, apiError
+	}
+
+	return &delivery, subscription, nil
+}
+
+func GetWebhookDeliveryAttempts(ctx context.Context, userId int, deliveryId int) ([]models.WebhookDeliveryAttempt, *models.ApiError) {
+
+	_, _, apiError := getUserWebhookDelivery(ctx, userId, deliveryId)
+	if apiError != nil {
+		return nil, apiError
+	}
+
+	attempts, appError := database.WebhookDb.GetWebhookDeliveryAttempts(ctx, deliveryId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "GetWebhookDeliveryAttempts-> Failed to get webhook delivery attempts", appError)
+		return nil, utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	if attempts == nil {
+		attempts = []models.WebhookDeliveryAttempt{}
+	}
+
+	return attempts, nil
+}
+
+// RedeliverWebhook queues a delivered, failed or cancelled delivery again with a
+// fresh retry budget.
+func RedeliverWebhook(ctx context.Context, userId int, deliveryId int) *models.ApiError {
+
+	delivery, subscription, apiError := getUserWebhookDelivery(ctx, userId, deliveryId)
+	if apiError != nil {
+		return apiError
+	}
+
+	if !subscription.Active {
+		errMsg := fmt.Sprintf("RedeliverWebhook: Webhook subscription is deleted! SubscriptionId: %d", subscription.SubscriptionId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusBadRequest, 5912, errMsg, "Webhook subscription is deleted", nil)
+	}
+
+	if delivery.Status == "pending" {
+		errMsg := fmt.Sprintf("RedeliverWebhook: Webhook delivery is still pending! DeliveryId: %d", deliveryId)
+		logger.WithContext(ctx).Error(errMsg)
+		return utils.RenderApiError(ctx, http.StatusBadRequest, 5913, errMsg, "Webhook delivery is still pending", nil)
+	}
+
+	appError := database.WebhookDb.RequeueWebhookDelivery(ctx, deliveryId)
+	if appError != nil {
+		misc.ProcessError(ctx, models.API_ERROR_REQUIRE_INTERVENTION, "RedeliverWebhook-> Failed to requeue webhook delivery", appError)
+		return utils.RenderApiErrorFromAppError(http.StatusInternalServerError, appError)
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"banking_ledger/clients"
+	"banking_ledger/config"
+	"banking_ledger/database"
+	"banking_ledger/models"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeTx stands in for the database transaction deliverWebhook records a result in.
+type fakeTx struct {
+	pgx.Tx
+}
+
+func (f fakeTx) Commit(ctx context.Context) error   { return nil }
+func (f fakeTx) Rollback(ctx context.Context) error { return nil }
+
+// fakeWebhookDb keeps the attempts and the latest result of the deliveries sent.
+type fakeWebhookDb struct {
+	mu         sync.Mutex
+	attempts   []models.WebhookDeliveryAttempt
+	deliveries map[int]models.WebhookDelivery
+}
+
+func (f *fakeWebhookDb) BeginTx(ctx context.Context) (pgx.Tx, error) { return fakeTx{}, nil }
+
+func (f *fakeWebhookDb) CreateWebhookSubscription(ctx context.Context, subscription models.WebhookSubscription) (models.WebhookSubscription, *models.ApplicationError) {
+	return subscription, nil
+}
+
+func (f *fakeWebhookDb) GetWebhookSubscriptionsByUserId(ctx context.Context, userId int) ([]models.WebhookSubscription, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeWebhookDb) GetWebhookSubscription(ctx context.Context, subscriptionId int) (bool, models.WebhookSubscription, *models.ApplicationError) {
+	return false, models.WebhookSubscription{}, nil
+}
+
+func (f *fakeWebhookDb) DeactivateWebhookSubscription(ctx context.Context, tx pgx.Tx, subscriptionId int) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeWebhookDb) CancelPendingWebhookDeliveries(ctx context.Context, tx pgx.Tx, subscriptionId int) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeWebhookDb) EnqueueWebhookDeliveries(ctx context.Context, tx pgx.Tx, event models.DomainEvent, payload []byte) *models.ApplicationError {
+	return nil
+}
+
+func (f *fakeWebhookDb) ClaimDueWebhookDeliveries(ctx context.Context, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeWebhookDb) RecordWebhookDeliveryAttempt(ctx context.Context, tx pgx.Tx, attempt models.WebhookDeliveryAttempt) *models.ApplicationError {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts = append(f.attempts, attempt)
+	return nil
+}
+
+func (f *fakeWebhookDb) UpdateWebhookDeliveryResult(ctx context.Context, tx pgx.Tx, delivery models.WebhookDelivery) *models.ApplicationError {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliveries[delivery.DeliveryId] = delivery
+	return nil
+}
+
+func (f *fakeWebhookDb) GetWebhookDeliveries(ctx context.Context, subscriptionId int, limit int) ([]models.WebhookDelivery, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeWebhookDb) GetWebhookDelivery(ctx context.Context, deliveryId int) (bool, models.WebhookDelivery, *models.ApplicationError) {
+	return false, models.WebhookDelivery{}, nil
+}
+
+func (f *fakeWebhookDb) GetWebhookDeliveryAttempts(ctx context.Context, deliveryId int) ([]models.WebhookDeliveryAttempt, *models.ApplicationError) {
+	return nil, nil
+}
+
+func (f *fakeWebhookDb) RequeueWebhookDelivery(ctx context.Context, deliveryId int) *models.ApplicationError {
+	return nil
+}
+
+// startWebhookReceiver starts an https receiver that checks the signature of every
+// delivery and answers with the next status of statuses, and points the webhook
+// client and the webhook config at it.
+func startWebhookReceiver(t *testing.T, secret string, maxAttempts int, statuses ...int) (*httptest.Server, *fakeWebhookDb, *[]string) {
+
+	var mu sync.Mutex
+	badSignatures := []string{}
+	requests := 0
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(clients.WEBHOOK_TIMESTAMP_HEADER), 10, 64)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get(clients.WEBHOOK_SIGNATURE_HEADER) != "v1="+clients.SignWebhookPayload(secret, timestamp, body) {
+			badSignatures = append(badSignatures, r.Header.Get(clients.WEBHOOK_DELIVERY_HEADER))
+		}
+
+		status := statuses[min(requests, len(statuses)-1)]
+		requests++
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	fakeDb := &fakeWebhookDb{deliveries: map[int]models.WebhookDelivery{}}
+
+	defaultClient, defaultDb := clients.WebhookHttpClient, database.WebhookDb
+	defaultTimeout, defaultMaxAttempts, defaultBackoff := config.WEBHOOK_TIMEOUT_SECONDS, config.WEBHOOK_MAX_ATTEMPTS, config.WEBHOOK_BACKOFF_BASE_SECONDS
+
+	clients.WebhookHttpClient = server.Client()
+	database.WebhookDb = fakeDb
+	config.WEBHOOK_TIMEOUT_SECONDS = 5
+	config.WEBHOOK_MAX_ATTEMPTS = maxAttempts
+	config.WEBHOOK_BACKOFF_BASE_SECONDS = 30
+
+	t.Cleanup(func() {
+		clients.WebhookHttpClient, database.WebhookDb = defaultClient, defaultDb
+		config.WEBHOOK_TIMEOUT_SECONDS, config.WEBHOOK_MAX_ATTEMPTS, config.WEBHOOK_BACKOFF_BASE_SECONDS = defaultTimeout, defaultMaxAttempts, defaultBackoff
+	})
+
+	return server, fakeDb, &badSignatures
+}
+
+func TestDeliverWebhookRetriesUntilDelivered(t *testing.T) {
+
+	secret := "whsec_test"
+	server, fakeDb, badSignatures := startWebhookReceiver(t, secret, 5, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+
+	delivery := models.WebhookDelivery{
+		DeliveryId: 11,
+		EventType:  models.EVENT_TRANSACTION_COMPLETED,
+		Payload:    []byte(`{"eventType":"TransactionCompleted","userId":7}`),
+		Status:     "pending",
+		Url:        server.URL + "/hooks",
+		Secret:     secret,
+	}
+
+	expectedDelays := []time.Duration{30 * time.Second, 60 * time.Second}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+
+		if appError := deliverWebhook(context.Background(), delivery); appError != nil {
+			t.Fatalf("attempt %d: deliverWebhook returned %v", attempt, appError.Message.ErrorMessage)
+		}
+
+		delivery = fakeDb.deliveries[delivery.DeliveryId]
+
+		if delivery.AttemptCount != attempt {
+			t.Fatalf("attempt %d: attemptCount = %d", attempt, delivery.AttemptCount)
+		}
+
+		if attempt < 3 {
+
+			if delivery.Status != "pending" || delivery.NextAttemptAt == nil {
+				t.Fatalf("attempt %d: status = %s nextAttemptAt = %v, expected a pending retry", attempt, delivery.Status, delivery.NextAttemptAt)
+			}
+
+			if delay := delivery.NextAttemptAt.Sub(*delivery.LastAttemptAt); delay != expectedDelays[attempt-1] {
+				t.Errorf("attempt %d: retry delay = %s, expected %s", attempt, delay, expectedDelays[attempt-1])
+			}
+		}
+	}
+
+	if delivery.Status != "delivered" || delivery.DeliveredAt == nil || delivery.NextAttemptAt != nil {
+		t.Errorf("status = %s deliveredAt = %v nextAttemptAt = %v, expected delivered", delivery.Status, delivery.DeliveredAt, delivery.NextAttemptAt)
+	}
+
+	expectedStatusCodes := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}
+	if len(fakeDb.attempts) != len(expectedStatusCodes) {
+		t.Fatalf("recorded %d attempts, expected %d", len(fakeDb.attempts), len(expectedStatusCodes))
+	}
+
+	for i, attempt := range fakeDb.attempts {
+
+		if attempt.StatusCode == nil || *attempt.StatusCode != expectedStatusCodes[i] {
+			t.Errorf("attempt %d: statusCode = %v, expected %d", i+1, attempt.StatusCode, expectedStatusCodes[i])
+		}
+
+		if (attempt.Error != nil) != (expectedStatusCodes[i] != http.StatusOK) {
+			t.Errorf("attempt %d: error = %v", i+1, attempt.Error)
+		}
+	}
+
+	if len(*badSignatures) > 0 {
+		t.Errorf("receiver rejected the signature of deliveries %v", *badSignatures)
+	}
+}
+
+func TestDeliverWebhookFailsAfterMaxAttempts(t *testing.T) {
+
+	secret := "whsec_test"
+	server, fakeDb, _ := startWebhookReceiver(t, secret, 2, http.StatusServiceUnavailable)
+
+	delivery := models.WebhookDelivery{
+		DeliveryId: 12,
+		EventType:  models.EVENT_TRANSACTION_FAILED,
+		Payload:    []byte(`{"eventType":"TransactionFailed","userId":7}`),
+		Status:     "pending",
+		Url:        server.URL + "/hooks",
+		Secret:     secret,
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		if appError := deliverWebhook(context.Background(), delivery); appError != nil {
+			t.Fatalf("attempt %d: deliverWebhook returned %v", attempt, appError.Message.ErrorMessage)
+		}
+		delivery = fakeDb.deliveries[delivery.DeliveryId]
+	}
+
+	if delivery.Status != "failed" || delivery.NextAttemptAt != nil || delivery.DeliveredAt != nil {
+		t.Errorf("status = %s nextAttemptAt = %v deliveredAt = %v, expected failed without a retry", delivery.Status, delivery.NextAttemptAt, delivery.DeliveredAt)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+
+	defaultBackoff := config.WEBHOOK_BACKOFF_BASE_SECONDS
+	config.WEBHOOK_BACKOFF_BASE_SECONDS = 30
+	defer func() { config.WEBHOOK_BACKOFF_BASE_SECONDS = defaultBackoff }()
+
+	tests := []struct {
+		attemptCount int
+		expected     time.Duration
+	}{
+		{attemptCount: 1, expected: 30 * time.Second},
+		{attemptCount: 2, expected: time.Minute},
+		{attemptCount: 3, expected: 2 * time.Minute},
+		{attemptCount: 10, expected: 512 * 30 * time.Second},
+		{attemptCount: 11, expected: webhookMaxRetryDelay},
+		{attemptCount: 40, expected: webhookMaxRetryDelay},
+	}
+
+	for _, test := range tests {
+		if delay := webhookRetryDelay(test.attemptCount); delay != test.expected {
+			t.Errorf("webhookRetryDelay(%d) = %s, expected %s", test.attemptCount, delay, test.expected)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package tracing
+
+import "go.opentelemetry.io/otel/attribute"
+
+const (
+	AttributeRequestId            = attribute.Key("banking_ledger.request_id")
+	AttributeErrorCode            = attribute.Key("banking_ledger.error_code")
+	AttributeUserId               = attribute.Key("banking_ledger.user_id")
+	AttributeTransactionType      = attribute.Key("banking_ledger.transaction_type")
+	AttributeTransactionRequestId = attribute.Key("banking_ledger.transaction_request_id")
+)
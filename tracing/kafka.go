@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+)
+
+// kafkaHeaderCarrier lets the propagator read and write the trace context in the
+// headers of a Kafka message.
+type kafkaHeaderCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c kafkaHeaderCarrier) Get(key string) string {
+
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+
+	return ""
+}
+
+func (c kafkaHeaderCarrier) Set(key string, value string) {
+
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaHeaderCarrier) Keys() []string {
+
+	keys := make([]string, 0, len(*c.headers))
+	for _, header := range *c.headers {
+		keys = append(keys, header.Key)
+	}
+
+	return keys
+}
+
+// InjectKafkaHeaders adds the trace context of ctx to the headers of a message.
+func InjectKafkaHeaders(ctx context.Context, headers []kafka.Header) []kafka.Header {
+
+	otel.GetTextMapPropagator().Inject(ctx, kafkaHeaderCarrier{headers: &headers})
+
+	return headers
+}
+
+// ExtractKafkaHeaders returns ctx with the trace context the producer put in the
+// headers of a message.
+func ExtractKafkaHeaders(ctx context.Context, headers []kafka.Header) context.Context {
+
+	return otel.GetTextMapPropagator().Extract(ctx, kafkaHeaderCarrier{headers: &headers})
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "banking_ledger"
+
+// Tracer starts the spans of the service. It uses the global provider, so spans
+// started before InitTracing or with tracing disabled are no-ops.
+var Tracer = otel.Tracer(tracerName)
+
+// InitTracing sets the global tracer provider for the exporter configured in
+// TRACING_EXPORTER and returns the function that flushes it on shutdown.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.TRACING_EXPORTER {
+	case "", "none":
+		logger.Log.Info("InitTracing: Tracing is disabled")
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		// The endpoint and the headers come from the standard OTEL_EXPORTER_OTLP_* variables.
+		exporter, err = otlptracegrpc.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		var file *os.File
+		file, err = os.OpenFile(config.TRACING_FILE_PATH, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err == nil {
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	default:
+		err = fmt.Errorf("unknown exporter %q", config.TRACING_EXPORTER)
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("InitTracing: Could not create %s trace exporter! Error: %s", config.TRACING_EXPORTER, err.Error())
+		logger.Log.Error(errMsg)
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(orphanClientSpanSampler{
+			sampler: sdktrace.TraceIDRatioBased(float64(config.TRACING_SAMPLE_PERCENT) / 100),
+		})),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(os.Getenv("SERVICE_NAME")),
+			semconv.DeploymentEnvironment(os.Getenv("ENV")),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	logger.Log.Info(fmt.Sprintf("InitTracing: Exporting traces to %s", config.TRACING_EXPORTER))
+
+	return provider.Shutdown, nil
+}
+
+// orphanClientSpanSampler drops database and Kafka client spans that have no parent.
+// Background jobs poll Postgres every few seconds, tracing every poll would bury the
+// traces of the API calls and the Kafka messages.
+type orphanClientSpanSampler struct {
+	sampler sdktrace.Sampler
+}
+
+func (s orphanClientSpanSampler) ShouldSample(parameters sdktrace.SamplingParameters) sdktrace.SamplingResult {
+
+	if parameters.Kind == trace.SpanKindClient {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+	}
+
+	return s.sampler.ShouldSample(parameters)
+}
+
+func (s orphanClientSpanSampler) Description() string {
+	return "OrphanClientSpanSampler{" + s.sampler.Description() + "}"
+}
+
+// EndSpan marks the span as failed when appError is set and ends it.
+func EndSpan(span trace.Span, appError *models.ApplicationError) {
+
+	if appError != nil {
+		span.SetStatus(codes.Error, appError.Message.ErrorMessage)
+		span.SetAttributes(AttributeErrorCode.Int(appError.Message.ErrorCode))
+	}
+
+	span.End()
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const apiKeyPrefix = "bl"
+
+// GenerateApiKey returns a key like "bl_<prefix>_<secret>". The prefix finds the stored
+// key and is shown in listings, the secret has 256 random bits so a plain SHA-256 of
+// the key is enough to store it.
+func GenerateApiKey() (key string, prefix string, keyHash string, err error) {
+
+	prefixBytes := make([]byte, 8)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = apiKeyPrefix + "_" + hex.EncodeToString(prefixBytes)
+	key = prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, prefix, HashApiKey(key), nil
+}
+
+// ApiKeyPrefix returns the prefix of a key, ok is false when it is not a managed key.
+func ApiKeyPrefix(key string) (prefix string, ok bool) {
+
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || len(parts[1]) != 16 || parts[2] == "" {
+		return "", false
+	}
+
+	return parts[0] + "_" + parts[1], true
+}
+
+func HashApiKey(key string) string {
+
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApiKeyPrefix(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		key            string
+		expectedPrefix string
+		expectedOk     bool
+	}{
+		{name: "managed key", key: "bl_0123456789abcdef_c2VjcmV0", expectedPrefix: "bl_0123456789abcdef", expectedOk: true},
+		{name: "secret with underscores", key: "bl_0123456789abcdef_c2V_jcm_V0", expectedPrefix: "bl_0123456789abcdef", expectedOk: true},
+		{name: "legacy shared key", key: "your-secret-api-key"},
+		{name: "other product", key: "sk_0123456789abcdef_c2VjcmV0"},
+		{name: "short prefix", key: "bl_0123456789abcde_c2VjcmV0"},
+		{name: "long prefix", key: "bl_0123456789abcdef0_c2VjcmV0"},
+		{name: "no secret", key: "bl_0123456789abcdef_"},
+		{name: "prefix only", key: "bl_0123456789abcdef"},
+		{name: "empty", key: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prefix, ok := ApiKeyPrefix(test.key)
+			if prefix != test.expectedPrefix || ok != test.expectedOk {
+				t.Errorf("ApiKeyPrefix(%q) = %q, %v, expected %q, %v", test.key, prefix, ok, test.expectedPrefix, test.expectedOk)
+			}
+		})
+	}
+}
+
+func TestGenerateApiKey(t *testing.T) {
+
+	key, prefix, keyHash, err := GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedPrefix, ok := ApiKeyPrefix(key)
+	if !ok || parsedPrefix != prefix {
+		t.Errorf("ApiKeyPrefix of a generated key = %q, %v, expected %q", parsedPrefix, ok, prefix)
+	}
+
+	if !strings.HasPrefix(key, prefix+"_") {
+		t.Errorf("key %q does not start with its prefix %q", key, prefix)
+	}
+
+	if keyHash != HashApiKey(key) || len(keyHash) != 64 {
+		t.Errorf("hash %q is not the SHA-256 of the key", keyHash)
+	}
+
+	otherKey, otherPrefix, otherHash, err := GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if otherKey == key || otherPrefix == prefix || otherHash == keyHash {
+		t.Error("two generated keys are the same")
+	}
+}
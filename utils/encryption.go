@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"banking_ledger/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// totpCipher is AES-256-GCM with TOTP_ENCRYPTION_KEY, a base64 encoded 32 byte key.
+func totpCipher() (cipher.AEAD, error) {
+
+	if config.TOTP_ENCRYPTION_KEY == "" {
+		return nil, errors.New("TOTP_ENCRYPTION_KEY is not set")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(config.TOTP_ENCRYPTION_KEY)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("TOTP_ENCRYPTION_KEY must be a base64 encoded 32 byte key")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptTotpSecret returns the nonce and the sealed secret, base64 encoded.
+func EncryptTotpSecret(secret string) (string, error) {
+
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func DecryptTotpSecret(encrypted string) (string, error) {
+
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted TOTP secret is malformed")
+	}
+
+	secret, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt TOTP secret: %w", err)
+	}
+
+	return string(secret), nil
+}
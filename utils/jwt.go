@@ -3,39 +3,89 @@ package utils
 import (
 	"banking_ledger/config"
 	"banking_ledger/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var jwtKey = []byte(config.JWT_SECRET)
-
-func GenerateJWTForUser(userId int, role string, name string) (string, error) {
+// GenerateJWTForUser issues an access token of the session. The returned claims hold
+// the jti that revoking the session adds to the denylist.
+func GenerateJWTForUser(role string, name string, session models.Session) (string, *models.CustomClaims, error) {
 	claims := &models.CustomClaims{
-		Role: role,
-		Name: name,
+		Role:      role,
+		Name:      name,
+		SessionId: session.SessionId.String(),
+		Amr:       session.Amr,
+		AuthTime:  jwt.NewNumericDate(session.AuthenticatedAt),
 		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   strconv.Itoa(userId),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
+			ID:        uuid.NewString(),
+			Issuer:    config.JWT_ISSUER,
+			Audience:  jwt.ClaimStrings{config.JWT_AUDIENCE},
+			Subject:   strconv.Itoa(session.UserId),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.ACCESS_TOKEN_TTL_MINUTES) * time.Minute)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	if jwtKeys == nil {
+		return "", nil, errors.New("JWT keys are not loaded")
+	}
+
+	token := jwt.NewWithClaims(jwtKeys.signingKey.method, claims)
+	token.Header["kid"] = jwtKeys.signingKey.id
+
+	signedToken, err := token.SignedString(jwtKeys.signingKey.privateKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return signedToken, claims, nil
 }
 
-func ValidateJWT(tokenString string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+// GenerateRefreshToken returns a random opaque refresh token and the hash it is
+// stored under.
+func GenerateRefreshToken() (token string, tokenHash string, err error) {
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(secret)
+
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken is a plain SHA-256, the token is random so it needs no salt and
+// the hash can be looked up.
+func HashRefreshToken(token string) string {
+
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// ValidateJWT verifies the signature, expiry, issuer and audience of an access token.
+func ValidateJWT(tokenString string) (*models.CustomClaims, error) {
+
+	token, err := jwt.ParseWithClaims(tokenString, &models.CustomClaims{}, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(config.JWT_ISSUER),
+		jwt.WithAudience(config.JWT_AUDIENCE),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*models.CustomClaims); ok && token.Valid {
 		return claims, nil
 	}
 
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/logger"
+	"banking_ledger/models"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const minRSAKeyBits = 2048
+
+// jwtKey is one key of the key ring. Only the signing key has a private key.
+type jwtKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+	jwk        models.JSONWebKey
+}
+
+type jwtKeyRing struct {
+	signingKey       *jwtKey
+	verificationKeys map[string]*jwtKey
+	jwks             models.JSONWebKeySet
+}
+
+var jwtKeys *jwtKeyRing
+
+// InitJWTKeys loads the signing key from JWT_SIGNING_KEY_FILE and the keys that are
+// only verified from JWT_VERIFICATION_KEY_FILES. Tokens of every loaded key are
+// accepted, so a rotated key stays valid until its tokens expired. A signing key file
+// is required, only JWT_DEV_GENERATED_KEY allows an Ed25519 key generated at start,
+// whose tokens neither survive a restart nor are valid on another instance.
+func InitJWTKeys() error {
+
+	keyRing := &jwtKeyRing{verificationKeys: map[string]*jwtKey{}}
+
+	var signingKey *jwtKey
+	var err error
+
+	if config.JWT_SIGNING_KEY_FILE == "" {
+		if !config.JWT_DEV_GENERATED_KEY {
+			return errors.New("InitJWTKeys: JWT_SIGNING_KEY_FILE is required, set JWT_DEV_GENERATED_KEY=true to sign with a generated key in local development")
+		}
+		logger.Log.Warn("InitJWTKeys: JWT_SIGNING_KEY_FILE is not set, signing tokens with a generated key because JWT_DEV_GENERATED_KEY is set")
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("InitJWTKeys: could not generate signing key: %w", err)
+		}
+		signingKey, err = newJWTKey(privateKey.Public(), privateKey)
+		if err != nil {
+			return err
+		}
+	} else {
+		signingKey, err = loadJWTKeyFile(config.JWT_SIGNING_KEY_FILE, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	keyRing.signingKey = signingKey
+	keyRing.verificationKeys[signingKey.id] = signingKey
+	keyRing.jwks.Keys = append(keyRing.jwks.Keys, signingKey.jwk)
+
+	for _, path := range SplitCommaSeparated(config.JWT_VERIFICATION_KEY_FILES) {
+
+		key, err := loadJWTKeyFile(path, false)
+		if err != nil {
+			return err
+		}
+
+		if _, exists := keyRing.verificationKeys[key.id]; exists {
+			continue
+		}
+
+		keyRing.verificationKeys[key.id] = key
+		keyRing.jwks.Keys = append(keyRing.jwks.Keys, key.jwk)
+	}
+
+	jwtKeys = keyRing
+
+	logger.Log.Info("InitJWTKeys: Loaded JWT keys", zap.String("signingKeyId", signingKey.id), zap.String("algorithm", signingKey.method.Alg()), zap.Int("verificationKeys", len(keyRing.verificationKeys)))
+
+	return nil
+}
+
+// GetJWKS returns the public keys that tokens are verified with.
+func GetJWKS() models.JSONWebKeySet {
+
+	if jwtKeys == nil {
+		return models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	}
+
+	return jwtKeys.jwks
+}
+
+// loadJWTKeyFile reads a PEM encoded RSA or Ed25519 key. A private key is required
+// for the signing key, a verification key file may hold either.
+func loadJWTKeyFile(path string, signing bool) (*jwtKey, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadJWTKeyFile: could not read %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("loadJWTKeyFile: %s is not PEM encoded", path)
+	}
+
+	var publicKey crypto.PublicKey
+	var privateKey crypto.Signer
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("loadJWTKeyFile: could not parse private key %s: %w", path, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("loadJWTKeyFile: unsupported private key type %T in %s", parsed, path)
+		}
+		privateKey = signer
+		publicKey = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("loadJWTKeyFile: could not parse private key %s: %w", path, err)
+		}
+		privateKey = parsed
+		publicKey = parsed.Public()
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("loadJWTKeyFile: could not parse public key %s: %w", path, err)
+		}
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("loadJWTKeyFile: could not parse public key %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("loadJWTKeyFile: unsupported PEM block %s in %s", block.Type, path)
+	}
+
+	if signing && privateKey == nil {
+		return nil, fmt.Errorf("loadJWTKeyFile: signing key %s is not a private key", path)
+	}
+
+	if !signing {
+		privateKey = nil
+	}
+
+	key, err := newJWTKey(publicKey, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("loadJWTKeyFile: %s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+// newJWTKey picks the signing method of the key and derives its kid, the RFC 7638
+// thumbprint of the public key, so every instance loading the same file uses the
+// same kid.
+func newJWTKey(publicKey crypto.PublicKey, privateKey crypto.Signer) (*jwtKey, error) {
+
+	key := &jwtKey{publicKey: publicKey, privateKey: privateKey}
+
+	var thumbprintInput string
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key has %d bits, at least %d are required", publicKey.N.BitLen(), minRSAKeyBits)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.jwk = models.JSONWebKey{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}
+		thumbprintInput = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, key.jwk.E, key.jwk.N)
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = models.JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		}
+		thumbprintInput = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, key.jwk.X)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use an RSA or Ed25519 key", publicKey)
+	}
+
+	thumbprint := sha256.Sum256([]byte(thumbprintInput))
+	key.id = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	key.jwk.Kid = key.id
+	key.jwk.Use = "sig"
+	key.jwk.Alg = key.method.Alg()
+
+	return key, nil
+}
+
+// verificationKey finds the key of a token by its kid. The algorithm of the token
+// must be the one of the key, so a public key is never used as an HMAC secret.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+
+	if jwtKeys == nil {
+		return nil, errors.New("JWT keys are not loaded")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := jwtKeys.verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	return key.publicKey, nil
+}
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// writeKeyFile writes the key as a PKCS #8 private key or, when public is set, the
+// PKIX public key of it and returns the path.
+func writeKeyFile(t *testing.T, key crypto.Signer, public bool) string {
+
+	var block *pem.Block
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	path := filepath.Join(t.TempDir(), uuid.NewString()+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// useJWTKeys loads the key ring of the key files for the test.
+func useJWTKeys(t *testing.T, signingKeyFile string, verificationKeyFiles ...string) {
+
+	defaultKeys := jwtKeys
+	defaultSigning, defaultVerification, defaultDev := config.JWT_SIGNING_KEY_FILE, config.JWT_VERIFICATION_KEY_FILES, config.JWT_DEV_GENERATED_KEY
+	defaultIssuer, defaultAudience, defaultTtl := config.JWT_ISSUER, config.JWT_AUDIENCE, config.ACCESS_TOKEN_TTL_MINUTES
+	t.Cleanup(func() {
+		jwtKeys = defaultKeys
+		config.JWT_SIGNING_KEY_FILE, config.JWT_VERIFICATION_KEY_FILES, config.JWT_DEV_GENERATED_KEY = defaultSigning, defaultVerification, defaultDev
+		config.JWT_ISSUER, config.JWT_AUDIENCE, config.ACCESS_TOKEN_TTL_MINUTES = defaultIssuer, defaultAudience, defaultTtl
+	})
+
+	config.JWT_SIGNING_KEY_FILE = signingKeyFile
+	config.JWT_VERIFICATION_KEY_FILES = strings.Join(verificationKeyFiles, ",")
+	config.JWT_DEV_GENERATED_KEY = false
+	config.JWT_ISSUER, config.JWT_AUDIENCE, config.ACCESS_TOKEN_TTL_MINUTES = "banking_ledger", "banking_ledger", 10
+
+	if err := InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signTestToken signs claims like GenerateJWTForUser, with any key, method and kid.
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestValidateJWT(t *testing.T) {
+
+	_, currentKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, rotatedKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, retiredKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentJWTKey, _ := newJWTKey(currentKey.Public(), currentKey)
+	rotatedJWTKey, _ := newJWTKey(rotatedKey.Public(), rotatedKey)
+	retiredJWTKey, _ := newJWTKey(retiredKey.Public(), retiredKey)
+	rsaJWTKey, _ := newJWTKey(rsaKey.Public(), rsaKey)
+
+	// rotatedKey was the signing key before and is still verified, retiredKey was removed
+	useJWTKeys(t, writeKeyFile(t, currentKey, false), writeKeyFile(t, rotatedKey, true), writeKeyFile(t, rsaKey, true))
+
+	claims := func(modify func(claims *models.CustomClaims)) *models.CustomClaims {
+		claims := &models.CustomClaims{
+			Role:      models.ROLE_CUSTOMER,
+			SessionId: uuid.NewString(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Issuer:    config.JWT_ISSUER,
+				Audience:  jwt.ClaimStrings{config.JWT_AUDIENCE},
+				Subject:   "7",
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
+			},
+		}
+		if modify != nil {
+			modify(claims)
+		}
+		return claims
+	}
+
+	generated, _, err := GenerateJWTForUser(models.ROLE_CUSTOMER, "Ann", models.Session{SessionId: uuid.New(), UserId: 7, AuthenticatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectValid bool
+	}{
+		{name: "generated token", token: generated, expectValid: true},
+		{name: "signed with the current key", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(nil)), expectValid: true},
+		{name: "signed with a rotated key that is still verified", token: signTestToken(t, jwt.SigningMethodEdDSA, rotatedKey, rotatedJWTKey.id, claims(nil)), expectValid: true},
+		{name: "signed with an RSA verification key", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, rsaJWTKey.id, claims(nil)), expectValid: true},
+		{name: "signed with a rotated out key", token: signTestToken(t, jwt.SigningMethodEdDSA, retiredKey, retiredJWTKey.id, claims(nil))},
+		{name: "rotated out key with the kid of the current key", token: signTestToken(t, jwt.SigningMethodEdDSA, retiredKey, currentJWTKey.id, claims(nil))},
+		{name: "unknown kid", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, "unknown", claims(nil))},
+		{name: "no kid", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, "", claims(nil))},
+		{name: "wrong issuer", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(func(c *models.CustomClaims) { c.Issuer = "other_service" }))},
+		{name: "no issuer", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(func(c *models.CustomClaims) { c.Issuer = "" }))},
+		{name: "wrong audience", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(func(c *models.CustomClaims) { c.Audience = jwt.ClaimStrings{"other_service"} }))},
+		{name: "no audience", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(func(c *models.CustomClaims) { c.Audience = nil }))},
+		{name: "no exp", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(func(c *models.CustomClaims) { c.ExpiresAt = nil }))},
+		{name: "expired", token: signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(func(c *models.CustomClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }))},
+		{name: "HS256 with the public key as secret", token: signTestToken(t, jwt.SigningMethodHS256, []byte(currentKey.Public().(ed25519.PublicKey)), currentJWTKey.id, claims(nil))},
+		{name: "HS256 with the RSA public key as secret", token: signTestToken(t, jwt.SigningMethodHS256, x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), rsaJWTKey.id, claims(nil))},
+		{name: "alg none", token: signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, currentJWTKey.id, claims(nil))},
+		{name: "RS256 with the kid of the Ed25519 key", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, currentJWTKey.id, claims(nil))},
+		{name: "tampered payload", token: tamperPayload(signTestToken(t, jwt.SigningMethodEdDSA, currentKey, currentJWTKey.id, claims(nil)))},
+		{name: "not a token", token: "not.a.token"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			validated, err := ValidateJWT(test.token)
+
+			if test.expectValid && (err != nil || validated == nil) {
+				t.Fatalf("ValidateJWT rejected a valid token: %v", err)
+			}
+
+			if !test.expectValid && err == nil {
+				t.Fatalf("ValidateJWT accepted the token with claims %+v", validated)
+			}
+		})
+	}
+}
+
+// tamperPayload swaps the payload of a token for one with the admin role.
+func tamperPayload(token string) string {
+
+	parts := strings.Split(token, ".")
+	payload, _ := jwt.NewParser().DecodeSegment(parts[1])
+	tampered := strings.Replace(string(payload), `"role":"customer"`, `"role":"admin"`, 1)
+
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(tampered)) + "." + parts[2]
+}
+
+func TestInitJWTKeysRequiresSigningKey(t *testing.T) {
+
+	defaultKeys := jwtKeys
+	defaultSigning, defaultDev := config.JWT_SIGNING_KEY_FILE, config.JWT_DEV_GENERATED_KEY
+	defer func() {
+		jwtKeys = defaultKeys
+		config.JWT_SIGNING_KEY_FILE, config.JWT_DEV_GENERATED_KEY = defaultSigning, defaultDev
+	}()
+
+	_, publicOnly, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name        string
+		keyFile     string
+		devKey      bool
+		expectError bool
+	}{
+		{name: "no key file", keyFile: "", expectError: true},
+		{name: "generated key for development", keyFile: "", devKey: true},
+		{name: "missing file", keyFile: filepath.Join(t.TempDir(), "missing.pem"), expectError: true},
+		{name: "public key as signing key", keyFile: writeKeyFile(t, publicOnly, true), expectError: true},
+		{name: "private key", keyFile: writeKeyFile(t, publicOnly, false)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.JWT_SIGNING_KEY_FILE, config.JWT_DEV_GENERATED_KEY = test.keyFile, test.devKey
+			if err := InitJWTKeys(); (err != nil) != test.expectError {
+				t.Errorf("InitJWTKeys error = %v, expected error %v", err, test.expectError)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package utils
 import (
 	"banking_ledger/logger"
 	"banking_ledger/models"
+	"banking_ledger/redact"
 	"context"
-	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,16 +21,18 @@ func GetContextFromGinContext(c *gin.Context) (ctx context.Context) {
 
 }
 
+// ConvertStructToString converts a struct to JSON for logs and error messages, the
+// fields tagged with redact and the default deny list of the redact package are masked.
 func ConvertStructToString(structInput interface{}) string {
 
-	structInputByteArray, err := json.Marshal(structInput)
+	structInputString, err := redact.Marshal(structInput)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Could not convert struct input to byte array.Struct value:%v.Error message:%s!", structInput, err.Error())
+		errorMsg := fmt.Sprintf("Could not convert struct input to byte array.Struct type:%T.Error message:%s!", structInput, err.Error())
 		logger.Log.Error(errorMsg)
 		return ""
 	}
 
-	return string(structInputByteArray)
+	return structInputString
 
 }
 
@@ -41,6 +45,32 @@ func CreateContextWithNewRequestId() (ctx context.Context) {
 
 }
 
+// CreateContextWithRequestId returns a context carrying the given request id, a new
+// one is generated when it is empty.
+func CreateContextWithRequestId(requestId string) (ctx context.Context) {
+
+	if requestId == "" {
+		return CreateContextWithNewRequestId()
+	}
+
+	ctx = context.WithValue(context.Background(), models.CONTEXT_REQUEST_ID_KEY, requestId)
+
+	return ctx
+
+}
+
+func GetRequestIdFromContext(ctx context.Context) string {
+
+	if ctx == nil {
+		return ""
+	}
+
+	requestId, _ := ctx.Value(models.CONTEXT_REQUEST_ID_KEY).(string)
+
+	return requestId
+
+}
+
 func GetClaimFromContext[T any](c *gin.Context, key string) (T, error) {
 	val, exists := c.Get(key)
 	if !exists {
@@ -54,3 +84,29 @@ func GetClaimFromContext[T any](c *gin.Context, key string) (T, error) {
 
 	return castVal, nil
 }
+
+func ConvertRupeesToPaise(amount float64) int64 {
+
+	return int64(math.Round(amount * 100))
+
+}
+
+func ConvertPaiseToRupees(amount int64) float64 {
+
+	return float64(amount) / 100
+
+}
+
+// SplitCommaSeparated splits a comma separated config value and drops empty items.
+func SplitCommaSeparated(value string) []string {
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+
+}
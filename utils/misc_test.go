@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestConvertRupeesToPaise(t *testing.T) {
+
+	tests := []struct {
+		rupees   float64
+		expected int64
+	}{
+		{rupees: 0, expected: 0},
+		{rupees: 10, expected: 1000},
+		{rupees: 0.29, expected: 29},
+		{rupees: 1.005, expected: 100},
+		{rupees: 19.99, expected: 1999},
+		{rupees: 0.015, expected: 2},
+		{rupees: -2.5, expected: -250},
+	}
+
+	for _, test := range tests {
+		if paise := ConvertRupeesToPaise(test.rupees); paise != test.expected {
+			t.Errorf("ConvertRupeesToPaise(%v) = %d, expected %d", test.rupees, paise, test.expected)
+		}
+	}
+}
+
+func TestConvertPaiseToRupees(t *testing.T) {
+
+	tests := []struct {
+		paise    int64
+		expected float64
+	}{
+		{paise: 0, expected: 0},
+		{paise: 29, expected: 0.29},
+		{paise: 1999, expected: 19.99},
+		{paise: -250, expected: -2.5},
+	}
+
+	for _, test := range tests {
+		if rupees := ConvertPaiseToRupees(test.paise); rupees != test.expected {
+			t.Errorf("ConvertPaiseToRupees(%d) = %v, expected %v", test.paise, rupees, test.expected)
+		}
+	}
+}
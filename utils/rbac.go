@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"banking_ledger/models"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHasPermission tells whether the role grants the permission, unknown roles grant
+// nothing.
+func RoleHasPermission(role string, permission string) bool {
+
+	return slices.Contains(models.ROLE_PERMISSIONS[role], permission)
+
+}
+
+// HasPermission checks the role claim set by AuthTokenMiddleware.
+func HasPermission(c *gin.Context, permission string) bool {
+
+	role, err := GetClaimFromContext[string](c, "role")
+	if err != nil {
+		return false
+	}
+
+	return RoleHasPermission(role, permission)
+
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"banking_ledger/models"
+	"slices"
+	"testing"
+)
+
+// everyPermission lists every permission constant of models/rbac.go.
+var everyPermission = []string{
+	models.PERMISSION_OWN_ACCOUNT_READ,
+	models.PERMISSION_OWN_ACCOUNT_WRITE,
+	models.PERMISSION_OWN_SECURITY_WRITE,
+	models.PERMISSION_LEDGER_READ_ALL,
+	models.PERMISSION_ACCOUNT_STATUS_READ,
+	models.PERMISSION_ACCOUNT_STATUS_WRITE,
+	models.PERMISSION_BALANCE_BACKFILL,
+	models.PERMISSION_RECONCILIATION_READ,
+	models.PERMISSION_RECONCILIATION_RUN,
+	models.PERMISSION_PRODUCTS_READ,
+	models.PERMISSION_PRODUCTS_WRITE,
+	models.PERMISSION_FEE_WAIVERS_WRITE,
+	models.PERMISSION_BATCHES_READ,
+	models.PERMISSION_BATCHES_WRITE,
+	models.PERMISSION_USERS_READ,
+	models.PERMISSION_ROLES_ASSIGN,
+	models.PERMISSION_SESSIONS_REVOKE,
+	models.PERMISSION_USERS_UNLOCK,
+	models.PERMISSION_API_CLIENTS_READ,
+	models.PERMISSION_API_CLIENTS_WRITE,
+}
+
+func TestRoleHasPermission(t *testing.T) {
+
+	tests := []struct {
+		role     string
+		expected []string
+	}{
+		{
+			role:     models.ROLE_CUSTOMER,
+			expected: []string{models.PERMISSION_OWN_ACCOUNT_READ, models.PERMISSION_OWN_ACCOUNT_WRITE, models.PERMISSION_OWN_SECURITY_WRITE},
+		},
+		{
+			role: models.ROLE_SUPPORT,
+			expected: []string{
+				models.PERMISSION_OWN_SECURITY_WRITE, models.PERMISSION_LEDGER_READ_ALL, models.PERMISSION_ACCOUNT_STATUS_READ,
+				models.PERMISSION_ACCOUNT_STATUS_WRITE, models.PERMISSION_PRODUCTS_READ, models.PERMISSION_FEE_WAIVERS_WRITE,
+				models.PERMISSION_BATCHES_READ, models.PERMISSION_USERS_READ, models.PERMISSION_SESSIONS_REVOKE, models.PERMISSION_USERS_UNLOCK,
+			},
+		},
+		{
+			role: models.ROLE_AUDITOR,
+			expected: []string{
+				models.PERMISSION_OWN_SECURITY_WRITE, models.PERMISSION_LEDGER_READ_ALL, models.PERMISSION_ACCOUNT_STATUS_READ,
+				models.PERMISSION_RECONCILIATION_READ, models.PERMISSION_PRODUCTS_READ, models.PERMISSION_BATCHES_READ,
+				models.PERMISSION_USERS_READ, models.PERMISSION_API_CLIENTS_READ,
+			},
+		},
+		{
+			role: models.ROLE_OPS,
+			expected: []string{
+				models.PERMISSION_OWN_SECURITY_WRITE, models.PERMISSION_ACCOUNT_STATUS_READ, models.PERMISSION_BALANCE_BACKFILL,
+				models.PERMISSION_RECONCILIATION_READ, models.PERMISSION_RECONCILIATION_RUN, models.PERMISSION_PRODUCTS_READ,
+				models.PERMISSION_BATCHES_READ, models.PERMISSION_BATCHES_WRITE,
+			},
+		},
+		{role: models.ROLE_ADMIN, expected: everyPermission},
+		{role: models.ROLE_SYSTEM, expected: nil},
+		{role: "user", expected: nil},
+		{role: "", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.role, func(t *testing.T) {
+			for _, permission := range everyPermission {
+				expected := slices.Contains(test.expected, permission)
+				if granted := RoleHasPermission(test.role, permission); granted != expected {
+					t.Errorf("RoleHasPermission(%q, %q) = %v, expected %v", test.role, permission, granted, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestRolePermissionsAreKnown(t *testing.T) {
+
+	for role, permissions := range models.ROLE_PERMISSIONS {
+		for _, permission := range permissions {
+			if !slices.Contains(everyPermission, permission) {
+				t.Errorf("role %s grants %q, which is not a permission", role, permission)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTP parameters of RFC 6238 that every authenticator app supports.
+const (
+	totpDigits        = 6
+	totpPeriodSeconds = 30
+	totpSkewSteps     = 1 // A code of the previous or next period is accepted for clock drift
+)
+
+const recoveryCodeBytes = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTotpSecret() (string, error) {
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TotpUrl is the otpauth:// url authenticator apps read from a QR code.
+func TotpUrl(accountName string, secret string) string {
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", config.TOTP_ISSUER)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriodSeconds))
+
+	label := url.PathEscape(config.TOTP_ISSUER + ":" + accountName)
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, query.Encode())
+}
+
+func totpCode(secret []byte, step int64) string {
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// MatchTotpCode returns the time step the code belongs to. The caller stores the step
+// so the same code can not be used twice.
+func MatchTotpCode(secret string, code string, now time.Time) (int64, bool) {
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	currentStep := now.Unix() / totpPeriodSeconds
+
+	for step := currentStep - totpSkewSteps; step <= currentStep+totpSkewSteps; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsTotpCodeFormat tells a TOTP code from a recovery code.
+func IsTotpCodeFormat(code string) bool {
+
+	if len(code) != totpDigits {
+		return false
+	}
+
+	for _, char := range code {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GenerateRecoveryCodes returns codes like "abcd-efgh-ijkl-mnop" and their hashes.
+// The codes have 80 random bits, so a plain SHA-256 is enough to store them.
+func GenerateRecoveryCodes(count int) (codes []string, hashes []string, err error) {
+
+	for range count {
+
+		secret := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+
+		encoded := strings.ToLower(totpEncoding.EncodeToString(secret))
+		code := fmt.Sprintf("%s-%s-%s-%s", encoded[0:4], encoded[4:8], encoded[8:12], encoded[12:16])
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode ignores case, spaces and dashes of the typed code.
+func HashRecoveryCode(code string) string {
+
+	normalised := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalised))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// HasRecentSecondFactor tells whether the session of the token was authenticated with
+// a second factor within STEP_UP_MAX_AGE_SECONDS.
+func HasRecentSecondFactor(c *gin.Context) bool {
+
+	amr, err := GetClaimFromContext[[]string](c, "amr")
+	if err != nil || !slices.Contains(amr, models.AMR_OTP) {
+		return false
+	}
+
+	authTime, err := GetClaimFromContext[time.Time](c, "auth_time")
+	if err != nil {
+		return false
+	}
+
+	return time.Since(authTime) <= time.Duration(config.STEP_UP_MAX_AGE_SECONDS)*time.Second
+}
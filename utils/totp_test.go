@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of the test vectors in appendix B of RFC 6238, the
+// codes are the last 6 digits of the 8 digit vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTotpCode(t *testing.T) {
+
+	tests := []struct {
+		unixTime int64
+		expected string
+	}{
+		{unixTime: 59, expected: "287082"},
+		{unixTime: 1111111109, expected: "081804"},
+		{unixTime: 1111111111, expected: "050471"},
+		{unixTime: 1234567890, expected: "005924"},
+		{unixTime: 2000000000, expected: "279037"},
+		{unixTime: 20000000000, expected: "353130"},
+	}
+
+	for _, test := range tests {
+		t.Run(time.Unix(test.unixTime, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			if code := totpCode(rfc6238Secret, test.unixTime/totpPeriodSeconds); code != test.expected {
+				t.Errorf("totpCode at %d = %s, expected %s", test.unixTime, code, test.expected)
+			}
+		})
+	}
+}
+
+func TestMatchTotpCode(t *testing.T) {
+
+	secret := totpEncoding.EncodeToString(rfc6238Secret)
+
+	tests := []struct {
+		name          string
+		secret        string
+		code          string
+		now           int64
+		expectedStep  int64
+		expectedMatch bool
+	}{
+		{name: "current period", secret: secret, code: "081804", now: 1111111109, expectedStep: 37037036, expectedMatch: true},
+		{name: "lower case secret", secret: strings.ToLower(secret), code: "081804", now: 1111111109, expectedStep: 37037036, expectedMatch: true},
+		{name: "previous period", secret: secret, code: "081804", now: 1111111109 + totpPeriodSeconds, expectedStep: 37037036, expectedMatch: true},
+		{name: "next period", secret: secret, code: "081804", now: 1111111109 - totpPeriodSeconds, expectedStep: 37037036, expectedMatch: true},
+		{name: "two periods late", secret: secret, code: "081804", now: 1111111109 + 2*totpPeriodSeconds},
+		{name: "wrong code", secret: secret, code: "081805", now: 1111111109},
+		{name: "too short", secret: secret, code: "81804", now: 1111111109},
+		{name: "8 digit vector", secret: secret, code: "07081804", now: 1111111109},
+		{name: "secret is not base32", secret: "not-base32!", code: "081804", now: 1111111109},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := MatchTotpCode(test.secret, test.code, time.Unix(test.now, 0))
+			if ok != test.expectedMatch || step != test.expectedStep {
+				t.Errorf("MatchTotpCode = %d, %v, expected %d, %v", step, ok, test.expectedStep, test.expectedMatch)
+			}
+		})
+	}
+}
+
+func TestIsTotpCodeFormat(t *testing.T) {
+
+	tests := []struct {
+		code     string
+		expected bool
+	}{
+		{code: "287082", expected: true},
+		{code: "28708", expected: false},
+		{code: "2870821", expected: false},
+		{code: "28708a", expected: false},
+		{code: "abcd-efgh-ijkl-mnop", expected: false},
+		{code: "", expected: false},
+	}
+
+	for _, test := range tests {
+		if isTotp := IsTotpCodeFormat(test.code); isTotp != test.expected {
+			t.Errorf("IsTotpCodeFormat(%q) = %v, expected %v", test.code, isTotp, test.expected)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+
+	codes, hashes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("generated %d codes and %d hashes, expected 10", len(codes), len(hashes))
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+
+		if len(code) != 19 || strings.Count(code, "-") != 3 || IsTotpCodeFormat(code) {
+			t.Errorf("code %q is not like abcd-efgh-ijkl-mnop", code)
+		}
+
+		if seen[code] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[code] = true
+
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		if HashRecoveryCode(typed) != hashes[i] {
+			t.Errorf("hash of %q typed as %q does not match", code, typed)
+		}
+	}
+
+	if HashRecoveryCode(codes[0]) == HashRecoveryCode(codes[1]) {
+		t.Error("different codes have the same hash")
+	}
+}
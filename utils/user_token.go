@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"banking_ledger/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userTokenKey is USER_TOKEN_SIGNING_KEY, a base64 encoded key of at least 32 bytes.
+func userTokenKey() ([]byte, error) {
+
+	if config.USER_TOKEN_SIGNING_KEY == "" {
+		return nil, errors.New("USER_TOKEN_SIGNING_KEY is not set")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(config.USER_TOKEN_SIGNING_KEY)
+	if err != nil || len(key) < 32 {
+		return nil, errors.New("USER_TOKEN_SIGNING_KEY must be a base64 encoded key of at least 32 bytes")
+	}
+
+	return key, nil
+}
+
+// InitUserTokenKey checks USER_TOKEN_SIGNING_KEY when the service starts, without it
+// no verification or reset link could be sent.
+func InitUserTokenKey() error {
+
+	_, err := userTokenKey()
+	return err
+}
+
+func userTokenSignature(key []byte, purpose string, payload string) []byte {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose + "." + payload))
+	return mac.Sum(nil)
+}
+
+// SignUserToken returns "<token id>.<expiry>.<signature>". The signature covers the
+// purpose as well, so a verification token can not be used to reset a password.
+func SignUserToken(purpose string, tokenId uuid.UUID, expiresAt time.Time) (string, error) {
+
+	key, err := userTokenKey()
+	if err != nil {
+		return "", err
+	}
+
+	payload := strings.ReplaceAll(tokenId.String(), "-", "") + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(userTokenSignature(key, purpose, payload)), nil
+}
+
+// ParseUserToken checks the signature and expiry of a token of the purpose. ok is
+// false for a malformed, forged or expired token, err is only set when the signing
+// key is not usable.
+func ParseUserToken(purpose string, token string) (tokenId uuid.UUID, ok bool, err error) {
+
+	key, err := userTokenKey()
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return uuid.Nil, false, nil
+	}
+
+	signature, decodeErr := base64.RawURLEncoding.DecodeString(parts[2])
+	if decodeErr != nil || !hmac.Equal(signature, userTokenSignature(key, purpose, parts[0]+"."+parts[1])) {
+		return uuid.Nil, false, nil
+	}
+
+	expiresAt, parseErr := strconv.ParseInt(parts[1], 10, 64)
+	if parseErr != nil || time.Now().Unix() > expiresAt {
+		return uuid.Nil, false, nil
+	}
+
+	tokenId, parseErr = uuid.Parse(parts[0])
+	if parseErr != nil {
+		return uuid.Nil, false, nil
+	}
+
+	return tokenId, true, nil
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"banking_ledger/config"
+	"banking_ledger/models"
+	"banking_ledger/redact"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// useSigningKey sets USER_TOKEN_SIGNING_KEY for the test.
+func useSigningKey(t *testing.T, key string) {
+	defaultKey := config.USER_TOKEN_SIGNING_KEY
+	config.USER_TOKEN_SIGNING_KEY = key
+	t.Cleanup(func() { config.USER_TOKEN_SIGNING_KEY = defaultKey })
+}
+
+func TestParseUserToken(t *testing.T) {
+
+	signingKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	otherKey := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+
+	useSigningKey(t, signingKey)
+
+	tokenId := uuid.MustParse("1b4e28ba-2fa1-4d3b-a3f5-ef19b5a7633b")
+
+	sign := func(purpose string, expiresAt time.Time) string {
+		token, err := SignUserToken(purpose, tokenId, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	valid := sign(models.USER_TOKEN_PASSWORD_RESET, time.Now().Add(time.Hour))
+	parts := strings.Split(valid, ".")
+
+	useSigningKey(t, otherKey)
+	forged := sign(models.USER_TOKEN_PASSWORD_RESET, time.Now().Add(time.Hour))
+	useSigningKey(t, signingKey)
+
+	tests := []struct {
+		name       string
+		purpose    string
+		token      string
+		expectedOk bool
+	}{
+		{name: "valid", purpose: models.USER_TOKEN_PASSWORD_RESET, token: valid, expectedOk: true},
+		{name: "wrong purpose", purpose: models.USER_TOKEN_EMAIL_VERIFICATION, token: valid},
+		{name: "expired", purpose: models.USER_TOKEN_PASSWORD_RESET, token: sign(models.USER_TOKEN_PASSWORD_RESET, time.Now().Add(-time.Minute))},
+		{name: "signed with another key", purpose: models.USER_TOKEN_PASSWORD_RESET, token: forged},
+		{name: "expiry extended", purpose: models.USER_TOKEN_PASSWORD_RESET, token: parts[0] + ".99999999999." + parts[2]},
+		{name: "token id swapped", purpose: models.USER_TOKEN_PASSWORD_RESET, token: strings.ReplaceAll(uuid.New().String(), "-", "") + "." + parts[1] + "." + parts[2]},
+		{name: "signature tampered", purpose: models.USER_TOKEN_PASSWORD_RESET, token: parts[0] + "." + parts[1] + "." + strings.ToUpper(parts[2])},
+		{name: "signature is not base64", purpose: models.USER_TOKEN_PASSWORD_RESET, token: parts[0] + "." + parts[1] + ".!!!"},
+		{name: "signature missing", purpose: models.USER_TOKEN_PASSWORD_RESET, token: parts[0] + "." + parts[1]},
+		{name: "extra part", purpose: models.USER_TOKEN_PASSWORD_RESET, token: valid + ".x"},
+		{name: "empty", purpose: models.USER_TOKEN_PASSWORD_RESET, token: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			parsedId, ok, err := ParseUserToken(test.purpose, test.token)
+			if err != nil {
+				t.Fatalf("ParseUserToken returned %s", err.Error())
+			}
+
+			if ok != test.expectedOk {
+				t.Fatalf("ParseUserToken ok = %v, expected %v", ok, test.expectedOk)
+			}
+
+			if ok && parsedId != tokenId {
+				t.Errorf("token id = %s, expected %s", parsedId, tokenId)
+			}
+
+			if !ok && parsedId != uuid.Nil {
+				t.Errorf("rejected token returned the token id %s", parsedId)
+			}
+		})
+	}
+}
+
+func TestUserTokenSigningKey(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		key         string
+		expectError bool
+	}{
+		{name: "not set", key: "", expectError: true},
+		{name: "not base64", key: "not base64!", expectError: true},
+		{name: "too short", key: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")), expectError: true},
+		{name: "32 bytes", key: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			useSigningKey(t, test.key)
+
+			_, signErr := SignUserToken(models.USER_TOKEN_EMAIL_VERIFICATION, uuid.New(), time.Now().Add(time.Hour))
+			_, _, parseErr := ParseUserToken(models.USER_TOKEN_EMAIL_VERIFICATION, "a.b.c")
+
+			initErr := InitUserTokenKey()
+
+			if (signErr != nil) != test.expectError || (parseErr != nil) != test.expectError || (initErr != nil) != test.expectError {
+				t.Errorf("sign error = %v parse error = %v init error = %v, expected error %v", signErr, parseErr, initErr, test.expectError)
+			}
+		})
+	}
+}
+
+func TestUserTokenIsRedacted(t *testing.T) {
+
+	useSigningKey(t, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+
+	for range 20 {
+
+		token, err := SignUserToken(models.USER_TOKEN_PASSWORD_RESET, uuid.New(), time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if redacted := redact.String("?token=" + url.QueryEscape(token) + " "); redacted != "?token="+redact.REDACTED+" " {
+			t.Fatalf("token %s redacted as %s", token, redacted)
+		}
+	}
+}